@@ -1,7 +1,8 @@
 package app
 
 import (
-	"encoding/json"
+	"cmp"
+	"context"
 	"fmt"
 	"image/color"
 	"io"
@@ -9,20 +10,20 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/mweagle/goestimate/generator"
-	goejson "github.com/mweagle/goestimate/json"
+	"github.com/mweagle/goestimate/plan"
 	"github.com/mweagle/goestimate/stats"
 	"github.com/rickar/cal/v2"
 	"github.com/rickar/cal/v2/us"
 	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/graph"
 	"gonum.org/v1/gonum/graph/encoding/dot"
-	"gonum.org/v1/gonum/graph/path"
 	"gonum.org/v1/gonum/graph/simple"
 	"gonum.org/v1/gonum/graph/topo"
 	"gonum.org/v1/plot"
@@ -39,28 +40,86 @@ func init() {
 	nowTime = time.Now()
 }
 
-func workdayWithOffset(offset float64) time.Time {
-	var once sync.Once
+var businessCalendarOnce sync.Once
+
+func workdayCalendar() *cal.BusinessCalendar {
 	onceBody := func() {
-		businessCalendar = cal.NewBusinessCalendar()
-		businessCalendar.Name = "goestimate."
-		businessCalendar.Description = "Default company calendar"
-		// add holidays that the business observes
-		businessCalendar.AddHoliday(
-			us.NewYear,
-			us.MemorialDay,
-			us.IndependenceDay,
-			us.LaborDay,
-			us.ThanksgivingDay,
-			us.ChristmasDay,
-		)
+		businessCalendar = newWorkdayCalendar(nil)
+	}
+	businessCalendarOnce.Do(onceBody)
+	return businessCalendar
+}
+
+// newWorkdayCalendar returns the company calendar with the additional
+// holidays
+func newWorkdayCalendar(holidays []time.Time) *cal.BusinessCalendar {
+	calendar := cal.NewBusinessCalendar()
+	calendar.Name = "goestimate."
+	calendar.Description = "Default company calendar"
+	// add holidays that the business observes
+	calendar.AddHoliday(
+		us.NewYear,
+		us.MemorialDay,
+		us.IndependenceDay,
+		us.LaborDay,
+		us.ThanksgivingDay,
+		us.ChristmasDay,
+	)
+	for _, eachHoliday := range holidays {
+		calendar.AddHoliday(&cal.Holiday{
+			Name:      eachHoliday.Format(DEADLINE_DATE_FORMAT),
+			Type:      cal.ObservanceOther,
+			StartYear: eachHoliday.Year(),
+			EndYear:   eachHoliday.Year(),
+			Month:     eachHoliday.Month(),
+			Day:       eachHoliday.Day(),
+			Func:      cal.CalcDayOfMonth,
+		})
+	}
+	return calendar
+}
+
+// unmarshalCalendar returns the workday calendar with the plan's holidays
+func unmarshalCalendar(holidays []string) (*cal.BusinessCalendar, error) {
+	if len(holidays) == 0 {
+		return workdayCalendar(), nil
+	}
+	holidayDates := make([]time.Time, len(holidays))
+	for i, eachHoliday := range holidays {
+		holidayDate, holidayDateErr := time.ParseInLocation(DEADLINE_DATE_FORMAT, eachHoliday, nowTime.Location())
+		if holidayDateErr != nil {
+			return nil, fmt.Errorf("invalid holiday: %s. Dates must be formatted as %s", eachHoliday, DEADLINE_DATE_FORMAT)
+		}
+		holidayDates[i] = holidayDate
 	}
-	once.Do(onceBody)
-	return businessCalendar.WorkdaysFrom(nowTime, int(math.Ceil(offset)))
+	return newWorkdayCalendar(holidayDates), nil
+}
+
+func workdayWithOffset(calendar *cal.BusinessCalendar, offset float64) time.Time {
+	return calendar.WorkdaysFrom(nowTime, int(math.Ceil(offset)))
+}
+
+// workdaysUntil returns the number of workdays after today up to and
+// including the date
+func workdaysUntil(calendar *cal.BusinessCalendar, date time.Time) int {
+	workdays := calendar.WorkdaysInRange(nowTime, date)
+	if calendar.IsWorkday(nowTime) {
+		workdays--
+	}
+	return workdays
+}
+
+// intervalFormatter formats the confidence interval, if there is one, as a
+// suffix of its estimate
+func intervalFormatter(interval *stats.Interval) string {
+	if interval == nil {
+		return ""
+	}
+	return fmt.Sprintf(" [%.2f, %.2f]", interval.Lower, interval.Upper)
 }
 
 func aggregatedStatsFormatter(aggStats *stats.AggregatedStatistics) string {
-	label := fmt.Sprintf("μ=%.2f, σ=%.2f", aggStats.Mean, aggStats.StdDev)
+	label := fmt.Sprintf("μ=%.2f%s, σ=%.2f", aggStats.Mean, intervalFormatter(aggStats.MeanInterval), aggStats.StdDev)
 	if len(aggStats.Percentiles) != 0 {
 		value := ""
 		for i := 0; i != len(aggStats.Percentiles); i++ {
@@ -70,9 +129,9 @@ func aggregatedStatsFormatter(aggStats *stats.AggregatedStatistics) string {
 				pVal *= 100
 			}
 			if math.Floor(pVal) == pVal {
-				value += fmt.Sprintf("p%.0f=%.2f, ", pVal, percentilePair.Val)
+				value += fmt.Sprintf("p%.0f=%.2f%s, ", pVal, percentilePair.Val, intervalFormatter(percentilePair.Interval))
 			} else {
-				value += fmt.Sprintf("p%.2f=%.2f, ", pVal, percentilePair.Val)
+				value += fmt.Sprintf("p%.2f=%.2f%s, ", pVal, percentilePair.Val, intervalFormatter(percentilePair.Interval))
 			}
 		}
 		value = strings.TrimSuffix(value, ", ")
@@ -81,6 +140,30 @@ func aggregatedStatsFormatter(aggStats *stats.AggregatedStatistics) string {
 	return label
 }
 
+// statisticsFormatter formats the selected shape and tail statistics, or
+// returns an empty string if none are selected
+func (ao *AggregationOptions) statisticsFormatter(aggStats *stats.AggregatedStatistics) string {
+	if ao == nil || aggStats == nil {
+		return ""
+	}
+	value := ""
+	for _, eachStatistic := range ao.statistics {
+		switch eachStatistic {
+		case stats.STATISTIC_SKEWNESS:
+			value += fmt.Sprintf("skew=%.2f, ", aggStats.Skewness)
+		case stats.STATISTIC_KURTOSIS:
+			value += fmt.Sprintf("kurt=%.2f, ", aggStats.Kurtosis)
+		case stats.STATISTIC_MODE:
+			value += fmt.Sprintf("mode=%.2f, ", aggStats.Mode)
+		case stats.STATISTIC_CV:
+			value += fmt.Sprintf("CV=%.2f, ", aggStats.CV)
+		case stats.STATISTIC_CVAR:
+			value += fmt.Sprintf("CVaR%.0f=%.2f, ", stats.CVAR_LEVEL*100, aggStats.CVaR)
+		}
+	}
+	return strings.TrimSuffix(value, ", ")
+}
+
 // /////////////////////////////////////////////////////////////////////////////
 //
 // TYPES
@@ -110,6 +193,11 @@ type DurationGeneratorGraphNode interface {
 
 type AggregationOptions struct {
 	workdays bool
+	// calendar is the workday calendar of the completion dates
+	calendar *cal.BusinessCalendar
+	// statistics are the shape and tail statistics rendered with the
+	// cumulative statistics
+	statistics []string
 }
 
 // /////////////////////////////////////////////////////////////////////////////
@@ -126,6 +214,13 @@ type flowGraphNode struct {
 	// comments       string
 	parentFlowSubgraphs []*flowSubgraph
 	generator           generator.DurationGenerator
+	resources           map[string]float64
+	cost                *taskCost
+	// streamKey identifies the task's random number stream when the graph
+	// uses common random numbers
+	streamKey string
+	// source is the plan value the node was built from
+	source plan.Source
 }
 
 func (fgn *flowGraphNode) AbsoluteNodePath() []int64 {
@@ -164,8 +259,8 @@ func (fgn *flowGraphNode) encodeD2MarkdownNode(heading string,
 	if writeErr != nil {
 		return writeErr
 	}
-	for eachKey, eachVal := range params {
-		_, writeErr = output.WriteString(fmt.Sprintf("- **%s**: %v\n", eachKey, eachVal))
+	for _, eachKey := range sortedKeys(params) {
+		_, writeErr = output.WriteString(fmt.Sprintf("- **%s**: %v\n", eachKey, params[eachKey]))
 		if writeErr != nil {
 			return writeErr
 		}
@@ -194,7 +289,18 @@ func (fgn *flowGraphNode) Generate(flowGraph *flowGraph,
 	if priorSamplesErr != nil {
 		return nil, priorSamplesErr
 	}
-	return fgn.generator.Generate(priorSamples, percentiles, src, log)
+	genResults, genResultsErr := fgn.generator.Generate(priorSamples, percentiles, src, log)
+	if genResultsErr != nil {
+		return nil, fgn.planError(genResultsErr)
+	}
+	return genResults, nil
+}
+
+// planError locates an error from the node's generator at the plan value the
+// node was built from. Some values, such as choice weights, are only
+// rejected when they are sampled, so these are plan errors.
+func (fgn *flowGraphNode) planError(err error) error {
+	return &InvalidPlanError{Err: fgn.source.Wrap(err)}
 }
 
 func (fgn *flowGraphNode) DOTID() string {
@@ -262,17 +368,47 @@ func (fgn *flowGraphNode) D2Params(_ *slog.Logger) (*D2Encoding, error) {
 				Value: incrementalValue,
 			},
 		)
+		repeatGenerator, repeatGeneratorOk := fgn.generator.(*generator.RepeatGenerator)
+		if repeatGeneratorOk {
+			encoding.Params = append(encoding.Params, &d2TableParams{
+				Key:   "⟳",
+				Value: repeatAnnotation(repeatGenerator.Count, repeatGenerator.MeanIterations()),
+			})
+		}
+		effortGenerator, effortGeneratorOk := fgn.generator.(*generator.EffortGenerator)
+		if effortGeneratorOk {
+			encoding.Params = append(encoding.Params, effortParams(effortGenerator)...)
+		}
+		if fgn.cost != nil && fgn.cost.stats != nil {
+			encoding.Params = append(encoding.Params, &d2TableParams{
+				Key:   "Cost",
+				Value: fmt.Sprintf("%s: %s", fgn.cost.Name(), aggregatedStatsFormatter(fgn.cost.stats)),
+			})
+		}
+		if len(fgn.resources) != 0 {
+			encoding.Params = append(encoding.Params, &d2TableParams{
+				Key:   "Resources",
+				Value: resourcesAnnotation(fgn.resources),
+			})
+		}
 		if genResults.CumulativeStats != nil {
 			encoding.Params = append(encoding.Params, &d2TableParams{
 				Key:   "∑",
 				Value: aggregatedStatsFormatter(genResults.CumulativeStats),
 			})
+			statisticsValue := fgn.aggregationOptions.statisticsFormatter(genResults.CumulativeStats)
+			if len(statisticsValue) != 0 {
+				encoding.Params = append(encoding.Params, &d2TableParams{
+					Key:   "Statistics",
+					Value: statisticsValue,
+				})
+			}
 		}
 
 		if fgn.aggregationOptions != nil && fgn.aggregationOptions.workdays {
 			encoding.Params = append(encoding.Params, &d2TableParams{
 				Key:   "ECD",
-				Value: workdayWithOffset(genResults.CumulativeStats.Mean).Format(ECD_TIME_FORMAT),
+				Value: workdayWithOffset(fgn.aggregationOptions.calendar, genResults.CumulativeStats.Mean).Format(ECD_TIME_FORMAT),
 			})
 
 		}
@@ -304,17 +440,28 @@ func (fgj *flowGraphJoinMaxValueNode) Generate(flowGraph *flowGraph,
 
 	// The upper bound is a logical operation that works on cumulative values
 	// rather than generator values. Take the existing samples and mock this
-	// behavior by promoting the cumulative values to the incremental raw values
+	// behavior by promoting the cumulative values to the incremental raw values.
+	// The cumulative values are the values at the subgraph's entry so that
+	// joins can compute the subgraph's own duration.
+	owningSubgraph := fgj.parentFlowSubgraphs[len(fgj.parentFlowSubgraphs)-1]
+	entryResults, entryResultsExist := flowGraph.generatorResults[owningSubgraph.inputNode.ID()]
+	if !entryResultsExist {
+		return nil, fmt.Errorf("no entry values for join nodeId: %d", fgj.ID())
+	}
 	cumulativeSamples := make(map[int64]*generator.GenerationResults, len(priorSamples))
 	for eachID, eachGenResult := range priorSamples {
 		cumulativeSamples[eachID] = &generator.GenerationResults{
 			RawValues:        eachGenResult.CumulativeValues,
 			GeneratorStats:   eachGenResult.CumulativeStats,
-			CumulativeValues: eachGenResult.CumulativeValues,
-			CumulativeStats:  eachGenResult.CumulativeStats,
+			CumulativeValues: entryResults.CumulativeValues,
+			CumulativeStats:  entryResults.CumulativeStats,
 		}
 	}
-	return fgj.generator.Generate(cumulativeSamples, percentiles, src, log)
+	genResults, genResultsErr := fgj.generator.Generate(cumulativeSamples, percentiles, src, log)
+	if genResultsErr != nil {
+		return nil, fgj.planError(genResultsErr)
+	}
+	return genResults, nil
 }
 
 func (fgj *flowGraphJoinMaxValueNode) D2Encode(output io.StringWriter,
@@ -326,18 +473,41 @@ func (fgj *flowGraphJoinMaxValueNode) D2Encode(output io.StringWriter,
 	markdownParams := map[string]interface{}{
 		"Cumulative": cumulativeValue,
 	}
+	statisticsValue := fgj.aggregationOptions.statisticsFormatter(genStats.CumulativeStats)
+	if len(statisticsValue) != 0 {
+		markdownParams["Statistics"] = statisticsValue
+	}
+	joinGenerator, joinGeneratorOk := fgj.generator.(generator.JoinGenerator)
+	_, upperBoundOk := fgj.generator.(*generator.UpperBoundGenerator)
+	if joinGeneratorOk && !upperBoundOk {
+		markdownParams["Join"] = joinGenerator.Name()
+	}
+	if joinGeneratorOk && joinGenerator.IncrementGenerator() != nil {
+		markdownParams["Risks"] = joinGenerator.IncrementGenerator().Name()
+	}
+	if joinGeneratorOk && joinGenerator.RepeatCount() != nil {
+		markdownParams["Repeat"] = repeatAnnotation(joinGenerator.RepeatCount(), joinGenerator.MeanIterations())
+	}
+	owningSubgraph := fgj.parentFlowSubgraphs[len(fgj.parentFlowSubgraphs)-1]
+	if owningSubgraph.deadline != nil {
+		markdownParams["Deadline"] = owningSubgraph.deadlineAnnotation()
+	}
 	// Optional aggregation options
 	if fgj.aggregationOptions != nil {
 		if fgj.aggregationOptions.workdays {
-			markdownParams["Estimated"] = workdayWithOffset(genStats.CumulativeStats.Mean).Format(ECD_TIME_FORMAT)
+			markdownParams["Estimated"] = workdayWithOffset(fgj.aggregationOptions.calendar, genStats.CumulativeStats.Mean).Format(ECD_TIME_FORMAT)
 		}
 	} else {
 		log.Debug("No aggregation options for node", "id", fgj.flowGraphNode.id, "type", fmt.Sprintf("%T", fgj))
 	}
-	return fgj.encodeD2MarkdownNode(fgj.name,
+	encodeErr := fgj.encodeD2MarkdownNode(fgj.name,
 		markdownParams,
 		output,
 		log)
+	if encodeErr != nil {
+		return encodeErr
+	}
+	return fgj.encodeD2DeadlineStyle(output)
 }
 
 func (fgj *flowGraphJoinMaxValueNode) DOTID() string {
@@ -349,6 +519,8 @@ func (fgj *flowGraphJoinMaxValueNode) DOTID() string {
 // /////////////////////////////////////////////////////////////////////////////
 type flowGraphStartNode struct {
 	runCount uint64
+	// sampling describes variance reduced sampling strategies
+	sampling string
 	flowGraphNode
 }
 
@@ -358,12 +530,14 @@ func (fgsn *flowGraphStartNode) D2Params(_ *slog.Logger) (*D2Encoding, error) {
 
 func (fgsn *flowGraphStartNode) encodeD2Markdown(output io.StringWriter, log *slog.Logger) error {
 	currentTime := nowTime.Format(time.ANSIC)
-	return fgsn.encodeD2MarkdownNode(fgsn.name,
-		map[string]interface{}{
-			"Runs":    fgsn.runCount,
-			"Created": currentTime,
-		}, output,
-		log)
+	params := map[string]interface{}{
+		"Runs":    fgsn.runCount,
+		"Created": currentTime,
+	}
+	if len(fgsn.sampling) != 0 {
+		params["Sampling"] = fgsn.sampling
+	}
+	return fgsn.encodeD2MarkdownNode(fgsn.name, params, output, log)
 }
 
 func (fgsn *flowGraphStartNode) Generate(_ *flowGraph,
@@ -438,9 +612,33 @@ type flowSubgraph struct {
 	outputJoinNode      *flowGraphJoinMaxValueNode
 	serialGenerators    []DurationGeneratorGraphNode
 	aggregationOptions  *AggregationOptions
+	deadline            *flowDeadline
+	// The last node ID assigned in the graph, shared by every subgraph
+	nodeIDSequence *int64
 	*simple.WeightedDirectedGraph
 }
 
+// newNodeID returns the next node ID. IDs are assigned sequentially in plan
+// document order so that every traversal of the graph is deterministic.
+func (fsg *flowSubgraph) newNodeID() int64 {
+	*fsg.nodeIDSequence++
+	return *fsg.nodeIDSequence
+}
+
+// sortedNodes returns every node in the graph in ID order
+func (fsg *flowSubgraph) sortedNodes() []graph.Node {
+	return sortedByID(fsg.WeightedDirectedGraph.Nodes())
+}
+
+// sortedByID returns the nodes in ID order, which is plan document order
+func sortedByID(nodes graph.Nodes) []graph.Node {
+	sorted := graph.NodesOf(nodes)
+	slices.SortFunc(sorted, func(a, b graph.Node) int {
+		return cmp.Compare(a.ID(), b.ID())
+	})
+	return sorted
+}
+
 func (fsg *flowSubgraph) Weight(xid, yid int64) (w float64, ok bool) {
 	connectionCost := float64(0)
 	fromNode := fsg.Node(xid)
@@ -524,15 +722,19 @@ func (fsg *flowSubgraph) AddSubgraph(name string) (*flowSubgraph, error) {
 func newFlowSubgraph(name string, parentSubgraph *flowSubgraph) *flowSubgraph {
 
 	var dirGraph *simple.WeightedDirectedGraph
+	var nodeIDSequence *int64
 	if parentSubgraph == nil {
 		dirGraph = simple.NewWeightedDirectedGraph(1, 0)
+		nodeIDSequence = new(int64)
 	} else {
 		dirGraph = parentSubgraph.WeightedDirectedGraph
+		nodeIDSequence = parentSubgraph.nodeIDSequence
 	}
 	// How will the subgraph know it's depth?
 	subgraph := &flowSubgraph{
 		parentFlowSubgraphs:   make([]*flowSubgraph, 0),
 		serialGenerators:      make([]DurationGeneratorGraphNode, 0),
+		nodeIDSequence:        nodeIDSequence,
 		WeightedDirectedGraph: dirGraph,
 	}
 	if parentSubgraph != nil {
@@ -545,7 +747,7 @@ func newFlowSubgraph(name string, parentSubgraph *flowSubgraph) *flowSubgraph {
 	subgraph.inputNode = &flowGraphPassThroughNode{
 		flowGraphNode: flowGraphNode{
 			name:                name,
-			id:                  rand.Int63(),
+			id:                  subgraph.newNodeID(),
 			parentFlowSubgraphs: append(subgraph.parentFlowSubgraphs, subgraph),
 		},
 	}
@@ -557,7 +759,7 @@ func newFlowSubgraph(name string, parentSubgraph *flowSubgraph) *flowSubgraph {
 	subgraph.outputJoinNode = &flowGraphJoinMaxValueNode{
 		flowGraphNode: flowGraphNode{
 			name:                "Summary",
-			id:                  rand.Int63(),
+			id:                  subgraph.newNodeID(),
 			parentFlowSubgraphs: append(subgraph.parentFlowSubgraphs, subgraph),
 			generator:           &generator.UpperBoundGenerator{},
 		},
@@ -581,6 +783,37 @@ type flowGraph struct {
 	startNode         *flowGraphStartNode
 	criticalPathGraph *simple.DirectedGraph
 	generatorResults  map[int64]*generator.GenerationResults
+	risks             []*flowRisk
+	correlations      *flowCorrelations
+	resources         map[string]float64
+	resourceSchedule  *resourceSchedule
+	costs             *costEstimate
+	deadlineThreshold float64
+	assertions        []string
+	seed              uint64
+	workers           int
+	streaming         bool
+	streamedAnalysis  *chunkAnalysis
+	convergence       *runConvergence
+	sampling          string
+	// intervals is the confidence interval method of the statistics, if
+	// they have intervals
+	intervals string
+	// sensitivity ranks the tasks by the sensitivity of the total duration,
+	// if the plan has a sensitivity method
+	sensitivity *sensitivityAnalysis
+	// samplingEfficiency is the variance reduction of variance reduced
+	// sampling strategies
+	samplingEfficiency *samplingEfficiency
+	// commonRandomNumbers samples each node from its own random number
+	// stream, so that the plan and its scenarios share their samples
+	commonRandomNumbers bool
+	// nodeSources are each node's random number stream of a chunk that uses
+	// common random numbers
+	nodeSources map[int64]rand.Source
+	// scenarios are the what-if scenarios compared against the plan
+	scenarios *scenarioComparison
+	planDef   *plan.Plan
 	*flowSubgraph
 }
 
@@ -597,7 +830,25 @@ func (fg *flowGraph) PredecessorValues(nodeID int64) (map[int64]*generator.Gener
 	return predecessorMap, nil
 }
 
-func (fg *flowGraph) PlotDistribution(histogramPath string, log *slog.Logger) error {
+// taskNamed returns the single task node with the given name
+func (fg *flowGraph) taskNamed(name string) (*flowGraphNode, error) {
+	var taskNode *flowGraphNode
+	matchCount := 0
+	for _, eachNode := range fg.sortedNodes() {
+		typedNode, typedNodeOk := eachNode.(*flowGraphNode)
+		if typedNodeOk && typedNode.name == name {
+			taskNode = typedNode
+			matchCount++
+		}
+	}
+	if matchCount != 1 {
+		return nil, fmt.Errorf("expected exactly one task named %q. Found: %d", name, matchCount)
+	}
+	return taskNode, nil
+}
+
+// distributionPlot plots the histogram and CDF of the total duration
+func (fg *flowGraph) distributionPlot() (*plot.Plot, error) {
 	// Make a plot and set its title.
 	p := plot.New()
 	p.X.Label.Text = "Total Duration"
@@ -608,11 +859,14 @@ func (fg *flowGraph) PlotDistribution(histogramPath string, log *slog.Logger) er
 
 	// Calculate the CDF
 	GenerationResults := fg.outputJoinNode.generator.GenerationResults()
+	if fg.streaming {
+		return fg.summaryDistributionPlot(p, GenerationResults.CumulativeSummary.Digest())
+	}
 
 	// First bin the data and graph that. We'll bin into 100 bins
 	rawHist, rawHistError := plotter.NewHist(plotter.Values(*GenerationResults.CumulativeValues), 100)
 	if rawHistError != nil {
-		return rawHistError
+		return nil, rawHistError
 	}
 	rawHist.Normalize(1)
 	p.Add(rawHist)
@@ -625,7 +879,7 @@ func (fg *flowGraph) PlotDistribution(histogramPath string, log *slog.Logger) er
 	// Bin the data into 100 bins...
 	hist, histErr := plotter.NewHist(plotter.Values(sortedSamples), 100)
 	if histErr != nil {
-		return histErr
+		return nil, histErr
 	}
 	cdfValues := make(plotter.XYs, len(hist.Bins))
 	cumulativeWeight := float64(0)
@@ -636,208 +890,392 @@ func (fg *flowGraph) PlotDistribution(histogramPath string, log *slog.Logger) er
 		cdfValues[i].Y = cumulativeWeight / float64(len(sortedSamples))
 	}
 
+	return fg.addCDFLine(p, cdfValues)
+}
+
+// summaryDistributionPlot plots the histogram and CDF of the total duration
+// estimated by its quantile sketch. The histogram bins the sketch's CDF.
+func (fg *flowGraph) summaryDistributionPlot(p *plot.Plot, digest *stats.TDigest) (*plot.Plot, error) {
+	binCount := 100
+	minValue := digest.Quantile(0)
+	binWidth := (digest.Quantile(1) - minValue) / float64(binCount)
+	if binWidth <= 0 {
+		binWidth = 1
+	}
+	binValues := make(plotter.XYs, binCount)
+	cdfValues := make(plotter.XYs, binCount)
+	priorProbability := float64(0)
+	for i := 0; i != binCount; i++ {
+		binMax := minValue + binWidth*float64(i+1)
+		cdfValues[i].X = binMax
+		cdfValues[i].Y = digest.CDF(binMax)
+		if i == binCount-1 {
+			cdfValues[i].Y = 1
+		}
+		// Histogram bins are positioned by their center and normalized
+		// by area
+		binValues[i].X = binMax - binWidth/2
+		binValues[i].Y = (cdfValues[i].Y - priorProbability) / binWidth
+		priorProbability = cdfValues[i].Y
+	}
+	rawHist, rawHistError := plotter.NewHistogram(binValues, binCount)
+	if rawHistError != nil {
+		return nil, rawHistError
+	}
+	p.Add(rawHist)
+	return fg.addCDFLine(p, cdfValues)
+}
+
+// addCDFLine adds the CDF and the deadline, if there is one, to the
+// distribution plot
+func (fg *flowGraph) addCDFLine(p *plot.Plot, cdfValues plotter.XYs) (*plot.Plot, error) {
 	line, _ := plotter.NewLine(cdfValues)
 	line.LineStyle.Width = vg.Points(2)
 	line.LineStyle.Dashes = []vg.Length{vg.Points(3), vg.Points(3)}
 	line.LineStyle.Color = color.RGBA{R: 255, G: 144, A: 255}
 	p.Add(line)
 
-	// Save the plot to a PNG file.
-	saveErr := p.Save(12*vg.Inch, 12*vg.Inch, histogramPath)
-	return saveErr
-}
+	// Then the deadline, if there is one
+	deadlineErr := fg.addDeadlineLine(p)
+	if deadlineErr != nil {
+		return nil, deadlineErr
+	}
 
-func (fg *flowGraph) recursiveUnmarshal(rootObj map[string]interface{},
-	subgraphParent *flowSubgraph,
-	log *slog.Logger) error {
+	return p, nil
+}
 
-	generatorUnmarshaller := func(rawData interface{}, defaultName string) (*flowGraphNode, error) {
-		mapData, mapDataOk := rawData.(map[string]interface{})
-		if !mapDataOk {
-			return nil, fmt.Errorf("failed to type assert generator unmarshaller")
-		}
-		durGenerator, durGeneratorErr := generator.NewDurationGenerator(mapData, log)
-		if durGeneratorErr != nil {
-			return nil, durGeneratorErr
+// taskNode returns the node for a single task. Tasks without a name are
+// named by their parallel key or serial index.
+func (fg *flowGraph) taskNode(task *plan.Task, defaultName string, log *slog.Logger) (*flowGraphNode, error) {
+	durGenerator, durGeneratorErr := unmarshalTaskGenerator(task, log)
+	if durGeneratorErr != nil {
+		return nil, durGeneratorErr
+	}
+	nodeName := task.Name
+	if len(nodeName) <= 0 {
+		nodeName = defaultName
+	}
+	if len(task.Repeat) != 0 {
+		repeatCount, repeatCountErr := generator.ParseRepeatCount(task.Repeat)
+		if repeatCountErr != nil {
+			return nil, task.Field("repeat").Wrap(repeatCountErr)
 		}
-		nodeName := goejson.String("name", mapData)
-		if len(nodeName) <= 0 {
-			nodeName = defaultName
+		durGenerator = &generator.RepeatGenerator{
+			Body:  durGenerator,
+			Count: repeatCount,
 		}
-		return &flowGraphNode{
-			id:        rand.Int63(),
-			name:      nodeName,
-			generator: durGenerator,
-		}, nil
 	}
-	rootMap, rootMapOk := rootObj["activities"].(map[string]interface{})
-
-	if !rootMapOk {
-		return fmt.Errorf("failed to extract %s from map", "activities")
+	resourcesErr := fg.validateTaskResources(nodeName, task.Resources)
+	if resourcesErr != nil {
+		return nil, task.Field("resources").Wrap(resourcesErr)
 	}
+	taskCost, taskCostErr := unmarshalTaskCost(task.Cost, log)
+	if taskCostErr != nil {
+		return nil, taskCostErr
+	}
+	return &flowGraphNode{
+		id:        fg.newNodeID(),
+		name:      nodeName,
+		generator: durGenerator,
+		resources: task.Resources,
+		cost:      taskCost,
+		streamKey: task.Pointer(),
+		source:    task.Source,
+	}, nil
+}
 
-	// Get all the keys
-	for eachKey := range rootMap {
-		log.Debug("Unmarshalling definition", "key", eachKey)
+func (fg *flowGraph) recursiveUnmarshal(activities *plan.Activities,
+	subgraphParent *flowSubgraph,
+	log *slog.Logger) error {
 
-		val := rootMap[eachKey]
-		switch typedVal := val.(type) {
-		case []interface{}:
-			for i := 0; i != len(typedVal); i++ {
-				node, nodeErr := generatorUnmarshaller(typedVal[i], fmt.Sprintf("serial-%d", i))
+	// Activities without tasks have no duration to join
+	if activities == nil {
+		return fmt.Errorf("missing activities. Expected at least one task")
+	}
+	if len(activities.Entries) <= 0 {
+		return activities.Wrap(fmt.Errorf("invalid activities: the activities are empty. Expected at least one task"))
+	}
+	// Sibling serial arrays are independent lanes that run in parallel. Each
+	// lane is a subgraph named by its key.
+	serialCount := 0
+	for _, eachEntry := range activities.Entries {
+		if eachEntry.Serial != nil {
+			serialCount++
+		}
+	}
+	for _, eachEntry := range activities.Entries {
+		log.Debug("Unmarshalling definition", "key", eachEntry.Key)
+
+		switch {
+		case eachEntry.Serial != nil:
+			if len(eachEntry.Serial) <= 0 {
+				return activities.Field(eachEntry.Key).Wrap(fmt.Errorf("invalid serial tasks %s: the array is empty. Expected at least one task", eachEntry.Key))
+			}
+			serialParent := subgraphParent
+			if serialCount > 1 {
+				lane, laneAddErr := subgraphParent.AddSubgraph(eachEntry.Key)
+				if laneAddErr != nil {
+					return laneAddErr
+				}
+				serialParent = lane
+			}
+			for i, eachTask := range eachEntry.Serial {
+				node, nodeErr := fg.taskNode(eachTask, plan.SerialTaskName(i), log)
 				if nodeErr != nil {
 					return nodeErr
 				}
-				addErr := subgraphParent.AddSerialGeneratorNode(node)
+				addErr := serialParent.AddSerialGeneratorNode(node)
 				if addErr != nil {
 					return addErr
 				}
 			}
-		case map[string]interface{}:
-			// Is this an activities blob, or a set of parallel tasks?
-			title := goejson.String("name", typedVal)
-			_, activitiesOk := typedVal["activities"]
-			if len(title) != 0 && activitiesOk {
-				// This is a subgraph...Add a subgraph to the parent and then keep going...
-				subgraph, subgraphAddErr := subgraphParent.AddSubgraph(title)
+		case eachEntry.Choice != nil:
+			// This is a choice between weighted branch subgraphs
+			choiceErr := fg.unmarshalChoice(eachEntry.Choice, subgraphParent, log)
+			if choiceErr != nil {
+				return choiceErr
+			}
+		case eachEntry.Subgraph != nil:
+			// This is a subgraph...Add a subgraph to the parent and then keep going...
+			subgraphDef := eachEntry.Subgraph
+			subgraph, subgraphAddErr := subgraphParent.AddSubgraph(subgraphDef.Name)
+			if subgraphAddErr != nil {
+				return subgraphAddErr
+			}
+			subgraph.outputJoinNode.source = subgraphDef.Source
+			joinErr := subgraph.unmarshalJoin(subgraphDef.Join)
+			if joinErr != nil {
+				return subgraphDef.Field("join").Wrap(joinErr)
+			}
+			repeatErr := subgraph.unmarshalRepeat(subgraphDef.Repeat)
+			if repeatErr != nil {
+				return subgraphDef.Field("repeat").Wrap(repeatErr)
+			}
+			deadlineErr := subgraph.unmarshalDeadline(subgraphDef.Deadline)
+			if deadlineErr != nil {
+				return subgraphDef.Field("deadline").Wrap(deadlineErr)
+			}
+			subgraphErr := fg.recursiveUnmarshal(subgraphDef.Activities, subgraph, log)
+			if subgraphErr != nil {
+				return subgraphErr
+			}
+			verifyErr := subgraph.verifyJoin()
+			if verifyErr != nil {
+				return subgraphDef.Field("join").Wrap(verifyErr)
+			}
+		case eachEntry.Parallel != nil:
+			// Parallel tasks with an explicit join are wrapped in a subgraph
+			// that is closed by that join.
+			parallelDef := eachEntry.Parallel
+			if len(parallelDef.Tasks) <= 0 {
+				return activities.Field(eachEntry.Key).Wrap(fmt.Errorf("invalid parallel tasks %s: the object is empty. Expected at least one task", eachEntry.Key))
+			}
+			parallelParent := subgraphParent
+			if len(parallelDef.Join) != 0 {
+				subgraph, subgraphAddErr := subgraphParent.AddSubgraph(eachEntry.Key)
 				if subgraphAddErr != nil {
 					return subgraphAddErr
 				}
-				subgraphErr := fg.recursiveUnmarshal(typedVal, subgraph, log)
-				if subgraphErr != nil {
-					return subgraphErr
+				subgraph.outputJoinNode.source = parallelDef.Source
+				joinErr := subgraph.unmarshalJoin(parallelDef.Join)
+				if joinErr != nil {
+					return parallelDef.Field("join").Wrap(joinErr)
 				}
-			} else {
-				// For each key, get the parallel node
-				for eachKey, eachDict := range typedVal {
-					node, nodeErr := generatorUnmarshaller(eachDict, eachKey)
-					if nodeErr != nil {
-						return nodeErr
-					}
-					subgraphParent.AddParallelGeneratorNode(node)
-
+				parallelParent = subgraph
+			}
+			// For each key, get the parallel node
+			for _, eachTask := range parallelDef.Tasks {
+				node, nodeErr := fg.taskNode(eachTask, eachTask.Key, log)
+				if nodeErr != nil {
+					return nodeErr
+				}
+				parallelParent.AddParallelGeneratorNode(node)
+			}
+			if len(parallelDef.Join) != 0 {
+				verifyErr := parallelParent.verifyJoin()
+				if verifyErr != nil {
+					return parallelDef.Field("join").Wrap(verifyErr)
 				}
 			}
-		default:
-			return fmt.Errorf("unsupported type: %T", val)
 		}
 	}
 	return nil
 }
 
-func (fg *flowGraph) Unmarshal(inputStream io.Reader, log *slog.Logger) error {
-	// Read the root object, unmarshal the props...then hand off the "activities"
-	// object to the recursive unmarshal with ourselves as the parent....
-	inputBytes, inputBytesErr := io.ReadAll(inputStream)
-	if inputBytesErr != nil {
-		return inputBytesErr
-	}
-	rootMap := make(map[string]interface{})
-	unmarshalErr := json.Unmarshal(inputBytes, &rootMap)
-	if unmarshalErr != nil {
-		return unmarshalErr
+// Unmarshal builds the graph from the decoded plan. Errors are located at
+// the plan value that caused them.
+func (fg *flowGraph) Unmarshal(planDef *plan.Plan, log *slog.Logger) error {
+	fg.name = planDef.Name
+	fg.planDef = planDef
+	fg.startNode.runCount = planDef.RunCount.Count
+	// Budget?
+	fg.costs = &costEstimate{
+		budget: planDef.Budget,
 	}
-	fg.name = goejson.String("name", rootMap)
-	fg.startNode.runCount = goejson.Uint("runCount", rootMap)
 	// Percentiles?
 	fg.percentiles = []float64{50, 95}
-	userPercentiles, userPercentilesExists := rootMap["percentiles"]
-	if userPercentilesExists {
-		switch typedVal := userPercentiles.(type) {
-		case []interface{}:
-			floatVals := make([]float64, 0)
-			for i := 0; i != len(typedVal); i++ {
-				castFloat, castFloatOK := typedVal[i].(float64)
-				if !castFloatOK {
-					return fmt.Errorf("invalid percentile specified: %v. Only arrays of float64 are supported", typedVal[i])
-				}
-				floatVals = append(floatVals, castFloat)
-			}
-			fg.percentiles = floatVals
-		default:
-			return fmt.Errorf("invalid percentiles specified: %v. Only arrays of float64 are supported", typedVal)
+	if planDef.Percentiles != nil {
+		fg.percentiles = planDef.Percentiles
+	}
+	// Sampling strategy?
+	fg.sampling = generator.SAMPLING_MC
+	if len(planDef.Sampling) != 0 {
+		samplingErr := generator.ValidateSamplingStrategy(planDef.Sampling)
+		if samplingErr != nil {
+			return planDef.Field("sampling").Wrap(samplingErr)
 		}
+		fg.sampling = planDef.Sampling
+	}
+	// Confidence intervals?
+	if len(planDef.Intervals) != 0 {
+		intervalsErr := stats.ValidateIntervalMethod(planDef.Intervals)
+		if intervalsErr != nil {
+			return planDef.Field("intervals").Wrap(intervalsErr)
+		}
+		fg.intervals = planDef.Intervals
+	}
+	// Sensitivity analysis?
+	if len(planDef.Sensitivity) != 0 {
+		sensitivityErr := ValidateSensitivityMethod(planDef.Sensitivity)
+		if sensitivityErr != nil {
+			return planDef.Field("sensitivity").Wrap(sensitivityErr)
+		}
+		fg.sensitivity = newSensitivityAnalysis(planDef.Sensitivity)
+	}
+	// Auto run counts evaluate up to the maximum runs, stopping when the
+	// percentiles converge
+	if planDef.RunCount.Auto {
+		fg.convergence = newRunConvergence(planDef.RunCount, fg.percentiles)
+		minRuns := uint64(CONVERGENCE_MIN_BATCHES) * EVALUATION_CHUNK_SIZE
+		if fg.convergence.maxRuns < minRuns {
+			return planDef.RunCount.Field("max").Wrap(fmt.Errorf("invalid auto runCount max: %d. Auto run counts need at least %d runs (%d batches of %d runs) to converge",
+				fg.convergence.maxRuns,
+				minRuns,
+				CONVERGENCE_MIN_BATCHES,
+				EVALUATION_CHUNK_SIZE))
+		}
+		fg.startNode.runCount = fg.convergence.maxRuns
 	}
 	// Set up the aggregation options
 	fg.flowSubgraph.aggregationOptions = &AggregationOptions{}
-	fg.flowSubgraph.aggregationOptions.workdays = goejson.Boolean("workdays", rootMap)
-	return fg.recursiveUnmarshal(rootMap, fg.flowSubgraph, log)
+	fg.flowSubgraph.aggregationOptions.workdays = planDef.Workdays
+	calendar, calendarErr := unmarshalCalendar(planDef.Holidays)
+	if calendarErr != nil {
+		return planDef.Field("holidays").Wrap(calendarErr)
+	}
+	fg.flowSubgraph.aggregationOptions.calendar = calendar
+	for i, eachStatistic := range planDef.Statistics {
+		statisticErr := stats.ValidateStatistic(eachStatistic)
+		if statisticErr != nil {
+			return planDef.Field("statistics").Index(i).Wrap(statisticErr)
+		}
+	}
+	fg.flowSubgraph.aggregationOptions.statistics = planDef.Statistics
+	// The plan deadline and the probability below which deadlines are flagged
+	deadlineErr := fg.flowSubgraph.unmarshalDeadline(planDef.Deadline)
+	if deadlineErr != nil {
+		return planDef.Field("deadline").Wrap(deadlineErr)
+	}
+	fg.deadlineThreshold = DEFAULT_DEADLINE_THRESHOLD
+	if planDef.DeadlineThreshold != nil {
+		fg.deadlineThreshold = *planDef.DeadlineThreshold
+	}
+	// Any assertions that `check` verifies
+	fg.assertions = planDef.Assertions
+	// The shared resources the tasks draw on must be known before the tasks
+	fg.resources = planDef.Resources
+	recursiveErr := fg.recursiveUnmarshal(planDef.Activities, fg.flowSubgraph, log)
+	if recursiveErr != nil {
+		return recursiveErr
+	}
+	// Then the risk register, which references the activities by name
+	riskRegister, riskRegisterErr := unmarshalRiskRegister(planDef.Risks, log)
+	if riskRegisterErr != nil {
+		return riskRegisterErr
+	}
+	fg.risks = riskRegister
+	attachErr := fg.attachRisks(log)
+	if attachErr != nil {
+		return attachErr
+	}
+	// And finally the task correlations
+	return fg.unmarshalCorrelations(planDef.Correlations, log)
 }
 
-func (fg *flowGraph) Evaluate(histogramPath string, log *slog.Logger) error {
-	sortedNodes, sortedNodesErr := topo.Sort(fg)
+// Evaluate runs the simulation. The runs are sampled in chunks by
+// concurrent workers. Evaluation stops early if the context is done.
+func (fg *flowGraph) Evaluate(ctx context.Context, log *slog.Logger) error {
+	sortedNodes, sortedNodesErr := topo.SortStabilized(fg, nil)
 	if sortedNodesErr != nil {
 		return sortedNodesErr
 	}
-
-	// Topo sort, then evaluate all the nodes.
 	percentiles := fg.percentiles
-	randSrc := rand.NewSource(0)
-	for _, val := range sortedNodes {
-		switch typedVal := val.(type) {
-		case DurationGeneratorGraphNode:
-			values, valuesErr := typedVal.Generate(fg, percentiles, randSrc, log)
-			if valuesErr != nil {
-				return valuesErr
+	randSrc, chunksErr := fg.evaluateChunks(ctx, sortedNodes, log)
+	if chunksErr != nil {
+		return chunksErr
+	}
+	fg.logConvergence(log)
+	fg.logSampling(log)
+	if fg.samplingEfficiency != nil {
+		fg.startNode.sampling = fmt.Sprintf("%s (variance reduction: %s)", fg.sampling, fg.samplingFormatter())
+	}
+	// Streaming evaluations analyzed the runs of each chunk
+	if fg.streaming {
+		fg.finishStreamedAnalysis()
+	}
+	// What's the critical path?
+	criticalPathErr := fg.computeCriticalPath(log)
+	if criticalPathErr != nil {
+		return criticalPathErr
+	}
+	fg.logChoiceFrequencies(log)
+	fg.logRiskContributions(log)
+	if fg.sensitivity != nil {
+		fg.computeSensitivity()
+		fg.logSensitivity(log)
+	}
+	fg.logCorrelations(log)
+	fg.computeDeadlines(log)
+
+	topoOrder := make([]int64, len(sortedNodes))
+	for i, eachNode := range sortedNodes {
+		topoOrder[i] = eachNode.ID()
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// Reschedule each run subject to the resource capacities
+	if len(fg.resources) != 0 {
+		if !fg.streaming {
+			scheduleErr := fg.scheduleResourceConstrained(topoOrder, log)
+			if scheduleErr != nil {
+				return scheduleErr
 			}
-			fg.generatorResults[val.ID()] = values
-		default:
-			return fmt.Errorf("invalid node type: %T", typedVal)
 		}
+		fg.logResourceSchedule(log)
 	}
-	// What's the critical path?
-	srcPt, ok := path.BellmanFordFrom(fg.startNode, fg)
-	if !ok {
-		fmt.Println("no negative cycle present")
-	} else {
-		allNodes := fg.flowSubgraph.WeightedDirectedGraph.Nodes()
-		for allNodes.Next() {
-			curTerminalNode := allNodes.Node()
-			shortestPath, shortestWeight := srcPt.To(curTerminalNode.ID())
-			if math.IsInf(shortestWeight, -1) {
-				return fmt.Errorf("negative cycle in path to %c path:%c", allNodes.Node().ID(), shortestPath)
-			} else {
-				nodePath := make([]int64, len(shortestPath))
-				for i := 0; i != len(shortestPath); i++ {
-					nodePath[i] = shortestPath[i].ID()
-				}
-				if curTerminalNode.ID() == fg.outputJoinNode.ID() {
-					// Shortest nodePath
-					log.Debug("shortest path to OUTPUT node",
-						"source", fg.startNode.ID(),
-						"end", allNodes.Node().ID(),
-						"weight", shortestWeight,
-						"path", fmt.Sprintf("%v", nodePath),
-					)
-					for i := 1; i != len(nodePath); i++ {
-						srcNode := &flowGraphNode{
-							id: nodePath[i-1],
-						}
-						destNode := &flowGraphNode{
-							id: nodePath[i],
-						}
-						// Update the separate critical path graph with the edges
-						// that are on the CPS.
-						criticalEdge := fg.criticalPathGraph.NewEdge(srcNode, destNode)
-						fg.criticalPathGraph.SetEdge(criticalEdge)
-					}
-				} else {
-					// Shortest nodePath
-					log.Debug("shortest path",
-						"source", fg.startNode.ID(),
-						"end", allNodes.Node().ID(),
-						"weight", shortestWeight,
-						"path", fmt.Sprintf("%v", nodePath),
-					)
-				}
+	// Accumulate the task costs and plot them against the durations
+	if fg.hasCosts() {
+		if !fg.streaming {
+			costErr := fg.evaluateCosts(topoOrder, percentiles, randSrc, log)
+			if costErr != nil {
+				return costErr
 			}
 		}
+		fg.logCosts(log)
+	}
+	// Then the what-if scenarios, with the plan's runs
+	if fg.scenarios != nil {
+		scenariosErr := fg.evaluateScenarios(ctx, log)
+		if scenariosErr != nil {
+			return scenariosErr
+		}
+		fg.logScenarios(log)
 	}
-	// Graph the final distribution
-	return fg.PlotDistribution(histogramPath, log)
+	return nil
 }
 
-func newFlowGraph(inputFile io.Reader, log *slog.Logger) (*flowGraph, error) {
+func newFlowGraph(planDef *plan.Plan, log *slog.Logger) (*flowGraph, error) {
 	// Create the beginning and end nodes...
 	fg := &flowGraph{
 		flowSubgraph:      newFlowSubgraph("input", nil),
@@ -848,15 +1286,15 @@ func newFlowGraph(inputFile io.Reader, log *slog.Logger) (*flowGraph, error) {
 		runCount: 0,
 		flowGraphNode: flowGraphNode{
 			name: "START",
-			id:   rand.Int63(),
+			id:   fg.newNodeID(),
 		},
 	}
 	fg.AddNode(fg.startNode)
 	edge := fg.WeightedDirectedGraph.NewWeightedEdge(fg.startNode, fg.flowSubgraph.inputNode, 0)
 	fg.WeightedDirectedGraph.SetWeightedEdge(edge)
 
-	// Then try to unmarshal from the input stream
-	unmarshalErr := fg.Unmarshal(inputFile, log)
+	// Then build the graph from the plan
+	unmarshalErr := fg.Unmarshal(planDef, log)
 	if unmarshalErr != nil {
 		return nil, unmarshalErr
 	}
@@ -864,26 +1302,147 @@ func newFlowGraph(inputFile io.Reader, log *slog.Logger) (*flowGraph, error) {
 	return fg, nil
 }
 
+// InvalidPlanError is returned when the plan can't be read or is invalid,
+// as distinct from errors evaluating or rendering a valid plan
+type InvalidPlanError struct {
+	Err error
+}
+
+func (ipe *InvalidPlanError) Error() string {
+	return fmt.Sprintf("invalid plan: %v", ipe.Err)
+}
+
+func (ipe *InvalidPlanError) Unwrap() error {
+	return ipe.Err
+}
+
+// STDIN_PATH is the input path that reads the plan from stdin
+const STDIN_PATH = "-"
+
+// readInput reads the input path, or stdin, and returns the name that
+// errors are reported against
+func readInput(inputPath string) ([]byte, string, error) {
+	if inputPath == STDIN_PATH {
+		inputBytes, inputBytesErr := io.ReadAll(os.Stdin)
+		return inputBytes, "<stdin>", inputBytesErr
+	}
+	inputBytes, inputBytesErr := os.ReadFile(inputPath)
+	return inputBytes, inputPath, inputBytesErr
+}
+
+// readPlan strictly decodes the plan at the path
+func readPlan(inputPath string) (*plan.Plan, error) {
+	inputBytes, inputName, inputBytesErr := readInput(inputPath)
+	if inputBytesErr != nil {
+		return nil, inputBytesErr
+	}
+	return plan.Decode(inputName, inputBytes)
+}
+
+// openFlowGraph reads the plan at the input path and applies the run
+// overrides
+func openFlowGraph(params *ApplicationFlowGraphParams, log *slog.Logger) (*flowGraph, error) {
+	planDef, planDefErr := readPlan(params.InputFile)
+	if planDefErr != nil {
+		return nil, &InvalidPlanError{Err: planDefErr}
+	}
+	if planDef.Version != nil {
+		log.Warn(DEPRECATED_SCHEMA_KEY_MESSAGE, "location", planDef.Field("schema").Location())
+	}
+	return newEvaluationGraph(planDef, params.EvaluateOptions, log)
+}
+
+// Supported outputs
+const (
+	OUTPUT_D2   = "d2"
+	OUTPUT_SVG  = "svg"
+	OUTPUT_PNG  = "png"
+	OUTPUT_DOT  = "dot"
+	OUTPUT_JSON = "json"
+)
+
+// SupportedOutputs are the outputs that `run` can create
+var SupportedOutputs = []string{OUTPUT_D2, OUTPUT_SVG, OUTPUT_PNG, OUTPUT_DOT, OUTPUT_JSON}
+
+// DefaultOutputs are the outputs created when none are selected
+var DefaultOutputs = []string{OUTPUT_D2, OUTPUT_SVG, OUTPUT_PNG, OUTPUT_DOT}
+
+// ParseOutputs parses a comma separated list of outputs
+func ParseOutputs(outputList string) ([]string, error) {
+	outputs := make([]string, 0)
+	for _, eachOutput := range strings.Split(outputList, ",") {
+		eachOutput = strings.ToLower(strings.TrimSpace(eachOutput))
+		if len(eachOutput) == 0 {
+			continue
+		}
+		if !slices.Contains(SupportedOutputs, eachOutput) {
+			return nil, fmt.Errorf("unsupported output: %s. Supported outputs: %v", eachOutput, SupportedOutputs)
+		}
+		if !slices.Contains(outputs, eachOutput) {
+			outputs = append(outputs, eachOutput)
+		}
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("no outputs selected. Supported outputs: %v", SupportedOutputs)
+	}
+	return outputs, nil
+}
+
 type ApplicationFlowGraphParams struct {
+	// InputFile is the plan path, or STDIN_PATH to read the plan from stdin
 	InputFile       string
 	OutputDirectory string
-	CreateDot       bool
-	LightThemeID    int64
-	DarkThemeID     int64
+	// Outputs are the artifacts to create. Defaults to DefaultOutputs.
+	Outputs      []string
+	LightThemeID int64
+	DarkThemeID  int64
+	EvaluateOptions
 }
 
-func NewApplicationFlowGraph(params *ApplicationFlowGraphParams, log *slog.Logger) (*graph.Directed, error) {
+// OutputPath returns the path of the output created in the output directory.
+// Outputs are named after the input file. The JSON summary is suffixed so
+// that it doesn't overwrite the plan.
+func (params *ApplicationFlowGraphParams) OutputPath(output string) string {
+	outputFileBaseName := "plan"
+	if params.InputFile != STDIN_PATH {
+		outputFileName := filepath.Base(params.InputFile)
+		outputFileBaseName = strings.TrimSuffix(outputFileName, filepath.Ext(outputFileName))
+	}
+	if output == OUTPUT_JSON {
+		outputFileBaseName += "-summary"
+	}
+	return filepath.Join(params.OutputDirectory, outputFileBaseName+"."+output)
+}
 
-	inputFile, _ := os.Open(params.InputFile)
-	appGraph, appGraphErr := newFlowGraph(inputFile, log)
+// writeOutputFile creates the output file and writes it
+func writeOutputFile(outputPath string, write func(io.Writer) error) error {
+	outputFile, outputFileErr := os.Create(outputPath)
+	if outputFileErr != nil {
+		return outputFileErr
+	}
+	writeErr := write(outputFile)
+	closeErr := outputFile.Close()
+	if writeErr != nil {
+		return writeErr
+	}
+	return closeErr
+}
+
+// NewApplicationFlowGraph evaluates the plan at the input path and creates
+// the selected outputs in the output directory
+func NewApplicationFlowGraph(params *ApplicationFlowGraphParams, log *slog.Logger) (*Evaluation, error) {
+
+	appGraph, appGraphErr := openFlowGraph(params, log)
 	if appGraphErr != nil {
 		return nil, appGraphErr
 	}
-	outputFileName := filepath.Base(params.InputFile)
-	outputFileBaseName := strings.TrimSuffix(outputFileName, filepath.Ext(outputFileName))
+	outputs := params.Outputs
+	if len(outputs) == 0 {
+		outputs = DefaultOutputs
+	}
 
-	if params.CreateDot {
-		dotOutPath := filepath.Join(params.OutputDirectory, outputFileBaseName+".dot")
+	if slices.Contains(outputs, OUTPUT_DOT) {
+		dotOutPath := params.OutputPath(OUTPUT_DOT)
 		dotBytes, dotBytesErr := dot.Marshal(appGraph, "Test", "", " ")
 		if dotBytesErr != nil {
 			return nil, dotBytesErr
@@ -896,30 +1455,82 @@ func NewApplicationFlowGraph(params *ApplicationFlowGraphParams, log *slog.Logge
 	}
 
 	// Evaluate the graph and output the results...
-	histogramPath := filepath.Join(params.OutputDirectory, outputFileBaseName+".png")
-	evalErr := appGraph.Evaluate(histogramPath, log)
+	evalErr := appGraph.Evaluate(context.Background(), log)
 	if evalErr != nil {
 		return nil, evalErr
 	}
-	d2File := filepath.Join(params.OutputDirectory, outputFileBaseName+".d2")
-	f, _ := os.Create(d2File)
-
-	encoder := D2EncodingVisitor{
-		criticalPathGraph: simple.NewDirectedGraph(),
+	evaluation := &Evaluation{graph: appGraph}
+
+	// The plots are only created, and referenced by the diagram, if
+	// they're selected.
+	histogramPath := ""
+	if slices.Contains(outputs, OUTPUT_PNG) {
+		histogramPath = params.OutputPath(OUTPUT_PNG)
+		log.Debug("Plotting distribution", "path", histogramPath)
+		writeErr := writeOutputFile(histogramPath, evaluation.WritePNG)
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		if appGraph.convergence != nil {
+			convergencePath := convergencePlotPath(histogramPath)
+			log.Debug("Plotting convergence", "path", convergencePath)
+			writeErr = writeOutputFile(convergencePath, evaluation.WriteConvergencePNG)
+			if writeErr != nil {
+				return nil, writeErr
+			}
+		}
+		if appGraph.sensitivity != nil {
+			sensitivityPath := sensitivityPlotPath(histogramPath)
+			log.Debug("Plotting sensitivity", "path", sensitivityPath)
+			writeErr = writeOutputFile(sensitivityPath, evaluation.WriteSensitivityPNG)
+			if writeErr != nil {
+				return nil, writeErr
+			}
+		}
+		if appGraph.scenarios != nil {
+			scenariosPath := scenariosPlotPath(histogramPath)
+			log.Debug("Plotting scenarios", "path", scenariosPath)
+			writeErr = writeOutputFile(scenariosPath, evaluation.WriteScenariosPNG)
+			if writeErr != nil {
+				return nil, writeErr
+			}
+		}
+		if appGraph.costs.totalStats != nil {
+			scatterPath := costScatterPath(histogramPath)
+			log.Debug("Plotting cost scatter", "path", scatterPath)
+			writeErr = writeOutputFile(scatterPath, evaluation.WriteCostPNG)
+			if writeErr != nil {
+				return nil, writeErr
+			}
+		}
 	}
-	encodeErr := encoder.Encode(appGraph, histogramPath, f, log)
-	closeErr := f.Close()
-	if closeErr != nil {
-		return nil, closeErr
+	if slices.Contains(outputs, OUTPUT_JSON) {
+		jsonOutPath := params.OutputPath(OUTPUT_JSON)
+		writeErr := writeOutputFile(jsonOutPath, evaluation.WriteJSON)
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		log.Info("Created JSON summary", "path", jsonOutPath)
 	}
-	if encodeErr != nil {
-		log.Error("Failed to encode node", "err", encodeErr)
+	if slices.Contains(outputs, OUTPUT_D2) {
+		d2File := params.OutputPath(OUTPUT_D2)
+		writeErr := writeOutputFile(d2File, func(output io.Writer) error {
+			return evaluation.WriteD2(output, histogramPath, log)
+		})
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		log.Info("Created D2 source", "path", d2File)
 	}
-	createErr := createD2Image(d2File,
-		filepath.Join(params.OutputDirectory, outputFileBaseName+".svg"),
-		params.LightThemeID,
-		params.DarkThemeID,
-		log)
-
-	return nil, createErr
+	if slices.Contains(outputs, OUTPUT_SVG) {
+		svgFile := params.OutputPath(OUTPUT_SVG)
+		writeErr := writeOutputFile(svgFile, func(output io.Writer) error {
+			return evaluation.WriteSVG(output, histogramPath, params.LightThemeID, params.DarkThemeID, log)
+		})
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		log.Info("Created SVG", "path", svgFile)
+	}
+	return evaluation, nil
 }
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"testing"
+
+	"github.com/mweagle/goestimate/plan"
+)
+
+// testLogger discards the log output of the tests
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// evaluateTestPlan decodes and evaluates the plan JSON
+func evaluateTestPlan(t *testing.T, planJSON string, opts EvaluateOptions) *Evaluation {
+	t.Helper()
+	planDef, planDefErr := plan.Decode("test.json", []byte(planJSON))
+	if planDefErr != nil {
+		t.Fatal(planDefErr)
+	}
+	evaluation, evaluationErr := EvaluatePlan(context.Background(), planDef, opts, testLogger())
+	if evaluationErr != nil {
+		t.Fatal(evaluationErr)
+	}
+	return evaluation
+}
+
+// expectNear fails the test if the value isn't within the tolerance of the
+// expected value
+func expectNear(t *testing.T, name string, value float64, expected float64, tolerance float64) {
+	t.Helper()
+	if math.Abs(value-expected) > tolerance {
+		t.Errorf("invalid %s. Expected: %v ± %v, Found: %v", name, expected, tolerance, value)
+	}
+}
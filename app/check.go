@@ -0,0 +1,285 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mweagle/goestimate/stats"
+	gonumstat "gonum.org/v1/gonum/stat"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Assertions
+//
+// Assertions are forecast conditions of the form `METRIC OP VALUE` that are
+// verified by `goestimate check`. Supported metrics are:
+//
+//	mean, median, stddev, pNN        : total duration
+//	cost.mean, cost.median, ...      : total cost
+//	deadline                         : probability of meeting the plan deadline
+//	overrun                          : probability of overrunning the budget
+//
+// Duration values may be dates (YYYY-MM-DD) and probabilities may be
+// percentages (ex: 80%).
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+var reAssertion = regexp.MustCompile(`^\s*([A-Za-z0-9_.]+)\s*(<=|>=|<|>)\s*(\S+)\s*$`)
+var rePercentileMetric = regexp.MustCompile(`^p(\d+(\.\d+)?)$`)
+
+// Assertion is a single forecast condition
+type Assertion struct {
+	Expression string
+	metric     string
+	op         string
+	rawValue   string
+}
+
+// AssertionResult is the outcome of checking an Assertion
+type AssertionResult struct {
+	Assertion *Assertion
+	Threshold float64
+	Actual    float64
+	Passed    bool
+}
+
+// CheckReport is the set of assertion results for a plan
+type CheckReport struct {
+	Name    string
+	Results []*AssertionResult
+}
+
+// Passed returns true if every assertion passed
+func (cr *CheckReport) Passed() bool {
+	for _, eachResult := range cr.Results {
+		if !eachResult.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTable writes the pass/fail table
+func (cr *CheckReport) WriteTable(output io.Writer) error {
+	tw := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ASSERTION\tTHRESHOLD\tACTUAL\tRESULT\n")
+	for _, eachResult := range cr.Results {
+		result := "PASS"
+		if !eachResult.Passed {
+			result = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%s\n",
+			eachResult.Assertion.Expression,
+			eachResult.Threshold,
+			eachResult.Actual,
+			result)
+	}
+	return tw.Flush()
+}
+
+// ParseAssertion parses a `METRIC OP VALUE` assertion expression
+func ParseAssertion(expression string) (*Assertion, error) {
+	matches := reAssertion.FindStringSubmatch(expression)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid assertion: %s. Assertions must be of the form: METRIC OP VALUE", expression)
+	}
+	assertion := &Assertion{
+		Expression: strings.TrimSpace(expression),
+		metric:     strings.ToLower(matches[1]),
+		op:         matches[2],
+		rawValue:   matches[3],
+	}
+	metricName := strings.TrimPrefix(assertion.metric, "cost.")
+	switch metricName {
+	case "mean", "median", "stddev", "deadline", "overrun":
+	default:
+		if !rePercentileMetric.MatchString(metricName) {
+			return nil, fmt.Errorf("invalid assertion metric: %s. Supported metrics: [mean median stddev pNN cost.* deadline overrun]", assertion.metric)
+		}
+		// Percentiles outside (0, 100] have no quantile
+		percentile, _ := strconv.ParseFloat(strings.TrimPrefix(metricName, "p"), 64)
+		if percentile <= 0 || percentile > 100 {
+			return nil, fmt.Errorf("invalid assertion percentile: %s. Percentiles must be in (0, 100]", assertion.metric)
+		}
+	}
+	if strings.HasPrefix(assertion.metric, "cost.") && (metricName == "deadline" || metricName == "overrun") {
+		return nil, fmt.Errorf("invalid assertion metric: %s", assertion.metric)
+	}
+	return assertion, nil
+}
+
+// isProbability returns true if the assertion's metric is a probability
+func (a *Assertion) isProbability() bool {
+	return a.metric == "deadline" || a.metric == "overrun"
+}
+
+// threshold returns the assertion's value in the metric's units
+func (a *Assertion) threshold(aggregationOptions *AggregationOptions) (float64, error) {
+	if a.isProbability() && strings.HasSuffix(a.rawValue, "%") {
+		percentValue, percentValueErr := strconv.ParseFloat(strings.TrimSuffix(a.rawValue, "%"), 64)
+		if percentValueErr != nil {
+			return 0, fmt.Errorf("invalid assertion value: %s", a.rawValue)
+		}
+		return percentValue / 100, nil
+	}
+	floatValue, floatValueErr := strconv.ParseFloat(a.rawValue, 64)
+	if floatValueErr == nil {
+		return floatValue, nil
+	}
+	// Dates only make sense for duration metrics
+	if a.isProbability() || strings.HasPrefix(a.metric, "cost.") {
+		return 0, fmt.Errorf("invalid assertion value: %s", a.rawValue)
+	}
+	dateValue, dateValueErr := time.ParseInLocation(DEADLINE_DATE_FORMAT, a.rawValue, nowTime.Location())
+	if dateValueErr != nil {
+		return 0, fmt.Errorf("invalid assertion value: %s. Values must be numbers or dates formatted as %s",
+			a.rawValue,
+			DEADLINE_DATE_FORMAT)
+	}
+	return dateOffset(dateValue, aggregationOptions), nil
+}
+
+// sampleMetric computes a summary statistic of the samples
+func sampleMetric(samples []float64, metricName string) float64 {
+	sortedSamples := make([]float64, len(samples))
+	copy(sortedSamples, samples)
+	sort.Float64s(sortedSamples)
+	switch metricName {
+	case "mean":
+		return gonumstat.Mean(sortedSamples, nil)
+	case "median":
+		return gonumstat.Quantile(0.5, gonumstat.Empirical, sortedSamples, nil)
+	case "stddev":
+		return gonumstat.StdDev(sortedSamples, nil)
+	}
+	percentile, _ := strconv.ParseFloat(strings.TrimPrefix(metricName, "p"), 64)
+	return gonumstat.Quantile(percentile/100, gonumstat.Empirical, sortedSamples, nil)
+}
+
+// summaryMetric returns the named metric (mean, median, stddev or pNN) of
+// the summarized samples
+func summaryMetric(summary *stats.Accumulator, metricName string) float64 {
+	switch metricName {
+	case "mean":
+		return summary.Mean()
+	case "median":
+		return summary.Digest().Quantile(0.5)
+	case "stddev":
+		return summary.StdDev()
+	}
+	percentile, _ := strconv.ParseFloat(strings.TrimPrefix(metricName, "p"), 64)
+	return summary.Digest().Quantile(percentile / 100)
+}
+
+// outputMetric returns the named metric of the total duration
+func (fg *flowGraph) outputMetric(metricName string) float64 {
+	outputResults := fg.outputJoinNode.GenerationResults()
+	if fg.streaming {
+		return summaryMetric(outputResults.CumulativeSummary, metricName)
+	}
+	return sampleMetric(*outputResults.CumulativeValues, metricName)
+}
+
+// costMetric returns the named metric of the total cost
+func (fg *flowGraph) costMetric(metricName string) float64 {
+	if fg.streaming {
+		return summaryMetric(fg.costs.totalSummary, metricName)
+	}
+	return sampleMetric(fg.costs.totalValues, metricName)
+}
+
+// check evaluates the assertion against the evaluated graph
+func (fg *flowGraph) check(assertion *Assertion) (*AssertionResult, error) {
+	threshold, thresholdErr := assertion.threshold(fg.flowSubgraph.aggregationOptions)
+	if thresholdErr != nil {
+		return nil, thresholdErr
+	}
+	result := &AssertionResult{
+		Assertion: assertion,
+		Threshold: threshold,
+	}
+	switch {
+	case assertion.metric == "deadline":
+		if fg.flowSubgraph.deadline == nil {
+			return nil, fmt.Errorf("assertion %s requires a plan deadline", assertion.Expression)
+		}
+		result.Actual = fg.flowSubgraph.deadline.probability
+	case assertion.metric == "overrun":
+		if fg.costs.budget <= 0 || fg.costs.totalStats == nil {
+			return nil, fmt.Errorf("assertion %s requires a plan budget and task costs", assertion.Expression)
+		}
+		result.Actual = fg.costs.overrunProbability
+	case strings.HasPrefix(assertion.metric, "cost."):
+		if fg.costs.totalStats == nil {
+			return nil, fmt.Errorf("assertion %s requires task costs", assertion.Expression)
+		}
+		result.Actual = fg.costMetric(strings.TrimPrefix(assertion.metric, "cost."))
+	default:
+		result.Actual = fg.outputMetric(assertion.metric)
+	}
+	switch assertion.op {
+	case "<=":
+		result.Passed = result.Actual <= threshold
+	case "<":
+		result.Passed = result.Actual < threshold
+	case ">=":
+		result.Passed = result.Actual >= threshold
+	case ">":
+		result.Passed = result.Actual > threshold
+	}
+	return result, nil
+}
+
+// CheckApplicationFlowGraph evaluates the plan without rendering it and
+// checks the plan's assertions together with any additional assertions.
+// Plans that can't be read or fail while they are sampled, and assertions
+// that can't be parsed, return an InvalidPlanError.
+func CheckApplicationFlowGraph(params *ApplicationFlowGraphParams,
+	additionalAssertions []string,
+	log *slog.Logger) (*CheckReport, error) {
+
+	appGraph, appGraphErr := openFlowGraph(params, log)
+	if appGraphErr != nil {
+		return nil, appGraphErr
+	}
+	assertionExpressions := append(appGraph.assertions, additionalAssertions...)
+	if len(assertionExpressions) <= 0 {
+		return nil, &InvalidPlanError{Err: fmt.Errorf("no assertions provided")}
+	}
+	assertions := make([]*Assertion, len(assertionExpressions))
+	for i, eachExpression := range assertionExpressions {
+		assertion, assertionErr := ParseAssertion(eachExpression)
+		if assertionErr != nil {
+			return nil, &InvalidPlanError{Err: assertionErr}
+		}
+		assertions[i] = assertion
+	}
+	evalErr := appGraph.Evaluate(context.Background(), log)
+	if evalErr != nil {
+		return nil, evalErr
+	}
+	report := &CheckReport{
+		Name:    appGraph.name,
+		Results: make([]*AssertionResult, 0, len(assertions)),
+	}
+	for _, eachAssertion := range assertions {
+		result, resultErr := appGraph.check(eachAssertion)
+		if resultErr != nil {
+			return nil, &InvalidPlanError{Err: resultErr}
+		}
+		log.Debug("Checked assertion",
+			"assertion", eachAssertion.Expression,
+			"actual", result.Actual,
+			"passed", result.Passed)
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
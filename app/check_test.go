@@ -0,0 +1,29 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestParseAssertion(t *testing.T) {
+	testCases := map[string]bool{
+		"p85 <= 30":           true,
+		"p99.9 < 40":          true,
+		"p100 < 50":           true,
+		"cost.p50 <= 1000":    true,
+		"deadline >= 80%":     true,
+		"mean < 2027-03-01":   true,
+		"p0 < 3":              false,
+		"p101 < 3":            false,
+		"cost.p250 < 3":       false,
+		"cost.deadline > 50%": false,
+		"median":              false,
+		"variance < 3":        false,
+		"p50 ~ 3":             false,
+	}
+	for expression, eachValid := range testCases {
+		_, assertionErr := ParseAssertion(expression)
+		if (assertionErr == nil) != eachValid {
+			t.Errorf("invalid ParseAssertion(%q) result. Expected valid: %v, Found error: %v", expression, eachValid, assertionErr)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/mweagle/goestimate/generator"
+	"github.com/mweagle/goestimate/plan"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Choice subgraphs
+//
+// A choice is a subgraph whose children are weighted branch subgraphs. For
+// each run exactly one branch is selected by the ChoiceGenerator that closes
+// the choice subgraph.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+func (fg *flowGraph) unmarshalChoice(choice *plan.Choice,
+	subgraphParent *flowSubgraph,
+	log *slog.Logger) error {
+
+	if len(choice.Branches) <= 0 {
+		return choice.Field("branches").Wrap(fmt.Errorf("invalid branches for choice %s. Choices must define an array of branch objects", choice.Name))
+	}
+	totalWeight := float64(0)
+	for _, eachBranch := range choice.Branches {
+		if eachBranch.Weight != nil {
+			totalWeight += *eachBranch.Weight
+		}
+	}
+	if totalWeight <= 0 {
+		return choice.Field("branches").Wrap(fmt.Errorf("invalid branch weights for choice %s: %v. Expected a positive total weight", choice.Name, totalWeight))
+	}
+	choiceSubgraph, choiceSubgraphErr := subgraphParent.AddSubgraph(choice.Name)
+	if choiceSubgraphErr != nil {
+		return choiceSubgraphErr
+	}
+	choiceGenerator := &generator.ChoiceGenerator{
+		Weights: make(map[int64]float64, len(choice.Branches)),
+	}
+	choiceSubgraph.outputJoinNode.generator = choiceGenerator
+	choiceSubgraph.outputJoinNode.source = choice.Source
+	repeatErr := choiceSubgraph.unmarshalRepeat(choice.Repeat)
+	if repeatErr != nil {
+		return choice.Field("repeat").Wrap(repeatErr)
+	}
+
+	for i, eachBranch := range choice.Branches {
+		branchName := eachBranch.Name
+		if len(branchName) <= 0 {
+			branchName = fmt.Sprintf("branch-%d", i)
+		}
+		branchSubgraph, branchSubgraphErr := choiceSubgraph.AddSubgraph(branchName)
+		if branchSubgraphErr != nil {
+			return branchSubgraphErr
+		}
+		choiceGenerator.Weights[branchSubgraph.outputJoinNode.ID()] = *eachBranch.Weight
+
+		// Branches without activities (ex: "the review passes") take no
+		// additional time.
+		if eachBranch.Activities != nil && len(eachBranch.Activities.Entries) != 0 {
+			branchErr := fg.recursiveUnmarshal(eachBranch.Activities, branchSubgraph, log)
+			if branchErr != nil {
+				return branchErr
+			}
+		}
+		if !branchSubgraph.From(branchSubgraph.inputNode.ID()).Next() {
+			edge := branchSubgraph.NewWeightedEdge(branchSubgraph.inputNode, branchSubgraph.outputJoinNode, 0)
+			branchSubgraph.SetWeightedEdge(edge)
+		}
+	}
+	return nil
+}
+
+// choiceBranchLabel returns the D2 edge label for the connection from a
+// choice subgraph's input node to one of its branches. Nodes that aren't
+// choice inputs return an empty label.
+func choiceBranchLabel(fromNode D2Encoder, branchJoinNodeID int64) string {
+	passThroughNode, passThroughNodeOk := fromNode.(*flowGraphPassThroughNode)
+	if !passThroughNodeOk || len(passThroughNode.parentFlowSubgraphs) <= 0 {
+		return ""
+	}
+	owningSubgraph := passThroughNode.parentFlowSubgraphs[len(passThroughNode.parentFlowSubgraphs)-1]
+	choiceGenerator, choiceGeneratorOk := owningSubgraph.outputJoinNode.generator.(*generator.ChoiceGenerator)
+	if !choiceGeneratorOk {
+		return ""
+	}
+	label := fmt.Sprintf("p=%.2f", choiceGenerator.Probability(branchJoinNodeID))
+	frequency, frequencyOk := choiceGenerator.Frequencies()[branchJoinNodeID]
+	if frequencyOk {
+		label = fmt.Sprintf("%s (observed %.2f%%)", label, frequency*100)
+	}
+	return label
+}
+
+func (fg *flowGraph) logChoiceFrequencies(log *slog.Logger) {
+	for _, eachNode := range fg.sortedNodes() {
+		joinNode, joinNodeOk := eachNode.(*flowGraphJoinMaxValueNode)
+		if !joinNodeOk {
+			continue
+		}
+		choiceGenerator, choiceGeneratorOk := joinNode.generator.(*generator.ChoiceGenerator)
+		if !choiceGeneratorOk {
+			continue
+		}
+		choiceSubgraph := joinNode.parentFlowSubgraphs[len(joinNode.parentFlowSubgraphs)-1]
+		for _, eachBranchNode := range sortedByID(fg.WeightedDirectedGraph.To(joinNode.ID())) {
+			branchJoinNode, branchJoinNodeOk := eachBranchNode.(*flowGraphJoinMaxValueNode)
+			if !branchJoinNodeOk {
+				continue
+			}
+			branchSubgraph := branchJoinNode.parentFlowSubgraphs[len(branchJoinNode.parentFlowSubgraphs)-1]
+			log.Info("Choice branch frequency",
+				"choice", choiceSubgraph.inputNode.name,
+				"branch", branchSubgraph.inputNode.name,
+				"p", fmt.Sprintf("%.2f", choiceGenerator.Probability(branchJoinNode.ID())),
+				"observed", fmt.Sprintf("%.2f%%", choiceGenerator.Frequencies()[branchJoinNode.ID()]*100))
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"math"
+	"testing"
+)
+
+// Each branch is chosen at the frequency of its normalized weight, and only
+// the chosen branch's duration is added
+func TestChoiceBranchFrequencies(t *testing.T) {
+	const runCount = 20000
+	evaluation := evaluateTestPlan(t, `{
+		"name": "Choices",
+		"runCount": 20000,
+		"activities": {
+			"review": {
+				"name": "Review",
+				"branches": [
+					{"name": "Approved", "weight": 2},
+					{
+						"name": "Rework",
+						"weight": 5,
+						"activities": {"tasks": [{"name": "Rework", "type": "Fixed(2)"}]}
+					},
+					{
+						"name": "Redesign",
+						"weight": 3,
+						"activities": {"tasks": [{"name": "Redesign", "type": "Fixed(3)"}]}
+					}
+				]
+			}
+		}
+	}`, EvaluateOptions{Seed: 7})
+	// Branches without activities take no time
+	frequencies := map[float64]float64{0: 0.2, 2: 0.5, 3: 0.3}
+	counts := make(map[float64]int)
+	for _, eachSample := range evaluation.Samples() {
+		counts[eachSample]++
+	}
+	for duration, eachFrequency := range frequencies {
+		tolerance := 4 * math.Sqrt(eachFrequency*(1-eachFrequency)/runCount)
+		expectNear(t, "branch frequency", float64(counts[duration])/runCount, eachFrequency, tolerance)
+	}
+	if len(counts) != len(frequencies) {
+		t.Errorf("invalid durations. Expected one per branch, Found: %v", counts)
+	}
+}
@@ -0,0 +1,294 @@
+package app
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/mweagle/goestimate/plan"
+	gonumstat "gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Convergence
+//
+// Plans with an `auto` runCount add chunks of runs until the percentiles of
+// the plan's total duration converge. Each evaluation chunk is a batch and
+// the precision is judged by batch means: the chunk percentiles are
+// approximately normal and independent, so the Student-t confidence
+// interval of their mean bounds the error of the percentile estimate. The
+// runs converge when every percentile's 95% confidence interval half-width,
+// relative to its estimate, is within the tolerance. Convergence is checked
+// in chunk order, so the run count doesn't depend on the number of workers.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// DEFAULT_CONVERGENCE_TOLERANCE is the relative half-width of the
+// percentiles' confidence intervals of auto run counts
+var DEFAULT_CONVERGENCE_TOLERANCE = 0.01
+
+// DEFAULT_CONVERGENCE_MAX_RUNS is the maximum number of runs of auto run
+// counts
+var DEFAULT_CONVERGENCE_MAX_RUNS uint64 = 1000000
+
+// CONVERGENCE_MIN_BATCHES is the minimum number of batches before the runs
+// can converge, so that the batch variance is a usable estimate
+var CONVERGENCE_MIN_BATCHES = 10
+
+// CONVERGENCE_CONFIDENCE is the confidence level of the percentile intervals
+var CONVERGENCE_CONFIDENCE = 0.95
+
+// convergencePoint is the precision of the percentiles after a batch
+type convergencePoint struct {
+	runs               uint64
+	relativeHalfWidths []float64
+}
+
+// runConvergence tracks the precision of the percentiles as batches of runs
+// are added
+type runConvergence struct {
+	tolerance   float64
+	maxRuns     uint64
+	percentiles []float64
+	// batchPercentiles are the percentiles of each batch
+	batchPercentiles [][]float64
+	estimates        []float64
+	halfWidths       []float64
+	history          []*convergencePoint
+	runs             uint64
+	converged        bool
+	// plotPath is the convergence plot next to the histogram, if the plan
+	// was evaluated with plots
+	plotPath string
+}
+
+func newRunConvergence(runCount plan.RunCount, percentiles []float64) *runConvergence {
+	convergence := &runConvergence{
+		tolerance:   runCount.Tolerance,
+		maxRuns:     runCount.Max,
+		percentiles: make([]float64, len(percentiles)),
+	}
+	if convergence.tolerance <= 0 {
+		convergence.tolerance = DEFAULT_CONVERGENCE_TOLERANCE
+	}
+	if convergence.maxRuns <= 0 {
+		convergence.maxRuns = DEFAULT_CONVERGENCE_MAX_RUNS
+	}
+	for i, eachPercentile := range percentiles {
+		if eachPercentile > 1.00 {
+			eachPercentile = eachPercentile / 100
+		}
+		convergence.percentiles[i] = eachPercentile
+	}
+	return convergence
+}
+
+// addBatch adds the percentiles of a batch of runs and returns true if the
+// percentiles have converged
+func (rc *runConvergence) addBatch(runs uint64, percentileValues []float64) bool {
+	rc.batchPercentiles = append(rc.batchPercentiles, percentileValues)
+	rc.runs += runs
+	batchCount := len(rc.batchPercentiles)
+	if batchCount < 2 {
+		return false
+	}
+	tDist := distuv.StudentsT{
+		Mu:    0,
+		Sigma: 1,
+		Nu:    float64(batchCount - 1),
+	}
+	tValue := tDist.Quantile(0.5 + CONVERGENCE_CONFIDENCE/2)
+	rc.estimates = make([]float64, len(rc.percentiles))
+	rc.halfWidths = make([]float64, len(rc.percentiles))
+	point := &convergencePoint{
+		runs:               rc.runs,
+		relativeHalfWidths: make([]float64, len(rc.percentiles)),
+	}
+	batchValues := make([]float64, batchCount)
+	withinTolerance := true
+	for i := range rc.percentiles {
+		for j, eachBatch := range rc.batchPercentiles {
+			batchValues[j] = eachBatch[i]
+		}
+		mean, stdDev := gonumstat.MeanStdDev(batchValues, nil)
+		rc.estimates[i] = mean
+		rc.halfWidths[i] = tValue * stdDev / math.Sqrt(float64(batchCount))
+		point.relativeHalfWidths[i] = relativeHalfWidth(rc.halfWidths[i], mean)
+		if point.relativeHalfWidths[i] > rc.tolerance {
+			withinTolerance = false
+		}
+	}
+	rc.history = append(rc.history, point)
+	rc.converged = withinTolerance && batchCount >= CONVERGENCE_MIN_BATCHES
+	return rc.converged
+}
+
+func relativeHalfWidth(halfWidth float64, estimate float64) float64 {
+	if halfWidth == 0 {
+		return 0
+	}
+	return halfWidth / math.Abs(estimate)
+}
+
+// precision returns the latest relative half-width of each percentile, or
+// nil before there are two batches
+func (rc *runConvergence) precision() []float64 {
+	if len(rc.history) == 0 {
+		return nil
+	}
+	return rc.history[len(rc.history)-1].relativeHalfWidths
+}
+
+// summary returns the achieved precision of the percentiles
+func (rc *runConvergence) summary() *ConvergenceSummary {
+	summary := &ConvergenceSummary{
+		Tolerance:  rc.tolerance,
+		MaxRuns:    rc.maxRuns,
+		Converged:  rc.converged,
+		Batches:    len(rc.batchPercentiles),
+		Confidence: CONVERGENCE_CONFIDENCE,
+	}
+	precision := rc.precision()
+	if len(precision) != 0 {
+		summary.Percentiles = make(map[string]*PercentilePrecision, len(precision))
+		for i, eachPrecision := range precision {
+			summary.Percentiles[percentileName(rc.percentiles[i])] = &PercentilePrecision{
+				Estimate:          rc.estimates[i],
+				HalfWidth:         rc.halfWidths[i],
+				RelativeHalfWidth: eachPrecision,
+			}
+		}
+	}
+	return summary
+}
+
+func (rc *runConvergence) precisionFormatter() string {
+	value := ""
+	for i, eachPrecision := range rc.precision() {
+		value += fmt.Sprintf("%s=±%.2f%%, ", percentileName(rc.percentiles[i]), eachPrecision*100)
+	}
+	return strings.TrimSuffix(value, ", ")
+}
+
+func (fg *flowGraph) logConvergence(log *slog.Logger) {
+	if fg.convergence == nil {
+		return
+	}
+	convergence := fg.convergence
+	if convergence.converged {
+		log.Info("Percentiles converged",
+			"runs", convergence.runs,
+			"batches", len(convergence.batchPercentiles),
+			"tolerance", fmt.Sprintf("±%.2f%%", convergence.tolerance*100),
+			"precision", convergence.precisionFormatter())
+	} else {
+		log.Warn("Percentiles didn't converge within the maximum runs",
+			"runs", convergence.runs,
+			"batches", len(convergence.batchPercentiles),
+			"tolerance", fmt.Sprintf("±%.2f%%", convergence.tolerance*100),
+			"precision", convergence.precisionFormatter())
+	}
+}
+
+// convergencePlotPath is the convergence plot path next to the histogram
+func convergencePlotPath(histogramPath string) string {
+	return strings.TrimSuffix(histogramPath, filepath.Ext(histogramPath)) + "-convergence" + filepath.Ext(histogramPath)
+}
+
+// convergencePlot plots the relative confidence interval half-width of each
+// percentile against the number of runs
+func (fg *flowGraph) convergencePlot() (*plot.Plot, error) {
+	convergence := fg.convergence
+	p := plot.New()
+	p.X.Label.Text = "Runs"
+	p.Y.Label.Text = fmt.Sprintf("Relative %.0f%% CI Half-Width (%%)", CONVERGENCE_CONFIDENCE*100)
+	p.Title.Text = "Percentile Convergence"
+	p.Title.TextStyle.Color = color.RGBA{B: 255, A: 255}
+	p.Y.Min = 0
+
+	for i, eachPercentile := range convergence.percentiles {
+		lineValues := make(plotter.XYs, len(convergence.history))
+		for j, eachPoint := range convergence.history {
+			lineValues[j].X = float64(eachPoint.runs)
+			lineValues[j].Y = eachPoint.relativeHalfWidths[i] * 100
+		}
+		line, lineErr := plotter.NewLine(lineValues)
+		if lineErr != nil {
+			return nil, lineErr
+		}
+		line.LineStyle.Width = vg.Points(2)
+		line.LineStyle.Color = plotutil.Color(i)
+		p.Add(line)
+		p.Legend.Add(percentileName(eachPercentile), line)
+	}
+
+	// Draw the tolerance as a horizontal line
+	toleranceLine := plotter.NewFunction(func(_ float64) float64 {
+		return convergence.tolerance * 100
+	})
+	toleranceLine.LineStyle.Width = vg.Points(2)
+	toleranceLine.LineStyle.Dashes = []vg.Length{vg.Points(3), vg.Points(3)}
+	toleranceLine.LineStyle.Color = color.RGBA{R: 220, G: 20, B: 60, A: 255}
+	p.Add(toleranceLine)
+	p.Legend.Add(fmt.Sprintf("Tolerance: ±%.2f%%", convergence.tolerance*100), toleranceLine)
+	return p, nil
+}
+
+// encodeD2Convergence writes the achieved precision of the percentiles and
+// the convergence plot
+func (fg *flowGraph) encodeD2Convergence(nodeName string, plotNodeName string, output io.StringWriter) error {
+	convergence := fg.convergence
+	status := "Converged"
+	if !convergence.converged {
+		status = "Maximum runs reached"
+	}
+	nodeContents := fmt.Sprintf(`%s : |||md
+# Convergence
+
+- **Status**: %s
+- **Runs**: %d (%d batches)
+- **Tolerance**: ±%.2f%%
+
+| Percentile | Estimate | %.0f%% CI | Precision |
+|---|---|---|---|
+`,
+		nodeName,
+		status,
+		convergence.runs,
+		len(convergence.batchPercentiles),
+		convergence.tolerance*100,
+		CONVERGENCE_CONFIDENCE*100)
+	for i, eachPrecision := range convergence.precision() {
+		nodeContents += fmt.Sprintf("| %s | %.2f | ±%.2f | ±%.2f%% |\n",
+			percentileName(convergence.percentiles[i]),
+			convergence.estimates[i],
+			convergence.halfWidths[i],
+			eachPrecision*100)
+	}
+	nodeContents += "|||\n\n"
+	// The plot only exists if the plan was evaluated with plots
+	if len(convergence.plotPath) == 0 {
+		_, writeErr := output.WriteString(nodeContents)
+		return writeErr
+	}
+	nodeContents += fmt.Sprintf(`%s: Percentile Convergence {
+shape: image
+icon: %s
+width: 768
+height: 768
+}
+`,
+		plotNodeName,
+		convergence.plotPath)
+	_, writeErr := output.WriteString(nodeContents)
+	return writeErr
+}
@@ -0,0 +1,285 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+
+	"github.com/mweagle/goestimate/generator"
+	"github.com/mweagle/goestimate/plan"
+	"github.com/mweagle/goestimate/stats"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// flowCorrelations
+//
+// Plan level correlation between task durations. Pairwise rank correlations
+// are induced with a Gaussian copula: correlated normal scores are sampled
+// for each run and each task's independently generated samples are reordered
+// to match the rank order of its scores (Iman-Conover). Risk drivers are
+// shared latent factors that multiply every task in their group.
+//
+// /////////////////////////////////////////////////////////////////////////////
+type flowCorrelationPair struct {
+	taskNames [2]string
+	rho       float64
+}
+
+type flowRiskDriver struct {
+	name      string
+	generator generator.DurationGenerator
+	taskNames []string
+}
+
+type flowCorrelations struct {
+	pairs   []*flowCorrelationPair
+	drivers []*flowRiskDriver
+	// All correlated tasks in order of their first reference
+	taskNames []string
+	tasks     map[string]*flowGraphNode
+}
+
+// unmarshalCorrelations sets the plan's correlations and resolves the task
+// node for every correlated task name
+func (fg *flowGraph) unmarshalCorrelations(correlationsDef *plan.Correlations, log *slog.Logger) error {
+	correlations := &flowCorrelations{
+		pairs:     make([]*flowCorrelationPair, 0),
+		drivers:   make([]*flowRiskDriver, 0),
+		taskNames: make([]string, 0),
+		tasks:     make(map[string]*flowGraphNode),
+	}
+	fg.correlations = correlations
+	if correlationsDef == nil {
+		return nil
+	}
+	for _, eachPair := range correlationsDef.Pairs {
+		resolveErr := fg.resolveCorrelatedTasks(eachPair.Tasks, eachPair.Field("tasks"))
+		if resolveErr != nil {
+			return resolveErr
+		}
+		correlations.pairs = append(correlations.pairs, &flowCorrelationPair{
+			taskNames: [2]string{eachPair.Tasks[0], eachPair.Tasks[1]},
+			rho:       eachPair.Rho,
+		})
+	}
+	for i, eachDriver := range correlationsDef.Drivers {
+		driverGenerator, driverGeneratorErr := generator.NewDurationGeneratorFromExpression(eachDriver.Type, log)
+		if driverGeneratorErr != nil {
+			return eachDriver.Field("type").Wrap(driverGeneratorErr)
+		}
+		resolveErr := fg.resolveCorrelatedTasks(eachDriver.Tasks, eachDriver.Field("tasks"))
+		if resolveErr != nil {
+			return resolveErr
+		}
+		driverName := eachDriver.Name
+		if len(driverName) <= 0 {
+			driverName = fmt.Sprintf("driver-%d", i)
+		}
+		correlations.drivers = append(correlations.drivers, &flowRiskDriver{
+			name:      driverName,
+			generator: driverGenerator,
+			taskNames: eachDriver.Tasks,
+		})
+	}
+	return nil
+}
+
+// resolveCorrelatedTasks finds the task node for each correlated task name
+func (fg *flowGraph) resolveCorrelatedTasks(taskNames []string, source plan.Source) error {
+	for i, eachTaskName := range taskNames {
+		_, taskResolved := fg.correlations.tasks[eachTaskName]
+		if taskResolved {
+			continue
+		}
+		taskNode, taskNodeErr := fg.taskNamed(eachTaskName)
+		if taskNodeErr != nil {
+			return source.Index(i).Wrap(taskNodeErr)
+		}
+		fg.correlations.tasks[eachTaskName] = taskNode
+		fg.correlations.taskNames = append(fg.correlations.taskNames, eachTaskName)
+	}
+	return nil
+}
+
+// prepareCorrelations sets the rank scores and scale factors for every
+// correlated task. It must be called before the tasks are evaluated.
+func (fg *flowGraph) prepareCorrelations(percentiles []float64, src rand.Source, log *slog.Logger) error {
+	runCount := int(fg.startNode.runCount)
+	correlations := fg.correlations
+	if len(correlations.pairs) != 0 {
+		// The pairwise tasks are the columns of the target correlation matrix
+		columnIndex := make(map[string]int)
+		columnNames := make([]string, 0)
+		for _, eachPair := range correlations.pairs {
+			for _, eachTaskName := range eachPair.taskNames {
+				_, columnExists := columnIndex[eachTaskName]
+				if !columnExists {
+					columnIndex[eachTaskName] = len(columnNames)
+					columnNames = append(columnNames, eachTaskName)
+				}
+			}
+		}
+		// Convert the rank correlation to the equivalent normal correlation
+		targetMatrix := mat.NewSymDense(len(columnNames), nil)
+		for i := range columnNames {
+			targetMatrix.SetSym(i, i, 1)
+		}
+		for _, eachPair := range correlations.pairs {
+			targetMatrix.SetSym(columnIndex[eachPair.taskNames[0]],
+				columnIndex[eachPair.taskNames[1]],
+				2*math.Sin(math.Pi*eachPair.rho/6))
+		}
+		var cholesky mat.Cholesky
+		if !cholesky.Factorize(targetMatrix) {
+			return fmt.Errorf("correlation matrix for tasks %v is not positive definite", columnNames)
+		}
+		var lowerTriangle mat.TriDense
+		cholesky.LTo(&lowerTriangle)
+
+		normal := distuv.Normal{
+			Mu:    0,
+			Sigma: 1,
+			Src:   src,
+		}
+		scores := make([][]float64, len(columnNames))
+		for i := range scores {
+			scores[i] = make([]float64, runCount)
+		}
+		independentScores := make([]float64, len(columnNames))
+		for runIndex := 0; runIndex != runCount; runIndex++ {
+			for i := range independentScores {
+				independentScores[i] = normal.Rand()
+			}
+			for i := range columnNames {
+				score := float64(0)
+				for j := 0; j <= i; j++ {
+					score += lowerTriangle.At(i, j) * independentScores[j]
+				}
+				scores[i][runIndex] = score
+			}
+		}
+		for i, eachTaskName := range columnNames {
+			correlatedGenerator, correlatedGeneratorOk := correlations.tasks[eachTaskName].generator.(generator.CorrelatedGenerator)
+			if !correlatedGeneratorOk {
+				return fmt.Errorf("task %s does not support correlation", eachTaskName)
+			}
+			correlatedGenerator.SetRankScores(scores[i])
+		}
+	}
+
+	// Risk drivers multiply their group of tasks by a shared per-run factor
+	taskScales := make(map[string][]float64)
+	for _, eachDriver := range correlations.drivers {
+		driverPrior := make([]float64, runCount)
+		driverResults, driverResultsErr := eachDriver.generator.Generate(map[int64]*generator.GenerationResults{
+			0: {
+				RawValues:        &driverPrior,
+				CumulativeValues: &driverPrior,
+			},
+		}, percentiles, src, log)
+		if driverResultsErr != nil {
+			return driverResultsErr
+		}
+		log.Debug("Sampled risk driver", "name", eachDriver.name, "mean", driverResults.GeneratorStats.Mean)
+		for _, eachTaskName := range eachDriver.taskNames {
+			taskScale, taskScaleExists := taskScales[eachTaskName]
+			if !taskScaleExists {
+				taskScale = make([]float64, runCount)
+				for i := range taskScale {
+					taskScale[i] = 1
+				}
+				taskScales[eachTaskName] = taskScale
+			}
+			for i, eachFactor := range *driverResults.RawValues {
+				taskScale[i] *= eachFactor
+			}
+		}
+	}
+	for eachTaskName, eachScale := range taskScales {
+		correlatedGenerator, correlatedGeneratorOk := correlations.tasks[eachTaskName].generator.(generator.CorrelatedGenerator)
+		if !correlatedGeneratorOk {
+			return fmt.Errorf("task %s does not support correlation", eachTaskName)
+		}
+		correlatedGenerator.SetScale(eachScale)
+	}
+	return nil
+}
+
+// achievedCorrelations returns the rank correlation matrix of all the
+// correlated tasks' generated samples. Streaming evaluations average the
+// matrix of each chunk of runs.
+func (fg *flowGraph) achievedCorrelations() [][]float64 {
+	if fg.streaming {
+		return fg.streamedAnalysis.achievedCorrelations()
+	}
+	taskNames := fg.correlations.taskNames
+	achieved := make([][]float64, len(taskNames))
+	for i := range taskNames {
+		achieved[i] = make([]float64, len(taskNames))
+		achieved[i][i] = 1
+	}
+	for i := range taskNames {
+		iValues := *fg.correlations.tasks[taskNames[i]].GenerationResults().RawValues
+		for j := i + 1; j < len(taskNames); j++ {
+			jValues := *fg.correlations.tasks[taskNames[j]].GenerationResults().RawValues
+			achieved[i][j] = stats.SpearmanCorrelation(iValues, jValues)
+			achieved[j][i] = achieved[i][j]
+		}
+	}
+	return achieved
+}
+
+func (fg *flowGraph) logCorrelations(log *slog.Logger) {
+	if len(fg.correlations.taskNames) <= 0 {
+		return
+	}
+	achieved := fg.achievedCorrelations()
+	taskIndex := make(map[string]int)
+	for i, eachTaskName := range fg.correlations.taskNames {
+		taskIndex[eachTaskName] = i
+	}
+	for _, eachPair := range fg.correlations.pairs {
+		log.Info("Task correlation",
+			"tasks", fmt.Sprintf("%v", eachPair.taskNames),
+			"target", fmt.Sprintf("%.2f", eachPair.rho),
+			"achieved", fmt.Sprintf("%.2f", achieved[taskIndex[eachPair.taskNames[0]]][taskIndex[eachPair.taskNames[1]]]))
+	}
+	for i := range fg.correlations.taskNames {
+		for j := i + 1; j < len(fg.correlations.taskNames); j++ {
+			log.Debug("Achieved rank correlation",
+				"tasks", fmt.Sprintf("[%s %s]", fg.correlations.taskNames[i], fg.correlations.taskNames[j]),
+				"achieved", fmt.Sprintf("%.2f", achieved[i][j]))
+		}
+	}
+}
+
+// encodeD2Correlations writes the achieved rank correlation matrix as a
+// markdown table node
+func (fg *flowGraph) encodeD2Correlations(nodeName string, output io.StringWriter) error {
+	taskNames := fg.correlations.taskNames
+	if len(taskNames) <= 0 {
+		return nil
+	}
+	achieved := fg.achievedCorrelations()
+	tableContents := fmt.Sprintf("%s : |||md\n# Achieved Rank Correlation\n\n| |", nodeName)
+	tableSeparator := "|---|"
+	for _, eachTaskName := range taskNames {
+		tableContents += fmt.Sprintf(" %s |", eachTaskName)
+		tableSeparator += "---|"
+	}
+	tableContents += "\n" + tableSeparator + "\n"
+	for i, eachTaskName := range taskNames {
+		tableContents += fmt.Sprintf("| **%s** |", eachTaskName)
+		for j := range taskNames {
+			tableContents += fmt.Sprintf(" %.2f |", achieved[i][j])
+		}
+		tableContents += "\n"
+	}
+	tableContents += "|||\n\n"
+	_, writeErr := output.WriteString(tableContents)
+	return writeErr
+}
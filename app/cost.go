@@ -0,0 +1,342 @@
+package app
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/mweagle/goestimate/generator"
+	"github.com/mweagle/goestimate/plan"
+	"github.com/mweagle/goestimate/stats"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Cost estimation
+//
+// Tasks can carry an optional cost model made up of a fixed cost, a rate
+// that is multiplied by the task's sampled duration and a cost
+// distribution. Per-run costs are accumulated through the graph like the
+// cumulative durations, except that parallel branches add their costs
+// rather than taking the longest branch. Choices only add the cost of the
+// branch chosen in each run.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// taskCost is a task's cost model
+type taskCost struct {
+	fixed     float64
+	rate      float64
+	generator generator.DurationGenerator
+	values    []float64
+	stats     *stats.AggregatedStatistics
+}
+
+// costEstimate is the plan level cost summary
+type costEstimate struct {
+	totalValues        []float64
+	totalStats         *stats.AggregatedStatistics
+	budget             float64
+	overrunProbability float64
+	scatterPath        string
+	// totalSummary and streamedScatter replace the total values of
+	// streaming evaluations. The scatter is the first chunk of runs.
+	totalSummary    *stats.Accumulator
+	streamedScatter plotter.XYs
+}
+
+func (tc *taskCost) Name() string {
+	costTerms := make([]string, 0)
+	if tc.fixed != 0 {
+		costTerms = append(costTerms, fmt.Sprintf("%.2f", tc.fixed))
+	}
+	if tc.rate != 0 {
+		costTerms = append(costTerms, fmt.Sprintf("%.2f × duration", tc.rate))
+	}
+	if tc.generator != nil {
+		costTerms = append(costTerms, tc.generator.Name())
+	}
+	return strings.Join(costTerms, " + ")
+}
+
+// unmarshalTaskCost returns a task's cost model. The fixed cost, the rate
+// cost and the cost generator are summed.
+func unmarshalTaskCost(costDef *plan.Cost, log *slog.Logger) (*taskCost, error) {
+	if costDef == nil {
+		return nil, nil
+	}
+	cost := &taskCost{
+		fixed: costDef.Fixed,
+		rate:  costDef.Rate,
+	}
+	if len(costDef.Type) != 0 {
+		costGenerator, costGeneratorErr := generator.NewDurationGeneratorFromExpression(costDef.Type, log)
+		if costGeneratorErr != nil {
+			return nil, costDef.Field("type").Wrap(costGeneratorErr)
+		}
+		cost.generator = costGenerator
+	}
+	return cost, nil
+}
+
+// hasCosts returns true if any task has a cost model
+func (fg *flowGraph) hasCosts() bool {
+	allNodes := fg.WeightedDirectedGraph.Nodes()
+	for allNodes.Next() {
+		taskNode, taskNodeOk := allNodes.Node().(*flowGraphNode)
+		if taskNodeOk && taskNode.cost != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateCosts samples each task's cost and accumulates the per-run
+// totals through the graph
+func (fg *flowGraph) evaluateCosts(topoOrder []int64,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) error {
+
+	runCount := int(fg.startNode.runCount)
+	costValues := make(map[int64][]float64, len(topoOrder))
+	for _, nodeID := range topoOrder {
+		switch typedNode := fg.WeightedDirectedGraph.Node(nodeID).(type) {
+		case *flowGraphStartNode:
+			costValues[nodeID] = make([]float64, runCount)
+		case *flowGraphPassThroughNode:
+			predecessorIter := fg.WeightedDirectedGraph.To(nodeID)
+			if predecessorIter.Len() != 1 {
+				return fmt.Errorf("invalid predecessor count for passthrough: %d", predecessorIter.Len())
+			}
+			predecessorIter.Next()
+			costValues[nodeID] = costValues[predecessorIter.Node().ID()]
+		case *flowGraphNode:
+			predecessorIter := fg.WeightedDirectedGraph.To(nodeID)
+			if predecessorIter.Len() != 1 {
+				return fmt.Errorf("invalid predecessor count for task %s: %d", typedNode.name, predecessorIter.Len())
+			}
+			predecessorIter.Next()
+			priorCosts := costValues[predecessorIter.Node().ID()]
+			if typedNode.cost == nil {
+				costValues[nodeID] = priorCosts
+				continue
+			}
+			// Each iteration of a repeated task adds its fixed cost
+			var iterations []int
+			repeatGenerator, repeatGeneratorOk := typedNode.generator.(*generator.RepeatGenerator)
+			if repeatGeneratorOk {
+				iterations = repeatGenerator.Iterations()
+			}
+			taskCosts, taskCostsErr := typedNode.cost.sample(*typedNode.GenerationResults().RawValues,
+				iterations,
+				percentiles,
+				stats.NodeIntervalMethod(fg.intervals),
+				src,
+				log)
+			if taskCostsErr != nil {
+				return taskCostsErr
+			}
+			cumulativeCosts := make([]float64, runCount)
+			for i := range cumulativeCosts {
+				cumulativeCosts[i] = priorCosts[i] + taskCosts[i]
+			}
+			costValues[nodeID] = cumulativeCosts
+		case *flowGraphJoinMaxValueNode:
+			owningSubgraph := typedNode.parentFlowSubgraphs[len(typedNode.parentFlowSubgraphs)-1]
+			entryCosts := costValues[owningSubgraph.inputNode.ID()]
+			joinedCosts := make([]float64, runCount)
+			copy(joinedCosts, entryCosts)
+			choiceGenerator, choiceGeneratorOk := typedNode.generator.(*generator.ChoiceGenerator)
+			for _, eachPredecessor := range sortedByID(fg.WeightedDirectedGraph.To(nodeID)) {
+				predecessorID := eachPredecessor.ID()
+				predecessorCosts := costValues[predecessorID]
+				for i := range joinedCosts {
+					if choiceGeneratorOk && choiceGenerator.Chosen()[i] != predecessorID {
+						continue
+					}
+					joinedCosts[i] += predecessorCosts[i] - entryCosts[i]
+				}
+			}
+			// Each iteration of a repeated subgraph adds the cost of its body
+			repeatedGenerator, repeatedGeneratorOk := typedNode.generator.(interface{ Iterations() []int })
+			if repeatedGeneratorOk && repeatedGenerator.Iterations() != nil {
+				iterations := repeatedGenerator.Iterations()
+				if len(iterations) != runCount {
+					return fmt.Errorf("invalid iteration count for join nodeId: %d. Expected: %d, Found: %d", nodeID, runCount, len(iterations))
+				}
+				for i, eachCount := range iterations {
+					joinedCosts[i] = entryCosts[i] + float64(eachCount)*(joinedCosts[i]-entryCosts[i])
+				}
+			}
+			costValues[nodeID] = joinedCosts
+		default:
+			return fmt.Errorf("invalid node type for cost evaluation: %T", typedNode)
+		}
+	}
+	totalValues := costValues[fg.outputJoinNode.ID()]
+	fg.costs.totalValues = totalValues
+	fg.costs.totalStats = stats.StatsWithIntervals(totalValues, percentiles, fg.intervals)
+	if fg.costs.budget > 0 {
+		overrunCount := 0
+		for _, eachCost := range totalValues {
+			if eachCost > fg.costs.budget {
+				overrunCount++
+			}
+		}
+		fg.costs.overrunProbability = float64(overrunCount) / float64(len(totalValues))
+	}
+	log.Debug("Cost evaluation complete", "runs", runCount)
+	return nil
+}
+
+// sample returns the per-run cost of the task for the sampled durations.
+// The fixed cost is charged once per iteration of repeated tasks, whose
+// durations sum their iterations.
+func (tc *taskCost) sample(durations []float64,
+	iterations []int,
+	percentiles []float64,
+	intervals string,
+	src rand.Source,
+	log *slog.Logger) ([]float64, error) {
+
+	tc.values = make([]float64, len(durations))
+	if iterations != nil && len(iterations) != len(durations) {
+		return nil, fmt.Errorf("invalid iteration count for cost. Expected: %d, Found: %d", len(durations), len(iterations))
+	}
+	for i, eachDuration := range durations {
+		fixedCost := tc.fixed
+		if iterations != nil {
+			fixedCost *= float64(iterations[i])
+		}
+		tc.values[i] = fixedCost + tc.rate*eachDuration
+	}
+	if tc.generator != nil {
+		zeroValues := make([]float64, len(durations))
+		priorSamples := map[int64]*generator.GenerationResults{
+			0: {
+				RawValues:        &zeroValues,
+				CumulativeValues: &zeroValues,
+			},
+		}
+		costResults, costResultsErr := tc.generator.Generate(priorSamples, percentiles, src, log)
+		if costResultsErr != nil {
+			return nil, costResultsErr
+		}
+		for i, eachCost := range *costResults.RawValues {
+			tc.values[i] += eachCost
+		}
+	}
+	tc.stats = stats.StatsWithIntervals(tc.values, percentiles, intervals)
+	return tc.values, nil
+}
+
+func (fg *flowGraph) logCosts(log *slog.Logger) {
+	if fg.costs.totalStats == nil {
+		return
+	}
+	if fg.costs.budget > 0 {
+		log.Info("Cost estimate",
+			"cost", aggregatedStatsFormatter(fg.costs.totalStats),
+			"budget", fmt.Sprintf("%.2f", fg.costs.budget),
+			"overrun", fmt.Sprintf("%.2f%%", fg.costs.overrunProbability*100))
+	} else {
+		log.Info("Cost estimate", "cost", aggregatedStatsFormatter(fg.costs.totalStats))
+	}
+}
+
+// costScatterPath is the joint cost/duration plot path next to the
+// histogram
+func costScatterPath(histogramPath string) string {
+	return strings.TrimSuffix(histogramPath, filepath.Ext(histogramPath)) + "-cost" + filepath.Ext(histogramPath)
+}
+
+// costScatterValues returns each run's total duration and total cost
+func (fg *flowGraph) costScatterValues() plotter.XYs {
+	durationValues := *fg.outputJoinNode.GenerationResults().CumulativeValues
+	scatterValues := make(plotter.XYs, len(durationValues))
+	for i := range scatterValues {
+		scatterValues[i].X = durationValues[i]
+		scatterValues[i].Y = fg.costs.totalValues[i]
+	}
+	return scatterValues
+}
+
+// costScatterPlot plots each run's total cost against its total duration.
+// Streaming evaluations plot the runs of the first chunk.
+func (fg *flowGraph) costScatterPlot() (*plot.Plot, error) {
+	p := plot.New()
+	p.X.Label.Text = "Total Duration"
+	p.Y.Label.Text = "Total Cost"
+	p.Title.Text = "Cost vs Duration"
+	p.Title.TextStyle.Color = color.RGBA{B: 255, A: 255}
+
+	scatterValues := fg.costs.streamedScatter
+	if fg.streaming {
+		p.Title.Text = fmt.Sprintf("Cost vs Duration (first %d runs)", len(scatterValues))
+	} else {
+		scatterValues = fg.costScatterValues()
+	}
+	scatter, scatterErr := plotter.NewScatter(scatterValues)
+	if scatterErr != nil {
+		return nil, scatterErr
+	}
+	scatter.GlyphStyle.Shape = draw.CircleGlyph{}
+	scatter.GlyphStyle.Radius = vg.Points(1)
+	scatter.GlyphStyle.Color = color.RGBA{B: 255, A: 64}
+	p.Add(scatter)
+
+	// Draw the budget as a horizontal line
+	if fg.costs.budget > 0 {
+		budgetLine := plotter.NewFunction(func(_ float64) float64 {
+			return fg.costs.budget
+		})
+		budgetLine.LineStyle.Width = vg.Points(2)
+		budgetLine.LineStyle.Dashes = []vg.Length{vg.Points(3), vg.Points(3)}
+		budgetLine.LineStyle.Color = color.RGBA{R: 220, G: 20, B: 60, A: 255}
+		p.Add(budgetLine)
+		p.Legend.Add(fmt.Sprintf("Budget: %.2f", fg.costs.budget), budgetLine)
+	}
+	return p, nil
+}
+
+// encodeD2CostSummary writes the total cost statistics and the joint
+// cost/duration plot
+func (fg *flowGraph) encodeD2CostSummary(nodeName string, scatterNodeName string, output io.StringWriter) error {
+	nodeContents := fmt.Sprintf(`%s : |||md
+# Cost
+
+- **Total Cost**: %s
+`,
+		nodeName,
+		aggregatedStatsFormatter(fg.costs.totalStats))
+	if fg.costs.budget > 0 {
+		nodeContents += fmt.Sprintf("- **Budget**: %.2f\n- **Overrun Probability**: %.2f%%\n",
+			fg.costs.budget,
+			fg.costs.overrunProbability*100)
+	}
+	nodeContents += "|||\n\n"
+	// The plot only exists if the plan was evaluated with plots
+	if len(fg.costs.scatterPath) == 0 {
+		_, writeErr := output.WriteString(nodeContents)
+		return writeErr
+	}
+	nodeContents += fmt.Sprintf(`%s: Cost vs Duration {
+shape: image
+icon: %s
+width: 768
+height: 768
+}
+`,
+		scatterNodeName,
+		fg.costs.scatterPath)
+	_, writeErr := output.WriteString(nodeContents)
+	return writeErr
+}
@@ -0,0 +1,59 @@
+package app
+
+import "testing"
+
+// Each iteration of a repeated task charges its fixed cost, like each
+// iteration of a repeated subgraph charges the cost of its body
+func TestRepeatedFixedCost(t *testing.T) {
+	testCases := map[string]string{
+		"task": `{
+			"name": "Repeated",
+			"runCount": 2000,
+			"activities": {
+				"tasks": [{"name": "Rework", "type": "Fixed(1)", "repeat": "Geometric(0.4)", "cost": 100}]
+			}
+		}`,
+		"subgraph": `{
+			"name": "Repeated",
+			"runCount": 2000,
+			"activities": {
+				"subgraph: ": {
+					"name": "Rework",
+					"repeat": "Geometric(0.4)",
+					"activities": {
+						"tasks": [{"name": "Iteration", "type": "Fixed(1)", "cost": 100}]
+					}
+				}
+			}
+		}`,
+	}
+	for name, eachPlan := range testCases {
+		t.Run(name, func(t *testing.T) {
+			evaluation := evaluateTestPlan(t, eachPlan, EvaluateOptions{Seed: 7})
+			// Each iteration takes one day, so each run's duration is its
+			// iteration count
+			durations := evaluation.Samples()
+			for i, eachCost := range evaluation.CostSamples() {
+				if eachCost != 100*durations[i] {
+					t.Fatalf("invalid cost of run %d. Expected: %v, Found: %v", i, 100*durations[i], eachCost)
+				}
+			}
+			expectNear(t, "iterations", evaluation.Summary().Duration.Mean, 2.5, 0.1)
+		})
+	}
+}
+
+func TestRepeatedRateCost(t *testing.T) {
+	evaluation := evaluateTestPlan(t, `{
+		"name": "Repeated",
+		"runCount": 1000,
+		"activities": {
+			"tasks": [{"name": "Review", "type": "Fixed(2)", "repeat": "Fixed(3)", "cost": {"fixed": 50, "rate": 10}}]
+		}
+	}`, EvaluateOptions{Seed: 7})
+	for i, eachCost := range evaluation.CostSamples() {
+		if eachCost != 3*50+10*6 {
+			t.Fatalf("invalid cost of run %d. Expected: %v, Found: %v", i, 3*50+10*6, eachCost)
+		}
+	}
+}
@@ -1,11 +1,9 @@
 package app
 
 import (
-	"cmp"
 	"fmt"
 	"io"
 	"log/slog"
-	"slices"
 	"strings"
 
 	"gonum.org/v1/gonum/graph"
@@ -35,6 +33,7 @@ type D2Connection struct {
 	from         string
 	to           string
 	cost         float64
+	label        string
 	criticalPath bool
 }
 
@@ -58,6 +57,10 @@ func (d2enc *D2EncodingVisitor) encodeNode(output io.StringWriter, node D2Encode
 	d2enc.log.Debug("Encoding node", "type", fmt.Sprintf("%T", node), "id", node.ID())
 	d2enc.visited[node.ID()] = 1
 	indent := strings.Repeat("\t", len(node.AbsoluteNodePath())-1)
+	loopConnection := d2enc.repeatLoopConnection(node)
+	if loopConnection != nil {
+		d2enc.connectionsList = append(d2enc.connectionsList, loopConnection)
+	}
 	return node.D2Encode(output, indent, d2enc.log)
 }
 
@@ -72,10 +75,10 @@ func (d2enc *D2EncodingVisitor) fullConnectionPathForNode(node D2Encoder) string
 	return strings.TrimPrefix(idPath, rootGraphIDPrefix)
 }
 
-func (d2enc *D2EncodingVisitor) createConnection(fromNode D2Encoder, toNode D2Encoder) {
+func (d2enc *D2EncodingVisitor) createConnection(fromNode D2Encoder, toNode D2Encoder) *D2Connection {
 	// No self-connections
 	if fromNode.ID() == toNode.ID() {
-		return
+		return nil
 	}
 	connectionCost := float64(0)
 	flowGraphSrcNode, flowGraphSrcNodeOk := fromNode.(*flowGraphNode)
@@ -87,12 +90,14 @@ func (d2enc *D2EncodingVisitor) createConnection(fromNode D2Encoder, toNode D2En
 	toPath := d2enc.fullConnectionPathForNode(toNode)
 
 	d2enc.log.Debug("Creating connection", "from", fromPath, "to", toPath)
-	d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+	connection := &D2Connection{
 		from:         fromPath,
 		to:           toPath,
 		cost:         connectionCost,
 		criticalPath: criticalPathEdge,
-	})
+	}
+	d2enc.connectionsList = append(d2enc.connectionsList, connection)
+	return connection
 }
 
 func (d2enc *D2EncodingVisitor) encodeDirectChildren(output io.StringWriter,
@@ -150,9 +155,9 @@ func (d2enc *D2EncodingVisitor) recursiveEncode(output io.StringWriter,
 	subgraphNodes := make([]*flowGraphPassThroughNode, 0)
 	atomicSuccessorNodes := make([]D2Encoder, 0)
 
-	successorIter := d2enc.owningGraph.From(fromNode.ID())
-	for successorIter.Next() {
-		switch typedNode := successorIter.Node().(type) {
+	// Successors are visited in ID order, which is the plan document order
+	for _, eachSuccessor := range sortedByID(d2enc.owningGraph.From(fromNode.ID())) {
+		switch typedNode := eachSuccessor.(type) {
 		case *flowGraphPassThroughNode: // Denotes entering a subgraph...
 			subgraphNodes = append(subgraphNodes, typedNode)
 		case D2Encoder:
@@ -160,11 +165,6 @@ func (d2enc *D2EncodingVisitor) recursiveEncode(output io.StringWriter,
 		}
 	}
 
-	// Sort them by name
-	slices.SortFunc(subgraphNodes, func(a, b *flowGraphPassThroughNode) int {
-		return cmp.Compare(strings.ToLower(a.name), strings.ToLower(b.name))
-	})
-
 	d2enc.log.Debug("recursiveEncode Node",
 		"depth", subgraphDepth(),
 		"ID", fromNode.ID(),
@@ -178,10 +178,22 @@ func (d2enc *D2EncodingVisitor) recursiveEncode(output io.StringWriter,
 		// What's the join node for this subgraph?
 		parentSubgraph := subgraphNode.parentFlowSubgraphs[len(subgraphNode.parentFlowSubgraphs)-1]
 		subgraphJoinNode := parentSubgraph.outputJoinNode
-		d2enc.createConnection(fromNode, subgraphNode)
+		branchConnection := d2enc.createConnection(fromNode, subgraphNode)
+		if branchConnection != nil {
+			branchConnection.label = choiceBranchLabel(fromNode, subgraphJoinNode.ID())
+		}
 		d2enc.createConnection(subgraphJoinNode, outputJoinNode)
 
+		subgraphLabel := subgraphNode.name
+		repeatCount, meanIterations := parentSubgraph.repeatCount()
+		if repeatCount != nil {
+			subgraphLabel = fmt.Sprintf("%s ⟳ %s", subgraphLabel, repeatAnnotation(repeatCount, meanIterations))
+		}
 		if subgraphDepth() > 0 {
+			loopConnection := d2enc.repeatLoopConnection(subgraphNode)
+			if loopConnection != nil {
+				d2enc.connectionsList = append(d2enc.connectionsList, loopConnection)
+			}
 			_, writeErr = output.WriteString(fmt.Sprintf(`%s%d: %s {
 				style: {
 					border-radius: 20
@@ -189,7 +201,7 @@ func (d2enc *D2EncodingVisitor) recursiveEncode(output io.StringWriter,
 	`,
 				autoIndent(),
 				subgraphNode.ID(),
-				subgraphNode.name))
+				subgraphLabel))
 
 			if writeErr != nil {
 				return writeErr
@@ -247,31 +259,173 @@ func (d2enc *D2EncodingVisitor) Encode(graph *flowGraph, histogramPath string, o
 		return nil
 	}
 
-	// Then write out the histogram node and add a link to the output join node
+	// Then write out the histogram node and add a link to the output join node.
+	// Plans evaluated without plots have no histogram to link to.
 	histogramNodeName := "histogram_summary"
-	_, writeErr = output.WriteString(fmt.Sprintf(`%s: Estimated Completion {
+	if len(histogramPath) != 0 {
+		_, writeErr = output.WriteString(fmt.Sprintf(`%s: Estimated Completion {
 shape: image
 icon: %s
 width: 768
 height: 768
 }
 `,
-		histogramNodeName,
-		histogramPath))
-	if writeErr != nil {
-		return writeErr
+			histogramNodeName,
+			histogramPath))
+		if writeErr != nil {
+			return writeErr
+		}
+		d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+			from:         fmt.Sprintf("%d", graph.outputJoinNode.ID()),
+			to:           histogramNodeName,
+			cost:         0,
+			criticalPath: false,
+		})
+	}
+
+	// The risk register, if there is one, hangs off the output join node
+	riskRegisterNodeName := "risk_register"
+	if len(graph.risks) != 0 {
+		writeErr = graph.encodeD2RiskRegister(riskRegisterNodeName, output)
+		if writeErr != nil {
+			return writeErr
+		}
+		d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+			from:         fmt.Sprintf("%d", graph.outputJoinNode.ID()),
+			to:           riskRegisterNodeName,
+			cost:         0,
+			criticalPath: false,
+		})
+	}
+
+	// As does the achieved correlation matrix
+	correlationNodeName := "correlation_matrix"
+	if len(graph.correlations.taskNames) != 0 {
+		writeErr = graph.encodeD2Correlations(correlationNodeName, output)
+		if writeErr != nil {
+			return writeErr
+		}
+		d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+			from:         fmt.Sprintf("%d", graph.outputJoinNode.ID()),
+			to:           correlationNodeName,
+			cost:         0,
+			criticalPath: false,
+		})
+	}
+
+	// And the resource constrained schedule
+	resourceScheduleNodeName := "resource_schedule"
+	if graph.resourceSchedule != nil {
+		writeErr = graph.encodeD2ResourceSchedule(resourceScheduleNodeName, output)
+		if writeErr != nil {
+			return writeErr
+		}
+		d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+			from:         fmt.Sprintf("%d", graph.outputJoinNode.ID()),
+			to:           resourceScheduleNodeName,
+			cost:         0,
+			criticalPath: false,
+		})
+	}
+
+	// And the cost summary with the joint cost/duration plot
+	costNodeName := "cost_summary"
+	costScatterNodeName := "cost_scatter"
+	if graph.costs.totalStats != nil {
+		writeErr = graph.encodeD2CostSummary(costNodeName, costScatterNodeName, output)
+		if writeErr != nil {
+			return writeErr
+		}
+		d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+			from:         fmt.Sprintf("%d", graph.outputJoinNode.ID()),
+			to:           costNodeName,
+			cost:         0,
+			criticalPath: false,
+		})
+		if len(graph.costs.scatterPath) != 0 {
+			d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+				from:         costNodeName,
+				to:           costScatterNodeName,
+				cost:         0,
+				criticalPath: false,
+			})
+		}
+	}
+
+	// And the precision of auto run counts with the convergence plot
+	convergenceNodeName := "convergence_summary"
+	convergencePlotNodeName := "convergence_plot"
+	if graph.convergence != nil {
+		writeErr = graph.encodeD2Convergence(convergenceNodeName, convergencePlotNodeName, output)
+		if writeErr != nil {
+			return writeErr
+		}
+		d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+			from:         fmt.Sprintf("%d", graph.outputJoinNode.ID()),
+			to:           convergenceNodeName,
+			cost:         0,
+			criticalPath: false,
+		})
+		if len(graph.convergence.plotPath) != 0 {
+			d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+				from:         convergenceNodeName,
+				to:           convergencePlotNodeName,
+				cost:         0,
+				criticalPath: false,
+			})
+		}
+	}
+
+	// And the ranked task sensitivity with the tornado chart
+	sensitivityNodeName := "sensitivity_summary"
+	sensitivityPlotNodeName := "sensitivity_plot"
+	if graph.sensitivity != nil {
+		writeErr = graph.encodeD2Sensitivity(sensitivityNodeName, sensitivityPlotNodeName, output)
+		if writeErr != nil {
+			return writeErr
+		}
+		d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+			from:         fmt.Sprintf("%d", graph.outputJoinNode.ID()),
+			to:           sensitivityNodeName,
+			cost:         0,
+			criticalPath: false,
+		})
+		if len(graph.sensitivity.plotPath) != 0 {
+			d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+				from:         sensitivityNodeName,
+				to:           sensitivityPlotNodeName,
+				cost:         0,
+				criticalPath: false,
+			})
+		}
+	}
+
+	// And the comparison of the what-if scenarios with their overlaid CDFs
+	scenariosNodeName := "scenarios_summary"
+	scenariosPlotNodeName := "scenarios_plot"
+	if graph.scenarios != nil {
+		writeErr = graph.encodeD2Scenarios(scenariosNodeName, scenariosPlotNodeName, output)
+		if writeErr != nil {
+			return writeErr
+		}
+		d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+			from:         fmt.Sprintf("%d", graph.outputJoinNode.ID()),
+			to:           scenariosNodeName,
+			cost:         0,
+			criticalPath: false,
+		})
+		if len(graph.scenarios.plotPath) != 0 {
+			d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
+				from:         scenariosNodeName,
+				to:           scenariosPlotNodeName,
+				cost:         0,
+				criticalPath: false,
+			})
+		}
 	}
-	d2enc.connectionsList = append(d2enc.connectionsList, &D2Connection{
-		from:         fmt.Sprintf("%d", graph.outputJoinNode.ID()),
-		to:           histogramNodeName,
-		cost:         0,
-		criticalPath: false,
-	})
 
 	// At this point we have the flowInputNode which is the top level subgraph
-	successorNodesIter := graph.WeightedDirectedGraph.From(graph.startNode.ID())
-	for successorNodesIter.Next() {
-		targetNode := successorNodesIter.Node()
+	for _, targetNode := range sortedByID(graph.WeightedDirectedGraph.From(graph.startNode.ID())) {
 		d2enc.createConnection(graph.startNode, targetNode.(D2Encoder))
 
 		// Encode this node
@@ -295,7 +449,9 @@ height: 768
 	for i := 0; i != len(d2enc.connectionsList); i++ {
 		connection := d2enc.connectionsList[i]
 		costSuffix := ""
-		if connection.cost != 0 {
+		if len(connection.label) != 0 {
+			costSuffix = fmt.Sprintf(" : %s", connection.label)
+		} else if connection.cost != 0 {
 			costSuffix = fmt.Sprintf(" : %.2f", connection.cost)
 		}
 		styleSuffix := ""
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"image/color"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/mweagle/goestimate/plan"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// DEADLINE_DATE_FORMAT is the layout for calendar date deadlines
+var DEADLINE_DATE_FORMAT = plan.DateFormat
+
+// DEFAULT_DEADLINE_THRESHOLD is the probability below which a join node's
+// deadline is flagged
+var DEFAULT_DEADLINE_THRESHOLD = 0.8
+
+// /////////////////////////////////////////////////////////////////////////////
+// flowDeadline
+//
+// A target completion for the plan or a subgraph. Deadlines are either
+// expressed in duration units or as a calendar date, which is converted to
+// duration units from the creation time. Plans that use workdays count
+// workdays to the date, otherwise calendar days are used.
+//
+// /////////////////////////////////////////////////////////////////////////////
+type flowDeadline struct {
+	date           *time.Time
+	offset         float64
+	probability    float64
+	belowThreshold bool
+}
+
+func (fd *flowDeadline) String() string {
+	if fd.date != nil {
+		return fmt.Sprintf("%s (%.2f)", fd.date.Format(DEADLINE_DATE_FORMAT), fd.offset)
+	}
+	return fmt.Sprintf("%.2f", fd.offset)
+}
+
+// unmarshalDeadline sets the optional deadline for the subgraph
+func (fsg *flowSubgraph) unmarshalDeadline(deadlineDef *plan.Deadline) error {
+	if deadlineDef == nil {
+		return nil
+	}
+	deadline := &flowDeadline{
+		offset: deadlineDef.Duration,
+	}
+	if len(deadlineDef.Date) != 0 {
+		deadlineDate, deadlineDateErr := time.ParseInLocation(DEADLINE_DATE_FORMAT, deadlineDef.Date, nowTime.Location())
+		if deadlineDateErr != nil {
+			return fmt.Errorf("invalid deadline date: %s. Dates must be formatted as %s", deadlineDef.Date, DEADLINE_DATE_FORMAT)
+		}
+		deadline.date = &deadlineDate
+		deadline.offset = dateOffset(deadlineDate, fsg.aggregationOptions)
+	}
+	fsg.deadline = deadline
+	return nil
+}
+
+// dateOffset converts a date into duration units from the creation time,
+// in workdays of the calendar if the plan uses workdays
+func dateOffset(date time.Time, aggregationOptions *AggregationOptions) float64 {
+	if aggregationOptions != nil && aggregationOptions.workdays {
+		return float64(workdaysUntil(aggregationOptions.calendar, date))
+	}
+	return date.Sub(nowTime).Hours() / 24
+}
+
+// deadlineSubgraphs returns the plan and every subgraph that has a deadline
+func (fg *flowGraph) deadlineSubgraphs() []*flowSubgraph {
+	deadlineSubgraphs := make([]*flowSubgraph, 0)
+	for _, eachNode := range fg.sortedNodes() {
+		joinNode, joinNodeOk := eachNode.(*flowGraphJoinMaxValueNode)
+		if !joinNodeOk {
+			continue
+		}
+		owningSubgraph := joinNode.parentFlowSubgraphs[len(joinNode.parentFlowSubgraphs)-1]
+		if owningSubgraph.deadline != nil {
+			deadlineSubgraphs = append(deadlineSubgraphs, owningSubgraph)
+		}
+	}
+	// Nearest deadline first
+	slices.SortStableFunc(deadlineSubgraphs, func(a, b *flowSubgraph) int {
+		return cmp.Compare(a.deadline.offset, b.deadline.offset)
+	})
+	return deadlineSubgraphs
+}
+
+// deadlineMetCount returns the number of runs that complete the subgraph
+// by its deadline
+func (fsg *flowSubgraph) deadlineMetCount() int {
+	metCount := 0
+	for _, eachValue := range *fsg.outputJoinNode.GenerationResults().CumulativeValues {
+		if eachValue <= fsg.deadline.offset {
+			metCount++
+		}
+	}
+	return metCount
+}
+
+// computeDeadlines computes the probability that each subgraph completes
+// by its deadline
+func (fg *flowGraph) computeDeadlines(log *slog.Logger) {
+	runCount := float64(fg.startNode.runCount)
+	for _, eachSubgraph := range fg.deadlineSubgraphs() {
+		metCount := 0
+		if fg.streaming {
+			metCount = fg.streamedAnalysis.deadlineMetCounts[eachSubgraph.outputJoinNode.ID()]
+		} else {
+			metCount = eachSubgraph.deadlineMetCount()
+		}
+		eachSubgraph.deadline.probability = float64(metCount) / runCount
+		eachSubgraph.deadline.belowThreshold = eachSubgraph.deadline.probability < fg.deadlineThreshold
+		subgraphName := eachSubgraph.inputNode.name
+		if eachSubgraph == fg.flowSubgraph {
+			subgraphName = fg.name
+		}
+		log.Info("Deadline",
+			"name", subgraphName,
+			"deadline", eachSubgraph.deadline.String(),
+			"probability", fmt.Sprintf("%.2f%%", eachSubgraph.deadline.probability*100),
+			"belowThreshold", eachSubgraph.deadline.belowThreshold)
+	}
+}
+
+// addDeadlineLine draws the plan deadline as a vertical line on the
+// distribution plot
+func (fg *flowGraph) addDeadlineLine(p *plot.Plot) error {
+	deadline := fg.flowSubgraph.deadline
+	if deadline == nil {
+		return nil
+	}
+	line, lineErr := plotter.NewLine(plotter.XYs{
+		{X: deadline.offset, Y: 0},
+		{X: deadline.offset, Y: 1},
+	})
+	if lineErr != nil {
+		return lineErr
+	}
+	line.LineStyle.Width = vg.Points(2)
+	line.LineStyle.Color = color.RGBA{R: 220, G: 20, B: 60, A: 255}
+	p.Add(line)
+	p.Legend.Add(fmt.Sprintf("Deadline %s: P=%.2f%%", deadline.String(), deadline.probability*100), line)
+	return nil
+}
+
+// encodeD2DeadlineStyle marks the join node whose deadline probability is
+// below the threshold
+func (fgj *flowGraphJoinMaxValueNode) encodeD2DeadlineStyle(output io.StringWriter) error {
+	owningSubgraph := fgj.parentFlowSubgraphs[len(fgj.parentFlowSubgraphs)-1]
+	if owningSubgraph.deadline == nil || !owningSubgraph.deadline.belowThreshold {
+		return nil
+	}
+	_, writeErr := output.WriteString(fmt.Sprintf("%d.style.stroke: crimson\n%d.style.stroke-width: 4\n\n",
+		fgj.ID(),
+		fgj.ID()))
+	return writeErr
+}
+
+// deadlineAnnotation is the join node label for the subgraph's deadline
+func (fsg *flowSubgraph) deadlineAnnotation() string {
+	annotation := fmt.Sprintf("≤ %s: %.2f%%", fsg.deadline.String(), fsg.deadline.probability*100)
+	if fsg.deadline.belowThreshold {
+		annotation += " ⚠"
+	}
+	return annotation
+}
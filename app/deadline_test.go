@@ -0,0 +1,66 @@
+package app
+
+import "testing"
+
+// deadlinePlan completes its subgraph at 1 + U(0, 1)
+const deadlinePlan = `{
+	"name": "Launch",
+	"runCount": 20000,
+	"deadline": 1.9,
+	"activities": {
+		"launch": {
+			"name": "Beta",
+			"deadline": 1.25,
+			"activities": {
+				"tasks": [
+					{"name": "Setup", "type": "Fixed(1)"},
+					{"name": "Build", "type": "Beta(1, 1)"}
+				]
+			}
+		}
+	}
+}`
+
+func TestDeadlineProbabilities(t *testing.T) {
+	for name, eachOpts := range map[string]EvaluateOptions{
+		"samples":   {Seed: 7},
+		"streaming": {Seed: 7, Streaming: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			summary := evaluateTestPlan(t, deadlinePlan, eachOpts).Summary()
+			expected := map[string]struct {
+				probability    float64
+				belowThreshold bool
+			}{
+				"Launch": {0.9, false},
+				"Beta":   {0.25, true},
+			}
+			if len(summary.Deadlines) != len(expected) {
+				t.Fatalf("invalid deadline count. Expected: %d, Found: %d", len(expected), len(summary.Deadlines))
+			}
+			for _, eachDeadline := range summary.Deadlines {
+				eachExpected := expected[eachDeadline.Name]
+				expectNear(t, eachDeadline.Name+" probability", eachDeadline.Probability, eachExpected.probability, 0.015)
+				if eachDeadline.BelowThreshold != eachExpected.belowThreshold {
+					t.Errorf("invalid %s belowThreshold. Expected: %v", eachDeadline.Name, eachExpected.belowThreshold)
+				}
+			}
+		})
+	}
+}
+
+// The plan deadline probability is the fraction of runs that complete by it
+func TestDeadlineProbabilityMatchesSamples(t *testing.T) {
+	evaluation := evaluateTestPlan(t, deadlinePlan, EvaluateOptions{Seed: 7})
+	metCount := 0
+	for _, eachSample := range evaluation.Samples() {
+		if eachSample <= 1.9 {
+			metCount++
+		}
+	}
+	for _, eachDeadline := range evaluation.Summary().Deadlines {
+		if eachDeadline.Name == "Launch" && eachDeadline.Probability != float64(metCount)/20000 {
+			t.Errorf("invalid plan deadline probability. Expected: %v, Found: %v", float64(metCount)/20000, eachDeadline.Probability)
+		}
+	}
+}
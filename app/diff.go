@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"text/tabwriter"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Diff
+//
+// `goestimate diff` evaluates two plans with the same seed and reports how
+// the total duration changed between them.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// DiffMetric is a single summary statistic of both plans
+type DiffMetric struct {
+	Metric   string
+	Baseline float64
+	Proposed float64
+}
+
+// Delta is the change from the baseline
+func (dm *DiffMetric) Delta() float64 {
+	return dm.Proposed - dm.Baseline
+}
+
+// DeltaPercent is the change as a percentage of the baseline
+func (dm *DiffMetric) DeltaPercent() float64 {
+	if dm.Baseline == 0 {
+		return math.NaN()
+	}
+	return dm.Delta() / dm.Baseline * 100
+}
+
+// DiffReport compares a proposed plan against a baseline plan
+type DiffReport struct {
+	BaselineName string
+	ProposedName string
+	Metrics      []*DiffMetric
+}
+
+// WriteTable writes the comparison table
+func (dr *DiffReport) WriteTable(output io.Writer) error {
+	tw := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "METRIC\tBASELINE\tPROPOSED\tDELTA\tDELTA%%\n")
+	for _, eachMetric := range dr.Metrics {
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%+.2f\t%+.2f%%\n",
+			eachMetric.Metric,
+			eachMetric.Baseline,
+			eachMetric.Proposed,
+			eachMetric.Delta(),
+			eachMetric.DeltaPercent())
+	}
+	return tw.Flush()
+}
+
+// DiffApplicationFlowGraphs evaluates the baseline and proposed plans and
+// compares their total durations. The proposed plan uses the baseline's run
+// parameters.
+func DiffApplicationFlowGraphs(baselineParams *ApplicationFlowGraphParams,
+	proposedInputFile string,
+	log *slog.Logger) (*DiffReport, error) {
+
+	proposedParams := *baselineParams
+	proposedParams.InputFile = proposedInputFile
+	evaluatedGraphs := make([]*flowGraph, 0, 2)
+	for _, eachParams := range []*ApplicationFlowGraphParams{baselineParams, &proposedParams} {
+		appGraph, appGraphErr := openFlowGraph(eachParams, log)
+		if appGraphErr != nil {
+			return nil, appGraphErr
+		}
+		evalErr := appGraph.Evaluate(context.Background(), log)
+		if evalErr != nil {
+			return nil, evalErr
+		}
+		evaluatedGraphs = append(evaluatedGraphs, appGraph)
+	}
+	baselineGraph := evaluatedGraphs[0]
+	proposedGraph := evaluatedGraphs[1]
+
+	// The baseline's percentiles, plus any the proposed plan adds
+	metricNames := []string{"mean", "stddev", "median"}
+	for _, eachGraph := range evaluatedGraphs {
+		for _, eachPercentile := range eachGraph.outputJoinNode.GenerationResults().CumulativeStats.Percentiles {
+			metricName := percentileName(eachPercentile.P)
+			if !slices.Contains(metricNames, metricName) {
+				metricNames = append(metricNames, metricName)
+			}
+		}
+	}
+	report := &DiffReport{
+		BaselineName: baselineGraph.name,
+		ProposedName: proposedGraph.name,
+		Metrics:      make([]*DiffMetric, 0, len(metricNames)),
+	}
+	for _, eachName := range metricNames {
+		report.Metrics = append(report.Metrics, &DiffMetric{
+			Metric:   eachName,
+			Baseline: baselineGraph.outputMetric(eachName),
+			Proposed: proposedGraph.outputMetric(eachName),
+		})
+	}
+	return report, nil
+}
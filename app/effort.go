@@ -0,0 +1,54 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/mweagle/goestimate/generator"
+	"github.com/mweagle/goestimate/plan"
+)
+
+// unmarshalTaskGenerator returns the generator for a task. Tasks either
+// provide a `type` expression that is the elapsed duration, or an
+// `effort` expression together with the `staff` assigned to the task and an
+// optional communication `overhead`.
+func unmarshalTaskGenerator(task *plan.Task, log *slog.Logger) (generator.DurationGenerator, error) {
+	if len(task.Effort) == 0 {
+		if len(task.Type) == 0 {
+			return nil, task.Wrap(fmt.Errorf("invalid task: %s. Tasks must specify either a type or an effort", task.Name))
+		}
+		durGenerator, durGeneratorErr := generator.NewDurationGeneratorFromExpression(task.Type, log)
+		return durGenerator, task.Field("type").Wrap(durGeneratorErr)
+	}
+	staff := float64(1)
+	if task.Staff != nil {
+		staff = *task.Staff
+	}
+	effortGenerator, effortGeneratorErr := generator.NewEffortGenerator(task.Effort, staff, task.Overhead, log)
+	if effortGeneratorErr != nil {
+		return nil, task.Field("effort").Wrap(effortGeneratorErr)
+	}
+	return effortGenerator, nil
+}
+
+// effortParams are the D2 table rows that break an effort task into its
+// effort, staffing and the resulting elapsed duration
+func effortParams(effortGenerator *generator.EffortGenerator) []*d2TableParams {
+	return []*d2TableParams{
+		{
+			Key:   "Effort",
+			Value: aggregatedStatsFormatter(effortGenerator.Effort.GenerationResults().GeneratorStats),
+		},
+		{
+			Key: "Staffing",
+			Value: fmt.Sprintf("%g, overhead=%.2f, elapsed=effort×%.2f",
+				effortGenerator.Staff,
+				effortGenerator.Overhead,
+				effortGenerator.ElapsedFactor()),
+		},
+		{
+			Key:   "Elapsed",
+			Value: aggregatedStatsFormatter(effortGenerator.GenerationResults().GeneratorStats),
+		},
+	}
+}
@@ -0,0 +1,389 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/mweagle/goestimate/generator"
+	"github.com/mweagle/goestimate/plan"
+	"github.com/mweagle/goestimate/stats"
+	"gonum.org/v1/gonum/graph/simple"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Evaluation
+//
+// An evaluated plan. The evaluation exposes the typed results and writes
+// each of the outputs to an io.Writer.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// PLOT_SIZE is the width and height of the PNG plots
+var PLOT_SIZE = 12 * vg.Inch
+
+// EvaluateOptions are the run parameters that override the plan
+type EvaluateOptions struct {
+	// Seed seeds the random number source
+	Seed uint64
+	// RunCount overrides the plan's runCount if non-zero. Overridden auto
+	// run counts evaluate exactly RunCount runs.
+	RunCount uint64
+	// Workers is the number of run chunks evaluated concurrently. Zero uses
+	// every available CPU. Results don't depend on the number of workers.
+	Workers int
+	// Sampling overrides the plan's sampling strategy if not empty
+	Sampling string
+	// Intervals overrides the plan's confidence interval method if not
+	// empty
+	Intervals string
+	// Sensitivity overrides the plan's sensitivity method if not empty
+	Sensitivity string
+	// Streaming summarizes the runs without retaining the samples. Medians
+	// and percentiles are quantile sketch estimates and Samples,
+	// CostSamples and the NodeResult samples are empty.
+	Streaming bool
+}
+
+// Node kinds
+const (
+	NODE_KIND_PLAN     = "plan"
+	NODE_KIND_SUBGRAPH = "subgraph"
+	NODE_KIND_TASK     = "task"
+)
+
+// NodeResult is the evaluated duration of a task, subgraph or the plan.
+// Duration is the node's own duration and Completion is the elapsed time
+// when the node completes.
+type NodeResult struct {
+	ID           int64         `json:"id"`
+	Name         string        `json:"name"`
+	Kind         string        `json:"kind"`
+	Generator    string        `json:"generator,omitempty"`
+	Duration     *StatsSummary `json:"duration,omitempty"`
+	Completion   *StatsSummary `json:"completion"`
+	CriticalPath bool          `json:"criticalPath"`
+	// DurationSamples and CompletionSamples are the per run values
+	DurationSamples   []float64 `json:"-"`
+	CompletionSamples []float64 `json:"-"`
+}
+
+// Evaluation is an evaluated plan
+type Evaluation struct {
+	graph *flowGraph
+}
+
+// newEvaluationGraph builds the graph for the plan and applies the run
+// overrides. Plans that can't be built return an InvalidPlanError.
+func newEvaluationGraph(planDef *plan.Plan, opts EvaluateOptions, log *slog.Logger) (*flowGraph, error) {
+	appGraph, appGraphErr := newFlowGraph(planDef, log)
+	if appGraphErr != nil {
+		return nil, &InvalidPlanError{Err: appGraphErr}
+	}
+	if opts.RunCount != 0 {
+		appGraph.startNode.runCount = opts.RunCount
+		appGraph.convergence = nil
+	}
+	if len(opts.Sampling) != 0 {
+		samplingErr := generator.ValidateSamplingStrategy(opts.Sampling)
+		if samplingErr != nil {
+			return nil, samplingErr
+		}
+		appGraph.sampling = opts.Sampling
+	}
+	if len(opts.Intervals) != 0 {
+		intervalsErr := stats.ValidateIntervalMethod(opts.Intervals)
+		if intervalsErr != nil {
+			return nil, intervalsErr
+		}
+		appGraph.intervals = opts.Intervals
+	}
+	if len(opts.Sensitivity) != 0 {
+		sensitivityErr := ValidateSensitivityMethod(opts.Sensitivity)
+		if sensitivityErr != nil {
+			return nil, sensitivityErr
+		}
+		appGraph.sensitivity = newSensitivityAnalysis(opts.Sensitivity)
+	}
+	appGraph.seed = opts.Seed
+	appGraph.workers = opts.Workers
+	appGraph.streaming = opts.Streaming
+	// The scenarios are evaluated with the same run overrides
+	scenariosErr := appGraph.unmarshalScenarios(opts, log)
+	if scenariosErr != nil {
+		return nil, scenariosErr
+	}
+	return appGraph, nil
+}
+
+// EvaluatePlan evaluates the decoded plan. Evaluation stops early if the
+// context is done.
+func EvaluatePlan(ctx context.Context,
+	planDef *plan.Plan,
+	opts EvaluateOptions,
+	log *slog.Logger) (*Evaluation, error) {
+	appGraph, appGraphErr := newEvaluationGraph(planDef, opts, log)
+	if appGraphErr != nil {
+		return nil, appGraphErr
+	}
+	evalErr := appGraph.Evaluate(ctx, log)
+	if evalErr != nil {
+		return nil, evalErr
+	}
+	return &Evaluation{graph: appGraph}, nil
+}
+
+// Summary returns the summary statistics
+func (e *Evaluation) Summary() *Summary {
+	return e.graph.summary()
+}
+
+// Samples returns the total duration of each run. Streaming evaluations
+// don't retain the runs.
+func (e *Evaluation) Samples() []float64 {
+	return *e.graph.outputJoinNode.GenerationResults().CumulativeValues
+}
+
+// CostSamples returns the total cost of each run, if the plan has costs.
+// Streaming evaluations don't retain the runs.
+func (e *Evaluation) CostSamples() []float64 {
+	return e.graph.costs.totalValues
+}
+
+// Nodes returns the results of every task and subgraph, and the plan, in
+// document order
+func (e *Evaluation) Nodes() []*NodeResult {
+	fg := e.graph
+	nodes := make([]*NodeResult, 0)
+	for _, eachNode := range fg.sortedNodes() {
+		var nodeResult *NodeResult
+		switch typedNode := eachNode.(type) {
+		case *flowGraphNode:
+			genResults := typedNode.GenerationResults()
+			nodeResult = &NodeResult{
+				ID:                typedNode.ID(),
+				Name:              typedNode.name,
+				Kind:              NODE_KIND_TASK,
+				Duration:          newStatsSummary(genResults.GeneratorStats),
+				Completion:        newStatsSummary(genResults.CumulativeStats),
+				CriticalPath:      fg.criticalPathGraph.Node(typedNode.ID()) != nil,
+				DurationSamples:   derefSamples(genResults.RawValues),
+				CompletionSamples: derefSamples(genResults.CumulativeValues),
+			}
+			if typedNode.generator != nil {
+				nodeResult.Generator = typedNode.generator.Name()
+			}
+		case *flowGraphJoinMaxValueNode:
+			// Subgraphs are reported by their join, named by their entry
+			owningSubgraph := typedNode.parentFlowSubgraphs[len(typedNode.parentFlowSubgraphs)-1]
+			genResults := typedNode.GenerationResults()
+			nodeResult = &NodeResult{
+				ID:                typedNode.ID(),
+				Name:              owningSubgraph.inputNode.name,
+				Kind:              NODE_KIND_SUBGRAPH,
+				Generator:         typedNode.generator.Name(),
+				Duration:          newStatsSummary(genResults.GeneratorStats),
+				Completion:        newStatsSummary(genResults.CumulativeStats),
+				CriticalPath:      fg.criticalPathGraph.Node(typedNode.ID()) != nil,
+				DurationSamples:   derefSamples(genResults.RawValues),
+				CompletionSamples: derefSamples(genResults.CumulativeValues),
+			}
+			if owningSubgraph == fg.flowSubgraph {
+				nodeResult.Name = fg.name
+				nodeResult.Kind = NODE_KIND_PLAN
+			}
+		}
+		if nodeResult != nil {
+			nodes = append(nodes, nodeResult)
+		}
+	}
+	return nodes
+}
+
+func derefSamples(samples *[]float64) []float64 {
+	if samples == nil {
+		return nil
+	}
+	return *samples
+}
+
+// WriteJSON writes the summary statistics as JSON
+func (e *Evaluation) WriteJSON(output io.Writer) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(e.Summary())
+}
+
+// WriteD2 writes the D2 diagram. The diagram links to the distribution plot
+// at histogramPath, and the cost, convergence, sensitivity and scenario
+// plots next to it, if the path is not empty.
+func (e *Evaluation) WriteD2(output io.Writer, histogramPath string, log *slog.Logger) error {
+	fg := e.graph
+	fg.costs.scatterPath = ""
+	if len(histogramPath) != 0 && fg.costs.totalStats != nil {
+		fg.costs.scatterPath = costScatterPath(histogramPath)
+	}
+	if fg.convergence != nil {
+		fg.convergence.plotPath = ""
+		if len(histogramPath) != 0 {
+			fg.convergence.plotPath = convergencePlotPath(histogramPath)
+		}
+	}
+	if fg.sensitivity != nil {
+		fg.sensitivity.plotPath = ""
+		if len(histogramPath) != 0 {
+			fg.sensitivity.plotPath = sensitivityPlotPath(histogramPath)
+		}
+	}
+	if fg.scenarios != nil {
+		fg.scenarios.plotPath = ""
+		if len(histogramPath) != 0 {
+			fg.scenarios.plotPath = scenariosPlotPath(histogramPath)
+		}
+	}
+	d2Source := &strings.Builder{}
+	encoder := D2EncodingVisitor{
+		criticalPathGraph: simple.NewDirectedGraph(),
+	}
+	encodeErr := encoder.Encode(fg, histogramPath, d2Source, log)
+	if encodeErr != nil {
+		log.Error("Failed to encode node", "err", encodeErr)
+	}
+	_, writeErr := io.WriteString(output, d2Source.String())
+	return writeErr
+}
+
+// WriteSVG writes the D2 diagram rendered as an SVG with the light and dark
+// themes
+func (e *Evaluation) WriteSVG(output io.Writer,
+	histogramPath string,
+	lightTheme int64,
+	darkTheme int64,
+	log *slog.Logger) error {
+	d2Source := &strings.Builder{}
+	d2Err := e.WriteD2(d2Source, histogramPath, log)
+	if d2Err != nil {
+		return d2Err
+	}
+	svgBytes, svgBytesErr := RenderSVG(d2Source.String(), lightTheme, darkTheme, log)
+	if svgBytesErr != nil {
+		return svgBytesErr
+	}
+	_, writeErr := output.Write(svgBytes)
+	return writeErr
+}
+
+// writePlotPNG writes the plot as a PNG
+func writePlotPNG(p *plot.Plot, output io.Writer) error {
+	writerTo, writerToErr := p.WriterTo(PLOT_SIZE, PLOT_SIZE, "png")
+	if writerToErr != nil {
+		return writerToErr
+	}
+	_, writeErr := writerTo.WriteTo(output)
+	return writeErr
+}
+
+// writePlotSVG writes the plot as an SVG
+func writePlotSVG(p *plot.Plot, output io.Writer) error {
+	writerTo, writerToErr := p.WriterTo(PLOT_SIZE, PLOT_SIZE, "svg")
+	if writerToErr != nil {
+		return writerToErr
+	}
+	_, writeErr := writerTo.WriteTo(output)
+	return writeErr
+}
+
+// WritePNG writes the histogram and CDF of the total duration as a PNG
+func (e *Evaluation) WritePNG(output io.Writer) error {
+	p, plotErr := e.graph.distributionPlot()
+	if plotErr != nil {
+		return plotErr
+	}
+	return writePlotPNG(p, output)
+}
+
+// WriteCostPNG writes the joint cost/duration plot as a PNG
+func (e *Evaluation) WriteCostPNG(output io.Writer) error {
+	if e.graph.costs.totalStats == nil {
+		return fmt.Errorf("plan %s has no task costs", e.graph.name)
+	}
+	p, plotErr := e.graph.costScatterPlot()
+	if plotErr != nil {
+		return plotErr
+	}
+	return writePlotPNG(p, output)
+}
+
+// WriteConvergencePNG writes the percentile convergence plot of an auto run
+// count as a PNG
+func (e *Evaluation) WriteConvergencePNG(output io.Writer) error {
+	if e.graph.convergence == nil {
+		return fmt.Errorf("plan %s doesn't have an auto runCount", e.graph.name)
+	}
+	p, plotErr := e.graph.convergencePlot()
+	if plotErr != nil {
+		return plotErr
+	}
+	return writePlotPNG(p, output)
+}
+
+// sensitivityPlot returns the tornado chart of the task sensitivity
+func (e *Evaluation) sensitivityPlot() (*plot.Plot, error) {
+	if e.graph.sensitivity == nil {
+		return nil, fmt.Errorf("plan %s doesn't have a sensitivity method", e.graph.name)
+	}
+	return e.graph.sensitivityPlot()
+}
+
+// WriteSensitivityPNG writes the tornado chart of the task sensitivity as a
+// PNG
+func (e *Evaluation) WriteSensitivityPNG(output io.Writer) error {
+	p, plotErr := e.sensitivityPlot()
+	if plotErr != nil {
+		return plotErr
+	}
+	return writePlotPNG(p, output)
+}
+
+// WriteSensitivitySVG writes the tornado chart of the task sensitivity as an
+// SVG
+func (e *Evaluation) WriteSensitivitySVG(output io.Writer) error {
+	p, plotErr := e.sensitivityPlot()
+	if plotErr != nil {
+		return plotErr
+	}
+	return writePlotSVG(p, output)
+}
+
+// scenariosPlot returns the overlaid CDFs of the plan and its scenarios
+func (e *Evaluation) scenariosPlot() (*plot.Plot, error) {
+	if e.graph.scenarios == nil {
+		return nil, fmt.Errorf("plan %s doesn't have scenarios", e.graph.name)
+	}
+	return e.graph.scenariosPlot()
+}
+
+// WriteScenariosPNG writes the overlaid CDFs of the total duration of the
+// plan and its scenarios as a PNG
+func (e *Evaluation) WriteScenariosPNG(output io.Writer) error {
+	p, plotErr := e.scenariosPlot()
+	if plotErr != nil {
+		return plotErr
+	}
+	return writePlotPNG(p, output)
+}
+
+// WriteScenariosSVG writes the overlaid CDFs of the total duration of the
+// plan and its scenarios as an SVG
+func (e *Evaluation) WriteScenariosSVG(output io.Writer) error {
+	p, plotErr := e.scenariosPlot()
+	if plotErr != nil {
+		return plotErr
+	}
+	return writePlotSVG(p, output)
+}
@@ -3,7 +3,6 @@ package app
 import (
 	"context"
 	"log/slog"
-	"os"
 
 	"oss.terrastruct.com/d2/d2exporter"
 	"oss.terrastruct.com/d2/d2layouts/d2elklayout"
@@ -13,51 +12,45 @@ import (
 	"oss.terrastruct.com/d2/lib/textmeasure"
 )
 
-func createD2Image(inputFile string,
-	outputFile string,
+// RenderSVG lays out the D2 source and renders it as an SVG with the light
+// and dark themes
+func RenderSVG(d2Source string,
 	lightTheme int64,
 	darkTheme int64,
-	log *slog.Logger) error {
-	log.Info("Creating D2 image from source", "path", inputFile)
-	srcFile, srcFileErr := os.ReadFile(inputFile)
-	if srcFileErr != nil {
-		return srcFileErr
-	}
-	_, config, configErr := d2lib.Compile(context.Background(), string(srcFile), nil, nil)
+	log *slog.Logger) ([]byte, error) {
+	_, config, configErr := d2lib.Compile(context.Background(), d2Source, nil, nil)
 	if configErr != nil {
 		log.Warn("Error during compile", "error", configErr)
 	}
+	if config == nil {
+		return nil, configErr
+	}
 	applyErr := config.ApplyTheme(d2themescatalog.ColorblindClear.ID)
 	if applyErr != nil {
-		return applyErr
+		return nil, applyErr
 	}
 	ruler, rulerErr := textmeasure.NewRuler()
 	if rulerErr != nil {
-		return rulerErr
+		return nil, rulerErr
 	}
 	dimErr := config.SetDimensions(nil, ruler, nil)
 	if dimErr != nil {
-		return dimErr
+		return nil, dimErr
 	}
 	layoutErr := d2elklayout.Layout(context.Background(), config, nil)
 	if layoutErr != nil {
-		return layoutErr
+		return nil, layoutErr
 	}
 	diagram, diagramErr := d2exporter.Export(context.Background(), config, nil)
 	if diagramErr != nil {
-		return diagramErr
+		return nil, diagramErr
 	}
 	sketch := false
 	padding := int64(50)
-	render, renderErr := d2svg.Render(diagram, &d2svg.RenderOpts{
+	return d2svg.Render(diagram, &d2svg.RenderOpts{
 		ThemeID:     &lightTheme,
 		Sketch:      &sketch,
 		DarkThemeID: &darkTheme,
 		Pad:         &padding,
 	})
-	if renderErr != nil {
-		return renderErr
-	}
-	log.Info("Writing D2 image", "path", outputFile)
-	return os.WriteFile(outputFile, render, 0600)
 }
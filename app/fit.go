@@ -0,0 +1,191 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mweagle/goestimate/generator"
+	"golang.org/x/exp/rand"
+	gonumstat "gonum.org/v1/gonum/stat"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Fit
+//
+// `goestimate fit` proposes task generators for historical durations. The
+// parameters of each candidate distribution are estimated from the samples
+// and the candidates are ranked by the two sample Kolmogorov-Smirnov
+// statistic between the samples and the candidate's own samples. Smaller is
+// better.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// FIT_SAMPLE_COUNT is the number of samples drawn from each candidate
+var FIT_SAMPLE_COUNT = 10000
+
+// FitCandidate is a generator expression that was fit to the samples
+type FitCandidate struct {
+	Expression  string
+	KSStatistic float64
+}
+
+// FitReport is the set of candidates, best fit first
+type FitReport struct {
+	SampleCount int
+	Mean        float64
+	StdDev      float64
+	Candidates  []*FitCandidate
+}
+
+// WriteTable writes the ranked candidates
+func (fr *FitReport) WriteTable(output io.Writer) error {
+	tw := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "RANK\tGENERATOR\tKS\n")
+	for i, eachCandidate := range fr.Candidates {
+		fmt.Fprintf(tw, "%d\t%s\t%.4f\n", i+1, eachCandidate.Expression, eachCandidate.KSStatistic)
+	}
+	return tw.Flush()
+}
+
+// parseSamples parses numeric samples separated by commas or whitespace.
+// Lines starting with `#`, and an optional non-numeric header line, are
+// ignored.
+func parseSamples(input []byte) ([]float64, error) {
+	samples := make([]float64, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ';' || r == ' ' || r == '\t'
+		})
+		for _, eachField := range fields {
+			sample, sampleErr := strconv.ParseFloat(eachField, 64)
+			if sampleErr != nil {
+				// Allow a single header line
+				if len(samples) == 0 && lineNumber == 1 {
+					break
+				}
+				return nil, fmt.Errorf("invalid sample on line %d: %s. Samples must be numbers", lineNumber, eachField)
+			}
+			samples = append(samples, sample)
+		}
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, scanErr
+	}
+	if len(samples) < 2 {
+		return nil, fmt.Errorf("invalid samples: at least 2 samples are required. Found: %d", len(samples))
+	}
+	return samples, nil
+}
+
+// fitExpressions returns the candidate generator expressions with the
+// parameters estimated from the sorted samples
+func fitExpressions(sortedSamples []float64) []string {
+	minValue := sortedSamples[0]
+	maxValue := sortedSamples[len(sortedSamples)-1]
+	mean, stddev := gonumstat.MeanStdDev(sortedSamples, nil)
+	if minValue == maxValue {
+		return []string{fmt.Sprintf("Fixed(%s)", formatParam(minValue))}
+	}
+	// The mode of a triangular distribution with the sample mean
+	modeValue := math.Max(minValue, math.Min(maxValue, 3*mean-minValue-maxValue))
+	expressions := []string{
+		fmt.Sprintf("PERT(%s, %s, %s)", formatParam(minValue), formatParam(modeValue), formatParam(maxValue)),
+		fmt.Sprintf("Normal(%s, %s)", formatParam(mean), formatParam(stddev)),
+	}
+	// Maximum likelihood Pareto shape for positive samples
+	if minValue > 0 {
+		logSum := float64(0)
+		for _, eachSample := range sortedSamples {
+			logSum += math.Log(eachSample / minValue)
+		}
+		if logSum > 0 {
+			alpha := float64(len(sortedSamples)) / logSum
+			expressions = append(expressions, fmt.Sprintf("Pareto(%s, %s)", formatParam(minValue), formatParam(alpha)))
+		}
+	}
+	return expressions
+}
+
+func formatParam(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+// sampleExpression draws samples from the generator expression
+func sampleExpression(expression string, sampleCount int, src rand.Source, log *slog.Logger) ([]float64, error) {
+	gen, genErr := generator.NewDurationGeneratorFromExpression(expression, log)
+	if genErr != nil {
+		return nil, genErr
+	}
+	zeroValues := make([]float64, sampleCount)
+	priorSamples := map[int64]*generator.GenerationResults{
+		0: {
+			RawValues:        &zeroValues,
+			CumulativeValues: &zeroValues,
+		},
+	}
+	genResults, genResultsErr := gen.Generate(priorSamples, nil, src, log)
+	if genResultsErr != nil {
+		return nil, genResultsErr
+	}
+	samples := make([]float64, sampleCount)
+	copy(samples, *genResults.RawValues)
+	return samples, nil
+}
+
+// FitSamples fits the candidate generators to the samples read from the
+// input path, or stdin
+func FitSamples(inputPath string, log *slog.Logger) (*FitReport, error) {
+	inputBytes, _, inputBytesErr := readInput(inputPath)
+	if inputBytesErr != nil {
+		return nil, inputBytesErr
+	}
+	samples, samplesErr := parseSamples(inputBytes)
+	if samplesErr != nil {
+		return nil, samplesErr
+	}
+	sort.Float64s(samples)
+	mean, stddev := gonumstat.MeanStdDev(samples, nil)
+	report := &FitReport{
+		SampleCount: len(samples),
+		Mean:        mean,
+		StdDev:      stddev,
+		Candidates:  make([]*FitCandidate, 0),
+	}
+	for _, eachExpression := range fitExpressions(samples) {
+		candidateSamples, candidateSamplesErr := sampleExpression(eachExpression,
+			FIT_SAMPLE_COUNT,
+			rand.NewSource(0),
+			log)
+		if candidateSamplesErr != nil {
+			return nil, candidateSamplesErr
+		}
+		sort.Float64s(candidateSamples)
+		ksStatistic := gonumstat.KolmogorovSmirnov(samples, nil, candidateSamples, nil)
+		log.Debug("Fit candidate", "generator", eachExpression, "ks", ksStatistic)
+		report.Candidates = append(report.Candidates, &FitCandidate{
+			Expression:  eachExpression,
+			KSStatistic: ksStatistic,
+		})
+	}
+	slices.SortStableFunc(report.Candidates, func(a, b *FitCandidate) int {
+		return cmp.Compare(a.KSStatistic, b.KSStatistic)
+	})
+	return report, nil
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mweagle/goestimate/plan"
+)
+
+// starterPlanTemplate is the plan created by `goestimate init`. The name is
+// a JSON encoded string.
+const starterPlanTemplate = `{
+    "$schema": "%s",
+    "name": %s,
+    "runCount": 10000,
+    "percentiles": [50, 85, 95],
+    "activities": {
+        "tasks": [
+            {
+                "name": "Design",
+                "type": "PERT(3, 5, 10)"
+            },
+            {
+                "name": "Build",
+                "type": "PERT(5, 8, 15)"
+            },
+            {
+                "name": "Release",
+                "type": "Fixed(1)"
+            }
+        ],
+        "support": {
+            "Documentation": {
+                "type": "PERT(4, 6, 12)"
+            }
+        }
+    }
+}
+`
+
+// StarterPlan returns a minimal plan, with serial tasks and a task that runs
+// in parallel with them, to be edited
+func StarterPlan(name string) ([]byte, error) {
+	nameBytes, nameBytesErr := json.Marshal(name)
+	if nameBytesErr != nil {
+		return nil, nameBytesErr
+	}
+	return []byte(fmt.Sprintf(starterPlanTemplate, plan.SchemaID, nameBytes)), nil
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/mweagle/goestimate/generator"
+)
+
+// unmarshalJoin replaces the subgraph's output join generator with the one
+// named by the optional join expression
+func (fsg *flowSubgraph) unmarshalJoin(joinExpr string) error {
+	if len(joinExpr) <= 0 {
+		return nil
+	}
+	joinGenerator, joinGeneratorErr := generator.NewJoinGenerator(joinExpr)
+	if joinGeneratorErr != nil {
+		return joinGeneratorErr
+	}
+	fsg.outputJoinNode.generator = joinGenerator
+	return nil
+}
+
+// verifyJoin checks that the subgraph's completed output join has enough
+// predecessors for its join. kofn(k) needs at least k predecessors.
+func (fsg *flowSubgraph) verifyJoin() error {
+	orderGenerator, orderGeneratorOk := fsg.outputJoinNode.generator.(*generator.OrderStatisticGenerator)
+	if !orderGeneratorOk {
+		return nil
+	}
+	predecessorCount := fsg.WeightedDirectedGraph.To(fsg.outputJoinNode.ID()).Len()
+	if orderGenerator.K > predecessorCount {
+		return fmt.Errorf("invalid kofn join: k=%d exceeds the number of predecessors: %d. Expected k <= %d",
+			orderGenerator.K,
+			predecessorCount,
+			predecessorCount)
+	}
+	return nil
+}
+
+// computeCriticalPath walks backwards from the output join node to the
+// start node. Join nodes follow the predecessors that determine their
+// joined value, based on each predecessor's mean cumulative value.
+// Every other node follows all of its predecessors.
+func (fg *flowGraph) computeCriticalPath(log *slog.Logger) error {
+	visited := make(map[int64]bool)
+	pendingNodes := []int64{fg.outputJoinNode.ID()}
+	for len(pendingNodes) != 0 {
+		nodeID := pendingNodes[len(pendingNodes)-1]
+		pendingNodes = pendingNodes[:len(pendingNodes)-1]
+		if visited[nodeID] {
+			continue
+		}
+		visited[nodeID] = true
+
+		predecessorMeans := make(map[int64]float64)
+		predecessorIter := fg.WeightedDirectedGraph.To(nodeID)
+		for predecessorIter.Next() {
+			predecessorID := predecessorIter.Node().ID()
+			predecessorResults, predecessorResultsExist := fg.generatorResults[predecessorID]
+			if !predecessorResultsExist {
+				return fmt.Errorf("no predecessor values for nodeId: %d", predecessorID)
+			}
+			predecessorMean := float64(0)
+			if predecessorResults.CumulativeStats != nil {
+				predecessorMean = predecessorResults.CumulativeStats.Mean
+			}
+			predecessorMeans[predecessorID] = predecessorMean
+		}
+		criticalPredecessors := make([]int64, 0, len(predecessorMeans))
+		var joinGenerator generator.JoinGenerator
+		joinGeneratorOk := false
+		joinNode, joinNodeOk := fg.WeightedDirectedGraph.Node(nodeID).(*flowGraphJoinMaxValueNode)
+		if joinNodeOk {
+			joinGenerator, joinGeneratorOk = joinNode.generator.(generator.JoinGenerator)
+		}
+		if joinGeneratorOk && len(predecessorMeans) != 0 {
+			criticalPredecessors = joinGenerator.CriticalPredecessors(predecessorMeans)
+		} else {
+			for eachID := range predecessorMeans {
+				criticalPredecessors = append(criticalPredecessors, eachID)
+			}
+		}
+		for _, eachID := range criticalPredecessors {
+			log.Debug("critical path edge", "source", eachID, "end", nodeID)
+			// Update the separate critical path graph with the edges
+			// that are on the CPS.
+			criticalEdge := fg.criticalPathGraph.NewEdge(&flowGraphNode{id: eachID}, &flowGraphNode{id: nodeID})
+			fg.criticalPathGraph.SetEdge(criticalEdge)
+			pendingNodes = append(pendingNodes, eachID)
+		}
+	}
+	return nil
+}
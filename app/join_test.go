@@ -0,0 +1,60 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+)
+
+// joinTestPlan is a parallel block of the tasks with the join
+func joinTestPlan(join string, taskType func(i int) string, taskCount int) string {
+	tasks := ""
+	for i := 0; i != taskCount; i++ {
+		tasks += fmt.Sprintf(`"T%d": {"type": %q},`, i, taskType(i))
+	}
+	return fmt.Sprintf(`{
+		"name": "Joins",
+		"runCount": 20000,
+		"activities": {
+			"parallel": {%s "join": %q}
+		}
+	}`, tasks, join)
+}
+
+func TestJoinFixedDurations(t *testing.T) {
+	fixedType := func(i int) string {
+		return fmt.Sprintf("Fixed(%d)", i+1)
+	}
+	expected := map[string]float64{
+		"max":     4,
+		"min":     1,
+		"sum":     10,
+		"kofn(1)": 1,
+		"kofn(2)": 2,
+		"kofn(4)": 4,
+	}
+	for join, eachDuration := range expected {
+		summary := evaluateTestPlan(t, joinTestPlan(join, fixedType, 4), EvaluateOptions{Seed: 7}).Summary()
+		expectNear(t, join, summary.Duration.Mean, eachDuration, 1e-9)
+	}
+}
+
+// The k-of-n join of n independent uniforms is their k-th order statistic,
+// whose mean is k/(n+1)
+func TestKofNOrderStatistics(t *testing.T) {
+	uniformType := func(int) string {
+		return "Beta(1, 1)"
+	}
+	for k := 1; k <= 4; k++ {
+		summary := evaluateTestPlan(t, joinTestPlan(fmt.Sprintf("kofn(%d)", k), uniformType, 4), EvaluateOptions{Seed: 7}).Summary()
+		expectNear(t, fmt.Sprintf("kofn(%d) mean", k), summary.Duration.Mean, float64(k)/5, 0.005)
+	}
+}
+
+func TestKofNExceedsPredecessors(t *testing.T) {
+	report := validateTestPlan(t, joinTestPlan("kofn(5)", func(int) string {
+		return "Fixed(1)"
+	}, 4))
+	if !report.HasErrors() {
+		t.Fatalf("expected an error for kofn(5) of 4 tasks. Found: %v", diagnosticRules(report))
+	}
+}
@@ -0,0 +1,437 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"runtime"
+	"sync"
+
+	"github.com/mweagle/goestimate/generator"
+	"github.com/mweagle/goestimate/stats"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/topo"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Parallel
+//
+// The runs are split into chunks of EVALUATION_CHUNK_SIZE runs. Each chunk
+// is evaluated by its own copy of the graph, built from the same plan, with
+// its own random number stream derived from the seed. A pool of workers
+// evaluates the chunks concurrently and the chunk samples are appended to
+// the graph in chunk order. The chunks, and therefore the results, don't
+// depend on the number of workers. Streaming evaluations merge chunk
+// summaries rather than appending the samples. Auto run counts stop adding
+// chunks once the percentiles converge.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// EVALUATION_CHUNK_SIZE is the number of runs in each evaluation chunk
+var EVALUATION_CHUNK_SIZE uint64 = 10000
+
+type evaluatedChunk struct {
+	index    int
+	runCount uint64
+	graph    *flowGraph
+	// outputPercentiles are the percentiles of the chunk's total durations,
+	// the batch of an auto run count
+	outputPercentiles []float64
+	// efficiency is the variance reduction of the chunk's sampling
+	efficiency *samplingEfficiency
+	// src is the chunk's random number source after its evaluation
+	src rand.Source
+	// analysis and generators replace the graph of streamed chunks
+	analysis   *chunkAnalysis
+	generators map[int64]generator.DurationGenerator
+	err        error
+}
+
+// chunkSeed derives the seed of a chunk's random number stream with
+// SplitMix64. The first chunk uses the evaluation seed, so plans with
+// EVALUATION_CHUNK_SIZE or fewer runs are a single chunk that draws the
+// same stream as a serial evaluation.
+func chunkSeed(seed uint64, chunkIndex int) uint64 {
+	if chunkIndex == 0 {
+		return seed
+	}
+	z := seed + uint64(chunkIndex)*0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+// streamSeed derives the seed of a node's random number stream from the
+// chunk's seed and the node's stream key
+func streamSeed(seed uint64, streamKey string) uint64 {
+	keyHash := fnv.New64a()
+	keyHash.Write([]byte(streamKey))
+	return chunkSeed(seed^keyHash.Sum64(), 1)
+}
+
+// evaluationWorkers returns the number of workers for the chunks. Zero
+// workers uses every available CPU.
+func (fg *flowGraph) evaluationWorkers(chunkCount int) int {
+	workers := fg.workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return max(1, min(workers, chunkCount))
+}
+
+// generateSamples samples every node of the graph, in topological order,
+// from the source
+func (fg *flowGraph) generateSamples(ctx context.Context, src rand.Source, log *slog.Logger) error {
+	// Ties are broken by node ID so that the evaluation order, and therefore
+	// the random number streams each node draws, are deterministic
+	sortedNodes, sortedNodesErr := topo.SortStabilized(fg, nil)
+	if sortedNodesErr != nil {
+		return sortedNodesErr
+	}
+	percentiles := fg.percentiles
+	correlationSrc := src
+	if fg.nodeSources != nil {
+		correlationSrc = rand.NewSource(streamSeed(fg.seed, "correlations"))
+	}
+	correlationErr := fg.prepareCorrelations(percentiles, correlationSrc, log)
+	if correlationErr != nil {
+		return correlationErr
+	}
+	for _, val := range sortedNodes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		nodeSrc := src
+		if fg.nodeSources != nil {
+			nodeSrc = fg.nodeSources[val.ID()]
+		}
+		switch typedVal := val.(type) {
+		case DurationGeneratorGraphNode:
+			values, valuesErr := typedVal.Generate(fg, percentiles, nodeSrc, log)
+			if valuesErr != nil {
+				return valuesErr
+			}
+			fg.generatorResults[val.ID()] = values
+		default:
+			return fmt.Errorf("invalid node type: %T", typedVal)
+		}
+	}
+	return nil
+}
+
+// evaluateChunk samples the runs of a chunk with a copy of the graph
+func (fg *flowGraph) evaluateChunk(ctx context.Context, chunkIndex int, log *slog.Logger) *evaluatedChunk {
+	chunk := &evaluatedChunk{
+		index: chunkIndex,
+	}
+	chunkGraph, chunkGraphErr := newFlowGraph(fg.planDef, log)
+	if chunkGraphErr != nil {
+		chunk.err = chunkGraphErr
+		return chunk
+	}
+	runCount := fg.startNode.runCount
+	chunkStart := uint64(chunkIndex) * EVALUATION_CHUNK_SIZE
+	chunkGraph.startNode.runCount = min(EVALUATION_CHUNK_SIZE, runCount-chunkStart)
+	chunk.runCount = chunkGraph.startNode.runCount
+	// The chunk's statistics are discarded, so they don't need intervals
+	chunkGraph.intervals = ""
+	// Streamed chunks analyze the sensitivity of their runs
+	chunkGraph.sensitivity = nil
+	if fg.sensitivity != nil {
+		chunkGraph.sensitivity = newSensitivityAnalysis(fg.sensitivity.method)
+	}
+	chunkGraph.seed = chunkSeed(fg.seed, chunkIndex)
+	chunk.src = rand.NewSource(chunkGraph.seed)
+	if fg.commonRandomNumbers {
+		chunkGraph.nodeSources = chunkGraph.commonRandomSources()
+	}
+	// Variance reduced strategies sample every generator of the chunk with
+	// the chunk's sampler
+	var sampler *generator.Sampler
+	if fg.sampling != generator.SAMPLING_MC {
+		var samplerErr error
+		sampler, samplerErr = generator.NewSampler(fg.sampling, chunk.src)
+		if samplerErr != nil {
+			chunk.err = samplerErr
+			return chunk
+		}
+	}
+	for _, eachNode := range chunkGraph.sortedNodes() {
+		chunkGenerator := nodeGenerator(eachNode)
+		if chunkGenerator != nil {
+			generator.DeferStatistics(chunkGenerator)
+			nodeSampler := sampler
+			// Common random numbers sample each node with its own stream,
+			// except for the dimensions of Sobol sequences
+			if sampler != nil && chunkGraph.nodeSources != nil && fg.sampling != generator.SAMPLING_SOBOL {
+				var nodeSamplerErr error
+				nodeSampler, nodeSamplerErr = generator.NewSampler(fg.sampling, chunkGraph.nodeSources[eachNode.ID()])
+				if nodeSamplerErr != nil {
+					chunk.err = nodeSamplerErr
+					return chunk
+				}
+			}
+			if nodeSampler != nil {
+				generator.SetSampler(chunkGenerator, nodeSampler)
+			}
+		}
+	}
+	chunk.err = chunkGraph.generateSamples(ctx, chunk.src, log)
+	if chunk.err == nil && sampler != nil {
+		outputValues := *chunkGraph.outputJoinNode.GenerationResults().CumulativeValues
+		chunk.efficiency = newSamplingEfficiency(outputValues, fg.percentiles)
+	}
+	if chunk.err == nil && fg.convergence != nil {
+		outputValues := *chunkGraph.outputJoinNode.GenerationResults().CumulativeValues
+		outputStats := stats.StatsForSequence(outputValues, fg.percentiles)
+		chunk.outputPercentiles = make([]float64, len(outputStats.Percentiles))
+		for i, eachPercentile := range outputStats.Percentiles {
+			chunk.outputPercentiles[i] = eachPercentile.Val
+		}
+	}
+	if chunk.err == nil && fg.streaming {
+		chunk.err = chunk.summarize(chunkGraph, log)
+		return chunk
+	}
+	chunk.graph = chunkGraph
+	return chunk
+}
+
+// summarize analyzes the chunk's runs, then replaces the samples of its
+// generators with summaries. Only the generators are retained.
+func (chunk *evaluatedChunk) summarize(chunkGraph *flowGraph, log *slog.Logger) error {
+	analysis, analysisErr := chunkGraph.analyzeChunk(chunk.src, chunk.index == 0, log)
+	if analysisErr != nil {
+		return analysisErr
+	}
+	chunk.analysis = analysis
+	chunk.generators = make(map[int64]generator.DurationGenerator)
+	for _, eachNode := range chunkGraph.sortedNodes() {
+		chunkGenerator := nodeGenerator(eachNode)
+		if chunkGenerator != nil {
+			generator.SummarizeChunk(chunkGenerator)
+			chunk.generators[eachNode.ID()] = chunkGenerator
+		}
+	}
+	return nil
+}
+
+// nodeGenerator returns the generator of task and join nodes
+func nodeGenerator(node graph.Node) generator.DurationGenerator {
+	switch typedNode := node.(type) {
+	case *flowGraphNode:
+		return typedNode.generator
+	case *flowGraphJoinMaxValueNode:
+		return typedNode.generator
+	}
+	return nil
+}
+
+// appendChunk appends the chunk samples to the graph's generators
+func (fg *flowGraph) appendChunk(sortedNodes []graph.Node, chunk *evaluatedChunk) error {
+	for _, eachNode := range sortedNodes {
+		targetGenerator := nodeGenerator(eachNode)
+		if targetGenerator == nil {
+			continue
+		}
+		chunkGenerator := nodeGenerator(chunk.graph.WeightedDirectedGraph.Node(eachNode.ID()))
+		if chunkGenerator == nil {
+			return fmt.Errorf("no generator for chunk %d nodeId: %d", chunk.index, eachNode.ID())
+		}
+		appendErr := generator.AppendChunk(targetGenerator, chunkGenerator)
+		if appendErr != nil {
+			return appendErr
+		}
+	}
+	return nil
+}
+
+// mergeChunk merges the summaries of a streamed chunk into the graph's
+// generators and analysis
+func (fg *flowGraph) mergeChunk(sortedNodes []graph.Node, chunk *evaluatedChunk) error {
+	for _, eachNode := range sortedNodes {
+		targetGenerator := nodeGenerator(eachNode)
+		if targetGenerator == nil {
+			continue
+		}
+		chunkGenerator, chunkGeneratorExists := chunk.generators[eachNode.ID()]
+		if !chunkGeneratorExists {
+			return fmt.Errorf("no generator for chunk %d nodeId: %d", chunk.index, eachNode.ID())
+		}
+		mergeErr := generator.MergeChunk(targetGenerator, chunkGenerator)
+		if mergeErr != nil {
+			return mergeErr
+		}
+	}
+	if fg.streamedAnalysis == nil {
+		fg.streamedAnalysis = chunk.analysis
+	} else {
+		fg.streamedAnalysis.merge(chunk.analysis)
+	}
+	return nil
+}
+
+// evaluateChunks samples the runs in chunks, concurrently, and appends the
+// samples to the graph in chunk order. Auto run counts stop at the chunk
+// whose percentiles converged and discard the chunks that follow. The
+// returned source is the first chunk's, which continues its stream for
+// sampling that follows the runs.
+func (fg *flowGraph) evaluateChunks(ctx context.Context,
+	sortedNodes []graph.Node,
+	log *slog.Logger) (rand.Source, error) {
+	runCount := fg.startNode.runCount
+	if runCount <= 0 {
+		return nil, fmt.Errorf("invalid run count for flowGraphStartNode %d", runCount)
+	}
+	chunkCount := int((runCount + EVALUATION_CHUNK_SIZE - 1) / EVALUATION_CHUNK_SIZE)
+	workers := fg.evaluationWorkers(chunkCount)
+	log.Debug("Evaluating runs", "runs", runCount, "chunks", chunkCount, "workers", workers)
+
+	workerCtx, cancelWorkers := context.WithCancel(ctx)
+	defer cancelWorkers()
+	chunkIndices := make(chan int)
+	evaluatedChunks := make(chan *evaluatedChunk)
+	go func() {
+		defer close(chunkIndices)
+		for i := 0; i != chunkCount; i++ {
+			select {
+			case chunkIndices <- i:
+			case <-workerCtx.Done():
+				return
+			}
+		}
+	}()
+	var workerGroup sync.WaitGroup
+	for i := 0; i != workers; i++ {
+		workerGroup.Add(1)
+		go func() {
+			defer workerGroup.Done()
+			for eachIndex := range chunkIndices {
+				evaluatedChunks <- fg.evaluateChunk(workerCtx, eachIndex, log)
+			}
+		}()
+	}
+	go func() {
+		workerGroup.Wait()
+		close(evaluatedChunks)
+	}()
+
+	// Chunks complete in any order, but are appended in chunk order
+	var firstSrc rand.Source
+	var chunksErr error
+	converged := false
+	pendingChunks := make(map[int]*evaluatedChunk)
+	nextIndex := 0
+	for eachChunk := range evaluatedChunks {
+		if chunksErr != nil || converged {
+			continue
+		}
+		if eachChunk.err != nil {
+			chunksErr = eachChunk.err
+			cancelWorkers()
+			continue
+		}
+		pendingChunks[eachChunk.index] = eachChunk
+		for pendingChunks[nextIndex] != nil && chunksErr == nil && !converged {
+			appendChunk := pendingChunks[nextIndex]
+			delete(pendingChunks, nextIndex)
+			if nextIndex == 0 {
+				firstSrc = appendChunk.src
+			}
+			if fg.streaming {
+				chunksErr = fg.mergeChunk(sortedNodes, appendChunk)
+			} else {
+				chunksErr = fg.appendChunk(sortedNodes, appendChunk)
+			}
+			if chunksErr != nil {
+				cancelWorkers()
+			}
+			nextIndex++
+			if chunksErr == nil && appendChunk.efficiency != nil {
+				if fg.samplingEfficiency == nil {
+					fg.samplingEfficiency = appendChunk.efficiency
+				} else {
+					fg.samplingEfficiency.merge(appendChunk.efficiency)
+				}
+			}
+			if chunksErr == nil && fg.convergence != nil {
+				converged = fg.convergence.addBatch(appendChunk.runCount, appendChunk.outputPercentiles)
+				if converged {
+					cancelWorkers()
+				}
+			}
+		}
+	}
+	if chunksErr != nil {
+		return nil, chunksErr
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if converged {
+		chunkCount = nextIndex
+		fg.startNode.runCount = fg.convergence.runs
+	}
+	if nextIndex != chunkCount {
+		return nil, fmt.Errorf("invalid chunk count. Expected: %d, Found: %d", chunkCount, nextIndex)
+	}
+
+	// Compute the statistics of all the runs, concurrently by node, then the
+	// results of the nodes that don't have generators. The plan's completion
+	// has the plan's intervals, and the other nodes the node intervals.
+	outputGenerator := fg.outputJoinNode.generator
+	nodeIntervals := stats.NodeIntervalMethod(fg.intervals)
+	finishGenerators := make(chan generator.DurationGenerator)
+	var finishGroup sync.WaitGroup
+	for i := 0; i != workers; i++ {
+		finishGroup.Add(1)
+		go func() {
+			defer finishGroup.Done()
+			for eachGenerator := range finishGenerators {
+				intervals := nodeIntervals
+				if eachGenerator == outputGenerator {
+					intervals = fg.intervals
+				}
+				generator.FinishChunks(eachGenerator, fg.percentiles, intervals)
+			}
+		}()
+	}
+	for _, eachNode := range sortedNodes {
+		targetGenerator := nodeGenerator(eachNode)
+		if targetGenerator != nil {
+			finishGenerators <- targetGenerator
+		}
+	}
+	close(finishGenerators)
+	finishGroup.Wait()
+	for _, eachNode := range sortedNodes {
+		switch typedNode := eachNode.(type) {
+		case *flowGraphStartNode:
+			if fg.streaming {
+				// Streaming evaluations don't retain the runs' start values
+				startValues := []float64{}
+				fg.generatorResults[eachNode.ID()] = &generator.GenerationResults{
+					RawValues:        &startValues,
+					CumulativeValues: &startValues,
+				}
+				continue
+			}
+			values, valuesErr := typedNode.Generate(fg, fg.percentiles, firstSrc, log)
+			if valuesErr != nil {
+				return nil, valuesErr
+			}
+			fg.generatorResults[eachNode.ID()] = values
+		case *flowGraphPassThroughNode:
+			values, valuesErr := typedNode.Generate(fg, fg.percentiles, firstSrc, log)
+			if valuesErr != nil {
+				return nil, valuesErr
+			}
+			fg.generatorResults[eachNode.ID()] = values
+		default:
+			fg.generatorResults[eachNode.ID()] = nodeGenerator(eachNode).GenerationResults()
+		}
+	}
+	return firstSrc, nil
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mweagle/goestimate/generator"
+)
+
+// workersPlan spans several evaluation chunks, with joins, choices, repeats,
+// risks, costs and resources that each draw from the random number source
+const workersPlan = `{
+	"name": "Workers",
+	"runCount": 35000,
+	"percentiles": [50, 90],
+	"budget": 50000,
+	"resources": {
+		"backend": 1
+	},
+	"activities": {
+		"tasks": [
+			{
+				"name": "Design",
+				"type": "PERT(3,5,9)",
+				"cost": {"rate": 1000}
+			},
+			{
+				"name": "Rework",
+				"type": "Triangle(1,2,4)",
+				"repeat": "Geometric(0.6)"
+			}
+		],
+		"build": {
+			"API": {
+				"type": "PERT(8,10,16)",
+				"resources": {"backend": 1}
+			},
+			"Storage": {
+				"type": "Normal(6, 1)",
+				"resources": {"backend": 1}
+			}
+		},
+		"subgraph: ": {
+			"name": "Vendors",
+			"join": "kofn(2)",
+			"repeat": "Poisson(1)+1",
+			"activities": {
+				"vendors": {
+					"A": {"type": "PERT(2,4,9)"},
+					"B": {"type": "Beta(2, 5)"},
+					"C": {"type": "Risk(p=0.3, impact=Pareto(2, 2, 30))"}
+				}
+			}
+		},
+		"review": {
+			"name": "Review",
+			"branches": [
+				{"name": "Approved", "weight": 0.7},
+				{
+					"name": "Redesign",
+					"weight": 0.3,
+					"activities": {
+						"tasks": [{"name": "Redesign", "type": "PERT(3,5,10)", "cost": "PERT(500,800,1500)"}]
+					}
+				}
+			]
+		}
+	},
+	"risks": [
+		{
+			"name": "Slip",
+			"type": "Risk(p=0.1, impact=PERT(5,10,20))",
+			"attach": "Design"
+		}
+	]
+}`
+
+// evaluateSummary evaluates the plan and returns its JSON summary and
+// samples
+func evaluateSummary(t *testing.T, opts EvaluateOptions) ([]byte, []float64, []float64) {
+	t.Helper()
+	evaluation := evaluateTestPlan(t, workersPlan, opts)
+	var summary bytes.Buffer
+	writeErr := evaluation.WriteJSON(&summary)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	return summary.Bytes(), evaluation.Samples(), evaluation.CostSamples()
+}
+
+func equalSamples(a []float64, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestWorkersBitIdentical verifies that the results don't depend on the
+// number of workers that evaluate the chunks
+func TestWorkersBitIdentical(t *testing.T) {
+	testCases := map[string]EvaluateOptions{
+		"mc":        {Seed: 7},
+		"sobol":     {Seed: 7, Sampling: generator.SAMPLING_SOBOL},
+		"lhs":       {Seed: 7, Sampling: generator.SAMPLING_LHS},
+		"streaming": {Seed: 7, Streaming: true},
+		"intervals": {Seed: 7, Intervals: "bootstrap"},
+	}
+	for name, eachOpts := range testCases {
+		t.Run(name, func(t *testing.T) {
+			serialOpts := eachOpts
+			serialOpts.Workers = 1
+			serialSummary, serialSamples, serialCosts := evaluateSummary(t, serialOpts)
+			for _, eachWorkers := range []int{2, 4} {
+				parallelOpts := eachOpts
+				parallelOpts.Workers = eachWorkers
+				parallelSummary, parallelSamples, parallelCosts := evaluateSummary(t, parallelOpts)
+				if !bytes.Equal(serialSummary, parallelSummary) {
+					t.Errorf("summary with %d workers differs from the summary with 1 worker", eachWorkers)
+				}
+				if !equalSamples(serialSamples, parallelSamples) {
+					t.Errorf("samples with %d workers differ from the samples with 1 worker", eachWorkers)
+				}
+				if !equalSamples(serialCosts, parallelCosts) {
+					t.Errorf("cost samples with %d workers differ from the cost samples with 1 worker", eachWorkers)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/mweagle/goestimate/generator"
+)
+
+// unmarshalRepeat applies the optional repeat count expression to the
+// subgraph's output join node
+func (fsg *flowSubgraph) unmarshalRepeat(repeatExpr string) error {
+	if len(repeatExpr) <= 0 {
+		return nil
+	}
+	repeatCount, repeatCountErr := generator.ParseRepeatCount(repeatExpr)
+	if repeatCountErr != nil {
+		return repeatCountErr
+	}
+	joinGenerator, joinGeneratorOk := fsg.outputJoinNode.generator.(generator.JoinGenerator)
+	if !joinGeneratorOk {
+		return fmt.Errorf("unsupported join generator for repeat: %T", fsg.outputJoinNode.generator)
+	}
+	joinGenerator.SetRepeat(repeatCount)
+	return nil
+}
+
+// repeatCount returns the subgraph's repeat count, if any
+func (fsg *flowSubgraph) repeatCount() (*generator.RepeatCount, float64) {
+	joinGenerator, joinGeneratorOk := fsg.outputJoinNode.generator.(generator.JoinGenerator)
+	if !joinGeneratorOk || joinGenerator.RepeatCount() == nil {
+		return nil, 0
+	}
+	return joinGenerator.RepeatCount(), joinGenerator.MeanIterations()
+}
+
+// repeatAnnotation is the label for a repeated task or subgraph
+func repeatAnnotation(repeatCount *generator.RepeatCount, meanIterations float64) string {
+	return fmt.Sprintf("%s, E(n)=%.2f, n̄=%.2f",
+		repeatCount.Name(),
+		repeatCount.Mean(),
+		meanIterations)
+}
+
+// repeatLoopConnection returns the self-loop connection for a repeated task
+// or subgraph, or nil if the node isn't repeated
+func (d2enc *D2EncodingVisitor) repeatLoopConnection(node D2Encoder) *D2Connection {
+	var repeatCount *generator.RepeatCount
+	meanIterations := float64(0)
+	switch typedNode := node.(type) {
+	case *flowGraphNode:
+		repeatGenerator, repeatGeneratorOk := typedNode.generator.(*generator.RepeatGenerator)
+		if repeatGeneratorOk {
+			repeatCount = repeatGenerator.Count
+			meanIterations = repeatGenerator.MeanIterations()
+		}
+	case *flowGraphPassThroughNode:
+		owningSubgraph := typedNode.parentFlowSubgraphs[len(typedNode.parentFlowSubgraphs)-1]
+		repeatCount, meanIterations = owningSubgraph.repeatCount()
+	}
+	if repeatCount == nil {
+		return nil
+	}
+	nodePath := d2enc.fullConnectionPathForNode(node)
+	return &D2Connection{
+		from:  nodePath,
+		to:    nodePath,
+		label: fmt.Sprintf("⟳ n̄=%.2f", meanIterations),
+	}
+}
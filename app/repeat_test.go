@@ -0,0 +1,40 @@
+package app
+
+import "testing"
+
+// The mean duration of a repeated task or subgraph is the mean iteration
+// count times the mean duration of an iteration. The task and the subgraph
+// run in parallel, so each completes at its own mean duration.
+func TestRepeatMeans(t *testing.T) {
+	evaluation := evaluateTestPlan(t, `{
+		"name": "Rework",
+		"runCount": 20000,
+		"activities": {
+			"tasks": [{"name": "Review", "type": "Triangle(1,2,3)", "repeat": "Geometric(0.5)"}],
+			"subgraph: ": {
+				"name": "QA",
+				"repeat": "Poisson(1.5)+1",
+				"activities": {
+					"tasks": [
+						{"name": "Test", "type": "Triangle(1,2,3)"},
+						{"name": "Fix", "type": "Fixed(1)"}
+					]
+				}
+			}
+		}
+	}`, EvaluateOptions{Seed: 7})
+	expected := map[string]float64{
+		"Review": 2 * 2,
+		"QA":     2.5 * 3,
+	}
+	for _, eachNode := range evaluation.Nodes() {
+		eachMean, meanExists := expected[eachNode.Name]
+		if meanExists {
+			expectNear(t, eachNode.Name, eachNode.Completion.Mean, eachMean, 0.1)
+			delete(expected, eachNode.Name)
+		}
+	}
+	if len(expected) != 0 {
+		t.Errorf("missing node results: %v", expected)
+	}
+}
@@ -0,0 +1,494 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mweagle/goestimate/generator"
+	"github.com/mweagle/goestimate/stats"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Resource constrained scheduling
+//
+// Plans can declare named resources with capacities and tasks can declare
+// the resource units they need. After the unconstrained evaluation each run
+// is rescheduled with a serial schedule generation scheme: tasks are
+// scheduled one at a time, in a precedence feasible order given by the
+// priority rule, at the earliest time that both their predecessors have
+// finished and their resources are available.
+//
+// The priority rule is the longest remaining path, measured with each
+// node's mean duration. Joins recombine the constrained predecessor
+// finish times with the random values drawn in the unconstrained run.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+const resourceTimeEpsilon = 1e-9
+
+type resourceInterval struct {
+	start  float64
+	end    float64
+	units  float64
+	taskID int64
+}
+
+// resourceSchedule is the result of the resource constrained scheduling
+type resourceSchedule struct {
+	finishValues   []float64
+	finishStats    *stats.AggregatedStatistics
+	utilization    map[string]float64
+	chainFrequency map[int64]float64
+}
+
+// validateTaskResources verifies that every resource a task requires is a
+// declared resource with sufficient capacity
+func (fg *flowGraph) validateTaskResources(taskName string, taskResources map[string]float64) error {
+	for _, eachName := range sortedKeys(taskResources) {
+		capacity, capacityExists := fg.resources[eachName]
+		if !capacityExists {
+			return fmt.Errorf("task %s requires undeclared resource: %s", taskName, eachName)
+		}
+		if taskResources[eachName] > capacity {
+			return fmt.Errorf("task %s requires %.2f units of resource %s which exceeds its capacity: %.2f",
+				taskName,
+				taskResources[eachName],
+				eachName,
+				capacity)
+		}
+	}
+	return nil
+}
+
+// meanDuration is the mean duration a node adds to its incoming value
+func (fg *flowGraph) meanDuration(nodeID int64) float64 {
+	nodeResults, nodeResultsExist := fg.generatorResults[nodeID]
+	if !nodeResultsExist || nodeResults.GeneratorStats == nil {
+		return 0
+	}
+	return math.Max(0, nodeResults.GeneratorStats.Mean)
+}
+
+// scheduleOrder returns a precedence feasible node order. Nodes that don't
+// consume resources are released as soon as they're eligible, eligible tasks
+// are ordered by the longest remaining path.
+func (fg *flowGraph) scheduleOrder(topoOrder []int64) []int64 {
+	remainingPath := make(map[int64]float64, len(topoOrder))
+	for i := len(topoOrder) - 1; i >= 0; i-- {
+		nodeID := topoOrder[i]
+		successorPath := float64(0)
+		successorIter := fg.WeightedDirectedGraph.From(nodeID)
+		for successorIter.Next() {
+			successorPath = math.Max(successorPath, remainingPath[successorIter.Node().ID()])
+		}
+		remainingPath[nodeID] = fg.meanDuration(nodeID) + successorPath
+	}
+	pendingPredecessors := make(map[int64]int, len(topoOrder))
+	eligible := make([]int64, 0)
+	for _, eachID := range topoOrder {
+		pendingPredecessors[eachID] = fg.WeightedDirectedGraph.To(eachID).Len()
+		if pendingPredecessors[eachID] == 0 {
+			eligible = append(eligible, eachID)
+		}
+	}
+	isTask := func(nodeID int64) bool {
+		_, taskNodeOk := fg.WeightedDirectedGraph.Node(nodeID).(*flowGraphNode)
+		return taskNodeOk
+	}
+	order := make([]int64, 0, len(topoOrder))
+	for len(eligible) != 0 {
+		slices.SortFunc(eligible, func(a, b int64) int {
+			if isTask(a) != isTask(b) {
+				if isTask(a) {
+					return 1
+				}
+				return -1
+			}
+			if remainingPath[a] != remainingPath[b] {
+				return cmp.Compare(remainingPath[b], remainingPath[a])
+			}
+			return cmp.Compare(a, b)
+		})
+		nextID := eligible[0]
+		eligible = eligible[1:]
+		order = append(order, nextID)
+		successorIter := fg.WeightedDirectedGraph.From(nextID)
+		for successorIter.Next() {
+			successorID := successorIter.Node().ID()
+			pendingPredecessors[successorID]--
+			if pendingPredecessors[successorID] == 0 {
+				eligible = append(eligible, successorID)
+			}
+		}
+	}
+	return order
+}
+
+// earliestResourceStart returns the earliest start time at or after ready
+// at which the required units are available for the duration
+func earliestResourceStart(ready float64,
+	duration float64,
+	required map[string]float64,
+	capacities map[string]float64,
+	intervals map[string][]*resourceInterval) (float64, int64) {
+
+	if duration <= 0 {
+		return ready, -1
+	}
+	candidates := []float64{ready}
+	for eachName := range required {
+		for _, eachInterval := range intervals[eachName] {
+			if eachInterval.end > ready {
+				candidates = append(candidates, eachInterval.end)
+			}
+		}
+	}
+	sort.Float64s(candidates)
+	for _, candidateStart := range candidates {
+		candidateEnd := candidateStart + duration
+		feasible := true
+		for eachName, eachUnits := range required {
+			// Usage is piecewise constant, so only the candidate start and
+			// interval starts inside the window need to be checked
+			checkPoints := []float64{candidateStart}
+			for _, eachInterval := range intervals[eachName] {
+				if eachInterval.start > candidateStart && eachInterval.start < candidateEnd {
+					checkPoints = append(checkPoints, eachInterval.start)
+				}
+			}
+			for _, eachPoint := range checkPoints {
+				usage := float64(0)
+				for _, eachInterval := range intervals[eachName] {
+					if eachInterval.start <= eachPoint+resourceTimeEpsilon && eachInterval.end > eachPoint+resourceTimeEpsilon {
+						usage += eachInterval.units
+					}
+				}
+				if usage+eachUnits > capacities[eachName]+resourceTimeEpsilon {
+					feasible = false
+					break
+				}
+			}
+			if !feasible {
+				break
+			}
+		}
+		if feasible {
+			// Which task released the resources at this start time? Ties
+			// are broken by the lowest task ID, so the critical chain
+			// doesn't depend on map order.
+			blockingTaskID := int64(-1)
+			if candidateStart > ready+resourceTimeEpsilon {
+				for _, eachName := range sortedKeys(required) {
+					for _, eachInterval := range intervals[eachName] {
+						if math.Abs(eachInterval.end-candidateStart) <= resourceTimeEpsilon &&
+							(blockingTaskID < 0 || eachInterval.taskID < blockingTaskID) {
+							blockingTaskID = eachInterval.taskID
+						}
+					}
+				}
+			}
+			return candidateStart, blockingTaskID
+		}
+	}
+	// Unreachable as the last interval end always has capacity
+	return candidates[len(candidates)-1], -1
+}
+
+// choiceBranchTaken returns, for each run, whether every choice that
+// encloses the task chose the task's branch, or nil if the task isn't on a
+// choice branch
+func choiceBranchTaken(taskNode *flowGraphNode, runCount int) ([]bool, error) {
+	var branchTaken []bool
+	parentSubgraphs := taskNode.parentFlowSubgraphs
+	for j := 0; j < len(parentSubgraphs)-1; j++ {
+		choiceGenerator, choiceGeneratorOk := parentSubgraphs[j].outputJoinNode.generator.(*generator.ChoiceGenerator)
+		if !choiceGeneratorOk {
+			continue
+		}
+		chosen := choiceGenerator.Chosen()
+		if len(chosen) != runCount {
+			return nil, fmt.Errorf("invalid choice count for join nodeId: %d. Expected: %d, Found: %d",
+				parentSubgraphs[j].outputJoinNode.ID(),
+				runCount,
+				len(chosen))
+		}
+		if branchTaken == nil {
+			branchTaken = make([]bool, runCount)
+			for i := range branchTaken {
+				branchTaken[i] = true
+			}
+		}
+		// The branch is the subgraph directly below the choice
+		branchID := parentSubgraphs[j+1].outputJoinNode.ID()
+		for i, eachID := range chosen {
+			branchTaken[i] = branchTaken[i] && eachID == branchID
+		}
+	}
+	return branchTaken, nil
+}
+
+// scheduleResourceConstrained reschedules every run subject to the
+// resource capacities
+func (fg *flowGraph) scheduleResourceConstrained(topoOrder []int64, log *slog.Logger) error {
+	runCount := int(fg.startNode.runCount)
+	order := fg.scheduleOrder(topoOrder)
+	constrainedValues := make(map[int64][]float64, len(order))
+	// Per run resource usage and the task that blocked each delayed task
+	runIntervals := make([]map[string][]*resourceInterval, runCount)
+	for i := range runIntervals {
+		runIntervals[i] = make(map[string][]*resourceInterval)
+	}
+	blockingTasks := make(map[int64][]int64)
+
+	for _, nodeID := range order {
+		switch typedNode := fg.WeightedDirectedGraph.Node(nodeID).(type) {
+		case *flowGraphStartNode:
+			constrainedValues[nodeID] = make([]float64, runCount)
+		case *flowGraphPassThroughNode:
+			predecessorIter := fg.WeightedDirectedGraph.To(nodeID)
+			if predecessorIter.Len() != 1 {
+				return fmt.Errorf("invalid predecessor count for passthrough: %d", predecessorIter.Len())
+			}
+			predecessorIter.Next()
+			constrainedValues[nodeID] = constrainedValues[predecessorIter.Node().ID()]
+		case *flowGraphNode:
+			predecessorIter := fg.WeightedDirectedGraph.To(nodeID)
+			if predecessorIter.Len() != 1 {
+				return fmt.Errorf("invalid predecessor count for task %s: %d", typedNode.name, predecessorIter.Len())
+			}
+			predecessorIter.Next()
+			readyValues := constrainedValues[predecessorIter.Node().ID()]
+			durations := *typedNode.GenerationResults().RawValues
+			finishValues := make([]float64, runCount)
+			if len(typedNode.resources) == 0 {
+				for i := range finishValues {
+					finishValues[i] = readyValues[i] + durations[i]
+				}
+			} else {
+				branchTaken, branchTakenErr := choiceBranchTaken(typedNode, runCount)
+				if branchTakenErr != nil {
+					return branchTakenErr
+				}
+				blockingTasks[nodeID] = make([]int64, runCount)
+				for i := range finishValues {
+					// Tasks on branches that weren't chosen don't run, so
+					// they don't hold their resources
+					if branchTaken != nil && !branchTaken[i] {
+						finishValues[i] = readyValues[i]
+						blockingTasks[nodeID][i] = -1
+						continue
+					}
+					start, blockingTaskID := earliestResourceStart(readyValues[i],
+						durations[i],
+						typedNode.resources,
+						fg.resources,
+						runIntervals[i])
+					finishValues[i] = start + durations[i]
+					blockingTasks[nodeID][i] = blockingTaskID
+					for eachName, eachUnits := range typedNode.resources {
+						runIntervals[i][eachName] = append(runIntervals[i][eachName], &resourceInterval{
+							start:  start,
+							end:    finishValues[i],
+							units:  eachUnits,
+							taskID: nodeID,
+						})
+					}
+				}
+			}
+			constrainedValues[nodeID] = finishValues
+		case *flowGraphJoinMaxValueNode:
+			joinGenerator, joinGeneratorOk := typedNode.generator.(generator.JoinGenerator)
+			if !joinGeneratorOk {
+				return fmt.Errorf("unsupported join generator for resource scheduling: %T", typedNode.generator)
+			}
+			priorValues := make(map[int64][]float64)
+			predecessorIter := fg.WeightedDirectedGraph.To(nodeID)
+			for predecessorIter.Next() {
+				priorValues[predecessorIter.Node().ID()] = constrainedValues[predecessorIter.Node().ID()]
+			}
+			owningSubgraph := typedNode.parentFlowSubgraphs[len(typedNode.parentFlowSubgraphs)-1]
+			rejoinedValues, rejoinedValuesErr := joinGenerator.Rejoin(priorValues, constrainedValues[owningSubgraph.inputNode.ID()])
+			if rejoinedValuesErr != nil {
+				return typedNode.planError(rejoinedValuesErr)
+			}
+			constrainedValues[nodeID] = rejoinedValues
+		default:
+			return fmt.Errorf("invalid node type for resource scheduling: %T", typedNode)
+		}
+	}
+
+	schedule := &resourceSchedule{
+		finishValues:   constrainedValues[fg.outputJoinNode.ID()],
+		utilization:    make(map[string]float64),
+		chainFrequency: make(map[int64]float64),
+	}
+	schedule.finishStats = stats.StatsWithIntervals(schedule.finishValues, fg.percentiles, fg.intervals)
+
+	// Utilization is the busy unit time over the available unit time
+	for eachName, eachCapacity := range fg.resources {
+		utilizationSum := float64(0)
+		for i := 0; i != runCount; i++ {
+			if schedule.finishValues[i] <= 0 {
+				continue
+			}
+			busyTime := float64(0)
+			for _, eachInterval := range runIntervals[i][eachName] {
+				busyTime += (eachInterval.end - eachInterval.start) * eachInterval.units
+			}
+			utilizationSum += busyTime / (eachCapacity * schedule.finishValues[i])
+		}
+		schedule.utilization[eachName] = utilizationSum / float64(runCount)
+	}
+
+	// The critical chain is found by walking back from the output through
+	// the binding predecessor of each node, including resource dependencies.
+	chainCounts := make(map[int64]int)
+	for i := 0; i != runCount; i++ {
+		visited := make(map[int64]bool)
+		pendingNodes := []int64{fg.outputJoinNode.ID()}
+		for len(pendingNodes) != 0 {
+			nodeID := pendingNodes[len(pendingNodes)-1]
+			pendingNodes = pendingNodes[:len(pendingNodes)-1]
+			if visited[nodeID] {
+				continue
+			}
+			visited[nodeID] = true
+			node := fg.WeightedDirectedGraph.Node(nodeID)
+			_, taskNodeOk := node.(*flowGraphNode)
+			if taskNodeOk {
+				chainCounts[nodeID]++
+				taskBlockers, taskBlockersExist := blockingTasks[nodeID]
+				if taskBlockersExist && taskBlockers[i] >= 0 {
+					pendingNodes = append(pendingNodes, taskBlockers[i])
+					continue
+				}
+			}
+			joinNode, joinNodeOk := node.(*flowGraphJoinMaxValueNode)
+			sumJoinOk := false
+			if joinNodeOk {
+				_, sumJoinOk = joinNode.generator.(*generator.SumJoinGenerator)
+			}
+			for _, eachPredecessor := range sortedByID(fg.WeightedDirectedGraph.To(nodeID)) {
+				predecessorID := eachPredecessor.ID()
+				if joinNodeOk && !sumJoinOk {
+					// Follow the predecessor whose value was joined
+					joinedValue := constrainedValues[nodeID][i] - (*joinNode.GenerationResults().RawValues)[i]
+					if math.Abs(constrainedValues[predecessorID][i]-joinedValue) > resourceTimeEpsilon {
+						continue
+					}
+				}
+				pendingNodes = append(pendingNodes, predecessorID)
+			}
+		}
+	}
+	for eachID, eachCount := range chainCounts {
+		schedule.chainFrequency[eachID] = float64(eachCount) / float64(runCount)
+	}
+	fg.resourceSchedule = schedule
+	log.Debug("Resource constrained schedule complete", "runs", runCount, "resources", len(fg.resources))
+	return nil
+}
+
+// criticalChain returns the tasks that are on the resource constrained
+// critical chain in at least half of the runs, most frequent first
+func (fg *flowGraph) criticalChain() []*flowGraphNode {
+	chainTasks := make([]*flowGraphNode, 0)
+	for eachID, eachFrequency := range fg.resourceSchedule.chainFrequency {
+		if eachFrequency >= 0.5 {
+			chainTasks = append(chainTasks, fg.WeightedDirectedGraph.Node(eachID).(*flowGraphNode))
+		}
+	}
+	slices.SortFunc(chainTasks, func(a, b *flowGraphNode) int {
+		frequencyA := fg.resourceSchedule.chainFrequency[a.ID()]
+		frequencyB := fg.resourceSchedule.chainFrequency[b.ID()]
+		if frequencyA != frequencyB {
+			return cmp.Compare(frequencyB, frequencyA)
+		}
+		return cmp.Compare(a.name, b.name)
+	})
+	return chainTasks
+}
+
+func (fg *flowGraph) sortedResourceNames() []string {
+	resourceNames := make([]string, 0, len(fg.resources))
+	for eachName := range fg.resources {
+		resourceNames = append(resourceNames, eachName)
+	}
+	slices.Sort(resourceNames)
+	return resourceNames
+}
+
+func (fg *flowGraph) logResourceSchedule(log *slog.Logger) {
+	if fg.resourceSchedule == nil {
+		return
+	}
+	log.Info("Resource constrained estimate",
+		"unconstrained", aggregatedStatsFormatter(fg.outputJoinNode.GenerationResults().CumulativeStats),
+		"constrained", aggregatedStatsFormatter(fg.resourceSchedule.finishStats))
+	for _, eachName := range fg.sortedResourceNames() {
+		log.Info("Resource utilization",
+			"resource", eachName,
+			"capacity", fg.resources[eachName],
+			"utilization", fmt.Sprintf("%.2f%%", fg.resourceSchedule.utilization[eachName]*100))
+	}
+	for _, eachTask := range fg.criticalChain() {
+		log.Info("Critical chain task",
+			"task", eachTask.name,
+			"frequency", fmt.Sprintf("%.2f%%", fg.resourceSchedule.chainFrequency[eachTask.ID()]*100))
+	}
+}
+
+// encodeD2ResourceSchedule writes the constrained estimate, resource
+// utilization and critical chain as a markdown node
+func (fg *flowGraph) encodeD2ResourceSchedule(nodeName string, output io.StringWriter) error {
+	if fg.resourceSchedule == nil {
+		return nil
+	}
+	nodeContents := fmt.Sprintf(`%s : |||md
+# Resource Constrained
+
+- **Unconstrained**: %s
+- **Constrained**: %s
+
+| Resource | Capacity | Utilization |
+|----------|----------|-------------|
+`,
+		nodeName,
+		aggregatedStatsFormatter(fg.outputJoinNode.GenerationResults().CumulativeStats),
+		aggregatedStatsFormatter(fg.resourceSchedule.finishStats))
+	for _, eachName := range fg.sortedResourceNames() {
+		nodeContents += fmt.Sprintf("| %s | %.0f | %.2f%% |\n",
+			eachName,
+			fg.resources[eachName],
+			fg.resourceSchedule.utilization[eachName]*100)
+	}
+	nodeContents += "\n| Critical Chain Task | Frequency |\n|---------------------|-----------|\n"
+	for _, eachTask := range fg.criticalChain() {
+		nodeContents += fmt.Sprintf("| %s | %.2f%% |\n",
+			eachTask.name,
+			fg.resourceSchedule.chainFrequency[eachTask.ID()]*100)
+	}
+	nodeContents += "|||\n\n"
+	_, writeErr := output.WriteString(nodeContents)
+	return writeErr
+}
+
+// resourcesAnnotation renders a task's requirements as `name×units`
+func resourcesAnnotation(taskResources map[string]float64) string {
+	resourceNames := make([]string, 0, len(taskResources))
+	for eachName := range taskResources {
+		resourceNames = append(resourceNames, eachName)
+	}
+	slices.Sort(resourceNames)
+	annotations := make([]string, len(resourceNames))
+	for i, eachName := range resourceNames {
+		annotations[i] = fmt.Sprintf("%s×%g", eachName, taskResources[eachName])
+	}
+	return strings.Join(annotations, ", ")
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestResourcesSkipUntakenBranches verifies that tasks on choice branches
+// that weren't chosen don't hold their resources, whichever task is
+// scheduled first
+func TestResourcesSkipUntakenBranches(t *testing.T) {
+	task := `"tasks": [{"name": "A", "type": "Fixed(10)", "resources": {"dev": 1}}]`
+	choice := `"review": {
+		"name": "Review",
+		"branches": [
+			{"name": "Approved", "weight": 99},
+			{
+				"name": "Redesign",
+				"weight": 1,
+				"activities": {"rework": [{"name": "Redesign", "type": "Fixed(10)", "resources": {"dev": 1}}]}
+			}
+		]
+	}`
+	testCases := map[string]string{
+		"task first":   task + ",\n" + choice,
+		"choice first": choice + ",\n" + task,
+	}
+	for name, eachActivities := range testCases {
+		t.Run(name, func(t *testing.T) {
+			planJSON := fmt.Sprintf(`{
+				"name": "Branch Resources",
+				"runCount": 20000,
+				"resources": {"dev": 1},
+				"activities": {%s}
+			}`, eachActivities)
+			for _, eachStreaming := range []bool{false, true} {
+				summary := evaluateTestPlan(t, planJSON, EvaluateOptions{Seed: 7, Streaming: eachStreaming}).Summary()
+				// Redesign runs, after A, in 1% of the runs
+				expectNear(t, "resource constrained finish", summary.Resources.Duration.Mean, 10.1, 0.03)
+				expectNear(t, "dev utilization", summary.Resources.Utilization["dev"], 1, 1e-9)
+			}
+		})
+	}
+}
+
+// TestResourceContention verifies that tasks competing for a resource are
+// serialized and that utilization is the busy fraction of the capacity
+func TestResourceContention(t *testing.T) {
+	planJSON := `{
+		"name": "Contention",
+		"runCount": 1000,
+		"resources": {"backend": 2, "frontend": 1},
+		"activities": {
+			"build": {
+				"API": {"type": "Fixed(10)", "resources": {"backend": 1}},
+				"Storage": {"type": "Fixed(8)", "resources": {"backend": 1}},
+				"Migration": {"type": "Fixed(6)", "resources": {"backend": 1}},
+				"UI": {"type": "Fixed(4)", "resources": {"frontend": 1}}
+			}
+		}
+	}`
+	summary := evaluateTestPlan(t, planJSON, EvaluateOptions{Seed: 7}).Summary()
+	// The unconstrained finish is the longest task. With two backend units
+	// the longest remaining path goes first and Migration waits for Storage.
+	expectNear(t, "unconstrained finish", summary.Duration.Mean, 10, 1e-9)
+	expectNear(t, "resource constrained finish", summary.Resources.Duration.Mean, 14, 1e-9)
+	expectNear(t, "backend utilization", summary.Resources.Utilization["backend"], 24.0/(2*14), 1e-9)
+	expectNear(t, "frontend utilization", summary.Resources.Utilization["frontend"], 4.0/14, 1e-9)
+}
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+
+	"github.com/mweagle/goestimate/generator"
+	"github.com/mweagle/goestimate/plan"
+	"github.com/mweagle/goestimate/stats"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// flowRisk
+//
+// An entry in the plan level risk register. Each risk is a Risk(...) generator
+// that is attached to either a named task or a named subgraph. An empty
+// attachment attaches the risk to the entire plan.
+//
+// /////////////////////////////////////////////////////////////////////////////
+type flowRisk struct {
+	name      string
+	attach    string
+	generator *generator.RiskGenerator
+	source    plan.Source
+}
+
+// riskContribution is the per-risk summary of how often the risk occurred
+// and how much it contributed to the tail of the final distribution
+type riskContribution struct {
+	risk              *flowRisk
+	tailThreshold     float64
+	triggeredRate     float64
+	meanImpact        float64
+	tailTriggeredRate float64
+	tailMeanImpact    float64
+}
+
+func unmarshalRiskRegister(riskDefs []*plan.Risk, log *slog.Logger) ([]*flowRisk, error) {
+	riskRegister := make([]*flowRisk, 0)
+	for i, eachRisk := range riskDefs {
+		durGenerator, durGeneratorErr := generator.NewDurationGeneratorFromExpression(eachRisk.Type, log)
+		if durGeneratorErr != nil {
+			return nil, eachRisk.Field("type").Wrap(durGeneratorErr)
+		}
+		riskGenerator, riskGeneratorOk := durGenerator.(*generator.RiskGenerator)
+		if !riskGeneratorOk {
+			return nil, eachRisk.Field("type").Wrap(fmt.Errorf("invalid risk type: %s. Risk register entries must be Risk(...) expressions", durGenerator.Name()))
+		}
+		riskName := eachRisk.Name
+		if len(riskName) <= 0 {
+			riskName = fmt.Sprintf("risk-%d", i)
+		}
+		riskRegister = append(riskRegister, &flowRisk{
+			name:      riskName,
+			attach:    eachRisk.Attach,
+			generator: riskGenerator,
+			source:    eachRisk.Field("attach"),
+		})
+	}
+	return riskRegister, nil
+}
+
+// attachRisks adds each risk's generator to the task or subgraph that
+// it references. Tasks are summed with their existing generator, subgraphs
+// add the risk to the subgraph's output join node.
+func (fg *flowGraph) attachRisks(log *slog.Logger) error {
+	for _, eachRisk := range fg.risks {
+		var targetTask *flowGraphNode
+		var targetSubgraph *flowSubgraph
+		matchCount := 0
+
+		if len(eachRisk.attach) <= 0 {
+			targetSubgraph = fg.flowSubgraph
+			matchCount = 1
+		} else {
+			for _, eachNode := range fg.sortedNodes() {
+				switch typedNode := eachNode.(type) {
+				case *flowGraphNode:
+					if typedNode.name == eachRisk.attach {
+						targetTask = typedNode
+						matchCount++
+					}
+				case *flowGraphPassThroughNode:
+					if typedNode.name == eachRisk.attach {
+						targetSubgraph = typedNode.parentFlowSubgraphs[len(typedNode.parentFlowSubgraphs)-1]
+						matchCount++
+					}
+				}
+			}
+		}
+		if matchCount != 1 {
+			return eachRisk.source.Wrap(fmt.Errorf("risk %s must attach to exactly one task or subgraph named %q. Found: %d",
+				eachRisk.name,
+				eachRisk.attach,
+				matchCount))
+		}
+		log.Debug("Attaching risk", "name", eachRisk.name, "attach", eachRisk.attach)
+
+		if targetTask != nil {
+			sumGenerator, sumGeneratorOk := targetTask.generator.(*generator.SumGenerator)
+			if !sumGeneratorOk {
+				sumGenerator = &generator.SumGenerator{
+					Generators: []generator.DurationGenerator{targetTask.generator},
+				}
+				targetTask.generator = sumGenerator
+			}
+			sumGenerator.Generators = append(sumGenerator.Generators, eachRisk.generator)
+		} else {
+			joinGenerator, joinGeneratorOk := targetSubgraph.outputJoinNode.generator.(generator.JoinGenerator)
+			if !joinGeneratorOk {
+				return fmt.Errorf("unsupported join generator for risk %s: %T", eachRisk.name, targetSubgraph.outputJoinNode.generator)
+			}
+			joinGenerator.AttachIncrement(eachRisk.generator)
+		}
+	}
+	return nil
+}
+
+// riskTally counts a risk's triggered runs, overall and in the tail of the
+// final distribution. Tallies of chunks of runs are summed.
+type riskTally struct {
+	runCount         int
+	triggerCount     int
+	tailCount        int
+	tailTriggerCount int
+	tailImpactSum    float64
+}
+
+func (rt *riskTally) add(other *riskTally) {
+	rt.runCount += other.runCount
+	rt.triggerCount += other.triggerCount
+	rt.tailCount += other.tailCount
+	rt.tailTriggerCount += other.tailTriggerCount
+	rt.tailImpactSum += other.tailImpactSum
+}
+
+// tailThreshold is the value of the largest requested percentile
+func tailThreshold(finalStats *stats.AggregatedStatistics) float64 {
+	threshold := math.Inf(-1)
+	for _, eachPercentile := range finalStats.Percentiles {
+		threshold = math.Max(threshold, eachPercentile.Val)
+	}
+	return threshold
+}
+
+// tallyRisks tallies each risk's triggered runs for the final values
+func (fg *flowGraph) tallyRisks(finalValues []float64, threshold float64) []*riskTally {
+	tallies := make([]*riskTally, len(fg.risks))
+	for riskIndex, eachRisk := range fg.risks {
+		riskValues := *eachRisk.generator.GenerationResults().RawValues
+		triggered := eachRisk.generator.Triggered()
+		tally := &riskTally{
+			runCount: len(finalValues),
+		}
+		for i := 0; i != len(finalValues); i++ {
+			if triggered[i] {
+				tally.triggerCount++
+			}
+			if finalValues[i] >= threshold {
+				tally.tailCount++
+				tally.tailImpactSum += riskValues[i]
+				if triggered[i] {
+					tally.tailTriggerCount++
+				}
+			}
+		}
+		tallies[riskIndex] = tally
+	}
+	return tallies
+}
+
+// riskContributions computes each risk's contribution to the tail of the
+// final distribution. The tail is every run at or above the largest
+// requested percentile. Streaming evaluations pool the tail of each chunk
+// of runs.
+func (fg *flowGraph) riskContributions() []*riskContribution {
+	contributions := make([]*riskContribution, 0, len(fg.risks))
+	if len(fg.risks) <= 0 {
+		return contributions
+	}
+	outputResults := fg.outputJoinNode.GenerationResults()
+	threshold := tailThreshold(outputResults.CumulativeStats)
+	var tallies []*riskTally
+	if fg.streaming {
+		tallies = fg.streamedAnalysis.riskTallies
+	} else {
+		tallies = fg.tallyRisks(*outputResults.CumulativeValues, threshold)
+	}
+	for riskIndex, eachRisk := range fg.risks {
+		tally := tallies[riskIndex]
+		contribution := &riskContribution{
+			risk:          eachRisk,
+			tailThreshold: threshold,
+			meanImpact:    eachRisk.generator.GenerationResults().GeneratorStats.Mean,
+			triggeredRate: float64(tally.triggerCount) / float64(tally.runCount),
+		}
+		if tally.tailCount != 0 {
+			contribution.tailTriggeredRate = float64(tally.tailTriggerCount) / float64(tally.tailCount)
+			contribution.tailMeanImpact = tally.tailImpactSum / float64(tally.tailCount)
+		}
+		contributions = append(contributions, contribution)
+	}
+	return contributions
+}
+
+func (fg *flowGraph) logRiskContributions(log *slog.Logger) {
+	for _, eachContribution := range fg.riskContributions() {
+		log.Info("Risk contribution",
+			"name", eachContribution.risk.name,
+			"attach", eachContribution.risk.attach,
+			"p", eachContribution.risk.generator.Probability(),
+			"triggered", fmt.Sprintf("%.2f%%", eachContribution.triggeredRate*100),
+			"meanImpact", fmt.Sprintf("%.2f", eachContribution.meanImpact),
+			"tailThreshold", fmt.Sprintf("%.2f", eachContribution.tailThreshold),
+			"tailTriggered", fmt.Sprintf("%.2f%%", eachContribution.tailTriggeredRate*100),
+			"tailMeanImpact", fmt.Sprintf("%.2f", eachContribution.tailMeanImpact))
+	}
+}
+
+// encodeD2RiskRegister writes the risk register as a markdown table node
+func (fg *flowGraph) encodeD2RiskRegister(nodeName string, output io.StringWriter) error {
+	contributions := fg.riskContributions()
+	if len(contributions) <= 0 {
+		return nil
+	}
+	// Markdown tables use pipes, so use the triple pipe block delimiter
+	tableContents := fmt.Sprintf(`%s : |||md
+# Risk Register
+
+Tail: runs ≥ %.2f
+
+| Risk | Attached To | p | Triggered | Mean Impact | Tail Triggered | Tail Mean Impact |
+|------|-------------|---|-----------|-------------|----------------|------------------|
+`,
+		nodeName,
+		contributions[0].tailThreshold)
+	for _, eachContribution := range contributions {
+		attach := eachContribution.risk.attach
+		if len(attach) <= 0 {
+			attach = fg.name
+		}
+		tableContents += fmt.Sprintf("| %s | %s | %.2f | %.2f%% | %.2f | %.2f%% | %.2f |\n",
+			eachContribution.risk.name,
+			attach,
+			eachContribution.risk.generator.Probability(),
+			eachContribution.triggeredRate*100,
+			eachContribution.meanImpact,
+			eachContribution.tailTriggeredRate*100,
+			eachContribution.tailMeanImpact)
+	}
+	tableContents += "|||\n\n"
+	_, writeErr := output.WriteString(tableContents)
+	return writeErr
+}
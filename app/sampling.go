@@ -0,0 +1,163 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/mweagle/goestimate/generator"
+	gonumstat "gonum.org/v1/gonum/stat"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Sampling
+//
+// Variance reduced sampling strategies split each chunk's runs into
+// independently randomized replicates. The variance of the replicates'
+// estimates, compared to the variance plain Monte Carlo sampling would
+// have, is the variance reduction. The effective sample size is the number
+// of plain Monte Carlo runs with the same variance.
+//
+// The variance of a percentile is the variance of the fraction of each
+// replicate's runs below the chunk's percentile, which is p(1-p)/m for
+// replicates of m plain Monte Carlo runs.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// samplingEfficiency is the variance reduction of the mean and each
+// percentile of the total duration
+type samplingEfficiency struct {
+	runCount int
+	// mcVariances and variances are, for the mean and then each percentile,
+	// the sums of each chunk's plain Monte Carlo and observed variance of a
+	// replicate's estimate
+	mcVariances []float64
+	variances   []float64
+}
+
+// newSamplingEfficiency estimates the variances of the replicates of a
+// chunk's total durations
+func newSamplingEfficiency(values []float64, percentiles []float64) *samplingEfficiency {
+	efficiency := &samplingEfficiency{
+		runCount:    len(values),
+		mcVariances: make([]float64, len(percentiles)+1),
+		variances:   make([]float64, len(percentiles)+1),
+	}
+	replicates := make([][]float64, 0)
+	bounds := generator.ReplicateBounds(len(values))
+	for i := 0; i != len(bounds)-1; i++ {
+		if bounds[i+1] > bounds[i] {
+			replicates = append(replicates, values[bounds[i]:bounds[i+1]])
+		}
+	}
+	if len(replicates) < 2 {
+		return efficiency
+	}
+	replicateSize := float64(len(values)) / float64(len(replicates))
+	replicateEstimates := make([]float64, len(replicates))
+
+	// The mean
+	for i, eachReplicate := range replicates {
+		replicateEstimates[i] = gonumstat.Mean(eachReplicate, nil)
+	}
+	efficiency.mcVariances[0] = gonumstat.Variance(values, nil) / replicateSize
+	efficiency.variances[0] = gonumstat.Variance(replicateEstimates, nil)
+
+	// And the fraction of runs below each percentile
+	sortedValues := make([]float64, len(values))
+	copy(sortedValues, values)
+	sort.Float64s(sortedValues)
+	for j, eachPercentile := range percentiles {
+		if eachPercentile > 1.00 {
+			eachPercentile = eachPercentile / 100
+		}
+		threshold := gonumstat.Quantile(eachPercentile, gonumstat.Empirical, sortedValues, nil)
+		for i, eachReplicate := range replicates {
+			belowCount := 0
+			for _, eachValue := range eachReplicate {
+				if eachValue <= threshold {
+					belowCount++
+				}
+			}
+			replicateEstimates[i] = float64(belowCount) / float64(len(eachReplicate))
+		}
+		efficiency.mcVariances[j+1] = eachPercentile * (1 - eachPercentile) / replicateSize
+		efficiency.variances[j+1] = gonumstat.Variance(replicateEstimates, nil)
+	}
+	return efficiency
+}
+
+// merge adds the variances of the next chunk
+func (se *samplingEfficiency) merge(next *samplingEfficiency) {
+	se.runCount += next.runCount
+	for i := range se.variances {
+		se.mcVariances[i] += next.mcVariances[i]
+		se.variances[i] += next.variances[i]
+	}
+}
+
+// varianceReductions returns the variance reduction of the mean and then
+// each percentile. Estimates without observed variance aren't reduced.
+func (se *samplingEfficiency) varianceReductions() []float64 {
+	reductions := make([]float64, len(se.variances))
+	for i, eachVariance := range se.variances {
+		reductions[i] = 1
+		if eachVariance > 0 {
+			reductions[i] = se.mcVariances[i] / eachVariance
+		}
+	}
+	return reductions
+}
+
+// samplingNames returns the name of the mean and then each percentile
+func (fg *flowGraph) samplingNames() []string {
+	names := []string{"mean"}
+	for _, eachPercentile := range fg.percentiles {
+		if eachPercentile > 1.00 {
+			eachPercentile = eachPercentile / 100
+		}
+		names = append(names, percentileName(eachPercentile))
+	}
+	return names
+}
+
+// samplingSummary returns the sampling strategy and the effective sample
+// sizes of variance reduced strategies
+func (fg *flowGraph) samplingSummary() *SamplingSummary {
+	summary := &SamplingSummary{
+		Strategy: fg.sampling,
+	}
+	if fg.samplingEfficiency == nil {
+		return summary
+	}
+	summary.EffectiveSampleSize = make(map[string]float64)
+	summary.VarianceReduction = make(map[string]float64)
+	reductions := fg.samplingEfficiency.varianceReductions()
+	for i, eachName := range fg.samplingNames() {
+		summary.VarianceReduction[eachName] = reductions[i]
+		summary.EffectiveSampleSize[eachName] = reductions[i] * float64(fg.startNode.runCount)
+	}
+	return summary
+}
+
+// samplingFormatter formats the variance reduction of each estimate
+func (fg *flowGraph) samplingFormatter() string {
+	value := ""
+	reductions := fg.samplingEfficiency.varianceReductions()
+	for i, eachName := range fg.samplingNames() {
+		value += fmt.Sprintf("%s=×%.2f, ", eachName, reductions[i])
+	}
+	return strings.TrimSuffix(value, ", ")
+}
+
+func (fg *flowGraph) logSampling(log *slog.Logger) {
+	if fg.samplingEfficiency == nil {
+		return
+	}
+	summary := fg.samplingSummary()
+	log.Info("Sampling",
+		"strategy", fg.sampling,
+		"varianceReduction", fg.samplingFormatter(),
+		"effectiveRuns", fmt.Sprintf("%.0f", summary.EffectiveSampleSize["mean"]))
+}
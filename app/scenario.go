@@ -0,0 +1,333 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"io"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mweagle/goestimate/plan"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Scenarios
+//
+// What-if scenarios are variations of the plan that override task types,
+// add or remove tasks, or change the calendar. Each scenario is evaluated
+// with the plan's seed and run count using common random numbers: every
+// node draws from its own random number stream, seeded by the chunk's seed
+// and the node's location in the plan, so the tasks a scenario doesn't
+// change sample the same durations as the plan. The differences between the
+// scenarios are then due to their changes rather than to sampling noise.
+// Sobol sequences assign their dimensions in evaluation order, so sobol
+// sampling only shares the pseudo-random draws.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// SCENARIO_CDF_POINTS is the number of probability steps of each CDF in the
+// scenario comparison plot
+var SCENARIO_CDF_POINTS = 200
+
+// scenarioComparison are the evaluated scenarios of the plan
+type scenarioComparison struct {
+	graphs []*flowGraph
+	// plotPath is the overlaid CDFs next to the histogram, if the plan was
+	// evaluated with plots
+	plotPath string
+}
+
+// unmarshalScenarios builds the graph of each of the plan's scenarios with
+// the same run overrides as the plan
+func (fg *flowGraph) unmarshalScenarios(opts EvaluateOptions, log *slog.Logger) error {
+	if len(fg.planDef.Scenarios) == 0 {
+		return nil
+	}
+	fg.commonRandomNumbers = true
+	fg.scenarios = &scenarioComparison{}
+	for _, eachScenario := range fg.planDef.Scenarios {
+		// Overrides are located at the scenario rather than at the task
+		// they override
+		overridesSource := eachScenario.Field("overrides")
+		for _, eachName := range sortedKeys(eachScenario.Overrides) {
+			_, overrideErr := unmarshalTaskGenerator(&plan.Task{
+				Name: eachName,
+				Type: eachScenario.Overrides[eachName],
+			}, log)
+			if overrideErr != nil {
+				return &InvalidPlanError{Err: overridesSource.Field(eachName).Wrap(overrideErr)}
+			}
+		}
+		scenarioPlan, scenarioPlanErr := fg.planDef.ScenarioPlan(eachScenario)
+		if scenarioPlanErr != nil {
+			return &InvalidPlanError{Err: scenarioPlanErr}
+		}
+		log.Debug("Building scenario", "name", eachScenario.Name)
+		scenarioGraph, scenarioGraphErr := newEvaluationGraph(scenarioPlan, opts, log)
+		if scenarioGraphErr != nil {
+			return scenarioGraphErr
+		}
+		scenarioGraph.commonRandomNumbers = true
+		scenarioGraph.sensitivity = nil
+		fg.scenarios.graphs = append(fg.scenarios.graphs, scenarioGraph)
+	}
+	return nil
+}
+
+// commonRandomSources returns the random number stream of each node. Tasks
+// are keyed by their location in the plan document and the other nodes by
+// their subgraph path, so the streams don't depend on the tasks that were
+// added or removed.
+func (fg *flowGraph) commonRandomSources() map[int64]rand.Source {
+	sources := make(map[int64]rand.Source)
+	keyCounts := make(map[string]int)
+	for _, eachNode := range fg.sortedNodes() {
+		var node *flowGraphNode
+		switch typedNode := eachNode.(type) {
+		case *flowGraphNode:
+			node = typedNode
+		case *flowGraphJoinMaxValueNode:
+			node = &typedNode.flowGraphNode
+		case *flowGraphPassThroughNode:
+			node = &typedNode.flowGraphNode
+		case *flowGraphStartNode:
+			node = &typedNode.flowGraphNode
+		}
+		streamKey := node.streamKey
+		if len(streamKey) == 0 {
+			pathNames := make([]string, 0, len(node.parentFlowSubgraphs)+1)
+			for _, eachSubgraph := range node.parentFlowSubgraphs {
+				pathNames = append(pathNames, eachSubgraph.inputNode.name)
+			}
+			streamKey = strings.Join(append(pathNames, node.name), "/")
+		}
+		// Nodes with the same key draw distinct streams
+		keyCounts[streamKey]++
+		if keyCounts[streamKey] > 1 {
+			streamKey = fmt.Sprintf("%s#%d", streamKey, keyCounts[streamKey])
+		}
+		sources[eachNode.ID()] = rand.NewSource(streamSeed(fg.seed, streamKey))
+	}
+	return sources
+}
+
+// evaluateScenarios evaluates each scenario with the plan's run count
+func (fg *flowGraph) evaluateScenarios(ctx context.Context, log *slog.Logger) error {
+	for _, eachGraph := range fg.scenarios.graphs {
+		log.Info("Evaluating scenario", "name", eachGraph.name)
+		eachGraph.startNode.runCount = fg.startNode.runCount
+		eachGraph.convergence = nil
+		evalErr := eachGraph.Evaluate(ctx, log.With("scenario", eachGraph.name))
+		if evalErr != nil {
+			return fmt.Errorf("failed to evaluate scenario %s: %w", eachGraph.name, evalErr)
+		}
+	}
+	return nil
+}
+
+// comparisonGraphs returns the plan followed by its scenarios
+func (fg *flowGraph) comparisonGraphs() []*flowGraph {
+	return append([]*flowGraph{fg}, fg.scenarios.graphs...)
+}
+
+// comparisonMetrics returns the names and values of the mean and the
+// percentiles of the total duration
+func (fg *flowGraph) comparisonMetrics() ([]string, []float64) {
+	outputStats := fg.outputJoinNode.GenerationResults().CumulativeStats
+	names := []string{"mean"}
+	values := []float64{outputStats.Mean}
+	for _, eachPercentile := range outputStats.Percentiles {
+		names = append(names, percentileName(eachPercentile.P))
+		values = append(values, eachPercentile.Val)
+	}
+	return names, values
+}
+
+// completionDate returns the completion date of the duration, if the plan
+// uses workdays
+func (fg *flowGraph) completionDate(duration float64) string {
+	aggregationOptions := fg.flowSubgraph.aggregationOptions
+	if !aggregationOptions.workdays {
+		return ""
+	}
+	return workdayWithOffset(aggregationOptions.calendar, duration).Format(ECD_TIME_FORMAT)
+}
+
+// scenarioFormatter formats the value and, for scenarios, its change from
+// the plan
+func scenarioFormatter(value float64, baseline float64, scenario bool) string {
+	if !scenario {
+		return fmt.Sprintf("%.2f", value)
+	}
+	return fmt.Sprintf("%.2f (%+.2f)", value, value-baseline)
+}
+
+func (fg *flowGraph) logScenarios(log *slog.Logger) {
+	if fg.scenarios == nil {
+		return
+	}
+	_, baselineValues := fg.comparisonMetrics()
+	for i, eachGraph := range fg.comparisonGraphs() {
+		names, values := eachGraph.comparisonMetrics()
+		logArgs := []interface{}{"name", eachGraph.name}
+		for j, eachName := range names {
+			logArgs = append(logArgs, eachName, scenarioFormatter(values[j], baselineValues[j], i != 0))
+		}
+		completionDate := eachGraph.completionDate(values[0])
+		if len(completionDate) != 0 {
+			logArgs = append(logArgs, "ecd", completionDate)
+		}
+		log.Info("Scenario", logArgs...)
+	}
+}
+
+// scenarioSummaries returns the total duration of each scenario
+func (fg *flowGraph) scenarioSummaries() []*ScenarioSummary {
+	_, baselineValues := fg.comparisonMetrics()
+	summaries := make([]*ScenarioSummary, 0, len(fg.scenarios.graphs))
+	for _, eachGraph := range fg.scenarios.graphs {
+		names, values := eachGraph.comparisonMetrics()
+		scenarioSummary := &ScenarioSummary{
+			Name:     eachGraph.name,
+			Duration: newStatsSummary(eachGraph.outputJoinNode.GenerationResults().CumulativeStats),
+			Delta:    make(map[string]float64),
+		}
+		for i, eachName := range names {
+			scenarioSummary.Delta[eachName] = values[i] - baselineValues[i]
+			completionDate := eachGraph.completionDate(values[i])
+			if len(completionDate) != 0 {
+				if scenarioSummary.CompletionDates == nil {
+					scenarioSummary.CompletionDates = make(map[string]string)
+				}
+				scenarioSummary.CompletionDates[eachName] = completionDate
+			}
+		}
+		summaries = append(summaries, scenarioSummary)
+	}
+	return summaries
+}
+
+// scenariosPlotPath is the scenario comparison plot path next to the
+// histogram
+func scenariosPlotPath(histogramPath string) string {
+	return strings.TrimSuffix(histogramPath, filepath.Ext(histogramPath)) + "-scenarios" + filepath.Ext(histogramPath)
+}
+
+// cdfPoints returns the CDF of the total duration at equal probability
+// steps
+func (fg *flowGraph) cdfPoints() plotter.XYs {
+	genResults := fg.outputJoinNode.GenerationResults()
+	var sortedSamples []float64
+	if !fg.streaming {
+		sortedSamples = make([]float64, len(*genResults.CumulativeValues))
+		copy(sortedSamples, *genResults.CumulativeValues)
+		sort.Float64s(sortedSamples)
+	}
+	cdfValues := make(plotter.XYs, SCENARIO_CDF_POINTS+1)
+	for i := range cdfValues {
+		probability := float64(i) / float64(SCENARIO_CDF_POINTS)
+		if fg.streaming {
+			cdfValues[i].X = genResults.CumulativeSummary.Digest().Quantile(probability)
+		} else {
+			cdfValues[i].X = sortedSamples[int(math.Round(probability*float64(len(sortedSamples)-1)))]
+		}
+		cdfValues[i].Y = probability
+	}
+	return cdfValues
+}
+
+// scenariosPlot overlays the CDFs of the total duration of the plan and
+// each of its scenarios
+func (fg *flowGraph) scenariosPlot() (*plot.Plot, error) {
+	p := plot.New()
+	p.X.Label.Text = "Total Duration"
+	p.Y.Label.Text = "Probability"
+	p.Title.Text = "Scenario Comparison"
+	p.Title.TextStyle.Color = color.RGBA{B: 255, A: 255}
+	p.Legend.Top = true
+	p.Legend.Left = true
+	for i, eachGraph := range fg.comparisonGraphs() {
+		line, lineErr := plotter.NewLine(eachGraph.cdfPoints())
+		if lineErr != nil {
+			return nil, lineErr
+		}
+		line.LineStyle.Width = vg.Points(2)
+		line.LineStyle.Color = plotutil.Color(i)
+		line.LineStyle.Dashes = plotutil.Dashes(i)
+		p.Add(line)
+		p.Legend.Add(eachGraph.name, line)
+	}
+	if fg.flowSubgraph.deadline != nil {
+		deadline := fg.flowSubgraph.deadline
+		line, lineErr := plotter.NewLine(plotter.XYs{
+			{X: deadline.offset, Y: 0},
+			{X: deadline.offset, Y: 1},
+		})
+		if lineErr != nil {
+			return nil, lineErr
+		}
+		line.LineStyle.Width = vg.Points(2)
+		line.LineStyle.Color = color.RGBA{R: 220, G: 20, B: 60, A: 255}
+		p.Add(line)
+		p.Legend.Add(fmt.Sprintf("Deadline %s", deadline.String()), line)
+	}
+	p.Add(plotter.NewGrid())
+	return p, nil
+}
+
+// encodeD2Scenarios writes the comparison tables of the plan and its
+// scenarios, and the overlaid CDFs
+func (fg *flowGraph) encodeD2Scenarios(nodeName string, plotNodeName string, output io.StringWriter) error {
+	names, baselineValues := fg.comparisonMetrics()
+	tableHeader := "| Scenario |"
+	tableSeparator := "|---|"
+	for _, eachName := range names {
+		tableHeader += fmt.Sprintf(" %s |", eachName)
+		tableSeparator += "---|"
+	}
+	nodeContents := fmt.Sprintf("%s : |||md\n# Scenarios\n\n%s\n%s\n", nodeName, tableHeader, tableSeparator)
+	dateRows := ""
+	for i, eachGraph := range fg.comparisonGraphs() {
+		_, values := eachGraph.comparisonMetrics()
+		nodeContents += fmt.Sprintf("| %s |", eachGraph.name)
+		dateRow := fmt.Sprintf("| %s |", eachGraph.name)
+		for j, eachValue := range values {
+			nodeContents += fmt.Sprintf(" %s |", scenarioFormatter(eachValue, baselineValues[j], i != 0))
+			dateRow += fmt.Sprintf(" %s |", eachGraph.completionDate(eachValue))
+		}
+		nodeContents += "\n"
+		if eachGraph.flowSubgraph.aggregationOptions.workdays {
+			dateRows += dateRow + "\n"
+		}
+	}
+	// The completion dates of the plan and scenarios that use workdays
+	if len(dateRows) != 0 {
+		nodeContents += fmt.Sprintf("\n## Completion Dates\n\n%s\n%s\n%s", tableHeader, tableSeparator, dateRows)
+	}
+	nodeContents += "|||\n\n"
+	// The plot only exists if the plan was evaluated with plots
+	if len(fg.scenarios.plotPath) == 0 {
+		_, writeErr := output.WriteString(nodeContents)
+		return writeErr
+	}
+	nodeContents += fmt.Sprintf(`%s: Scenario Comparison {
+shape: image
+icon: %s
+width: 768
+height: 768
+}
+`,
+		plotNodeName,
+		fg.scenarios.plotPath)
+	_, writeErr := output.WriteString(nodeContents)
+	return writeErr
+}
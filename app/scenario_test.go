@@ -0,0 +1,57 @@
+package app
+
+import "testing"
+
+// The Docs lane never outlasts the serial tasks, so the total duration is
+// the sum of the serial tasks
+const scenariosPlan = `{
+	"name": "As Planned",
+	"runCount": 5000,
+	"percentiles": [50, 95],
+	"activities": {
+		"build": [
+			{"name": "Design", "type": "PERT(3,5,10)"},
+			{"name": "Build", "type": "PERT(10,15,30)"},
+			{"name": "Gate", "type": "Fixed(2)"}
+		],
+		"docs": [
+			{"name": "Docs", "type": "PERT(1,2,3)"}
+		]
+	},
+	"scenarios": [
+		{"name": "Unchanged", "overrides": {"Gate": "Fixed(2)"}},
+		{"name": "Slower gate", "overrides": {"Gate": "Fixed(3)"}},
+		{"name": "Onboarding", "add": [{"after": "Design", "task": {"name": "Onboarding", "type": "Fixed(1)"}}]},
+		{"name": "No gate", "remove": ["Gate"]}
+	]
+}`
+
+// Common random numbers sample the same durations for the tasks a scenario
+// doesn't change, so a scenario's deltas are exactly its changes
+func TestScenarioCommonRandomNumbers(t *testing.T) {
+	expected := map[string]float64{
+		"Unchanged":   0,
+		"Slower gate": 1,
+		"Onboarding":  1,
+		"No gate":     -2,
+	}
+	for name, eachOpts := range map[string]EvaluateOptions{
+		"mc":  {Seed: 7},
+		"lhs": {Seed: 7, Sampling: "lhs"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			summary := evaluateTestPlan(t, scenariosPlan, eachOpts).Summary()
+			if len(summary.Scenarios) != len(expected) {
+				t.Fatalf("invalid scenario count. Expected: %d, Found: %d", len(expected), len(summary.Scenarios))
+			}
+			for _, eachScenario := range summary.Scenarios {
+				if len(eachScenario.Delta) != 3 {
+					t.Errorf("invalid %s deltas: %v. Expected the mean, p50 and p95", eachScenario.Name, eachScenario.Delta)
+				}
+				for metric, eachDelta := range eachScenario.Delta {
+					expectNear(t, eachScenario.Name+" "+metric+" delta", eachDelta, expected[eachScenario.Name], 1e-9)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package app
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mweagle/goestimate/stats"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Sensitivity
+//
+// Sensitivity analysis ranks the tasks by how much their uncertainty drives
+// the plan's total duration. Each task's sampled durations are compared to
+// the outputJoinNode cumulative values of the same runs:
+//
+//   - spearman: the Spearman rank correlation of the task and the total
+//   - sobol: the rank correlation and the first-order Sobol index, the
+//     fraction of the total's variance explained by the task alone,
+//     estimated from the runs by binning them by the task's duration
+//
+// The tasks are ranked by their Sobol index if it's computed, otherwise by
+// the magnitude of their rank correlation. Streaming evaluations average
+// the sensitivity of each chunk of runs.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// Sensitivity methods
+const (
+	SENSITIVITY_SPEARMAN = "spearman"
+	SENSITIVITY_SOBOL    = "sobol"
+)
+
+// SENSITIVITY_METHODS are the supported sensitivity analysis methods
+var SENSITIVITY_METHODS = []string{
+	SENSITIVITY_SPEARMAN,
+	SENSITIVITY_SOBOL,
+}
+
+// SENSITIVITY_SOBOL_BINS is the number of bins the runs are sorted into to
+// estimate a task's first-order Sobol index
+var SENSITIVITY_SOBOL_BINS = 50
+
+// SENSITIVITY_CHART_TASKS is the maximum number of tasks in the tornado
+// chart
+var SENSITIVITY_CHART_TASKS = 15
+
+// ValidateSensitivityMethod returns an error if the method isn't supported
+func ValidateSensitivityMethod(method string) error {
+	for _, eachMethod := range SENSITIVITY_METHODS {
+		if method == eachMethod {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sensitivity method: %s. Expected one of: %v", method, SENSITIVITY_METHODS)
+}
+
+// taskSensitivity is the sensitivity of the total duration to a task
+type taskSensitivity struct {
+	task     *flowGraphNode
+	spearman float64
+	sobol    float64
+}
+
+// sensitivityAnalysis is the ranked sensitivity of each task
+type sensitivityAnalysis struct {
+	method string
+	tasks  []*taskSensitivity
+	// plotPath is the tornado chart next to the histogram, if the plan was
+	// evaluated with plots
+	plotPath string
+}
+
+func newSensitivityAnalysis(method string) *sensitivityAnalysis {
+	return &sensitivityAnalysis{
+		method: method,
+	}
+}
+
+// sensitivityTasks returns the tasks that sample a duration, in document
+// order
+func (fg *flowGraph) sensitivityTasks() []*flowGraphNode {
+	tasks := make([]*flowGraphNode, 0)
+	for _, eachNode := range fg.sortedNodes() {
+		taskNode, taskNodeOk := eachNode.(*flowGraphNode)
+		if taskNodeOk && taskNode.generator != nil {
+			tasks = append(tasks, taskNode)
+		}
+	}
+	return tasks
+}
+
+// taskSensitivityValues returns the rank correlation and, for the sobol
+// method, the first-order Sobol index of each task's runs, keyed by task
+// ID. Tasks with a constant duration have no sensitivity.
+func (fg *flowGraph) taskSensitivityValues() map[int64][]float64 {
+	totalValues := *fg.outputJoinNode.GenerationResults().CumulativeValues
+	values := make(map[int64][]float64)
+	for _, eachTask := range fg.sensitivityTasks() {
+		taskValues := *eachTask.GenerationResults().RawValues
+		spearman := stats.SpearmanCorrelation(taskValues, totalValues)
+		if math.IsNaN(spearman) {
+			spearman = 0
+		}
+		sobol := float64(0)
+		if fg.sensitivity.method == SENSITIVITY_SOBOL {
+			sobol = stats.FirstOrderSobolIndex(taskValues, totalValues, SENSITIVITY_SOBOL_BINS)
+		}
+		values[eachTask.ID()] = []float64{spearman, sobol}
+	}
+	return values
+}
+
+// computeSensitivity ranks the tasks by their sensitivity
+func (fg *flowGraph) computeSensitivity() {
+	analysis := fg.sensitivity
+	var values map[int64][]float64
+	if fg.streaming {
+		values = fg.streamedAnalysis.taskSensitivities()
+	} else {
+		values = fg.taskSensitivityValues()
+	}
+	analysis.tasks = make([]*taskSensitivity, 0)
+	for _, eachTask := range fg.sensitivityTasks() {
+		analysis.tasks = append(analysis.tasks, &taskSensitivity{
+			task:     eachTask,
+			spearman: values[eachTask.ID()][0],
+			sobol:    values[eachTask.ID()][1],
+		})
+	}
+	// Ties keep document order
+	sort.SliceStable(analysis.tasks, func(i, j int) bool {
+		if analysis.method == SENSITIVITY_SOBOL {
+			return analysis.tasks[i].sobol > analysis.tasks[j].sobol
+		}
+		return math.Abs(analysis.tasks[i].spearman) > math.Abs(analysis.tasks[j].spearman)
+	})
+}
+
+// summary returns the ranked sensitivity of each task
+func (sa *sensitivityAnalysis) summary() *SensitivitySummary {
+	summary := &SensitivitySummary{
+		Method: sa.method,
+		Tasks:  make([]*TaskSensitivity, len(sa.tasks)),
+	}
+	for i, eachTask := range sa.tasks {
+		summary.Tasks[i] = &TaskSensitivity{
+			Name:     eachTask.task.name,
+			Spearman: eachTask.spearman,
+		}
+		if sa.method == SENSITIVITY_SOBOL {
+			sobol := eachTask.sobol
+			summary.Tasks[i].Sobol = &sobol
+		}
+	}
+	return summary
+}
+
+func (fg *flowGraph) logSensitivity(log *slog.Logger) {
+	if fg.sensitivity == nil {
+		return
+	}
+	for i, eachTask := range fg.sensitivity.tasks {
+		if fg.sensitivity.method == SENSITIVITY_SOBOL {
+			log.Info("Task sensitivity",
+				"rank", i+1,
+				"name", eachTask.task.name,
+				"spearman", fmt.Sprintf("%.2f", eachTask.spearman),
+				"sobol", fmt.Sprintf("%.2f", eachTask.sobol))
+		} else {
+			log.Info("Task sensitivity",
+				"rank", i+1,
+				"name", eachTask.task.name,
+				"spearman", fmt.Sprintf("%.2f", eachTask.spearman))
+		}
+	}
+}
+
+// sensitivityPlotPath is the tornado chart path next to the histogram
+func sensitivityPlotPath(histogramPath string) string {
+	return strings.TrimSuffix(histogramPath, filepath.Ext(histogramPath)) + "-sensitivity" + filepath.Ext(histogramPath)
+}
+
+// sensitivityPlot plots the rank correlation, and Sobol index, of the most
+// sensitive tasks as a tornado chart, the most sensitive task at the top
+func (fg *flowGraph) sensitivityPlot() (*plot.Plot, error) {
+	analysis := fg.sensitivity
+	chartTasks := analysis.tasks[:min(len(analysis.tasks), SENSITIVITY_CHART_TASKS)]
+	p := plot.New()
+	p.X.Label.Text = "Sensitivity of the Total Duration"
+	p.Title.Text = "Task Sensitivity"
+	p.Title.TextStyle.Color = color.RGBA{B: 255, A: 255}
+	if len(chartTasks) == 0 {
+		return p, nil
+	}
+
+	names := make([]string, len(chartTasks))
+	correlations := make(plotter.Values, len(chartTasks))
+	sobolIndices := make(plotter.Values, len(chartTasks))
+	for i, eachTask := range chartTasks {
+		row := len(chartTasks) - 1 - i
+		names[row] = eachTask.task.name
+		correlations[row] = eachTask.spearman
+		sobolIndices[row] = eachTask.sobol
+	}
+	barWidth := vg.Points(16)
+	correlationBars, correlationBarsErr := plotter.NewBarChart(correlations, barWidth)
+	if correlationBarsErr != nil {
+		return nil, correlationBarsErr
+	}
+	correlationBars.Horizontal = true
+	correlationBars.Color = plotutil.Color(0)
+	correlationBars.LineStyle.Width = 0
+	p.Add(correlationBars)
+	p.Legend.Add("Spearman ρ", correlationBars)
+	if analysis.method == SENSITIVITY_SOBOL {
+		sobolBars, sobolBarsErr := plotter.NewBarChart(sobolIndices, barWidth)
+		if sobolBarsErr != nil {
+			return nil, sobolBarsErr
+		}
+		sobolBars.Horizontal = true
+		sobolBars.Color = plotutil.Color(1)
+		sobolBars.LineStyle.Width = 0
+		correlationBars.Offset = barWidth / 2
+		sobolBars.Offset = -barWidth / 2
+		p.Add(sobolBars)
+		p.Legend.Add("First-order Sobol index", sobolBars)
+	}
+	p.NominalY(names...)
+	// Pad the bars away from the edges. The least sensitive tasks are at
+	// the bottom, which leaves room for the legend.
+	xPadding := 0.1 * math.Max(p.X.Max-p.X.Min, 0.1)
+	p.X.Min = math.Min(p.X.Min, 0) - xPadding
+	p.X.Max = math.Max(p.X.Max, 0) + xPadding
+	p.Y.Min -= 0.5
+	p.Y.Max += 0.5
+	p.Add(plotter.NewGrid())
+	return p, nil
+}
+
+// encodeD2Sensitivity writes the ranked sensitivity table and the tornado
+// chart
+func (fg *flowGraph) encodeD2Sensitivity(nodeName string, plotNodeName string, output io.StringWriter) error {
+	analysis := fg.sensitivity
+	nodeContents := fmt.Sprintf("%s : |||md\n# Sensitivity\n\n", nodeName)
+	if analysis.method == SENSITIVITY_SOBOL {
+		nodeContents += "| Rank | Task | Spearman ρ | Sobol Index |\n|---|---|---|---|\n"
+	} else {
+		nodeContents += "| Rank | Task | Spearman ρ |\n|---|---|---|\n"
+	}
+	for i, eachTask := range analysis.tasks {
+		if analysis.method == SENSITIVITY_SOBOL {
+			nodeContents += fmt.Sprintf("| %d | %s | %.2f | %.2f |\n", i+1, eachTask.task.name, eachTask.spearman, eachTask.sobol)
+		} else {
+			nodeContents += fmt.Sprintf("| %d | %s | %.2f |\n", i+1, eachTask.task.name, eachTask.spearman)
+		}
+	}
+	nodeContents += "|||\n\n"
+	// The plot only exists if the plan was evaluated with plots
+	if len(analysis.plotPath) == 0 {
+		_, writeErr := output.WriteString(nodeContents)
+		return writeErr
+	}
+	nodeContents += fmt.Sprintf(`%s: Task Sensitivity {
+shape: image
+icon: %s
+width: 768
+height: 768
+}
+`,
+		plotNodeName,
+		analysis.plotPath)
+	_, writeErr := output.WriteString(nodeContents)
+	return writeErr
+}
@@ -0,0 +1,227 @@
+package app
+
+import (
+	"log/slog"
+
+	"github.com/mweagle/goestimate/stats"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/graph/topo"
+	"gonum.org/v1/plot/plotter"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Streaming
+//
+// Streaming evaluations don't retain the per run samples, so the memory used
+// is bounded by the chunks in flight rather than the number of runs. Each
+// chunk's runs are analyzed before its samples are replaced by mergeable
+// summaries: exact moments and a t-digest quantile sketch for every node,
+// and the counts and run weighted sums of the deadline, risk, correlation,
+// resource and cost analyses. The summaries are merged in chunk order, so
+// the results don't depend on the number of workers.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// chunkAnalysis summarizes the analyses of one or more chunks of runs
+type chunkAnalysis struct {
+	runCount          int
+	deadlineMetCounts map[int64]int
+	riskTallies       []*riskTally
+	// correlationSums are the run weighted sums of each chunk's achieved
+	// correlation matrix
+	correlationSums [][]float64
+	finishSummary   *stats.Accumulator
+	// utilizationSums and chainCounts are the run weighted sums of each
+	// chunk's resource utilization and critical chain frequency
+	utilizationSums   map[string]float64
+	chainCounts       map[int64]float64
+	costSummary       *stats.Accumulator
+	taskCostSummaries map[int64]*stats.Accumulator
+	overrunCount      int
+	// costScatter is the cost and duration of each run of the first chunk
+	costScatter plotter.XYs
+	// sensitivitySums are the run weighted sums of each chunk's task
+	// sensitivity values
+	sensitivitySums map[int64][]float64
+}
+
+// analyzeChunk analyzes the runs of a chunk graph whose samples have been
+// generated. The source continues the chunk's random number stream.
+func (fg *flowGraph) analyzeChunk(src rand.Source, firstChunk bool, log *slog.Logger) (*chunkAnalysis, error) {
+	sortedNodes, sortedNodesErr := topo.SortStabilized(fg, nil)
+	if sortedNodesErr != nil {
+		return nil, sortedNodesErr
+	}
+	topoOrder := make([]int64, len(sortedNodes))
+	for i, eachNode := range sortedNodes {
+		topoOrder[i] = eachNode.ID()
+	}
+	outputResults := fg.outputJoinNode.GenerationResults()
+	finalValues := *outputResults.CumulativeValues
+	analysis := &chunkAnalysis{
+		runCount:          len(finalValues),
+		deadlineMetCounts: make(map[int64]int),
+		utilizationSums:   make(map[string]float64),
+		chainCounts:       make(map[int64]float64),
+		taskCostSummaries: make(map[int64]*stats.Accumulator),
+	}
+	for _, eachSubgraph := range fg.deadlineSubgraphs() {
+		analysis.deadlineMetCounts[eachSubgraph.outputJoinNode.ID()] = eachSubgraph.deadlineMetCount()
+	}
+	// The chunk's statistics are deferred, so the tail is computed here
+	if len(fg.risks) != 0 {
+		finalStats := stats.StatsForSequence(finalValues, fg.percentiles)
+		analysis.riskTallies = fg.tallyRisks(finalValues, tailThreshold(finalStats))
+	}
+	if fg.sensitivity != nil {
+		analysis.sensitivitySums = fg.taskSensitivityValues()
+		for _, eachValues := range analysis.sensitivitySums {
+			for i := range eachValues {
+				eachValues[i] *= float64(analysis.runCount)
+			}
+		}
+	}
+	if len(fg.correlations.taskNames) != 0 {
+		analysis.correlationSums = fg.achievedCorrelations()
+		for _, eachRow := range analysis.correlationSums {
+			for j := range eachRow {
+				eachRow[j] *= float64(analysis.runCount)
+			}
+		}
+	}
+	if len(fg.resources) != 0 {
+		scheduleErr := fg.scheduleResourceConstrained(topoOrder, log)
+		if scheduleErr != nil {
+			return nil, scheduleErr
+		}
+		analysis.finishSummary = stats.NewAccumulator()
+		analysis.finishSummary.Add(fg.resourceSchedule.finishValues...)
+		for eachName, eachUtilization := range fg.resourceSchedule.utilization {
+			analysis.utilizationSums[eachName] = eachUtilization * float64(analysis.runCount)
+		}
+		for eachID, eachFrequency := range fg.resourceSchedule.chainFrequency {
+			analysis.chainCounts[eachID] = eachFrequency * float64(analysis.runCount)
+		}
+	}
+	if fg.hasCosts() {
+		costErr := fg.evaluateCosts(topoOrder, fg.percentiles, src, log)
+		if costErr != nil {
+			return nil, costErr
+		}
+		analysis.costSummary = stats.NewAccumulator()
+		analysis.costSummary.Add(fg.costs.totalValues...)
+		for _, eachCost := range fg.costs.totalValues {
+			if fg.costs.budget > 0 && eachCost > fg.costs.budget {
+				analysis.overrunCount++
+			}
+		}
+		for _, eachNode := range sortedNodes {
+			taskNode, taskNodeOk := eachNode.(*flowGraphNode)
+			if taskNodeOk && taskNode.cost != nil {
+				taskSummary := stats.NewAccumulator()
+				taskSummary.Add(taskNode.cost.values...)
+				analysis.taskCostSummaries[taskNode.ID()] = taskSummary
+			}
+		}
+		if firstChunk {
+			analysis.costScatter = fg.costScatterValues()
+		}
+	}
+	return analysis, nil
+}
+
+// merge merges the analysis of the next chunk
+func (ca *chunkAnalysis) merge(next *chunkAnalysis) {
+	ca.runCount += next.runCount
+	for eachID, eachCount := range next.deadlineMetCounts {
+		ca.deadlineMetCounts[eachID] += eachCount
+	}
+	for i, eachTally := range next.riskTallies {
+		ca.riskTallies[i].add(eachTally)
+	}
+	for i, eachRow := range next.correlationSums {
+		for j, eachSum := range eachRow {
+			ca.correlationSums[i][j] += eachSum
+		}
+	}
+	if next.finishSummary != nil {
+		ca.finishSummary.Merge(next.finishSummary)
+	}
+	for eachName, eachSum := range next.utilizationSums {
+		ca.utilizationSums[eachName] += eachSum
+	}
+	for eachID, eachCount := range next.chainCounts {
+		ca.chainCounts[eachID] += eachCount
+	}
+	if next.costSummary != nil {
+		ca.costSummary.Merge(next.costSummary)
+	}
+	for eachID, eachSummary := range next.taskCostSummaries {
+		ca.taskCostSummaries[eachID].Merge(eachSummary)
+	}
+	for eachID, eachValues := range next.sensitivitySums {
+		for i, eachSum := range eachValues {
+			ca.sensitivitySums[eachID][i] += eachSum
+		}
+	}
+	ca.overrunCount += next.overrunCount
+}
+
+// achievedCorrelations returns the run weighted mean of the chunks' achieved
+// correlation matrices
+func (ca *chunkAnalysis) achievedCorrelations() [][]float64 {
+	achieved := make([][]float64, len(ca.correlationSums))
+	for i, eachRow := range ca.correlationSums {
+		achieved[i] = make([]float64, len(eachRow))
+		for j, eachSum := range eachRow {
+			achieved[i][j] = eachSum / float64(ca.runCount)
+		}
+	}
+	return achieved
+}
+
+// taskSensitivities returns the run weighted mean of the chunks' task
+// sensitivity values
+func (ca *chunkAnalysis) taskSensitivities() map[int64][]float64 {
+	sensitivities := make(map[int64][]float64, len(ca.sensitivitySums))
+	for eachID, eachValues := range ca.sensitivitySums {
+		sensitivities[eachID] = make([]float64, len(eachValues))
+		for i, eachSum := range eachValues {
+			sensitivities[eachID][i] = eachSum / float64(ca.runCount)
+		}
+	}
+	return sensitivities
+}
+
+// finishStreamedAnalysis sets the resource schedule and costs of the graph
+// from the merged analysis of every chunk
+func (fg *flowGraph) finishStreamedAnalysis() {
+	analysis := fg.streamedAnalysis
+	runCount := float64(analysis.runCount)
+	if analysis.finishSummary != nil {
+		schedule := &resourceSchedule{
+			finishStats:    analysis.finishSummary.StatisticsWithIntervals(fg.percentiles, fg.intervals),
+			utilization:    make(map[string]float64),
+			chainFrequency: make(map[int64]float64),
+		}
+		for eachName, eachSum := range analysis.utilizationSums {
+			schedule.utilization[eachName] = eachSum / runCount
+		}
+		for eachID, eachCount := range analysis.chainCounts {
+			schedule.chainFrequency[eachID] = eachCount / runCount
+		}
+		fg.resourceSchedule = schedule
+	}
+	if analysis.costSummary != nil {
+		fg.costs.totalSummary = analysis.costSummary
+		fg.costs.totalStats = analysis.costSummary.StatisticsWithIntervals(fg.percentiles, fg.intervals)
+		fg.costs.streamedScatter = analysis.costScatter
+		if fg.costs.budget > 0 {
+			fg.costs.overrunProbability = float64(analysis.overrunCount) / runCount
+		}
+		for eachID, eachSummary := range analysis.taskCostSummaries {
+			taskNode := fg.WeightedDirectedGraph.Node(eachID).(*flowGraphNode)
+			taskNode.cost.stats = eachSummary.StatisticsWithIntervals(fg.percentiles, fg.intervals)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package app
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/mweagle/goestimate/stats"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Summary
+//
+// The machine readable results of evaluating a plan, written by the `json`
+// output. Durations are in plan units.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// IntervalSummary is the confidence interval of an estimate
+type IntervalSummary struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// StatsSummary is the summary statistics of a set of samples. Percentiles
+// and their confidence intervals are keyed by name, ex: p95. The intervals
+// are present if the plan was evaluated with intervals. Kurtosis is the
+// excess kurtosis, CV the coefficient of variation and CVaR the mean of the
+// worst 10% of the samples.
+type StatsSummary struct {
+	Mean                float64                     `json:"mean"`
+	MeanInterval        *IntervalSummary            `json:"meanInterval,omitempty"`
+	Median              float64                     `json:"median"`
+	StdDev              float64                     `json:"stddev"`
+	Percentiles         map[string]float64          `json:"percentiles,omitempty"`
+	PercentileIntervals map[string]*IntervalSummary `json:"percentileIntervals,omitempty"`
+	Skewness            float64                     `json:"skewness"`
+	Kurtosis            float64                     `json:"kurtosis"`
+	Mode                float64                     `json:"mode"`
+	CV                  float64                     `json:"cv"`
+	CVaR                float64                     `json:"cvar"`
+}
+
+// TaskSummary is the duration of a single task
+type TaskSummary struct {
+	Name         string        `json:"name"`
+	Generator    string        `json:"generator"`
+	Duration     *StatsSummary `json:"duration"`
+	CriticalPath bool          `json:"criticalPath"`
+}
+
+// DeadlineSummary is the probability of meeting a deadline
+type DeadlineSummary struct {
+	Name           string  `json:"name"`
+	Deadline       string  `json:"deadline"`
+	Offset         float64 `json:"offset"`
+	Probability    float64 `json:"probability"`
+	BelowThreshold bool    `json:"belowThreshold"`
+}
+
+// CostSummary is the total cost of the plan
+type CostSummary struct {
+	Total              *StatsSummary `json:"total"`
+	Budget             float64       `json:"budget,omitempty"`
+	OverrunProbability float64       `json:"overrunProbability,omitempty"`
+}
+
+// ResourceSummary is the duration of the plan subject to its resource
+// capacities
+type ResourceSummary struct {
+	Duration    *StatsSummary      `json:"duration"`
+	Utilization map[string]float64 `json:"utilization"`
+}
+
+// PercentilePrecision is the confidence interval of a percentile estimate
+type PercentilePrecision struct {
+	Estimate          float64 `json:"estimate"`
+	HalfWidth         float64 `json:"halfWidth"`
+	RelativeHalfWidth float64 `json:"relativeHalfWidth"`
+}
+
+// ConvergenceSummary is the achieved precision of an auto run count.
+// Percentiles are keyed by name, ex: p95.
+type ConvergenceSummary struct {
+	Tolerance   float64                         `json:"tolerance"`
+	MaxRuns     uint64                          `json:"maxRuns"`
+	Converged   bool                            `json:"converged"`
+	Batches     int                             `json:"batches"`
+	Confidence  float64                         `json:"confidence"`
+	Percentiles map[string]*PercentilePrecision `json:"percentiles,omitempty"`
+}
+
+// SamplingSummary is the sampling strategy. Variance reduced strategies
+// report the variance reduction and effective sample size of the mean and
+// each percentile, keyed by name, ex: p95.
+type SamplingSummary struct {
+	Strategy            string             `json:"strategy"`
+	VarianceReduction   map[string]float64 `json:"varianceReduction,omitempty"`
+	EffectiveSampleSize map[string]float64 `json:"effectiveSampleSize,omitempty"`
+}
+
+// TaskSensitivity is the sensitivity of the total duration to a task. The
+// Sobol index is present for the sobol method.
+type TaskSensitivity struct {
+	Name     string   `json:"name"`
+	Spearman float64  `json:"spearman"`
+	Sobol    *float64 `json:"sobol,omitempty"`
+}
+
+// SensitivitySummary is the tasks ranked by the sensitivity of the total
+// duration, most sensitive first
+type SensitivitySummary struct {
+	Method string             `json:"method"`
+	Tasks  []*TaskSensitivity `json:"tasks"`
+}
+
+// ScenarioSummary is the total duration of a what-if scenario. The change
+// from the plan and the completion dates are keyed by name, ex: mean or
+// p95. Completion dates are present if the scenario uses workdays.
+type ScenarioSummary struct {
+	Name            string             `json:"name"`
+	Duration        *StatsSummary      `json:"duration"`
+	Delta           map[string]float64 `json:"delta"`
+	CompletionDates map[string]string  `json:"completionDates,omitempty"`
+}
+
+// Summary is the result of evaluating a plan
+type Summary struct {
+	Name                string              `json:"name"`
+	RunCount            uint64              `json:"runCount"`
+	Seed                uint64              `json:"seed"`
+	Streaming           bool                `json:"streaming,omitempty"`
+	Convergence         *ConvergenceSummary `json:"convergence,omitempty"`
+	Sampling            *SamplingSummary    `json:"sampling"`
+	Duration            *StatsSummary       `json:"duration"`
+	EstimatedCompletion string              `json:"estimatedCompletion,omitempty"`
+	CriticalPath        []string            `json:"criticalPath"`
+	Tasks               []*TaskSummary      `json:"tasks"`
+	Deadlines           []*DeadlineSummary  `json:"deadlines,omitempty"`
+	Cost                *CostSummary        `json:"cost,omitempty"`
+	Resources           *ResourceSummary    `json:"resources,omitempty"`
+	Sensitivity         *SensitivitySummary `json:"sensitivity,omitempty"`
+	Scenarios           []*ScenarioSummary  `json:"scenarios,omitempty"`
+}
+
+// percentileName is the pNN name of the percentile
+func percentileName(percentile float64) string {
+	return "p" + strconv.FormatFloat(math.Round(percentile*1e4)/1e2, 'f', -1, 64)
+}
+
+func newIntervalSummary(interval *stats.Interval) *IntervalSummary {
+	if interval == nil {
+		return nil
+	}
+	return &IntervalSummary{
+		Lower: interval.Lower,
+		Upper: interval.Upper,
+	}
+}
+
+func newStatsSummary(aggStats *stats.AggregatedStatistics) *StatsSummary {
+	if aggStats == nil {
+		return nil
+	}
+	summary := &StatsSummary{
+		Mean:         aggStats.Mean,
+		MeanInterval: newIntervalSummary(aggStats.MeanInterval),
+		Median:       aggStats.Median,
+		StdDev:       aggStats.StdDev,
+		Skewness:     aggStats.Skewness,
+		Kurtosis:     aggStats.Kurtosis,
+		Mode:         aggStats.Mode,
+		CV:           aggStats.CV,
+		CVaR:         aggStats.CVaR,
+	}
+	if len(aggStats.Percentiles) != 0 {
+		summary.Percentiles = make(map[string]float64, len(aggStats.Percentiles))
+		for _, eachPercentile := range aggStats.Percentiles {
+			summary.Percentiles[percentileName(eachPercentile.P)] = eachPercentile.Val
+			if eachPercentile.Interval != nil {
+				if summary.PercentileIntervals == nil {
+					summary.PercentileIntervals = make(map[string]*IntervalSummary, len(aggStats.Percentiles))
+				}
+				summary.PercentileIntervals[percentileName(eachPercentile.P)] = newIntervalSummary(eachPercentile.Interval)
+			}
+		}
+	}
+	return summary
+}
+
+// summary returns the results of the evaluated graph
+func (fg *flowGraph) summary() *Summary {
+	outputResults := fg.outputJoinNode.GenerationResults()
+	summary := &Summary{
+		Name:         fg.name,
+		RunCount:     fg.startNode.runCount,
+		Seed:         fg.seed,
+		Streaming:    fg.streaming,
+		Sampling:     fg.samplingSummary(),
+		Duration:     newStatsSummary(outputResults.CumulativeStats),
+		CriticalPath: make([]string, 0),
+		Tasks:        make([]*TaskSummary, 0),
+	}
+	if fg.flowSubgraph.aggregationOptions.workdays {
+		summary.EstimatedCompletion = workdayWithOffset(fg.flowSubgraph.aggregationOptions.calendar, outputResults.CumulativeStats.Mean).Format(ECD_TIME_FORMAT)
+	}
+	// Tasks in document order
+	for _, eachNode := range fg.sortedNodes() {
+		taskNode, taskNodeOk := eachNode.(*flowGraphNode)
+		if !taskNodeOk {
+			continue
+		}
+		criticalPath := fg.criticalPathGraph.Node(taskNode.ID()) != nil
+		if criticalPath {
+			summary.CriticalPath = append(summary.CriticalPath, taskNode.name)
+		}
+		taskSummary := &TaskSummary{
+			Name:         taskNode.name,
+			Duration:     newStatsSummary(taskNode.GenerationResults().GeneratorStats),
+			CriticalPath: criticalPath,
+		}
+		if taskNode.generator != nil {
+			taskSummary.Generator = taskNode.generator.Name()
+		}
+		summary.Tasks = append(summary.Tasks, taskSummary)
+	}
+	for _, eachSubgraph := range fg.deadlineSubgraphs() {
+		subgraphName := eachSubgraph.inputNode.name
+		if eachSubgraph == fg.flowSubgraph {
+			subgraphName = fg.name
+		}
+		summary.Deadlines = append(summary.Deadlines, &DeadlineSummary{
+			Name:           subgraphName,
+			Deadline:       eachSubgraph.deadline.String(),
+			Offset:         eachSubgraph.deadline.offset,
+			Probability:    eachSubgraph.deadline.probability,
+			BelowThreshold: eachSubgraph.deadline.belowThreshold,
+		})
+	}
+	if fg.convergence != nil {
+		summary.Convergence = fg.convergence.summary()
+	}
+	if fg.sensitivity != nil {
+		summary.Sensitivity = fg.sensitivity.summary()
+	}
+	if fg.scenarios != nil {
+		summary.Scenarios = fg.scenarioSummaries()
+	}
+	if fg.costs.totalStats != nil {
+		summary.Cost = &CostSummary{
+			Total:              newStatsSummary(fg.costs.totalStats),
+			Budget:             fg.costs.budget,
+			OverrunProbability: fg.costs.overrunProbability,
+		}
+	}
+	if fg.resourceSchedule != nil {
+		summary.Resources = &ResourceSummary{
+			Duration:    newStatsSummary(fg.resourceSchedule.finishStats),
+			Utilization: fg.resourceSchedule.utilization,
+		}
+	}
+	return summary
+}
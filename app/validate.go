@@ -0,0 +1,471 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mweagle/goestimate/generator"
+	"github.com/mweagle/goestimate/plan"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Validation
+//
+// `goestimate validate` decodes a plan without evaluating or rendering it and
+// reports every problem with its location. Each diagnostic has a stable rule
+// ID and a severity. Any object may include a `suppress` array of rule IDs
+// that are ignored for that object and everything nested within it.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// Diagnostic severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Rule IDs
+const (
+	RuleInvalidPlan          = "invalid-plan"
+	RuleInvalidValue         = "invalid-value"
+	RuleInvalidGenerator     = "invalid-generator"
+	RuleMissingGenerator     = "missing-generator"
+	RuleUnknownKey           = "unknown-key"
+	RuleDeprecatedKey        = "deprecated-key"
+	RuleDuplicateSiblingName = "duplicate-sibling-name"
+	RuleEmptySubgraph        = "empty-subgraph"
+	RuleParetoInfiniteMean   = generator.RULE_PARETO_INFINITE_MEAN
+	RuleDegeneratePERT       = generator.RULE_DEGENERATE_PERT
+	RuleNormalNegativeMass   = generator.RULE_NORMAL_NEGATIVE_MASS
+)
+
+// DEPRECATED_SCHEMA_KEY_MESSAGE describes the plan format version key, which
+// `$schema` replaced
+const DEPRECATED_SCHEMA_KEY_MESSAGE = `key "schema" is deprecated and ignored. Use "$schema" to reference the JSON Schema`
+
+var (
+	ruleSeverityDefs = map[string]string{
+		RuleInvalidPlan:          SeverityError,
+		RuleInvalidValue:         SeverityError,
+		RuleInvalidGenerator:     SeverityError,
+		RuleMissingGenerator:     SeverityError,
+		RuleUnknownKey:           SeverityError,
+		RuleDeprecatedKey:        SeverityWarning,
+		RuleDuplicateSiblingName: SeverityWarning,
+		RuleEmptySubgraph:        SeverityError,
+		RuleParetoInfiniteMean:   SeverityWarning,
+		RuleDegeneratePERT:       SeverityWarning,
+		RuleNormalNegativeMass:   SeverityWarning,
+	}
+	// planErrorRules are the rule IDs of the plan decoding error codes
+	planErrorRules = map[string]string{
+		plan.CodeSyntax:       RuleInvalidPlan,
+		plan.CodeDuplicateKey: RuleInvalidPlan,
+		plan.CodeUnknownKey:   RuleUnknownKey,
+	}
+)
+
+// Diagnostic is a single validation problem
+type Diagnostic struct {
+	RuleID   string
+	Severity string
+	Location string
+	Message  string
+	// err is the located plan error of the diagnostic
+	err *plan.Error
+}
+
+// ValidationReport is the set of diagnostics for a plan
+type ValidationReport struct {
+	Diagnostics     []*Diagnostic
+	SuppressedCount int
+}
+
+// HasErrors returns true if any diagnostic is an error
+func (vr *ValidationReport) HasErrors() bool {
+	for _, eachDiagnostic := range vr.Diagnostics {
+		if eachDiagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns an InvalidPlanError with the located error diagnostics, or
+// nil if there aren't any
+func (vr *ValidationReport) Err() error {
+	planErrs := make(plan.Errors, 0)
+	for _, eachDiagnostic := range vr.Diagnostics {
+		if eachDiagnostic.Severity == SeverityError {
+			planErrs = append(planErrs, eachDiagnostic.err)
+		}
+	}
+	if len(planErrs) <= 0 {
+		return nil
+	}
+	return &InvalidPlanError{Err: planErrs}
+}
+
+// WriteTable writes the diagnostics table
+func (vr *ValidationReport) WriteTable(output io.Writer) error {
+	tw := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	if len(vr.Diagnostics) <= 0 {
+		fmt.Fprintf(tw, "No problems found (%d suppressed)\n", vr.SuppressedCount)
+		return tw.Flush()
+	}
+	fmt.Fprintf(tw, "SEVERITY\tRULE\tLOCATION\tMESSAGE\n")
+	for _, eachDiagnostic := range vr.Diagnostics {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			eachDiagnostic.Severity,
+			eachDiagnostic.RuleID,
+			eachDiagnostic.Location,
+			eachDiagnostic.Message)
+	}
+	fmt.Fprintf(tw, "\n%d problems (%d suppressed)\n", len(vr.Diagnostics), vr.SuppressedCount)
+	return tw.Flush()
+}
+
+// planValidator lints the decoded plan
+type planValidator struct {
+	report *ValidationReport
+	log    *slog.Logger
+	// decodePointers are the JSON pointers of the values that couldn't be
+	// decoded
+	decodePointers []string
+}
+
+// decodeFailed returns true if the value, or a value within it, couldn't be
+// decoded. Partially decoded tasks, risks and drivers aren't linted, since
+// their missing values were already reported.
+func (pv *planValidator) decodeFailed(source plan.Source) bool {
+	pointer := source.Pointer()
+	for _, eachPointer := range pv.decodePointers {
+		if eachPointer == pointer || strings.HasPrefix(eachPointer, pointer+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (pv *planValidator) addDiagnostic(ruleID string, source plan.Source, suppressed []string, format string, args ...interface{}) {
+	// Values that couldn't be decoded are only reported once
+	if slices.Contains(pv.decodePointers, source.Pointer()) {
+		return
+	}
+	if slices.Contains(suppressed, ruleID) {
+		pv.report.SuppressedCount++
+		return
+	}
+	severity, severityExists := ruleSeverityDefs[ruleID]
+	if !severityExists {
+		severity = SeverityWarning
+	}
+	diagnosticErr := source.Node().Errorf(ruleID, format, args...)
+	pv.report.Diagnostics = append(pv.report.Diagnostics, &Diagnostic{
+		RuleID:   ruleID,
+		Severity: severity,
+		Location: diagnosticErr.Location(),
+		Message:  diagnosticErr.Message,
+		err:      diagnosticErr,
+	})
+}
+
+// addPlanErrors adds a diagnostic for each located plan error
+func (pv *planValidator) addPlanErrors(err error) {
+	planErrs := plan.AsErrors(err)
+	if planErrs == nil {
+		pv.addDiagnostic(RuleInvalidPlan, plan.Source{}, nil, "%v", err)
+		return
+	}
+	for _, eachErr := range planErrs {
+		ruleID, ruleIDExists := planErrorRules[eachErr.Code]
+		if !ruleIDExists {
+			ruleID = RuleInvalidValue
+		}
+		pv.report.Diagnostics = append(pv.report.Diagnostics, &Diagnostic{
+			RuleID:   ruleID,
+			Severity: ruleSeverityDefs[ruleID],
+			Location: eachErr.Location(),
+			Message:  eachErr.Message,
+			err:      eachErr,
+		})
+		pv.decodePointers = append(pv.decodePointers, eachErr.Pointer)
+	}
+}
+
+// childSuppressed returns the suppressed rule IDs for an object, including
+// those inherited from its parents
+func childSuppressed(suppressed []string, objectSuppressed []string) []string {
+	if len(objectSuppressed) <= 0 {
+		return suppressed
+	}
+	return append(slices.Clone(suppressed), objectSuppressed...)
+}
+
+func (pv *planValidator) validateGenerator(source plan.Source, expression string, suppressed []string) generator.DurationGenerator {
+	durGenerator, durGeneratorErr := generator.NewDurationGeneratorFromExpression(expression, pv.log)
+	if durGeneratorErr != nil {
+		pv.addDiagnostic(RuleInvalidGenerator, source, suppressed, "%v", durGeneratorErr)
+		return nil
+	}
+	for _, eachFinding := range generator.LintGenerator(durGenerator) {
+		pv.addDiagnostic(eachFinding.RuleID, source, suppressed, "%s", eachFinding.Message)
+	}
+	return durGenerator
+}
+
+func (pv *planValidator) validateRepeat(source plan.Source, repeatExpr string, suppressed []string) {
+	if len(repeatExpr) <= 0 {
+		return
+	}
+	_, repeatErr := generator.ParseRepeatCount(repeatExpr)
+	if repeatErr != nil {
+		pv.addDiagnostic(RuleInvalidValue, source, suppressed, "%v", repeatErr)
+	}
+}
+
+func (pv *planValidator) validateJoin(source plan.Source, joinExpr string, suppressed []string) {
+	if len(joinExpr) <= 0 {
+		return
+	}
+	_, joinErr := generator.NewJoinGenerator(joinExpr)
+	if joinErr != nil {
+		pv.addDiagnostic(RuleInvalidValue, source, suppressed, "%v", joinErr)
+	}
+}
+
+func newPlanValidator(log *slog.Logger) *planValidator {
+	return &planValidator{
+		report: &ValidationReport{
+			Diagnostics: make([]*Diagnostic, 0),
+		},
+		log: log,
+	}
+}
+
+// validateDecoded lints the plan, then builds the graphs of the plan and its
+// scenarios to find any cross references that don't resolve
+func (pv *planValidator) validateDecoded(planDef *plan.Plan) {
+	pv.validatePlan(planDef)
+	if !pv.report.HasErrors() {
+		_, graphErr := newEvaluationGraph(planDef, EvaluateOptions{}, pv.log)
+		if graphErr != nil {
+			pv.addPlanErrors(graphErr)
+		}
+	}
+}
+
+func (pv *planValidator) validatePlan(planDef *plan.Plan) {
+	suppressed := childSuppressed(nil, planDef.Suppress)
+	if planDef.Version != nil {
+		pv.addDiagnostic(RuleDeprecatedKey, planDef.Field("schema"), suppressed, "%s", DEPRECATED_SCHEMA_KEY_MESSAGE)
+	}
+	for i, eachAssertion := range planDef.Assertions {
+		if pv.decodeFailed(planDef.Field("assertions").Index(i)) {
+			continue
+		}
+		_, assertionErr := ParseAssertion(eachAssertion)
+		if assertionErr != nil {
+			pv.addDiagnostic(RuleInvalidValue, planDef.Field("assertions").Index(i), suppressed, "%v", assertionErr)
+		}
+	}
+	pv.validateActivities(planDef.Activities, suppressed)
+	for _, eachRisk := range planDef.Risks {
+		if pv.decodeFailed(eachRisk.Source) {
+			continue
+		}
+		riskSuppressed := childSuppressed(suppressed, eachRisk.Suppress)
+		riskGenerator := pv.validateGenerator(eachRisk.Field("type"), eachRisk.Type, riskSuppressed)
+		_, riskGeneratorOk := riskGenerator.(*generator.RiskGenerator)
+		if riskGenerator != nil && !riskGeneratorOk {
+			pv.addDiagnostic(RuleInvalidGenerator, eachRisk.Field("type"), riskSuppressed, "risk register entries must be Risk(...) expressions")
+		}
+	}
+	if planDef.Correlations != nil {
+		correlationSuppressed := childSuppressed(suppressed, planDef.Correlations.Suppress)
+		for _, eachDriver := range planDef.Correlations.Drivers {
+			if pv.decodeFailed(eachDriver.Source) {
+				continue
+			}
+			pv.validateGenerator(eachDriver.Field("type"),
+				eachDriver.Type,
+				childSuppressed(correlationSuppressed, eachDriver.Suppress))
+		}
+	}
+}
+
+// validateActivities validates an activities object. Sibling names are the
+// task and subgraph names directly within the object.
+func (pv *planValidator) validateActivities(activities *plan.Activities, suppressed []string) {
+	// Missing activities are reported by the decoder
+	if activities == nil {
+		return
+	}
+	if len(activities.Entries) <= 0 {
+		pv.addDiagnostic(RuleEmptySubgraph, activities.Source, suppressed, "activities are empty")
+		return
+	}
+	siblingNames := make([]string, 0)
+	siblingSources := make(map[string][]plan.Source)
+	addSibling := func(name string, source plan.Source) {
+		_, nameExists := siblingSources[name]
+		if !nameExists {
+			siblingNames = append(siblingNames, name)
+		}
+		siblingSources[name] = append(siblingSources[name], source)
+	}
+	for _, eachEntry := range activities.Entries {
+		entrySource := activities.Field(eachEntry.Key)
+		switch {
+		case eachEntry.Serial != nil:
+			if len(eachEntry.Serial) <= 0 {
+				pv.addDiagnostic(RuleEmptySubgraph, entrySource, suppressed, "serial task array is empty")
+			}
+			for i, eachTask := range eachEntry.Serial {
+				addSibling(defaultString(eachTask.Name, plan.SerialTaskName(i)), eachTask.Source)
+				pv.validateTask(eachTask, suppressed)
+			}
+		case eachEntry.Parallel != nil:
+			if len(eachEntry.Parallel.Tasks) <= 0 {
+				pv.addDiagnostic(RuleEmptySubgraph, entrySource, suppressed, "parallel tasks are empty")
+			}
+			pv.validateJoin(eachEntry.Parallel.Field("join"), eachEntry.Parallel.Join, suppressed)
+			for _, eachTask := range eachEntry.Parallel.Tasks {
+				addSibling(defaultString(eachTask.Name, eachTask.Key), eachTask.Source)
+				pv.validateTask(eachTask, suppressed)
+			}
+		case eachEntry.Subgraph != nil:
+			addSibling(eachEntry.Subgraph.Name, eachEntry.Subgraph.Source)
+			pv.validateSubgraph(eachEntry.Subgraph, suppressed)
+		case eachEntry.Choice != nil:
+			addSibling(eachEntry.Choice.Name, eachEntry.Choice.Source)
+			pv.validateChoice(eachEntry.Choice, suppressed)
+		}
+	}
+	for _, eachName := range siblingNames {
+		if len(siblingSources[eachName]) > 1 {
+			for _, eachSource := range siblingSources[eachName] {
+				pv.addDiagnostic(RuleDuplicateSiblingName, eachSource, suppressed, "name %q is used by %d siblings", eachName, len(siblingSources[eachName]))
+			}
+		}
+	}
+}
+
+func (pv *planValidator) validateTask(task *plan.Task, parentSuppressed []string) {
+	if pv.decodeFailed(task.Source) {
+		return
+	}
+	suppressed := childSuppressed(parentSuppressed, task.Suppress)
+	switch {
+	case len(task.Type) != 0 && len(task.Effort) != 0:
+		// Reported by the decoder
+	case len(task.Type) != 0:
+		pv.validateGenerator(task.Field("type"), task.Type, suppressed)
+	case len(task.Effort) != 0:
+		pv.validateGenerator(task.Field("effort"), task.Effort, suppressed)
+	default:
+		pv.addDiagnostic(RuleMissingGenerator, task.Source, suppressed, "tasks must specify a type or an effort")
+	}
+	pv.validateRepeat(task.Field("repeat"), task.Repeat, suppressed)
+	if task.Cost != nil && len(task.Cost.Type) != 0 {
+		pv.validateGenerator(task.Cost.Field("type"), task.Cost.Type, suppressed)
+	}
+}
+
+func (pv *planValidator) validateSubgraph(subgraph *plan.Subgraph, parentSuppressed []string) {
+	suppressed := childSuppressed(parentSuppressed, subgraph.Suppress)
+	pv.validateJoin(subgraph.Field("join"), subgraph.Join, suppressed)
+	pv.validateRepeat(subgraph.Field("repeat"), subgraph.Repeat, suppressed)
+	pv.validateActivities(subgraph.Activities, suppressed)
+}
+
+func (pv *planValidator) validateChoice(choice *plan.Choice, parentSuppressed []string) {
+	suppressed := childSuppressed(parentSuppressed, choice.Suppress)
+	pv.validateRepeat(choice.Field("repeat"), choice.Repeat, suppressed)
+	if len(choice.Branches) <= 0 {
+		pv.addDiagnostic(RuleInvalidValue, choice.Field("branches"), suppressed, "choices must define at least one branch")
+		return
+	}
+	totalWeight := float64(0)
+	weightsDecoded := true
+	for _, eachBranch := range choice.Branches {
+		if eachBranch.Weight != nil {
+			totalWeight += *eachBranch.Weight
+		}
+		weightsDecoded = weightsDecoded && !pv.decodeFailed(eachBranch.Field("weight"))
+	}
+	if weightsDecoded && totalWeight <= 0 {
+		pv.addDiagnostic(RuleInvalidValue, choice.Field("branches"), suppressed, "branch weights must have a positive total. Found: %v", totalWeight)
+	}
+	branchNames := make([]string, 0)
+	branchSources := make(map[string][]plan.Source)
+	for i, eachBranch := range choice.Branches {
+		branchSuppressed := childSuppressed(suppressed, eachBranch.Suppress)
+		branchName := defaultString(eachBranch.Name, fmt.Sprintf("branch-%d", i))
+		_, nameExists := branchSources[branchName]
+		if !nameExists {
+			branchNames = append(branchNames, branchName)
+		}
+		branchSources[branchName] = append(branchSources[branchName], eachBranch.Source)
+		// Branches without activities take no additional time
+		if eachBranch.Activities != nil && len(eachBranch.Activities.Entries) != 0 {
+			pv.validateActivities(eachBranch.Activities, branchSuppressed)
+		}
+	}
+	for _, eachName := range branchNames {
+		if len(branchSources[eachName]) > 1 {
+			for _, eachSource := range branchSources[eachName] {
+				pv.addDiagnostic(RuleDuplicateSiblingName, eachSource, suppressed, "name %q is used by %d branches", eachName, len(branchSources[eachName]))
+			}
+		}
+	}
+}
+
+// ValidatePlan decodes the plan at the path and returns every problem found,
+// located by file, line, column and JSON pointer.
+func ValidatePlan(inputPath string, log *slog.Logger) (*ValidationReport, error) {
+	inputBytes, inputName, inputBytesErr := readInput(inputPath)
+	if inputBytesErr != nil {
+		return nil, &InvalidPlanError{Err: inputBytesErr}
+	}
+	validator := newPlanValidator(log)
+	// Plans with decoding errors are linted as far as they were decoded
+	planDef, planDefErr := plan.Decode(inputName, inputBytes)
+	if planDefErr != nil {
+		validator.addPlanErrors(planDefErr)
+	}
+	if planDef == nil {
+		return validator.report, nil
+	}
+	validator.validateDecoded(planDef)
+	return validator.report, nil
+}
+
+// VerifyPlan validates the decoded plan with the same rules as ValidatePlan,
+// without evaluating it. Plans with errors return an InvalidPlanError.
+func VerifyPlan(planDef *plan.Plan, log *slog.Logger) error {
+	validator := newPlanValidator(log)
+	validator.validateDecoded(planDef)
+	return validator.report.Err()
+}
+
+func sortedKeys[V any](dict map[string]V) []string {
+	keys := make([]string, 0, len(dict))
+	for eachKey := range dict {
+		keys = append(keys, eachKey)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func defaultString(value string, defaultValue string) string {
+	if len(value) <= 0 {
+		return defaultValue
+	}
+	return value
+}
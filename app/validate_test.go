@@ -0,0 +1,138 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// validateTestPlan validates the plan JSON
+func validateTestPlan(t *testing.T, planJSON string) *ValidationReport {
+	t.Helper()
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	writeErr := os.WriteFile(planPath, []byte(planJSON), 0644)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	report, reportErr := ValidatePlan(planPath, testLogger())
+	if reportErr != nil {
+		t.Fatal(reportErr)
+	}
+	return report
+}
+
+// diagnosticRules returns the rule ID of each diagnostic
+func diagnosticRules(report *ValidationReport) []string {
+	ruleIDs := make([]string, 0, len(report.Diagnostics))
+	for _, eachDiagnostic := range report.Diagnostics {
+		ruleIDs = append(ruleIDs, eachDiagnostic.RuleID)
+	}
+	return ruleIDs
+}
+
+func TestDegeneratePERTSuppression(t *testing.T) {
+	planJSON := `{
+		"name": "Degenerate",
+		"runCount": 1000,
+		"activities": {
+			"tasks": [{"name": "Review", "type": "PERT(5,5,5)"SUPPRESS}]
+		}
+	}`
+	warned := validateTestPlan(t, strings.Replace(planJSON, "SUPPRESS", "", 1))
+	if warned.HasErrors() || len(warned.Diagnostics) != 1 || warned.Diagnostics[0].RuleID != RuleDegeneratePERT {
+		t.Fatalf("invalid diagnostics. Expected a %s warning, Found: %v", RuleDegeneratePERT, diagnosticRules(warned))
+	}
+	suppressedJSON := strings.Replace(planJSON, "SUPPRESS", `, "suppress": ["degenerate-pert"]`, 1)
+	suppressed := validateTestPlan(t, suppressedJSON)
+	if len(suppressed.Diagnostics) != 0 || suppressed.SuppressedCount != 1 {
+		t.Fatalf("invalid suppressed diagnostics: %v (%d suppressed)", diagnosticRules(suppressed), suppressed.SuppressedCount)
+	}
+	// The suppressed plan runs, and the degenerate PERT is its fixed value
+	summary := evaluateTestPlan(t, suppressedJSON, EvaluateOptions{Seed: 7}).Summary()
+	expectNear(t, "duration", summary.Duration.Mean, 5, 1e-9)
+	expectNear(t, "stddev", summary.Duration.StdDev, 0, 1e-9)
+}
+
+// The deprecated plan format version key is a warning, so the plan still runs
+func TestDeprecatedSchemaKey(t *testing.T) {
+	report := validateTestPlan(t, `{
+		"schema": 1,
+		"name": "Legacy",
+		"runCount": 100,
+		"activities": {"tasks": [{"name": "Design", "type": "PERT(1,2,3)"}]}
+	}`)
+	if report.HasErrors() || len(report.Diagnostics) != 1 || report.Diagnostics[0].RuleID != RuleDeprecatedKey {
+		t.Fatalf("invalid diagnostics. Expected a %s warning, Found: %v", RuleDeprecatedKey, diagnosticRules(report))
+	}
+	if !strings.HasSuffix(report.Diagnostics[0].Location, "plan.json:2:13: /schema") {
+		t.Errorf("invalid location: %s", report.Diagnostics[0].Location)
+	}
+}
+
+// Each rule reports its problem with its severity
+func TestValidateRules(t *testing.T) {
+	testCases := map[string]struct {
+		task     string
+		ruleID   string
+		severity string
+	}{
+		"invalid-generator":    {`{"name": "A", "type": "PERT(1,2"}`, RuleInvalidGenerator, SeverityError},
+		"missing-generator":    {`{"name": "A"}`, RuleMissingGenerator, SeverityError},
+		"unknown-key":          {`{"name": "A", "type": "Fixed(1)", "tpye": "Fixed(1)"}`, RuleUnknownKey, SeverityError},
+		"invalid-value":        {`{"name": "A", "type": "Fixed(1)", "repeat": "Geometric(0)"}`, RuleInvalidValue, SeverityError},
+		"pareto-infinite-mean": {`{"name": "A", "type": "Pareto(2, 0.8)"}`, RuleParetoInfiniteMean, SeverityWarning},
+		"normal-negative-mass": {`{"name": "A", "type": "Normal(1, 2)"}`, RuleNormalNegativeMass, SeverityWarning},
+		"degenerate-pert":      {`{"name": "A", "type": "PERT(2,2,2)"}`, RuleDegeneratePERT, SeverityWarning},
+	}
+	for name, eachCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			report := validateTestPlan(t, `{
+				"name": "Rules",
+				"runCount": 100,
+				"activities": {"tasks": [`+eachCase.task+`]}
+			}`)
+			if len(report.Diagnostics) != 1 ||
+				report.Diagnostics[0].RuleID != eachCase.ruleID ||
+				report.Diagnostics[0].Severity != eachCase.severity {
+				t.Fatalf("invalid diagnostics. Expected a %s %s, Found: %v", eachCase.ruleID, eachCase.severity, diagnosticRules(report))
+			}
+			if !strings.Contains(report.Diagnostics[0].Location, "/activities/tasks/0") {
+				t.Errorf("invalid location: %s", report.Diagnostics[0].Location)
+			}
+		})
+	}
+}
+
+// Suppressions apply to the object and everything nested within it
+func TestValidateNestedSuppression(t *testing.T) {
+	report := validateTestPlan(t, `{
+		"name": "Suppressed",
+		"runCount": 100,
+		"activities": {
+			"subgraph: ": {
+				"name": "Outages",
+				"suppress": ["pareto-infinite-mean"],
+				"activities": {
+					"tasks": [
+						{"name": "A", "type": "Pareto(2, 0.8)"},
+						{"name": "B", "type": "Pareto(2, 0.9)"}
+					]
+				}
+			},
+			"tasks": [{"name": "C", "type": "Pareto(2, 0.7)"}],
+			"empty": {"name": "Empty", "activities": {}}
+		}
+	}`)
+	expected := []string{RuleParetoInfiniteMean, RuleEmptySubgraph}
+	if !slices.Equal(diagnosticRules(report), expected) || report.SuppressedCount != 2 {
+		t.Fatalf("invalid diagnostics. Expected: %v with 2 suppressed, Found: %v with %d suppressed",
+			expected,
+			diagnosticRules(report),
+			report.SuppressedCount)
+	}
+	if report.Err() == nil {
+		t.Error("expected an error for the empty subgraph")
+	}
+}
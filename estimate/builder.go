@@ -0,0 +1,438 @@
+package estimate
+
+import (
+	"fmt"
+
+	"github.com/mweagle/goestimate/plan"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Builder
+//
+// Fluent builders for plans created in code:
+//
+//	launchPlan, launchPlanErr := estimate.NewPlan("Launch").
+//		Serial(estimate.Task("Design", "PERT(3,5,10)")).
+//		Parallel(estimate.Task("Docs", "PERT(2,4,8)")).
+//		Subgraph(estimate.NewSubgraph("Release").
+//			Serial(estimate.Task("Deploy", "Fixed(1)"))).
+//		Build()
+//
+// The builders create the plan model, so built plans have the same
+// structure, and are validated by the same rules, as plans that are read.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// DEFAULT_RUN_COUNT is the run count of built plans
+var DEFAULT_RUN_COUNT uint64 = 10000
+
+// Task returns a task with a duration generator expression
+func Task(name string, typeExpr string) *plan.Task {
+	return &plan.Task{
+		Name: name,
+		Type: typeExpr,
+	}
+}
+
+// activitiesBuilder adds the activities of a plan, subgraph or branch. T is
+// the embedding builder, which each method returns for chaining.
+type activitiesBuilder[T any] struct {
+	activities  *plan.Activities
+	serialEntry *plan.ActivityEntry
+	self        T
+}
+
+// entryKey returns a key that's unique among the activities
+func (ab *activitiesBuilder[T]) entryKey(baseKey string) string {
+	key := baseKey
+	for i := 2; ab.entry(key) != nil; i++ {
+		key = fmt.Sprintf("%s%d", baseKey, i)
+	}
+	return key
+}
+
+func (ab *activitiesBuilder[T]) entry(key string) *plan.ActivityEntry {
+	for _, eachEntry := range ab.activities.Entries {
+		if eachEntry.Key == key {
+			return eachEntry
+		}
+	}
+	return nil
+}
+
+// Serial appends tasks that run one after another. Repeated calls extend
+// the same serial array.
+func (ab *activitiesBuilder[T]) Serial(tasks ...*plan.Task) T {
+	if ab.serialEntry == nil {
+		ab.serialEntry = &plan.ActivityEntry{
+			Key:    ab.entryKey("tasks"),
+			Serial: make([]*plan.Task, 0, len(tasks)),
+		}
+		ab.activities.Entries = append(ab.activities.Entries, ab.serialEntry)
+	}
+	ab.serialEntry.Serial = append(ab.serialEntry.Serial, tasks...)
+	return ab.self
+}
+
+// Lane adds a named serial array. Sibling serial arrays, including the one
+// created by Serial, are lanes that run in parallel.
+func (ab *activitiesBuilder[T]) Lane(name string, tasks ...*plan.Task) T {
+	ab.activities.Entries = append(ab.activities.Entries, &plan.ActivityEntry{
+		Key:    ab.entryKey(name),
+		Serial: tasks,
+	})
+	return ab.self
+}
+
+// Parallel adds tasks that run in parallel, closed by the enclosing join
+func (ab *activitiesBuilder[T]) Parallel(tasks ...*plan.Task) T {
+	return ab.ParallelJoin("", tasks...)
+}
+
+// ParallelJoin adds tasks that run in parallel, closed by the join
+// expression: max, min, sum or kofn(k)
+func (ab *activitiesBuilder[T]) ParallelJoin(join string, tasks ...*plan.Task) T {
+	parallelTasks := make([]*plan.Task, len(tasks))
+	for i, eachTask := range tasks {
+		keyedTask := *eachTask
+		keyedTask.Key = eachTask.Name
+		parallelTasks[i] = &keyedTask
+	}
+	ab.activities.Entries = append(ab.activities.Entries, &plan.ActivityEntry{
+		Key: ab.entryKey("parallel"),
+		Parallel: &plan.Parallel{
+			Join:  join,
+			Tasks: parallelTasks,
+		},
+	})
+	return ab.self
+}
+
+// Subgraph adds a nested subgraph
+func (ab *activitiesBuilder[T]) Subgraph(subgraph *SubgraphBuilder) T {
+	ab.activities.Entries = append(ab.activities.Entries, &plan.ActivityEntry{
+		Key:      ab.entryKey(subgraph.model.Name),
+		Subgraph: subgraph.model,
+	})
+	return ab.self
+}
+
+// Choice adds a weighted choice between the branches
+func (ab *activitiesBuilder[T]) Choice(name string, branches ...*BranchBuilder) T {
+	choice := &plan.Choice{
+		Name:     name,
+		Branches: make([]*plan.Branch, len(branches)),
+	}
+	for i, eachBranch := range branches {
+		choice.Branches[i] = eachBranch.model
+		// Branches without activities take no time
+		if len(eachBranch.model.Activities.Entries) == 0 {
+			choice.Branches[i] = &plan.Branch{
+				Name:   eachBranch.model.Name,
+				Weight: eachBranch.model.Weight,
+			}
+		}
+	}
+	ab.activities.Entries = append(ab.activities.Entries, &plan.ActivityEntry{
+		Key:    ab.entryKey(name),
+		Choice: choice,
+	})
+	return ab.self
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// PlanBuilder
+// /////////////////////////////////////////////////////////////////////////////
+
+// PlanBuilder builds a plan
+type PlanBuilder struct {
+	activitiesBuilder[*PlanBuilder]
+	model *plan.Plan
+}
+
+// NewPlan returns the builder for the named plan, with DEFAULT_RUN_COUNT
+// runs
+func NewPlan(name string) *PlanBuilder {
+	builder := &PlanBuilder{
+		model: &plan.Plan{
+			Name:       name,
+			RunCount:   plan.RunCount{Count: DEFAULT_RUN_COUNT},
+			Activities: &plan.Activities{},
+		},
+	}
+	builder.activitiesBuilder = activitiesBuilder[*PlanBuilder]{
+		activities: builder.model.Activities,
+		self:       builder,
+	}
+	return builder
+}
+
+// RunCount sets the number of Monte Carlo runs
+func (pb *PlanBuilder) RunCount(runCount uint64) *PlanBuilder {
+	pb.model.RunCount = plan.RunCount{Count: runCount}
+	return pb
+}
+
+// AutoRunCount adds runs until the percentiles' confidence intervals are
+// within the relative tolerance, up to maxRuns of at least 100000. Zero
+// values use the defaults.
+func (pb *PlanBuilder) AutoRunCount(tolerance float64, maxRuns uint64) *PlanBuilder {
+	pb.model.RunCount = plan.RunCount{
+		Auto:      true,
+		Tolerance: tolerance,
+		Max:       maxRuns,
+	}
+	return pb
+}
+
+// Percentiles sets the reported percentiles
+func (pb *PlanBuilder) Percentiles(percentiles ...float64) *PlanBuilder {
+	pb.model.Percentiles = percentiles
+	return pb
+}
+
+// Sampling sets the sampling strategy: mc, lhs, antithetic or sobol
+func (pb *PlanBuilder) Sampling(strategy string) *PlanBuilder {
+	pb.model.Sampling = strategy
+	return pb
+}
+
+// Intervals sets the confidence interval method of the mean and
+// percentiles: order, bootstrap or bootstrap-all
+func (pb *PlanBuilder) Intervals(method string) *PlanBuilder {
+	pb.model.Intervals = method
+	return pb
+}
+
+// Sensitivity sets the task sensitivity method: spearman or sobol
+func (pb *PlanBuilder) Sensitivity(method string) *PlanBuilder {
+	pb.model.Sensitivity = method
+	return pb
+}
+
+// Statistics sets the shape and tail statistics the diagram shows:
+// skewness, kurtosis, mode, cv and cvar
+func (pb *PlanBuilder) Statistics(names ...string) *PlanBuilder {
+	pb.model.Statistics = names
+	return pb
+}
+
+// Workdays estimates workday completion dates
+func (pb *PlanBuilder) Workdays() *PlanBuilder {
+	pb.model.Workdays = true
+	return pb
+}
+
+// Holidays adds non-working YYYY-MM-DD dates to the workday calendar
+func (pb *PlanBuilder) Holidays(dates ...string) *PlanBuilder {
+	pb.model.Holidays = append(pb.model.Holidays, dates...)
+	return pb
+}
+
+// Budget sets the budget the total cost is compared against
+func (pb *PlanBuilder) Budget(budget float64) *PlanBuilder {
+	pb.model.Budget = budget
+	return pb
+}
+
+// Deadline sets the plan deadline in duration units
+func (pb *PlanBuilder) Deadline(duration float64) *PlanBuilder {
+	pb.model.Deadline = &plan.Deadline{Duration: duration}
+	return pb
+}
+
+// DeadlineDate sets the plan deadline to a YYYY-MM-DD date
+func (pb *PlanBuilder) DeadlineDate(date string) *PlanBuilder {
+	pb.model.Deadline = &plan.Deadline{Date: date}
+	return pb
+}
+
+// Resource declares a shared resource capacity
+func (pb *PlanBuilder) Resource(name string, capacity float64) *PlanBuilder {
+	if pb.model.Resources == nil {
+		pb.model.Resources = make(plan.Capacities)
+	}
+	pb.model.Resources[name] = capacity
+	return pb
+}
+
+// Risk adds a risk register entry. An empty attach attaches the risk to the
+// plan.
+func (pb *PlanBuilder) Risk(name string, riskExpr string, attach string) *PlanBuilder {
+	pb.model.Risks = append(pb.model.Risks, &plan.Risk{
+		Name:   name,
+		Type:   riskExpr,
+		Attach: attach,
+	})
+	return pb
+}
+
+// Correlate sets the target rank correlation between two tasks
+func (pb *PlanBuilder) Correlate(taskA string, taskB string, rho float64) *PlanBuilder {
+	if pb.model.Correlations == nil {
+		pb.model.Correlations = &plan.Correlations{}
+	}
+	pb.model.Correlations.Pairs = append(pb.model.Correlations.Pairs, &plan.CorrelationPair{
+		Tasks: []string{taskA, taskB},
+		Rho:   rho,
+	})
+	return pb
+}
+
+// Assert adds an assertion verified by the check command
+func (pb *PlanBuilder) Assert(assertion string) *PlanBuilder {
+	pb.model.Assertions = append(pb.model.Assertions, assertion)
+	return pb
+}
+
+// Scenario adds a what-if scenario compared against the plan
+func (pb *PlanBuilder) Scenario(scenario *ScenarioBuilder) *PlanBuilder {
+	pb.model.Scenarios = append(pb.model.Scenarios, scenario.model)
+	return pb
+}
+
+// Model returns the plan model as built so far
+func (pb *PlanBuilder) Model() *plan.Plan {
+	return pb.model
+}
+
+// Build validates the plan and returns it. Errors are located by JSON
+// pointer into the plan's canonical JSON.
+func (pb *PlanBuilder) Build() (*Plan, error) {
+	return PlanFromModel(pb.model)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// SubgraphBuilder
+// /////////////////////////////////////////////////////////////////////////////
+
+// SubgraphBuilder builds a subgraph
+type SubgraphBuilder struct {
+	activitiesBuilder[*SubgraphBuilder]
+	model *plan.Subgraph
+}
+
+// NewSubgraph returns the builder for the named subgraph
+func NewSubgraph(name string) *SubgraphBuilder {
+	builder := &SubgraphBuilder{
+		model: &plan.Subgraph{
+			Name:       name,
+			Activities: &plan.Activities{},
+		},
+	}
+	builder.activitiesBuilder = activitiesBuilder[*SubgraphBuilder]{
+		activities: builder.model.Activities,
+		self:       builder,
+	}
+	return builder
+}
+
+// Join sets the join expression: max, min, sum or kofn(k)
+func (sb *SubgraphBuilder) Join(join string) *SubgraphBuilder {
+	sb.model.Join = join
+	return sb
+}
+
+// Repeat sets the repeat count expression for rework loops
+func (sb *SubgraphBuilder) Repeat(repeat string) *SubgraphBuilder {
+	sb.model.Repeat = repeat
+	return sb
+}
+
+// Deadline sets the subgraph deadline in duration units
+func (sb *SubgraphBuilder) Deadline(duration float64) *SubgraphBuilder {
+	sb.model.Deadline = &plan.Deadline{Duration: duration}
+	return sb
+}
+
+// DeadlineDate sets the subgraph deadline to a YYYY-MM-DD date
+func (sb *SubgraphBuilder) DeadlineDate(date string) *SubgraphBuilder {
+	sb.model.Deadline = &plan.Deadline{Date: date}
+	return sb
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// BranchBuilder
+// /////////////////////////////////////////////////////////////////////////////
+
+// BranchBuilder builds a choice branch. Branches without activities take no
+// time.
+type BranchBuilder struct {
+	activitiesBuilder[*BranchBuilder]
+	model *plan.Branch
+}
+
+// NewBranch returns the builder for the named branch with the relative
+// weight
+func NewBranch(name string, weight float64) *BranchBuilder {
+	builder := &BranchBuilder{
+		model: &plan.Branch{
+			Name:       name,
+			Weight:     &weight,
+			Activities: &plan.Activities{},
+		},
+	}
+	builder.activitiesBuilder = activitiesBuilder[*BranchBuilder]{
+		activities: builder.model.Activities,
+		self:       builder,
+	}
+	return builder
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// ScenarioBuilder
+// /////////////////////////////////////////////////////////////////////////////
+
+// ScenarioBuilder builds a what-if scenario. Tasks are referenced by name.
+type ScenarioBuilder struct {
+	model *plan.Scenario
+}
+
+// NewScenario returns the builder for the named scenario
+func NewScenario(name string) *ScenarioBuilder {
+	return &ScenarioBuilder{
+		model: &plan.Scenario{
+			Name: name,
+		},
+	}
+}
+
+// Override replaces the task's duration with the generator expression
+func (sb *ScenarioBuilder) Override(task string, typeExpr string) *ScenarioBuilder {
+	if sb.model.Overrides == nil {
+		sb.model.Overrides = make(map[string]string)
+	}
+	sb.model.Overrides[task] = typeExpr
+	return sb
+}
+
+// Add adds the named task after the serial task. An empty after adds the
+// task in parallel with the plan's activities.
+func (sb *ScenarioBuilder) Add(after string, task *plan.Task) *ScenarioBuilder {
+	sb.model.Add = append(sb.model.Add, &plan.ScenarioTask{
+		After: after,
+		Task:  task,
+	})
+	return sb
+}
+
+// Remove removes the tasks, and the risks and correlations that reference
+// them
+func (sb *ScenarioBuilder) Remove(tasks ...string) *ScenarioBuilder {
+	sb.model.Remove = append(sb.model.Remove, tasks...)
+	return sb
+}
+
+// Workdays overrides whether the scenario estimates workday completion
+// dates
+func (sb *ScenarioBuilder) Workdays(workdays bool) *ScenarioBuilder {
+	sb.model.Workdays = &workdays
+	return sb
+}
+
+// Holidays adds non-working YYYY-MM-DD dates to the scenario's workday
+// calendar
+func (sb *ScenarioBuilder) Holidays(dates ...string) *ScenarioBuilder {
+	sb.model.Holidays = append(sb.model.Holidays, dates...)
+	return sb
+}
@@ -0,0 +1,122 @@
+package estimate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mweagle/goestimate/plan"
+)
+
+// canonicalJSON returns the plan's canonical JSON
+func canonicalJSON(t *testing.T, estimatePlan *Plan) []byte {
+	t.Helper()
+	var output bytes.Buffer
+	writeErr := estimatePlan.WriteJSON(&output)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	return output.Bytes()
+}
+
+// roundTrip reads the plan's canonical JSON and verifies that the read plan
+// has the same canonical JSON
+func roundTrip(t *testing.T, estimatePlan *Plan) *Plan {
+	t.Helper()
+	planJSON := canonicalJSON(t, estimatePlan)
+	readPlan, readPlanErr := ReadPlan(bytes.NewReader(planJSON))
+	if readPlanErr != nil {
+		t.Fatalf("invalid canonical JSON: %v\n%s", readPlanErr, planJSON)
+	}
+	if !bytes.Equal(planJSON, canonicalJSON(t, readPlan)) {
+		t.Fatalf("canonical JSON changed when read:\n%s\n%s", planJSON, canonicalJSON(t, readPlan))
+	}
+	return readPlan
+}
+
+func summaryJSON(t *testing.T, estimatePlan *Plan) []byte {
+	t.Helper()
+	result, resultErr := estimatePlan.Evaluate(context.Background(), &Options{Seed: 7, RunCount: 2000})
+	if resultErr != nil {
+		t.Fatal(resultErr)
+	}
+	summaryBytes, summaryBytesErr := json.Marshal(result.Summary)
+	if summaryBytesErr != nil {
+		t.Fatal(summaryBytesErr)
+	}
+	return summaryBytes
+}
+
+// A built plan survives a JSON round trip, and the read plan evaluates to
+// the same results
+func TestBuilderRoundTrip(t *testing.T) {
+	review := Task("Review", "Triangle(1,2,4)")
+	review.Repeat = "Geometric(0.6)"
+	review.Cost = &plan.Cost{Fixed: 500, Rate: 100}
+	builtPlan, builtPlanErr := NewPlan("Launch").
+		Percentiles(50, 90).
+		Budget(20000).
+		Deadline(40).
+		Resource("dev", 1).
+		Serial(Task("Design", "PERT(3,5,10)"), review).
+		ParallelJoin("kofn(2)",
+			Task("A", "PERT(2,4,9)"),
+			Task("B", "Normal(5, 1)"),
+			Task("C", "Beta(2, 5)")).
+		Subgraph(NewSubgraph("Release").
+			Repeat("Poisson(1)+1").
+			Deadline(20).
+			Serial(Task("Deploy", "Fixed(1)"))).
+		Choice("Approval",
+			NewBranch("Approved", 0.7),
+			NewBranch("Redesign", 0.3).Serial(Task("Redesign", "PERT(3,5,10)"))).
+		Risk("Slip", "Risk(p=0.1, impact=PERT(5,10,20))", "Design").
+		Correlate("Design", "Review", 0.5).
+		Assert("p90 < 100").
+		Scenario(NewScenario("Faster").Override("Design", "PERT(2,3,5)").Remove("C")).
+		Build()
+	if builtPlanErr != nil {
+		t.Fatal(builtPlanErr)
+	}
+	readPlan := roundTrip(t, builtPlan)
+	if !bytes.Equal(summaryJSON(t, builtPlan), summaryJSON(t, readPlan)) {
+		t.Error("the read plan's summary differs from the built plan's summary")
+	}
+}
+
+// Every example plan survives a JSON round trip
+func TestExamplesRoundTrip(t *testing.T) {
+	examplePaths, examplePathsErr := filepath.Glob("../examples/*.json")
+	if examplePathsErr != nil || len(examplePaths) == 0 {
+		t.Fatalf("missing examples: %v", examplePathsErr)
+	}
+	for _, eachPath := range examplePaths {
+		t.Run(filepath.Base(eachPath), func(t *testing.T) {
+			planFile, planFileErr := os.Open(eachPath)
+			if planFileErr != nil {
+				t.Fatal(planFileErr)
+			}
+			defer planFile.Close()
+			examplePlan, examplePlanErr := ReadNamedPlan(eachPath, planFile)
+			if examplePlanErr != nil {
+				t.Fatal(examplePlanErr)
+			}
+			roundTrip(t, examplePlan)
+		})
+	}
+}
+
+// Built plans are validated like plans that are read, with errors located in
+// the canonical JSON
+func TestBuilderErrors(t *testing.T) {
+	_, builtPlanErr := NewPlan("Invalid").
+		Serial(Task("Design", "PERT(10,5,1)")).
+		Build()
+	planErrs := plan.AsErrors(builtPlanErr)
+	if len(planErrs) != 1 || planErrs[0].Pointer != "/activities/tasks/0/type" || planErrs[0].Filename != MODEL_NAME {
+		t.Fatalf("invalid build error. Expected an error at %s /activities/tasks/0/type, Found: %v", MODEL_NAME, builtPlanErr)
+	}
+}
@@ -0,0 +1,118 @@
+package estimate
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mweagle/goestimate/app"
+)
+
+// Stats are the summary statistics of a set of samples. Percentiles are
+// keyed by name, ex: p95. Plans evaluated with intervals add the confidence
+// intervals of the mean and percentiles.
+type Stats = app.StatsSummary
+
+// Summary is the plan level results: the total duration, critical path,
+// task durations, deadlines, cost, resource constrained duration, the
+// achieved precision of auto run counts and the what-if scenarios
+type Summary = app.Summary
+
+// NodeResult is the evaluated duration and per run samples of a task,
+// subgraph or the plan
+type NodeResult = app.NodeResult
+
+// InvalidPlanError is returned when a plan can't be evaluated because its
+// tasks, risks or correlations don't resolve
+type InvalidPlanError = app.InvalidPlanError
+
+// Options are the evaluation options
+type Options struct {
+	// Seed seeds the random number source. Evaluations with the same seed
+	// produce the same results.
+	Seed uint64
+	// RunCount overrides the plan's runCount, including auto run counts,
+	// if non-zero
+	RunCount uint64
+	// Workers is the number of concurrent evaluation workers. Zero uses
+	// every available CPU. Results don't depend on the number of workers.
+	Workers int
+	// Sampling overrides the plan's sampling strategy if not empty: mc, lhs,
+	// antithetic or sobol
+	Sampling string
+	// Intervals overrides the plan's confidence interval method of the mean
+	// and percentiles if not empty: order, bootstrap or bootstrap-all
+	Intervals string
+	// Sensitivity overrides the plan's task sensitivity method if not
+	// empty: spearman or sobol
+	Sensitivity string
+	// Streaming summarizes the runs with quantile sketches rather than
+	// retaining every sample, so memory doesn't grow with RunCount. Medians
+	// and percentiles are estimates within the sketch error bound. Samples,
+	// CostSamples and the node samples are empty.
+	Streaming bool
+	// Logger receives the evaluation log. Defaults to discarding it.
+	Logger *slog.Logger
+}
+
+// Result is an evaluated plan
+type Result struct {
+	*Summary
+	// Nodes are the results of every task and subgraph, and the plan, in
+	// document order
+	Nodes []*NodeResult
+	// Samples are the total duration of each run, unless the evaluation
+	// was streamed
+	Samples []float64
+	// CostSamples are the total cost of each run, if the plan has costs,
+	// unless the evaluation was streamed
+	CostSamples []float64
+
+	evaluation *app.Evaluation
+	log        *slog.Logger
+}
+
+// Evaluate runs the Monte Carlo simulation of the plan. Evaluation stops
+// early if the context is done.
+func (p *Plan) Evaluate(ctx context.Context, opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	log := opts.Logger
+	if log == nil {
+		log = discardLogger()
+	}
+	evaluation, evaluationErr := app.EvaluatePlan(ctx,
+		p.model,
+		app.EvaluateOptions{
+			Seed:        opts.Seed,
+			RunCount:    opts.RunCount,
+			Workers:     opts.Workers,
+			Streaming:   opts.Streaming,
+			Sampling:    opts.Sampling,
+			Intervals:   opts.Intervals,
+			Sensitivity: opts.Sensitivity,
+		},
+		log)
+	if evaluationErr != nil {
+		return nil, evaluationErr
+	}
+	return &Result{
+		Summary:     evaluation.Summary(),
+		Nodes:       evaluation.Nodes(),
+		Samples:     evaluation.Samples(),
+		CostSamples: evaluation.CostSamples(),
+		evaluation:  evaluation,
+		log:         log,
+	}, nil
+}
+
+// Node returns the result of the task or subgraph with the given name, or
+// nil if there isn't one
+func (r *Result) Node(name string) *NodeResult {
+	for _, eachNode := range r.Nodes {
+		if eachNode.Name == name {
+			return eachNode
+		}
+	}
+	return nil
+}
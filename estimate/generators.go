@@ -0,0 +1,26 @@
+package estimate
+
+import (
+	"github.com/mweagle/goestimate/generator"
+)
+
+// GeneratorFactory returns the generator for the validated parameters of a
+// custom GENERATOR(...) expression
+type GeneratorFactory = generator.Factory
+
+// ParamSchema describes the parameters of a custom generator expression
+type ParamSchema = generator.ParamSchema
+
+// RegisterGenerator registers a custom GENERATOR(...) expression, usable by
+// tasks, risks, costs and correlation drivers in every plan. The expression
+// parameters are validated against the schema before the factory is called.
+// Names may only be registered once, and the built-in names are reserved.
+func RegisterGenerator(name string, factory GeneratorFactory, schema *ParamSchema) error {
+	return generator.RegisterGenerator(name, factory, schema)
+}
+
+// RegisteredGenerators returns the sorted names of the built-in and custom
+// generators
+func RegisteredGenerators() []string {
+	return generator.RegisteredGenerators()
+}
@@ -0,0 +1,93 @@
+// Package estimate is the embeddable goestimate API. Plans are read from an
+// io.Reader, or built from the typed plan model, and evaluated in-process:
+//
+//	estimatePlan, planErr := estimate.ReadPlan(planReader)
+//	if planErr != nil {
+//		return planErr
+//	}
+//	result, resultErr := estimatePlan.Evaluate(ctx, &estimate.Options{Seed: 42})
+//	if resultErr != nil {
+//		return resultErr
+//	}
+//	fmt.Printf("p95: %.2f\n", result.Duration.Percentiles["p95"])
+//	return (&estimate.SVGRenderer{}).Render(svgWriter, result)
+package estimate
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+
+	"github.com/mweagle/goestimate/app"
+	"github.com/mweagle/goestimate/plan"
+)
+
+// READER_NAME and MODEL_NAME are the names that errors in plans read from an
+// io.Reader, or built from a model, are located against
+var READER_NAME = "<reader>"
+var MODEL_NAME = "<model>"
+
+// Plan is a decoded and validated plan
+type Plan struct {
+	model *plan.Plan
+}
+
+// ReadPlan strictly decodes and validates the plan JSON. Decoding errors are
+// plan.Errors located by line, column and JSON pointer.
+func ReadPlan(reader io.Reader) (*Plan, error) {
+	return ReadNamedPlan(READER_NAME, reader)
+}
+
+// ReadNamedPlan strictly decodes the plan JSON and validates it with the
+// rules of the validate command. Warnings are ignored, and errors are
+// located against the name, typically the plan's path.
+func ReadNamedPlan(name string, reader io.Reader) (*Plan, error) {
+	planBytes, planBytesErr := io.ReadAll(reader)
+	if planBytesErr != nil {
+		return nil, planBytesErr
+	}
+	model, modelErr := plan.Decode(name, planBytes)
+	if modelErr != nil {
+		return nil, modelErr
+	}
+	verifyErr := app.VerifyPlan(model, discardLogger())
+	if verifyErr != nil {
+		return nil, verifyErr
+	}
+	return &Plan{model: model}, nil
+}
+
+// PlanFromModel returns the plan for a model built in code. The model is
+// validated as if it had been read, so it must satisfy the same rules.
+func PlanFromModel(model *plan.Plan) (*Plan, error) {
+	modelBytes, modelBytesErr := json.Marshal(model)
+	if modelBytesErr != nil {
+		return nil, modelBytesErr
+	}
+	return ReadNamedPlan(MODEL_NAME, bytes.NewReader(modelBytes))
+}
+
+// Model returns the typed plan model
+func (p *Plan) Model() *plan.Plan {
+	return p.model
+}
+
+// Name returns the plan's name
+func (p *Plan) Name() string {
+	return p.model.Name
+}
+
+// WriteJSON writes the plan as canonical JSON
+func (p *Plan) WriteJSON(output io.Writer) error {
+	return plan.EncodeJSON(p.model, output)
+}
+
+// WriteYAML writes the plan as canonical YAML
+func (p *Plan) WriteYAML(output io.Writer) error {
+	return plan.EncodeYAML(p.model, output)
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
@@ -0,0 +1,99 @@
+package estimate
+
+import (
+	"io"
+
+	"oss.terrastruct.com/d2/d2themes/d2themescatalog"
+)
+
+// Renderer writes an evaluated plan
+type Renderer interface {
+	Render(output io.Writer, result *Result) error
+}
+
+// D2Renderer writes the D2 diagram. The diagram links to the distribution
+// plot at HistogramPath, and the cost plot next to it, if the path is set.
+type D2Renderer struct {
+	HistogramPath string
+}
+
+// Render writes the D2 source
+func (d2r *D2Renderer) Render(output io.Writer, result *Result) error {
+	return result.evaluation.WriteD2(output, d2r.HistogramPath, result.log)
+}
+
+// SVGRenderer writes the D2 diagram rendered as an SVG. Theme IDs default to
+// NeutralGrey and DarkMauve.
+type SVGRenderer struct {
+	HistogramPath string
+	LightThemeID  *int64
+	DarkThemeID   *int64
+}
+
+// Render writes the SVG
+func (svgr *SVGRenderer) Render(output io.Writer, result *Result) error {
+	lightThemeID := d2themescatalog.NeutralGrey.ID
+	if svgr.LightThemeID != nil {
+		lightThemeID = *svgr.LightThemeID
+	}
+	darkThemeID := d2themescatalog.DarkMauve.ID
+	if svgr.DarkThemeID != nil {
+		darkThemeID = *svgr.DarkThemeID
+	}
+	return result.evaluation.WriteSVG(output,
+		svgr.HistogramPath,
+		lightThemeID,
+		darkThemeID,
+		result.log)
+}
+
+// PNGRenderer writes the histogram and CDF of the total duration, or the
+// joint cost/duration plot if Cost is true
+type PNGRenderer struct {
+	Cost bool
+}
+
+// Render writes the PNG
+func (pngr *PNGRenderer) Render(output io.Writer, result *Result) error {
+	if pngr.Cost {
+		return result.evaluation.WriteCostPNG(output)
+	}
+	return result.evaluation.WritePNG(output)
+}
+
+// SensitivityRenderer writes the tornado chart of the task sensitivity as a
+// PNG, or as an SVG if SVG is true. The plan must have a sensitivity method.
+type SensitivityRenderer struct {
+	SVG bool
+}
+
+// Render writes the tornado chart
+func (sr *SensitivityRenderer) Render(output io.Writer, result *Result) error {
+	if sr.SVG {
+		return result.evaluation.WriteSensitivitySVG(output)
+	}
+	return result.evaluation.WriteSensitivityPNG(output)
+}
+
+// ScenariosRenderer writes the overlaid CDFs of the plan and its what-if
+// scenarios as a PNG, or as an SVG if SVG is true. The plan must have
+// scenarios.
+type ScenariosRenderer struct {
+	SVG bool
+}
+
+// Render writes the overlaid CDFs
+func (sr *ScenariosRenderer) Render(output io.Writer, result *Result) error {
+	if sr.SVG {
+		return result.evaluation.WriteScenariosSVG(output)
+	}
+	return result.evaluation.WriteScenariosPNG(output)
+}
+
+// JSONRenderer writes the summary as JSON, the same as the `json` output
+type JSONRenderer struct{}
+
+// Render writes the JSON
+func (jsonr *JSONRenderer) Render(output io.Writer, result *Result) error {
+	return result.evaluation.WriteJSON(output)
+}
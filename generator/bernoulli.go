@@ -3,8 +3,6 @@ package generator
 import (
 	"fmt"
 	"log/slog"
-	"regexp"
-	"strings"
 
 	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/stat/distuv"
@@ -39,24 +37,14 @@ func (bg *BernoulliGenerator) Generate(priorSamples map[int64]*GenerationResults
 	return bg.BaseGenerator.Generate(generator, priorSamples, percentiles, log)
 }
 
+// bernoulliFactory returns the Bernoulli generator for the arguments
+func bernoulliFactory(args *Arguments, _ *slog.Logger) (DurationGenerator, error) {
+	return &BernoulliGenerator{
+		prob: args.Float("prob"),
+	}, nil
+}
+
+// UnmarshalBernoulli returns the Bernoulli generator for the Bernoulli(...) expression
 func UnmarshalBernoulli(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
-	// Supported forms:
-	// Bernoulli(prob)
-	bg := &BernoulliGenerator{}
-	reParams := regexp.MustCompile(`[()]`)
-	bernoulliParts := reParams.Split(typeParameter, -1)
-	if len(bernoulliParts) < 2 {
-		return nil, fmt.Errorf("invalid Bernoulli generator expression: %s", typeParameter)
-	}
-	bernoulliVals := strings.Split(bernoulliParts[1], ",")
-
-	if len(bernoulliVals) == 1 {
-		err := bg.BaseGenerator.parseFloat(strings.TrimSpace(bernoulliVals[0]), &bg.prob)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		return nil, fmt.Errorf("invalid Bernoulli generator expression: %s", typeParameter)
-	}
-	return bg, nil
+	return unmarshalRegistered("Bernoulli", typeParameter, log)
 }
@@ -3,8 +3,6 @@ package generator
 import (
 	"fmt"
 	"log/slog"
-	"regexp"
-	"strings"
 
 	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/stat/distuv"
@@ -36,34 +34,22 @@ func (bg *BetaGenerator) Generate(priorSamples map[int64]*GenerationResults,
 	generator := distuv.Beta{
 		Alpha: bg.alpha,
 		Beta:  bg.beta,
+		Src:   src,
 	}
 
 	// Delegate to the Base generator
 	return bg.BaseGenerator.Generate(generator, priorSamples, percentiles, log)
 }
 
-func UnmarshalBeta(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
-	// Supported forms:
-	// Bernoulli(prob)
-	bg := &BetaGenerator{}
-	reParams := regexp.MustCompile(`[()]`)
-	bernoulliParts := reParams.Split(typeParameter, -1)
-	if len(bernoulliParts) < 2 {
-		return nil, fmt.Errorf("invalid Beta generator expression: %s", typeParameter)
-	}
-	bernoulliVals := strings.Split(bernoulliParts[1], ",")
+// betaFactory returns the Beta generator for the arguments
+func betaFactory(args *Arguments, _ *slog.Logger) (DurationGenerator, error) {
+	return &BetaGenerator{
+		alpha: args.Float("alpha"),
+		beta:  args.Float("beta"),
+	}, nil
+}
 
-	if len(bernoulliVals) == 2 {
-		err := bg.BaseGenerator.parseFloat(strings.TrimSpace(bernoulliVals[0]), &bg.alpha)
-		if err != nil {
-			return nil, err
-		}
-		err = bg.BaseGenerator.parseFloat(strings.TrimSpace(bernoulliVals[1]), &bg.beta)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		return nil, fmt.Errorf("invalid Beta generator expression: %s", typeParameter)
-	}
-	return bg, nil
+// UnmarshalBeta returns the Beta generator for the Beta(...) expression
+func UnmarshalBeta(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
+	return unmarshalRegistered("Beta", typeParameter, log)
 }
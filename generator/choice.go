@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+//   ___ _         _
+//  / __| |_  ___ (_)__ ___
+// | (__| ' \/ _ \| / _/ -_)
+//  \___|_||_\___/|_\__\___|
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// ChoiceGenerator is a join that, for each run, selects exactly one of its
+// predecessors according to the predecessor weights and propagates that
+// predecessor's value.
+type ChoiceGenerator struct {
+	JoinBase
+	// Weights is the relative weight of each predecessor, keyed by predecessor ID
+	Weights     map[int64]float64
+	frequencies map[int64]float64
+	chosenKeys  []int64
+}
+
+func (cg *ChoiceGenerator) Name() string {
+	return fmt.Sprintf("Choice(n=%d)", len(cg.Weights))
+}
+
+// Probability returns the normalized probability that the predecessor
+// with the given ID is chosen
+func (cg *ChoiceGenerator) Probability(predecessorID int64) float64 {
+	totalWeight := float64(0)
+	for _, eachWeight := range cg.Weights {
+		totalWeight += eachWeight
+	}
+	if totalWeight <= 0 {
+		return 0
+	}
+	return cg.Weights[predecessorID] / totalWeight
+}
+
+// Frequencies returns the observed fraction of runs that chose each
+// predecessor, keyed by predecessor ID
+func (cg *ChoiceGenerator) Frequencies() map[int64]float64 {
+	return cg.frequencies
+}
+
+// Chosen returns, for each run, the ID of the predecessor that was chosen
+func (cg *ChoiceGenerator) Chosen() []int64 {
+	return cg.chosenKeys
+}
+
+// CriticalPredecessors returns the most likely branch
+func (cg *ChoiceGenerator) CriticalPredecessors(predecessorMeans map[int64]float64) []int64 {
+	var likelyID int64
+	likelyWeight := math.Inf(-1)
+	for _, eachID := range sortedMeanKeys(predecessorMeans) {
+		if cg.Weights[eachID] > likelyWeight {
+			likelyWeight = cg.Weights[eachID]
+			likelyID = eachID
+		}
+	}
+	return []int64{likelyID}
+}
+
+// Rejoin selects the same branch for each run that was selected by Generate
+func (cg *ChoiceGenerator) Rejoin(priorValues map[int64][]float64, entry []float64) ([]float64, error) {
+	chosenValues := make([]float64, len(cg.chosenKeys))
+	for sampleIdx, eachKey := range cg.chosenKeys {
+		branchValues, branchValuesExist := priorValues[eachKey]
+		if !branchValuesExist {
+			return nil, fmt.Errorf("no rejoin values for ChoiceGenerator predecessor: %d", eachKey)
+		}
+		chosenValues[sampleIdx] = branchValues[sampleIdx]
+	}
+	return cg.rejoin(func(_ [][]float64, _ []float64) []float64 {
+		return chosenValues
+	}, priorValues, entry)
+}
+
+func (cg *ChoiceGenerator) Generate(priorSamples map[int64]*GenerationResults,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) (*GenerationResults, error) {
+
+	if len(priorSamples) <= 0 {
+		return nil, fmt.Errorf("no slices provided to ChoiceGenerator")
+	}
+	priorSampleKeys := sortedPriorKeys(priorSamples)
+	weights := make([]float64, len(priorSampleKeys))
+	totalWeight := float64(0)
+	for i, eachKey := range priorSampleKeys {
+		weight, weightExists := cg.Weights[eachKey]
+		if !weightExists || weight < 0 {
+			return nil, fmt.Errorf("invalid ChoiceGenerator weight for predecessor: %d", eachKey)
+		}
+		weights[i] = weight
+		totalWeight += weight
+	}
+	// NewCategorical panics without a positive total weight
+	if totalWeight <= 0 {
+		return nil, fmt.Errorf("invalid ChoiceGenerator weights: %v. Expected a positive total weight", weights)
+	}
+	chooser := distuv.NewCategorical(weights, src)
+
+	sampleSize := len(*priorSamples[priorSampleKeys[0]].RawValues)
+	choiceCounts := make([]int, len(priorSampleKeys))
+	chosenValues := make([]float64, sampleSize)
+	cg.chosenKeys = make([]int64, sampleSize)
+	for sampleIdx := 0; sampleIdx != sampleSize; sampleIdx++ {
+		chosenIndex := int(chooser.Rand())
+		choiceCounts[chosenIndex]++
+		cg.chosenKeys[sampleIdx] = priorSampleKeys[chosenIndex]
+		chosenValues[sampleIdx] = (*priorSamples[priorSampleKeys[chosenIndex]].RawValues)[sampleIdx]
+	}
+	cg.frequencies = make(map[int64]float64, len(priorSampleKeys))
+	for i, eachKey := range priorSampleKeys {
+		cg.frequencies[eachKey] = float64(choiceCounts[i]) / float64(sampleSize)
+	}
+	return cg.computeJoinAggregates(chosenValues, entryValues(priorSamples), percentiles, src, log)
+}
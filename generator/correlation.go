@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CorrelatedGenerator is the interface satisfied by generators whose samples
+// can be correlated with other generators
+type CorrelatedGenerator interface {
+	// SetRankScores reorders the generated samples so that their ranks match
+	// the ranks of the per-run scores. The marginal distribution is unchanged.
+	SetRankScores(scores []float64)
+	// SetScale multiplies each run's generated sample by the per-run factor
+	SetScale(factors []float64)
+}
+
+func (bg *BaseGenerator) SetRankScores(scores []float64) {
+	bg.rankScores = scores
+}
+
+func (bg *BaseGenerator) SetScale(factors []float64) {
+	bg.scale = factors
+}
+
+// applyCorrelation applies the rank reordering and then the scale factors
+// to the generated samples
+func (bg *BaseGenerator) applyCorrelation(generatorSamples []float64) ([]float64, error) {
+	if bg.rankScores != nil {
+		if len(bg.rankScores) != len(generatorSamples) {
+			return nil, fmt.Errorf("invalid rank score length. Expected: %d, Found: %d", len(generatorSamples), len(bg.rankScores))
+		}
+		sortedSamples := make([]float64, len(generatorSamples))
+		copy(sortedSamples, generatorSamples)
+		sort.Float64s(sortedSamples)
+
+		// The run with the i-th smallest score gets the i-th smallest sample
+		runOrder := make([]int, len(bg.rankScores))
+		for i := range runOrder {
+			runOrder[i] = i
+		}
+		sort.SliceStable(runOrder, func(i, j int) bool {
+			return bg.rankScores[runOrder[i]] < bg.rankScores[runOrder[j]]
+		})
+		reorderedSamples := make([]float64, len(generatorSamples))
+		for rank, runIndex := range runOrder {
+			reorderedSamples[runIndex] = sortedSamples[rank]
+		}
+		generatorSamples = reorderedSamples
+	}
+	if bg.scale != nil {
+		if len(bg.scale) != len(generatorSamples) {
+			return nil, fmt.Errorf("invalid scale length. Expected: %d, Found: %d", len(generatorSamples), len(bg.scale))
+		}
+		for i := range generatorSamples {
+			generatorSamples[i] *= bg.scale[i]
+		}
+	}
+	return generatorSamples, nil
+}
@@ -0,0 +1,92 @@
+package generator
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+
+	"golang.org/x/exp/rand"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+//  ___  __  __          _
+// | __|/ _|/ _|___ _ _| |_
+// | _||  _|  _/ _ \ '_|  _|
+// |___|_| |_| \___/_|  \__|
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// EffortGenerator derives elapsed duration from an effort distribution,
+// measured in person-units (ex: person-days), and an assigned headcount.
+// The optional overhead is the Brooks factor: each additional person adds
+// that fraction of communication overhead, so that:
+//
+//	elapsed = effort / staff * (1 + overhead * (staff - 1))
+type EffortGenerator struct {
+	BaseGenerator
+	Effort   DurationGenerator
+	Staff    float64
+	Overhead float64
+}
+
+// NewEffortGenerator returns an EffortGenerator for the effort expression
+// with the given staffing
+func NewEffortGenerator(effortExpr string, staff float64, overhead float64, log *slog.Logger) (*EffortGenerator, error) {
+	effortGenerator, effortGeneratorErr := NewDurationGeneratorFromExpression(effortExpr, log)
+	if effortGeneratorErr != nil {
+		return nil, effortGeneratorErr
+	}
+	eg := &EffortGenerator{
+		Effort:   effortGenerator,
+		Staff:    staff,
+		Overhead: overhead,
+	}
+	return eg, eg.Validate()
+}
+
+func (eg *EffortGenerator) Validate() error {
+	if eg.Staff <= 0 {
+		return fmt.Errorf("invalid Effort staff: %.2f. Staff must be greater than zero", eg.Staff)
+	}
+	if eg.Overhead < 0 {
+		return fmt.Errorf("invalid Effort overhead: %.2f. Overhead must be greater than or equal to zero", eg.Overhead)
+	}
+	return nil
+}
+
+func (eg *EffortGenerator) Name() string {
+	return fmt.Sprintf("Effort(%s, staff=%g, overhead=%.2f)",
+		eg.Effort.Name(),
+		eg.Staff,
+		eg.Overhead)
+}
+
+// ElapsedFactor is the multiplier that converts effort into elapsed duration
+func (eg *EffortGenerator) ElapsedFactor() float64 {
+	return (1 + eg.Overhead*math.Max(0, eg.Staff-1)) / eg.Staff
+}
+
+func (eg *EffortGenerator) Generate(priorSamples map[int64]*GenerationResults,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) (*GenerationResults, error) {
+
+	effortResults, effortResultsErr := eg.Effort.Generate(priorSamples, percentiles, src, log)
+	if effortResultsErr != nil {
+		return nil, effortResultsErr
+	}
+	if len(priorSamples) != 1 {
+		return nil, fmt.Errorf("invalid length for prior samples: %d", len(priorSamples))
+	}
+	var genResults *GenerationResults
+	for _, val := range priorSamples {
+		genResults = val
+	}
+	elapsedFactor := eg.ElapsedFactor()
+	effortValues := *effortResults.RawValues
+	elapsedValues := make([]float64, len(effortValues))
+	for i, eachEffort := range effortValues {
+		elapsedValues[i] = eachEffort * elapsedFactor
+	}
+	return eg.computeAggregates(elapsedValues, *genResults.CumulativeValues, percentiles, log)
+}
@@ -3,8 +3,6 @@ package generator
 import (
 	"fmt"
 	"log/slog"
-	"regexp"
-	"strings"
 
 	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/stat/distuv"
@@ -41,24 +39,14 @@ func (ng *FixedGenerator) Generate(priorSamples map[int64]*GenerationResults,
 	return ng.BaseGenerator.Generate(generator, priorSamples, percentiles, log)
 }
 
-func UnmarshalFixed(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
-	// Supported forms:
-	// Fixed(value)
-	ng := &FixedGenerator{}
-	reParams := regexp.MustCompile(`[()]`)
-	normalParts := reParams.Split(typeParameter, -1)
-	if len(normalParts) < 2 {
-		return nil, fmt.Errorf("invalid Fixed generator expression: %s", typeParameter)
-	}
-	normalFloatParts := strings.Split(normalParts[1], ",")
+// fixedFactory returns the Fixed generator for the arguments
+func fixedFactory(args *Arguments, _ *slog.Logger) (DurationGenerator, error) {
+	return &FixedGenerator{
+		value: args.Float("value"),
+	}, nil
+}
 
-	if len(normalFloatParts) == 1 {
-		err := ng.BaseGenerator.parseFloat(strings.TrimSpace(normalFloatParts[0]), &ng.value)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		return nil, fmt.Errorf("invalid normal generator expression: %s", typeParameter)
-	}
-	return ng, nil
+// UnmarshalFixed returns the Fixed generator for the Fixed(...) expression
+func UnmarshalFixed(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
+	return unmarshalRegistered("Fixed", typeParameter, log)
 }
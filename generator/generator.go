@@ -4,10 +4,8 @@ import (
 	"fmt"
 	"log/slog"
 	"regexp"
-	"strconv"
 	"strings"
 
-	"github.com/mweagle/goestimate/json"
 	"github.com/mweagle/goestimate/stats"
 
 	"golang.org/x/exp/rand"
@@ -19,6 +17,9 @@ type GenerationResults struct {
 	GeneratorStats   *stats.AggregatedStatistics
 	CumulativeValues *[]float64
 	CumulativeStats  *stats.AggregatedStatistics
+	// CumulativeSummary summarizes the cumulative values of streaming
+	// evaluations, which don't retain the values
+	CumulativeSummary *stats.Accumulator
 }
 
 type DurationGenerator interface {
@@ -32,23 +33,69 @@ type DurationGenerator interface {
 
 type generatorFilter func(in float64) float64
 
-type unmarshalFunc func(string, *slog.Logger) (DurationGenerator, error)
-
-var unmarshalMap map[string]unmarshalFunc
-
 func init() {
-	// The map of supported distributions. The upper bound generator doesn't
+	// The supported distributions. The upper bound generator doesn't
 	// take any parameters and is implicitly created as part of the graph
 	// creation
-	unmarshalMap = map[string]unmarshalFunc{
-		"PERT":      UnmarshalPERT,
-		"Pareto":    UnmarshalPareto,
-		"Normal":    UnmarshalNormal,
-		"Fixed":     UnmarshalFixed,
-		"Bernoulli": UnmarshalBernoulli,
-		"Beta":      UnmarshalBeta,
-		"Triangle":  UnmarshalTriangle,
-	}
+	MustRegisterGenerator("PERT", pertFactory, &ParamSchema{
+		Description: "PERT distribution. PERT(n) is the fixed value n",
+		Params: []*Param{
+			{Name: "min", Description: "Minimum value"},
+			{Name: "mode", Description: "Most likely value"},
+			{Name: "max", Description: "Maximum value"},
+		},
+		Arities: []int{1, 3},
+	})
+	MustRegisterGenerator("Pareto", paretoFactory, &ParamSchema{
+		Description: "Pareto distribution, optionally capped at max",
+		Params: []*Param{
+			{Name: "xmin", Description: "Scale (minimum value)", Min: Bound(0)},
+			{Name: "alpha", Description: "Shape", Min: Bound(0)},
+			{Name: "max", Description: "Maximum value", Optional: true},
+		},
+	})
+	MustRegisterGenerator("Normal", normalFactory, &ParamSchema{
+		Description: "Normal distribution",
+		Params: []*Param{
+			{Name: "mean", Description: "Mean"},
+			{Name: "stddev", Description: "Standard deviation", Min: Bound(0)},
+		},
+	})
+	MustRegisterGenerator("Fixed", fixedFactory, &ParamSchema{
+		Description: "Fixed value",
+		Params: []*Param{
+			{Name: "value", Description: "Value"},
+		},
+	})
+	MustRegisterGenerator("Bernoulli", bernoulliFactory, &ParamSchema{
+		Description: "Bernoulli distribution: 1 with probability prob, otherwise 0",
+		Params: []*Param{
+			{Name: "prob", Description: "Probability of 1", Min: Bound(0), Max: Bound(1)},
+		},
+	})
+	MustRegisterGenerator("Beta", betaFactory, &ParamSchema{
+		Description: "Beta distribution",
+		Params: []*Param{
+			{Name: "alpha", Description: "Shape α", Min: Bound(0)},
+			{Name: "beta", Description: "Shape β", Min: Bound(0)},
+		},
+	})
+	MustRegisterGenerator("Triangle", triangleFactory, &ParamSchema{
+		Description: "Triangular distribution. Triangle(n) is the fixed value n",
+		Params: []*Param{
+			{Name: "min", Description: "Minimum value"},
+			{Name: "mode", Description: "Most likely value"},
+			{Name: "max", Description: "Maximum value"},
+		},
+		Arities: []int{1, 3},
+	})
+	MustRegisterGenerator("Risk", riskFactory, &ParamSchema{
+		Description: "Risk event with probability p and the impact when it occurs",
+		Params: []*Param{
+			{Name: "p", Description: "Probability the event occurs", Min: Bound(0), Max: Bound(1)},
+			{Name: "impact", Description: "Impact generator", Kind: PARAM_KIND_GENERATOR},
+		},
+	})
 }
 
 // /////////////////////////////////////////////////////////////////////////////
@@ -64,25 +111,25 @@ type BaseGenerator struct {
 	cumulativeValues []float64
 	generatorStats   *stats.AggregatedStatistics
 	cumulativeStats  *stats.AggregatedStatistics
-}
-
-func (bg *BaseGenerator) parseFloat(strVal string, target *float64) error {
-
-	trimmedVal := strings.TrimSpace(strVal)
-	parseVal, parseValErr := strconv.ParseFloat(trimmedVal, 64)
-	if parseValErr != nil {
-		return parseValErr
-	}
-	*target = parseVal
-	return nil
+	rankScores       []float64
+	scale            []float64
+	// deferStats skips the statistics, which FinishChunks computes
+	deferStats bool
+	// rawSummary and cumulativeSummary replace the values of summarized
+	// chunks
+	rawSummary        *stats.Accumulator
+	cumulativeSummary *stats.Accumulator
+	// sampler draws the samples of variance reduced sampling strategies
+	sampler *Sampler
 }
 
 func (bg *BaseGenerator) GenerationResults() *GenerationResults {
 	return &GenerationResults{
-		RawValues:        &bg.rawValues,
-		CumulativeValues: &bg.cumulativeValues,
-		GeneratorStats:   bg.generatorStats,
-		CumulativeStats:  bg.cumulativeStats,
+		RawValues:         &bg.rawValues,
+		CumulativeValues:  &bg.cumulativeValues,
+		GeneratorStats:    bg.generatorStats,
+		CumulativeStats:   bg.cumulativeStats,
+		CumulativeSummary: bg.cumulativeSummary,
 	}
 }
 
@@ -91,13 +138,19 @@ func (bg *BaseGenerator) computeAggregates(generatorSamples []float64,
 	percentiles []float64,
 	_ *slog.Logger) (*GenerationResults, error) {
 
+	generatorSamples, correlationErr := bg.applyCorrelation(generatorSamples)
+	if correlationErr != nil {
+		return nil, correlationErr
+	}
 	bg.rawValues = generatorSamples
-	bg.generatorStats = stats.StatsForSequence(generatorSamples, percentiles)
 	bg.cumulativeValues = make([]float64, len(generatorSamples))
 	for i := 0; i != len(generatorSamples); i++ {
 		bg.cumulativeValues[i] = incomingCumulativeValues[i] + generatorSamples[i]
 	}
-	bg.cumulativeStats = stats.StatsForSequence(bg.cumulativeValues, percentiles)
+	if !bg.deferStats {
+		bg.generatorStats = stats.StatsForSequence(generatorSamples, percentiles)
+		bg.cumulativeStats = stats.StatsForSequence(bg.cumulativeValues, percentiles)
+	}
 	return bg.GenerationResults(), nil
 }
 func (bg *BaseGenerator) FilterGenerate(rander distuv.Rander,
@@ -114,14 +167,11 @@ func (bg *BaseGenerator) FilterGenerate(rander distuv.Rander,
 	for _, val := range priorSamples {
 		genResults = val
 	}
-	generatedSamples := make([]float64, len(*genResults.RawValues))
-	var genValue float64
-	for i := range generatedSamples {
-		genValue = rander.Rand()
-		if filter != nil {
-			genValue = filter(genValue)
+	generatedSamples := bg.draw(rander, len(*genResults.RawValues))
+	if filter != nil {
+		for i, eachSample := range generatedSamples {
+			generatedSamples[i] = filter(eachSample)
 		}
-		generatedSamples[i] = genValue
 	}
 	return bg.computeAggregates(generatedSamples, *genResults.CumulativeValues, percentiles, log)
 }
@@ -134,9 +184,52 @@ func (bg *BaseGenerator) Generate(rander distuv.Rander,
 	return bg.FilterGenerate(rander, nil, priorSamples, percentiles, log)
 
 }
-func NewDurationGenerator(dictActivityParams map[string]interface{}, log *slog.Logger) (DurationGenerator, error) {
-	generatorType := json.String("type", dictActivityParams)
 
+// splitExpression splits a GENERATOR(a, b, ...) expression into the generator
+// name and its top level parameters. Nested expressions (ex: `impact=PERT(1,2,3)`)
+// are returned as a single parameter.
+func splitExpression(expression string) (string, []string, error) {
+	trimmedExpr := strings.TrimSpace(expression)
+	openIndex := strings.Index(trimmedExpr, "(")
+	if openIndex <= 0 || !strings.HasSuffix(trimmedExpr, ")") {
+		return "", nil, fmt.Errorf("invalid generator expression: %s", expression)
+	}
+	exprName := strings.TrimSpace(trimmedExpr[:openIndex])
+	paramBody := trimmedExpr[openIndex+1 : len(trimmedExpr)-1]
+
+	params := make([]string, 0)
+	depth := 0
+	paramStart := 0
+	for i, eachRune := range paramBody {
+		switch eachRune {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return "", nil, fmt.Errorf("unbalanced parentheses in generator expression: %s", expression)
+			}
+		case ',':
+			if depth == 0 {
+				params = append(params, strings.TrimSpace(paramBody[paramStart:i]))
+				paramStart = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return "", nil, fmt.Errorf("unbalanced parentheses in generator expression: %s", expression)
+	}
+	lastParam := strings.TrimSpace(paramBody[paramStart:])
+	if len(lastParam) != 0 || len(params) != 0 {
+		params = append(params, lastParam)
+	}
+	return exprName, params, nil
+}
+
+// NewDurationGeneratorFromExpression returns the DurationGenerator for a
+// GENERATOR(...) expression string. The parameters are validated against the
+// registered schema before the generator is created.
+func NewDurationGeneratorFromExpression(generatorType string, log *slog.Logger) (DurationGenerator, error) {
 	// All generators satisfy:
 	// GENERATOR(...)
 	reSplit := regexp.MustCompile(`[\(\)]`)
@@ -145,14 +238,9 @@ func NewDurationGenerator(dictActivityParams map[string]interface{}, log *slog.L
 
 	// The generator type is always the distribution name before the
 	// opening parens
-	unmarshalFunc, unmarshalFuncExists := unmarshalMap[generatorBasename]
-	if !unmarshalFuncExists {
-		// Get all the keys
-		unmarshalTypes := []string{}
-		for eachKey := range unmarshalMap {
-			unmarshalTypes = append(unmarshalTypes, eachKey)
-		}
-		return nil, fmt.Errorf("unsupported generator function name: %s. Supported types: %v", generatorBasename, unmarshalTypes)
+	entry, entryExists := lookupGenerator(generatorBasename)
+	if !entryExists {
+		return nil, fmt.Errorf("unsupported generator function name: %s. Supported types: %v", generatorBasename, RegisteredGenerators())
 	}
-	return unmarshalFunc(generatorType, log)
+	return entry.newGenerator(generatorBasename, generatorType, log)
 }
@@ -0,0 +1,222 @@
+package generator
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// JoinGenerator is the interface satisfied by generators that close a
+// subgraph by combining the cumulative values of all their predecessors.
+type JoinGenerator interface {
+	DurationGenerator
+	// AttachIncrement adds a generator whose values are added to each run's
+	// joined value
+	AttachIncrement(gen DurationGenerator)
+	// IncrementGenerator returns the generator attached by AttachIncrement, if any
+	IncrementGenerator() DurationGenerator
+	// SetRepeat repeats the joined subgraph according to the repeat count
+	SetRepeat(repeat *RepeatCount)
+	// RepeatCount returns the repeat count set by SetRepeat, if any
+	RepeatCount() *RepeatCount
+	// MeanIterations returns the mean number of sampled iterations
+	MeanIterations() float64
+	// CriticalPredecessors returns the IDs of the predecessors that determine
+	// the joined value, given the mean cumulative value of each predecessor
+	CriticalPredecessors(predecessorMeans map[int64]float64) []int64
+	// Rejoin recombines alternative predecessor values (ex: resource
+	// constrained finish times) using the random values drawn by the most
+	// recent call to Generate
+	Rejoin(priorValues map[int64][]float64, entry []float64) ([]float64, error)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// JoinBase
+//
+// Shared implementation for JoinGenerators. Join generators produce the
+// per-run joined value as the cumulative value, and any attached increment
+// as the generator value.
+//
+// Join generators receive each predecessor's cumulative values as the
+// RawValues and the cumulative values at the subgraph's entry as the
+// CumulativeValues.
+//
+// /////////////////////////////////////////////////////////////////////////////
+type JoinBase struct {
+	BaseGenerator
+	// Optional generator whose values are added to each run's joined value.
+	// Used for risk events that are attached to a subgraph.
+	Increment DurationGenerator
+	// Optional repeat count for the subgraph. Additional iterations are
+	// resampled from the distribution of the subgraph's body duration.
+	Repeat       *RepeatCount
+	repeatCounts []int
+	// iterations tallies the repeat counts of summarized chunks
+	iterations iterationTally
+}
+
+func (jb *JoinBase) AttachIncrement(gen DurationGenerator) {
+	if jb.Increment == nil {
+		jb.Increment = gen
+		return
+	}
+	sumGenerator, sumGeneratorOk := jb.Increment.(*SumGenerator)
+	if !sumGeneratorOk {
+		sumGenerator = &SumGenerator{
+			Generators: []DurationGenerator{jb.Increment},
+		}
+		jb.Increment = sumGenerator
+	}
+	sumGenerator.Generators = append(sumGenerator.Generators, gen)
+}
+
+func (jb *JoinBase) IncrementGenerator() DurationGenerator {
+	return jb.Increment
+}
+
+func (jb *JoinBase) SetRepeat(repeat *RepeatCount) {
+	jb.Repeat = repeat
+}
+
+func (jb *JoinBase) RepeatCount() *RepeatCount {
+	return jb.Repeat
+}
+
+// Iterations returns the sampled iteration count of each run, or nil if
+// the subgraph isn't repeated
+func (jb *JoinBase) Iterations() []int {
+	if jb.Repeat == nil {
+		return nil
+	}
+	return jb.repeatCounts
+}
+
+func (jb *JoinBase) MeanIterations() float64 {
+	if jb.iterations.runs != 0 {
+		return jb.iterations.mean()
+	}
+	return meanCount(jb.repeatCounts)
+}
+
+// repeatValues returns the per-run adjustment for a repeated subgraph. The
+// body duration of each run is the joined value less the entry value. Each
+// additional iteration is an independent draw from the empirical distribution
+// of body durations across all runs. Runs that sample zero iterations
+// skip the body entirely.
+func (jb *JoinBase) repeatValues(joinValues []float64,
+	entryValues []float64,
+	src rand.Source) ([]float64, error) {
+
+	if len(entryValues) != len(joinValues) {
+		return nil, fmt.Errorf("invalid entry values for repeated join. Expected: %d, Found: %d", len(joinValues), len(entryValues))
+	}
+	runCount := len(joinValues)
+	bodyValues := make([]float64, runCount)
+	for i := range bodyValues {
+		bodyValues[i] = joinValues[i] - entryValues[i]
+	}
+	jb.repeatCounts = jb.Repeat.Sample(runCount, src)
+	resampler := distuv.Uniform{
+		Min: 0,
+		Max: float64(runCount),
+		Src: src,
+	}
+	adjustments := make([]float64, runCount)
+	for i, eachCount := range jb.repeatCounts {
+		if eachCount <= 0 {
+			adjustments[i] = -bodyValues[i]
+			continue
+		}
+		for j := 1; j < eachCount; j++ {
+			resampleIndex := min(int(resampler.Rand()), runCount-1)
+			adjustments[i] += bodyValues[resampleIndex]
+		}
+	}
+	return adjustments, nil
+}
+
+func (jb *JoinBase) computeJoinAggregates(joinValues []float64,
+	entryValues []float64,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) (*GenerationResults, error) {
+
+	generatorValues := make([]float64, len(joinValues))
+	if jb.Repeat != nil {
+		adjustments, adjustmentsErr := jb.repeatValues(joinValues, entryValues, src)
+		if adjustmentsErr != nil {
+			return nil, adjustmentsErr
+		}
+		copy(generatorValues, adjustments)
+	}
+	if jb.Increment != nil {
+		incrementSamples := map[int64]*GenerationResults{
+			0: {
+				RawValues:        &joinValues,
+				CumulativeValues: &joinValues,
+			},
+		}
+		incrementResults, incrementResultsErr := jb.Increment.Generate(incrementSamples, percentiles, src, log)
+		if incrementResultsErr != nil {
+			return nil, incrementResultsErr
+		}
+		for i, eachValue := range *incrementResults.RawValues {
+			generatorValues[i] += eachValue
+		}
+	}
+	return jb.computeAggregates(generatorValues, joinValues, percentiles, log)
+}
+
+// rejoin combines the alternative predecessor values and adds the
+// generator values (increments and repeats) from the last Generate call
+func (jb *JoinBase) rejoin(combine func(priorValues [][]float64, entry []float64) []float64,
+	priorValues map[int64][]float64,
+	entry []float64) ([]float64, error) {
+
+	if len(priorValues) <= 0 {
+		return nil, fmt.Errorf("no slices provided to rejoin")
+	}
+	priorKeys := make([]int64, 0, len(priorValues))
+	for eachKey := range priorValues {
+		priorKeys = append(priorKeys, eachKey)
+	}
+	slices.Sort(priorKeys)
+	orderedValues := make([][]float64, len(priorKeys))
+	for i, eachKey := range priorKeys {
+		orderedValues[i] = priorValues[eachKey]
+	}
+	joinedValues := combine(orderedValues, entry)
+	if len(jb.rawValues) != len(joinedValues) {
+		return nil, fmt.Errorf("invalid rejoin length. Expected: %d, Found: %d", len(jb.rawValues), len(joinedValues))
+	}
+	rejoinedValues := make([]float64, len(joinedValues))
+	for i := range rejoinedValues {
+		rejoinedValues[i] = joinedValues[i] + jb.rawValues[i]
+	}
+	return rejoinedValues, nil
+}
+
+// entryValues returns the subgraph entry cumulative values shared by all
+// of the join's predecessors
+func entryValues(priorSamples map[int64]*GenerationResults) []float64 {
+	for _, eachKey := range sortedPriorKeys(priorSamples) {
+		if priorSamples[eachKey].CumulativeValues != nil {
+			return *priorSamples[eachKey].CumulativeValues
+		}
+	}
+	return nil
+}
+
+// sortedPriorKeys returns the prior sample keys in ascending order so that
+// joins consume random values in a stable order
+func sortedPriorKeys(priorSamples map[int64]*GenerationResults) []int64 {
+	priorSampleKeys := make([]int64, 0, len(priorSamples))
+	for eachKey := range priorSamples {
+		priorSampleKeys = append(priorSampleKeys, eachKey)
+	}
+	slices.Sort(priorSampleKeys)
+	return priorSampleKeys
+}
@@ -0,0 +1,245 @@
+package generator
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/rand"
+)
+
+// NewJoinGenerator returns the JoinGenerator for a join expression. Supported
+// forms:
+//
+//	max     - the subgraph completes when every predecessor completes
+//	min     - the subgraph completes when the first predecessor completes
+//	kofn(k) - the subgraph completes when k predecessors complete
+//	sum     - the predecessors are performed one after another, in any order
+func NewJoinGenerator(joinExpression string) (JoinGenerator, error) {
+	normalizedExpr := strings.ToLower(strings.TrimSpace(joinExpression))
+	switch normalizedExpr {
+	case "", "max":
+		return &UpperBoundGenerator{}, nil
+	case "min":
+		return &LowerBoundGenerator{}, nil
+	case "sum":
+		return &SumJoinGenerator{}, nil
+	}
+	reKofN := regexp.MustCompile(`^kofn\(\s*(\d+)\s*\)$`)
+	matches := reKofN.FindStringSubmatch(normalizedExpr)
+	if matches != nil {
+		k, kErr := strconv.Atoi(matches[1])
+		if kErr != nil {
+			return nil, kErr
+		}
+		if k <= 0 {
+			return nil, fmt.Errorf("invalid kofn join: %s. k must be positive", joinExpression)
+		}
+		return &OrderStatisticGenerator{K: k}, nil
+	}
+	return nil, fmt.Errorf("unsupported join: %s. Supported joins: [max min sum kofn(k)]", joinExpression)
+}
+
+// predecessorValues returns the predecessor raw values in sorted key order
+func predecessorValues(priorSamples map[int64]*GenerationResults) ([][]float64, error) {
+	if len(priorSamples) <= 0 {
+		return nil, fmt.Errorf("no slices provided to join")
+	}
+	priorValues := make([][]float64, 0, len(priorSamples))
+	for _, eachKey := range sortedPriorKeys(priorSamples) {
+		priorValues = append(priorValues, *priorSamples[eachKey].RawValues)
+	}
+	return priorValues, nil
+}
+
+// extremePredecessor returns the ID of the predecessor with the largest
+// (or smallest) mean
+func extremePredecessor(predecessorMeans map[int64]float64, largest bool) []int64 {
+	var extremeID int64
+	extremeMean := math.Inf(1)
+	if largest {
+		extremeMean = math.Inf(-1)
+	}
+	for _, eachID := range sortedMeanKeys(predecessorMeans) {
+		eachMean := predecessorMeans[eachID]
+		if (largest && eachMean > extremeMean) || (!largest && eachMean < extremeMean) {
+			extremeMean = eachMean
+			extremeID = eachID
+		}
+	}
+	return []int64{extremeID}
+}
+
+func sortedMeanKeys(predecessorMeans map[int64]float64) []int64 {
+	meanKeys := make([]int64, 0, len(predecessorMeans))
+	for eachKey := range predecessorMeans {
+		meanKeys = append(meanKeys, eachKey)
+	}
+	slices.Sort(meanKeys)
+	return meanKeys
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// LowerBoundGenerator
+//
+// First-to-finish join. Takes the pairwise min of all the predecessors.
+// /////////////////////////////////////////////////////////////////////////////
+type LowerBoundGenerator struct {
+	JoinBase
+}
+
+func (lbg *LowerBoundGenerator) Name() string {
+	return "LowerBound"
+}
+
+func (lbg *LowerBoundGenerator) CriticalPredecessors(predecessorMeans map[int64]float64) []int64 {
+	return extremePredecessor(predecessorMeans, false)
+}
+
+func (lbg *LowerBoundGenerator) combine(priorValues [][]float64, _ []float64) []float64 {
+	minValues := make([]float64, len(priorValues[0]))
+	for sampleIdx := range minValues {
+		curMin := math.MaxFloat64
+		for _, eachSeries := range priorValues {
+			curMin = math.Min(curMin, eachSeries[sampleIdx])
+		}
+		minValues[sampleIdx] = curMin
+	}
+	return minValues
+}
+
+func (lbg *LowerBoundGenerator) Rejoin(priorValues map[int64][]float64, entry []float64) ([]float64, error) {
+	return lbg.rejoin(lbg.combine, priorValues, entry)
+}
+
+func (lbg *LowerBoundGenerator) Generate(priorSamples map[int64]*GenerationResults,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) (*GenerationResults, error) {
+
+	priorValues, priorValuesErr := predecessorValues(priorSamples)
+	if priorValuesErr != nil {
+		return nil, priorValuesErr
+	}
+	entry := entryValues(priorSamples)
+	return lbg.computeJoinAggregates(lbg.combine(priorValues, entry), entry, percentiles, src, log)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// OrderStatisticGenerator
+//
+// k-of-n join. Takes the k-th smallest value of all the predecessors.
+// /////////////////////////////////////////////////////////////////////////////
+type OrderStatisticGenerator struct {
+	JoinBase
+	K int
+}
+
+func (osg *OrderStatisticGenerator) Name() string {
+	return fmt.Sprintf("OrderStatistic(k=%d)", osg.K)
+}
+
+func (osg *OrderStatisticGenerator) CriticalPredecessors(predecessorMeans map[int64]float64) []int64 {
+	meanKeys := sortedMeanKeys(predecessorMeans)
+	slices.SortStableFunc(meanKeys, func(a, b int64) int {
+		if predecessorMeans[a] < predecessorMeans[b] {
+			return -1
+		} else if predecessorMeans[a] > predecessorMeans[b] {
+			return 1
+		}
+		return 0
+	})
+	if osg.K > len(meanKeys) {
+		return meanKeys
+	}
+	return []int64{meanKeys[osg.K-1]}
+}
+
+func (osg *OrderStatisticGenerator) combine(priorValues [][]float64, _ []float64) []float64 {
+	kthValues := make([]float64, len(priorValues[0]))
+	runValues := make([]float64, len(priorValues))
+	for sampleIdx := range kthValues {
+		for seriesIdx, eachSeries := range priorValues {
+			runValues[seriesIdx] = eachSeries[sampleIdx]
+		}
+		slices.Sort(runValues)
+		kthValues[sampleIdx] = runValues[osg.K-1]
+	}
+	return kthValues
+}
+
+func (osg *OrderStatisticGenerator) Rejoin(priorValues map[int64][]float64, entry []float64) ([]float64, error) {
+	if osg.K > len(priorValues) {
+		return nil, fmt.Errorf("invalid kofn join: k=%d exceeds the number of predecessors: %d", osg.K, len(priorValues))
+	}
+	return osg.rejoin(osg.combine, priorValues, entry)
+}
+
+func (osg *OrderStatisticGenerator) Generate(priorSamples map[int64]*GenerationResults,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) (*GenerationResults, error) {
+
+	priorValues, priorValuesErr := predecessorValues(priorSamples)
+	if priorValuesErr != nil {
+		return nil, priorValuesErr
+	}
+	if osg.K > len(priorValues) {
+		return nil, fmt.Errorf("invalid kofn join: k=%d exceeds the number of predecessors: %d", osg.K, len(priorValues))
+	}
+	entry := entryValues(priorSamples)
+	return osg.computeJoinAggregates(osg.combine(priorValues, entry), entry, percentiles, src, log)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// SumJoinGenerator
+//
+// The predecessors are performed one after another in any order. The joined
+// value is the entry value plus the sum of each predecessor's duration.
+// /////////////////////////////////////////////////////////////////////////////
+type SumJoinGenerator struct {
+	JoinBase
+}
+
+func (sjg *SumJoinGenerator) Name() string {
+	return "Sum"
+}
+
+func (sjg *SumJoinGenerator) CriticalPredecessors(predecessorMeans map[int64]float64) []int64 {
+	return sortedMeanKeys(predecessorMeans)
+}
+
+func (sjg *SumJoinGenerator) combine(priorValues [][]float64, entry []float64) []float64 {
+	sumValues := make([]float64, len(priorValues[0]))
+	for sampleIdx := range sumValues {
+		sumValues[sampleIdx] = entry[sampleIdx]
+		for _, eachSeries := range priorValues {
+			sumValues[sampleIdx] += eachSeries[sampleIdx] - entry[sampleIdx]
+		}
+	}
+	return sumValues
+}
+
+func (sjg *SumJoinGenerator) Rejoin(priorValues map[int64][]float64, entry []float64) ([]float64, error) {
+	return sjg.rejoin(sjg.combine, priorValues, entry)
+}
+
+func (sjg *SumJoinGenerator) Generate(priorSamples map[int64]*GenerationResults,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) (*GenerationResults, error) {
+
+	priorValues, priorValuesErr := predecessorValues(priorSamples)
+	if priorValuesErr != nil {
+		return nil, priorValuesErr
+	}
+	entry := entryValues(priorSamples)
+	if len(entry) != len(priorValues[0]) {
+		return nil, fmt.Errorf("invalid entry values for sum join. Expected: %d, Found: %d", len(priorValues[0]), len(entry))
+	}
+	return sjg.computeJoinAggregates(sjg.combine(priorValues, entry), entry, percentiles, src, log)
+}
@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Lint
+//
+// Generators can report parameters that are valid but likely mistakes, such
+// as a Pareto distribution without a finite mean.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// Lint rule IDs
+const (
+	// RULE_PARETO_INFINITE_MEAN is a Pareto distribution with alpha <= 1
+	RULE_PARETO_INFINITE_MEAN = "pareto-infinite-mean"
+	// RULE_DEGENERATE_PERT is a PERT distribution with min == max
+	RULE_DEGENERATE_PERT = "degenerate-pert"
+	// RULE_NORMAL_NEGATIVE_MASS is a Normal distribution that samples
+	// negative durations more often than NORMAL_NEGATIVE_THRESHOLD
+	RULE_NORMAL_NEGATIVE_MASS = "normal-negative-mass"
+)
+
+// NORMAL_NEGATIVE_THRESHOLD is the probability of a negative sample above
+// which a Normal generator is reported
+var NORMAL_NEGATIVE_THRESHOLD = 0.01
+
+// Finding is a questionable generator parameter. RuleID is a stable
+// identifier that plans can use to suppress the finding.
+type Finding struct {
+	RuleID  string
+	Message string
+}
+
+// Linter is implemented by generators that can detect questionable
+// parameters
+type Linter interface {
+	Lint() []*Finding
+}
+
+func (pg *ParetoGenerator) Lint() []*Finding {
+	if pg.alpha <= 1 {
+		return []*Finding{{
+			RuleID:  RULE_PARETO_INFINITE_MEAN,
+			Message: fmt.Sprintf("Pareto alpha=%.2f has an infinite mean. Use alpha > 1 or provide a max value", pg.alpha),
+		}}
+	}
+	return nil
+}
+
+func (pg *PERTGenerator) Lint() []*Finding {
+	if pg.min == pg.max {
+		return []*Finding{{
+			RuleID:  RULE_DEGENERATE_PERT,
+			Message: fmt.Sprintf("degenerate PERT distribution: min == max (%.2f). Use Fixed(%.2f) instead", pg.min, pg.min),
+		}}
+	}
+	return nil
+}
+
+func (ng *NormalGenerator) Lint() []*Finding {
+	if ng.stddev <= 0 {
+		return nil
+	}
+	normalDist := distuv.Normal{
+		Mu:    ng.mean,
+		Sigma: ng.stddev,
+	}
+	negativeProbability := normalDist.CDF(0)
+	if negativeProbability > NORMAL_NEGATIVE_THRESHOLD {
+		return []*Finding{{
+			RuleID:  RULE_NORMAL_NEGATIVE_MASS,
+			Message: fmt.Sprintf("Normal(%.2f, %.2f) samples negative durations with probability %.2f%%", ng.mean, ng.stddev, negativeProbability*100),
+		}}
+	}
+	return nil
+}
+
+// LintGenerator returns the findings for the generator and any generators
+// it's composed of
+func LintGenerator(durGenerator DurationGenerator) []*Finding {
+	findings := make([]*Finding, 0)
+	linter, linterOk := durGenerator.(Linter)
+	if linterOk {
+		findings = append(findings, linter.Lint()...)
+	}
+	switch typedGenerator := durGenerator.(type) {
+	case *RiskGenerator:
+		findings = append(findings, LintGenerator(typedGenerator.impact)...)
+	case *RepeatGenerator:
+		findings = append(findings, LintGenerator(typedGenerator.Body)...)
+	case *EffortGenerator:
+		findings = append(findings, LintGenerator(typedGenerator.Effort)...)
+	case *SumGenerator:
+		for _, eachGenerator := range typedGenerator.Generators {
+			findings = append(findings, LintGenerator(eachGenerator)...)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,332 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/mweagle/goestimate/stats"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Merge
+//
+// Runs can be evaluated in chunks, each by its own copy of the generators.
+// The chunk samples, and any other per run state, are appended in chunk
+// order to the generators of the evaluated graph, then FinishChunks computes
+// the statistics of all the runs.
+//
+// Streaming evaluations don't retain the samples. SummarizeChunk replaces
+// the chunk samples with mergeable summaries, which MergeChunk merges in
+// chunk order. The statistics are exact moments and quantile sketch
+// estimates.
+//
+// Generators that embed BaseGenerator support chunks. Generators with per
+// run state of their own, or that are composed of other generators,
+// implement runStateMerger and composedGenerator.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// chunkedGenerator is satisfied by every generator that embeds BaseGenerator
+type chunkedGenerator interface {
+	base() *BaseGenerator
+}
+
+// runStateMerger is implemented by generators with per run state in
+// addition to their samples
+type runStateMerger interface {
+	appendRunState(chunk DurationGenerator)
+	summarizeRunState()
+	mergeRunState(chunk DurationGenerator)
+	finishRunState()
+}
+
+// composedGenerator is implemented by generators that are composed of other
+// generators
+type composedGenerator interface {
+	nestedGenerators() []DurationGenerator
+}
+
+func (bg *BaseGenerator) base() *BaseGenerator {
+	return bg
+}
+
+// chunkBases returns the BaseGenerators of target and its chunk
+func chunkBases(target DurationGenerator, chunk DurationGenerator) (*BaseGenerator, *BaseGenerator, error) {
+	targetChunked, targetChunkedOk := target.(chunkedGenerator)
+	chunkChunked, chunkChunkedOk := chunk.(chunkedGenerator)
+	if !targetChunkedOk || !chunkChunkedOk {
+		return nil, nil, fmt.Errorf("generator %s does not support chunked evaluation. Generators must embed BaseGenerator", target.Name())
+	}
+	if fmt.Sprintf("%T", target) != fmt.Sprintf("%T", chunk) {
+		return nil, nil, fmt.Errorf("invalid chunk generator type: %T. Expected: %T", chunk, target)
+	}
+	return targetChunked.base(), chunkChunked.base(), nil
+}
+
+// mergeNestedChunks applies merge to each of the nested generators of
+// target and its chunk
+func mergeNestedChunks(target DurationGenerator,
+	chunk DurationGenerator,
+	merge func(DurationGenerator, DurationGenerator) error) error {
+	targetNested := nestedGenerators(target)
+	chunkNested := nestedGenerators(chunk)
+	if len(targetNested) != len(chunkNested) {
+		return fmt.Errorf("invalid chunk for generator %s. Expected %d nested generators, Found: %d",
+			target.Name(),
+			len(targetNested),
+			len(chunkNested))
+	}
+	for i := range targetNested {
+		mergeErr := merge(targetNested[i], chunkNested[i])
+		if mergeErr != nil {
+			return mergeErr
+		}
+	}
+	return nil
+}
+
+// AppendChunk appends the runs evaluated by chunk, a copy of target
+// created from the same expression or plan, to target
+func AppendChunk(target DurationGenerator, chunk DurationGenerator) error {
+	targetBase, chunkBase, basesErr := chunkBases(target, chunk)
+	if basesErr != nil {
+		return basesErr
+	}
+	targetBase.rawValues = append(targetBase.rawValues, chunkBase.rawValues...)
+	targetBase.cumulativeValues = append(targetBase.cumulativeValues, chunkBase.cumulativeValues...)
+
+	merger, mergerOk := target.(runStateMerger)
+	if mergerOk {
+		merger.appendRunState(chunk)
+	}
+	return mergeNestedChunks(target, chunk, AppendChunk)
+}
+
+// SummarizeChunk replaces the samples of the chunk generator, and its
+// nested generators, with mergeable summaries
+func SummarizeChunk(chunk DurationGenerator) {
+	chunkChunked, chunkChunkedOk := chunk.(chunkedGenerator)
+	if chunkChunkedOk {
+		chunkBase := chunkChunked.base()
+		chunkBase.rawSummary = stats.NewAccumulator()
+		chunkBase.rawSummary.Add(chunkBase.rawValues...)
+		chunkBase.cumulativeSummary = stats.NewAccumulator()
+		chunkBase.cumulativeSummary.Add(chunkBase.cumulativeValues...)
+		chunkBase.rawValues = nil
+		chunkBase.cumulativeValues = nil
+		chunkBase.rankScores = nil
+		chunkBase.scale = nil
+	}
+	merger, mergerOk := chunk.(runStateMerger)
+	if mergerOk {
+		merger.summarizeRunState()
+	}
+	for _, eachNested := range nestedGenerators(chunk) {
+		SummarizeChunk(eachNested)
+	}
+}
+
+// MergeChunk merges the summaries of a chunk, a copy of target created
+// from the same expression or plan that was summarized by SummarizeChunk,
+// into target
+func MergeChunk(target DurationGenerator, chunk DurationGenerator) error {
+	targetBase, chunkBase, basesErr := chunkBases(target, chunk)
+	if basesErr != nil {
+		return basesErr
+	}
+	if chunkBase.rawSummary == nil || chunkBase.cumulativeSummary == nil {
+		return fmt.Errorf("invalid chunk for generator %s. Chunks must be summarized before they're merged", target.Name())
+	}
+	if targetBase.rawSummary == nil {
+		targetBase.rawSummary = stats.NewAccumulator()
+		targetBase.cumulativeSummary = stats.NewAccumulator()
+	}
+	targetBase.rawSummary.Merge(chunkBase.rawSummary)
+	targetBase.cumulativeSummary.Merge(chunkBase.cumulativeSummary)
+
+	merger, mergerOk := target.(runStateMerger)
+	if mergerOk {
+		merger.mergeRunState(chunk)
+	}
+	return mergeNestedChunks(target, chunk, MergeChunk)
+}
+
+// DeferStatistics skips the statistics of the generator, and its nested
+// generators, when the chunk is generated. Only the samples of a chunk are
+// appended, so its statistics would be discarded.
+func DeferStatistics(gen DurationGenerator) {
+	genChunked, genChunkedOk := gen.(chunkedGenerator)
+	if genChunkedOk {
+		genChunked.base().deferStats = true
+	}
+	for _, eachNested := range nestedGenerators(gen) {
+		DeferStatistics(eachNested)
+	}
+}
+
+// FinishChunks computes the statistics of the runs appended, or merged, to
+// the generator and its nested generators, with the confidence intervals of
+// the method if it isn't empty
+func FinishChunks(target DurationGenerator, percentiles []float64, intervals string) {
+	targetChunked, targetChunkedOk := target.(chunkedGenerator)
+	if !targetChunkedOk {
+		return
+	}
+	targetBase := targetChunked.base()
+	if targetBase.rawSummary != nil {
+		targetBase.generatorStats = targetBase.rawSummary.StatisticsWithIntervals(percentiles, intervals)
+		targetBase.cumulativeStats = targetBase.cumulativeSummary.StatisticsWithIntervals(percentiles, intervals)
+	} else if len(targetBase.rawValues) != 0 {
+		// Generators that never ran, such as the body of a task repeated
+		// zero times, have no statistics
+		targetBase.generatorStats = stats.StatsWithIntervals(targetBase.rawValues, percentiles, intervals)
+		targetBase.cumulativeStats = stats.StatsWithIntervals(targetBase.cumulativeValues, percentiles, intervals)
+	}
+	merger, mergerOk := target.(runStateMerger)
+	if mergerOk {
+		merger.finishRunState()
+	}
+	for _, eachNested := range nestedGenerators(target) {
+		FinishChunks(eachNested, percentiles, intervals)
+	}
+}
+
+func nestedGenerators(gen DurationGenerator) []DurationGenerator {
+	composed, composedOk := gen.(composedGenerator)
+	if !composedOk {
+		return nil
+	}
+	return composed.nestedGenerators()
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// Per generator run state
+// /////////////////////////////////////////////////////////////////////////////
+
+func (jb *JoinBase) joinBase() *JoinBase {
+	return jb
+}
+
+func (jb *JoinBase) appendRunState(chunk DurationGenerator) {
+	chunkJoin, chunkJoinOk := chunk.(interface{ joinBase() *JoinBase })
+	if chunkJoinOk {
+		jb.repeatCounts = append(jb.repeatCounts, chunkJoin.joinBase().repeatCounts...)
+	}
+}
+
+func (jb *JoinBase) summarizeRunState() {
+	jb.iterations.add(jb.repeatCounts)
+	jb.repeatCounts = nil
+}
+
+func (jb *JoinBase) mergeRunState(chunk DurationGenerator) {
+	chunkJoin, chunkJoinOk := chunk.(interface{ joinBase() *JoinBase })
+	if chunkJoinOk {
+		jb.iterations.merge(&chunkJoin.joinBase().iterations)
+	}
+}
+
+func (jb *JoinBase) finishRunState() {
+}
+
+func (jb *JoinBase) nestedGenerators() []DurationGenerator {
+	if jb.Increment == nil {
+		return nil
+	}
+	return []DurationGenerator{jb.Increment}
+}
+
+// Choice frequencies are accumulated as counts until the runs are finished
+func (cg *ChoiceGenerator) addFrequencyCounts(chunkFrequencies map[int64]float64) {
+	if cg.frequencies == nil {
+		cg.frequencies = make(map[int64]float64, len(chunkFrequencies))
+	}
+	for eachKey, eachCount := range chunkFrequencies {
+		cg.frequencies[eachKey] += eachCount
+	}
+}
+
+func (cg *ChoiceGenerator) appendRunState(chunk DurationGenerator) {
+	cg.JoinBase.appendRunState(chunk)
+	chunkChoice := chunk.(*ChoiceGenerator)
+	chunkCounts := make(map[int64]float64, len(chunkChoice.frequencies))
+	for eachKey := range chunkChoice.frequencies {
+		chunkCounts[eachKey] = 0
+	}
+	for _, eachKey := range chunkChoice.chosenKeys {
+		chunkCounts[eachKey]++
+	}
+	cg.addFrequencyCounts(chunkCounts)
+	cg.chosenKeys = append(cg.chosenKeys, chunkChoice.chosenKeys...)
+}
+
+func (cg *ChoiceGenerator) summarizeRunState() {
+	cg.JoinBase.summarizeRunState()
+	for eachKey := range cg.frequencies {
+		cg.frequencies[eachKey] = 0
+	}
+	for _, eachKey := range cg.chosenKeys {
+		cg.frequencies[eachKey]++
+	}
+	cg.chosenKeys = nil
+}
+
+func (cg *ChoiceGenerator) mergeRunState(chunk DurationGenerator) {
+	cg.JoinBase.mergeRunState(chunk)
+	cg.addFrequencyCounts(chunk.(*ChoiceGenerator).frequencies)
+}
+
+func (cg *ChoiceGenerator) finishRunState() {
+	totalCount := float64(0)
+	for _, eachCount := range cg.frequencies {
+		totalCount += eachCount
+	}
+	for eachKey, eachCount := range cg.frequencies {
+		cg.frequencies[eachKey] = eachCount / totalCount
+	}
+}
+
+func (rg *RiskGenerator) appendRunState(chunk DurationGenerator) {
+	rg.triggered = append(rg.triggered, chunk.(*RiskGenerator).triggered...)
+}
+
+func (rg *RiskGenerator) summarizeRunState() {
+	rg.triggered = nil
+}
+
+func (rg *RiskGenerator) mergeRunState(_ DurationGenerator) {
+}
+
+func (rg *RiskGenerator) finishRunState() {
+}
+
+func (rg *RiskGenerator) nestedGenerators() []DurationGenerator {
+	return []DurationGenerator{rg.impact}
+}
+
+func (rg *RepeatGenerator) appendRunState(chunk DurationGenerator) {
+	rg.counts = append(rg.counts, chunk.(*RepeatGenerator).counts...)
+}
+
+func (rg *RepeatGenerator) summarizeRunState() {
+	rg.iterations.add(rg.counts)
+	rg.counts = nil
+}
+
+func (rg *RepeatGenerator) mergeRunState(chunk DurationGenerator) {
+	rg.iterations.merge(&chunk.(*RepeatGenerator).iterations)
+}
+
+func (rg *RepeatGenerator) finishRunState() {
+}
+
+func (rg *RepeatGenerator) nestedGenerators() []DurationGenerator {
+	return []DurationGenerator{rg.Body}
+}
+
+func (sg *SumGenerator) nestedGenerators() []DurationGenerator {
+	return sg.Generators
+}
+
+func (eg *EffortGenerator) nestedGenerators() []DurationGenerator {
+	return []DurationGenerator{eg.Effort}
+}
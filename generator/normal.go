@@ -3,8 +3,6 @@ package generator
 import (
 	"fmt"
 	"log/slog"
-	"regexp"
-	"strings"
 
 	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/stat/distuv"
@@ -42,28 +40,15 @@ func (ng *NormalGenerator) Generate(priorSamples map[int64]*GenerationResults,
 	return ng.BaseGenerator.Generate(generator, priorSamples, percentiles, log)
 }
 
-func UnmarshalNormal(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
-	// Supported forms:
-	// Normal(mean, stddev)
-	ng := &NormalGenerator{}
-	reParams := regexp.MustCompile(`[()]`)
-	normalParts := reParams.Split(typeParameter, -1)
-	if len(normalParts) < 2 {
-		return nil, fmt.Errorf("invalid Normal generator expression: %s", typeParameter)
-	}
-	normalFloatParts := strings.Split(normalParts[1], ",")
+// normalFactory returns the Normal generator for the arguments
+func normalFactory(args *Arguments, _ *slog.Logger) (DurationGenerator, error) {
+	return &NormalGenerator{
+		mean:   args.Float("mean"),
+		stddev: args.Float("stddev"),
+	}, nil
+}
 
-	if len(normalFloatParts) == 2 {
-		err := ng.BaseGenerator.parseFloat(strings.TrimSpace(normalFloatParts[0]), &ng.mean)
-		if err != nil {
-			return nil, err
-		}
-		err = ng.BaseGenerator.parseFloat(strings.TrimSpace(normalFloatParts[1]), &ng.stddev)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		return nil, fmt.Errorf("invalid normal generator expression: %s", typeParameter)
-	}
-	return ng, nil
+// UnmarshalNormal returns the Normal generator for the Normal(...) expression
+func UnmarshalNormal(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
+	return unmarshalRegistered("Normal", typeParameter, log)
 }
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"log/slog"
 	"math"
-	"regexp"
-	"strings"
 
 	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/stat/distuv"
@@ -45,36 +43,21 @@ func (pg *ParetoGenerator) Generate(priorSamples map[int64]*GenerationResults,
 	return pg.BaseGenerator.FilterGenerate(generator, filterFunc, priorSamples, percentiles, log)
 }
 
-func UnmarshalPareto(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
-	// Supported forms:
-	// Pareto(Xmin, alphaShape)
-	pg := &ParetoGenerator{}
-	reParams := regexp.MustCompile(`[()]`)
-	paretoParts := reParams.Split(typeParameter, -1)
-	if len(paretoParts) < 2 {
-		return nil, fmt.Errorf("invalid Pareto generator expression: %s", typeParameter)
+// paretoFactory returns the Pareto generator for the arguments. Pareto
+// generators without a max value are uncapped.
+func paretoFactory(args *Arguments, _ *slog.Logger) (DurationGenerator, error) {
+	pg := &ParetoGenerator{
+		x:        args.Float("xmin"),
+		alpha:    args.Float("alpha"),
+		maxValue: math.MaxFloat64,
 	}
-	paretoFloatParts := strings.Split(paretoParts[1], ",")
-
-	if len(paretoFloatParts) >= 2 {
-		err := pg.BaseGenerator.parseFloat(strings.TrimSpace(paretoFloatParts[0]), &pg.x)
-		if err != nil {
-			return nil, err
-		}
-		err = pg.BaseGenerator.parseFloat(strings.TrimSpace(paretoFloatParts[1]), &pg.alpha)
-		if err != nil {
-			return nil, err
-		}
-		pg.maxValue = math.MaxFloat64
-		if len(paretoFloatParts) == 3 {
-			err = pg.BaseGenerator.parseFloat(strings.TrimSpace(paretoFloatParts[2]), &pg.maxValue)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-	} else {
-		return nil, fmt.Errorf("invalid pareto generator expression: %s", typeParameter)
+	if args.Has("max") {
+		pg.maxValue = args.Float("max")
 	}
 	return pg, nil
 }
+
+// UnmarshalPareto returns the Pareto generator for the Pareto(...) expression
+func UnmarshalPareto(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
+	return unmarshalRegistered("Pareto", typeParameter, log)
+}
@@ -3,8 +3,6 @@ package generator
 import (
 	"fmt"
 	"log/slog"
-	"regexp"
-	"strings"
 
 	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/stat/distuv"
@@ -24,9 +22,10 @@ type PERTGenerator struct {
 	max  float64
 }
 
+// Validate accepts min == max, which lint reports as a degenerate PERT
 func (pg *PERTGenerator) Validate() error {
 	var validationError error
-	if (pg.min >= pg.max) ||
+	if (pg.min > pg.max) ||
 		(pg.min > pg.mode) ||
 		(pg.mode > pg.max) {
 		validationError = fmt.Errorf("inavlid PERT distribution: (lower=%.2f, upper=%.2f, mode=%.2f). Distribution must satisfy: lower <= mode <= upper",
@@ -48,47 +47,33 @@ func (pg *PERTGenerator) Generate(priorSamples map[int64]*GenerationResults,
 	percentiles []float64,
 	src rand.Source,
 	log *slog.Logger) (*GenerationResults, error) {
+	// NewTriangle panics without min < max, so a degenerate PERT is its
+	// fixed value
+	if pg.min == pg.max {
+		return pg.BaseGenerator.Generate(distuv.Uniform{Min: pg.min, Max: pg.max, Src: src}, priorSamples, percentiles, log)
+	}
 	generator := distuv.NewTriangle(pg.min, pg.max, pg.mode, src)
 
 	// Delegate to the Base generator
 	return pg.BaseGenerator.Generate(generator, priorSamples, percentiles, log)
 }
 
-func UnmarshalPERT(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
-	// Supported forms:
-	// PERT(n)
-	// PERT(min, mode, max)
-	pg := &PERTGenerator{}
-	reParams := regexp.MustCompile(`[()]`)
-	pertParts := reParams.Split(typeParameter, -1)
-	if len(pertParts) < 2 {
-		return nil, fmt.Errorf("invalid PERT generator expression: %s", typeParameter)
-	}
-	pertFloatParts := strings.Split(pertParts[1], ",")
-	var floatErr error
-	if len(pertFloatParts) == 1 {
-		floatErr = pg.BaseGenerator.parseFloat(pertFloatParts[0], &pg.mode)
-		if floatErr != nil {
-			return nil, floatErr
-		}
-		pg.max = pg.mode
-		pg.min = pg.mode
-	} else if len(pertFloatParts) == 3 {
-		floatErr = pg.BaseGenerator.parseFloat(pertFloatParts[0], &pg.min)
-		if floatErr != nil {
-			return nil, floatErr
-		}
-		floatErr = pg.BaseGenerator.parseFloat(pertFloatParts[1], &pg.mode)
-		if floatErr != nil {
-			return nil, floatErr
-		}
-		floatErr = pg.BaseGenerator.parseFloat(pertFloatParts[2], &pg.max)
-		if floatErr != nil {
-			return nil, floatErr
-		}
-	} else {
-		return nil, fmt.Errorf("invalid PERT generator expression: %s", typeParameter)
+// pertFactory returns the PERT generator for the arguments. PERT(n) is the
+// fixed value n.
+func pertFactory(args *Arguments, _ *slog.Logger) (DurationGenerator, error) {
+	if args.Count == 1 {
+		return &FixedGenerator{
+			value: args.first("min", "mode", "max"),
+		}, nil
 	}
-	// Check the values
-	return pg, pg.Validate()
+	return &PERTGenerator{
+		min:  args.Float("min"),
+		mode: args.Float("mode"),
+		max:  args.Float("max"),
+	}, nil
+}
+
+// UnmarshalPERT returns the PERT generator for the PERT(...) expression
+func UnmarshalPERT(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
+	return unmarshalRegistered("PERT", typeParameter, log)
 }
@@ -0,0 +1,394 @@
+package generator
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Registry
+//
+// The registry of GENERATOR(...) expression names. The built-in
+// distributions are registered in init(). Custom distributions registered
+// with RegisterGenerator are parsed, validated, named and rendered the same
+// way.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// Parameter kinds
+const (
+	// PARAM_KIND_NUMBER is a numeric parameter (ex: `PERT(1, 2, 3)`)
+	PARAM_KIND_NUMBER = "number"
+	// PARAM_KIND_GENERATOR is a nested generator expression
+	// (ex: `impact=PERT(1, 2, 3)`)
+	PARAM_KIND_GENERATOR = "generator"
+)
+
+// Param describes a generator expression parameter. Parameters are passed
+// by position or by name (ex: `p=0.25`).
+type Param struct {
+	Name        string
+	Description string
+	// Kind is PARAM_KIND_NUMBER (default) or PARAM_KIND_GENERATOR
+	Kind string
+	// Optional parameters may be omitted
+	Optional bool
+	// Min and Max are the inclusive bounds of a numeric parameter, if not nil
+	Min *float64
+	Max *float64
+}
+
+// ParamSchema describes the parameters of a generator expression
+type ParamSchema struct {
+	Description string
+	Params      []*Param
+	// Arities are the accepted parameter counts. If empty, expressions
+	// accept from the number of required parameters to all of them.
+	Arities []int
+}
+
+// Bound returns a Param Min or Max bound
+func Bound(value float64) *float64 {
+	return &value
+}
+
+// Usage returns the expression form (ex: `PERT(min, mode, max)`)
+func (ps *ParamSchema) Usage(name string) string {
+	paramNames := make([]string, len(ps.Params))
+	for i, eachParam := range ps.Params {
+		paramNames[i] = eachParam.Name
+		if eachParam.Optional {
+			paramNames[i] = fmt.Sprintf("[%s]", eachParam.Name)
+		}
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(paramNames, ", "))
+}
+
+func (ps *ParamSchema) acceptsArity(count int) bool {
+	if len(ps.Arities) != 0 {
+		return slices.Contains(ps.Arities, count)
+	}
+	requiredCount := 0
+	for _, eachParam := range ps.Params {
+		if !eachParam.Optional {
+			requiredCount++
+		}
+	}
+	return count >= requiredCount && count <= len(ps.Params)
+}
+
+func (ps *ParamSchema) param(name string) *Param {
+	for _, eachParam := range ps.Params {
+		if strings.EqualFold(eachParam.Name, name) {
+			return eachParam
+		}
+	}
+	return nil
+}
+
+// Arguments are the validated parameters of a generator expression
+type Arguments struct {
+	// Expression is the complete GENERATOR(...) expression
+	Expression string
+	// Count is the number of parameters in the expression
+	Count      int
+	values     map[string]float64
+	generators map[string]DurationGenerator
+}
+
+// Has returns true if the parameter was provided
+func (a *Arguments) Has(name string) bool {
+	_, valueExists := a.values[name]
+	_, generatorExists := a.generators[name]
+	return valueExists || generatorExists
+}
+
+// Float returns the value of a numeric parameter, or zero if it wasn't
+// provided
+func (a *Arguments) Float(name string) float64 {
+	return a.values[name]
+}
+
+// first returns the value of the first provided numeric parameter of the
+// names, or zero if none were provided
+func (a *Arguments) first(names ...string) float64 {
+	for _, eachName := range names {
+		value, valueExists := a.values[eachName]
+		if valueExists {
+			return value
+		}
+	}
+	return 0
+}
+
+// Generator returns the nested generator of a generator parameter, or nil
+// if it wasn't provided
+func (a *Arguments) Generator(name string) DurationGenerator {
+	return a.generators[name]
+}
+
+// Factory returns the DurationGenerator for the validated arguments
+type Factory func(args *Arguments, log *slog.Logger) (DurationGenerator, error)
+
+// Validator is implemented by generators that verify their parameters
+// after they're created
+type Validator interface {
+	Validate() error
+}
+
+type registration struct {
+	factory Factory
+	schema  *ParamSchema
+}
+
+var reGeneratorName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+var reParamName = regexp.MustCompile(`^\s*([A-Za-z][A-Za-z0-9_]*)\s*=`)
+
+var registryMutex sync.RWMutex
+var registry = map[string]*registration{}
+
+// RegisterGenerator registers the factory for GENERATOR(...) expressions
+// with the name. Names are case sensitive and may only be registered once.
+func RegisterGenerator(name string, factory Factory, schema *ParamSchema) error {
+	if !reGeneratorName.MatchString(name) {
+		return fmt.Errorf("invalid generator name: %q. Names must match %s", name, reGeneratorName.String())
+	}
+	if factory == nil {
+		return fmt.Errorf("invalid generator %s: missing factory", name)
+	}
+	if schema == nil {
+		return fmt.Errorf("invalid generator %s: missing parameter schema", name)
+	}
+	for _, eachParam := range schema.Params {
+		if !reGeneratorName.MatchString(eachParam.Name) {
+			return fmt.Errorf("invalid generator %s parameter name: %q", name, eachParam.Name)
+		}
+		if eachParam.Kind != "" && eachParam.Kind != PARAM_KIND_NUMBER && eachParam.Kind != PARAM_KIND_GENERATOR {
+			return fmt.Errorf("invalid generator %s parameter %s kind: %s. Supported kinds: %s, %s",
+				name,
+				eachParam.Name,
+				eachParam.Kind,
+				PARAM_KIND_NUMBER,
+				PARAM_KIND_GENERATOR)
+		}
+	}
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, nameExists := registry[name]; nameExists {
+		return fmt.Errorf("duplicate generator name: %s. The name is already registered", name)
+	}
+	registry[name] = &registration{
+		factory: factory,
+		schema:  schema,
+	}
+	return nil
+}
+
+// MustRegisterGenerator registers the generator and panics if it can't be
+// registered
+func MustRegisterGenerator(name string, factory Factory, schema *ParamSchema) {
+	registerErr := RegisterGenerator(name, factory, schema)
+	if registerErr != nil {
+		panic(registerErr)
+	}
+}
+
+// RegisteredGenerators returns the sorted names of the registered generators
+func RegisteredGenerators() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	names := make([]string, 0, len(registry))
+	for eachName := range registry {
+		names = append(names, eachName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GeneratorSchema returns the parameter schema of a registered generator
+func GeneratorSchema(name string) (*ParamSchema, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	entry, entryExists := registry[name]
+	if !entryExists {
+		return nil, false
+	}
+	return entry.schema, true
+}
+
+func lookupGenerator(name string) (*registration, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	entry, entryExists := registry[name]
+	return entry, entryExists
+}
+
+// parseArguments validates the expression parameters against the schema
+func parseArguments(expression string, schema *ParamSchema, log *slog.Logger) (*Arguments, error) {
+	exprName, params, paramsErr := splitExpression(expression)
+	if paramsErr != nil {
+		return nil, paramsErr
+	}
+	if !schema.acceptsArity(len(params)) {
+		return nil, fmt.Errorf("invalid %s generator expression: %s. Expected: %s",
+			exprName,
+			expression,
+			schema.Usage(exprName))
+	}
+	args := &Arguments{
+		Expression: strings.TrimSpace(expression),
+		Count:      len(params),
+		values:     make(map[string]float64),
+		generators: make(map[string]DurationGenerator),
+	}
+	for i, eachParam := range params {
+		paramDef := (*Param)(nil)
+		paramValue := eachParam
+		// Named parameters are of the form name=value
+		if nameMatch := reParamName.FindStringSubmatch(eachParam); nameMatch != nil {
+			paramDef = schema.param(nameMatch[1])
+			if paramDef == nil {
+				return nil, fmt.Errorf("unsupported %s parameter: %s. Expected: %s",
+					exprName,
+					nameMatch[1],
+					schema.Usage(exprName))
+			}
+			paramValue = strings.TrimSpace(eachParam[len(nameMatch[0]):])
+		} else if i < len(schema.Params) {
+			paramDef = schema.Params[i]
+		}
+		if paramDef == nil {
+			return nil, fmt.Errorf("invalid %s generator expression: %s. Expected: %s",
+				exprName,
+				expression,
+				schema.Usage(exprName))
+		}
+		if args.Has(paramDef.Name) {
+			return nil, fmt.Errorf("duplicate %s parameter: %s", exprName, paramDef.Name)
+		}
+		if paramDef.Kind == PARAM_KIND_GENERATOR {
+			nestedGenerator, nestedGeneratorErr := NewDurationGeneratorFromExpression(paramValue, log)
+			if nestedGeneratorErr != nil {
+				return nil, nestedGeneratorErr
+			}
+			args.generators[paramDef.Name] = nestedGenerator
+			continue
+		}
+		floatVal, floatValErr := strconv.ParseFloat(paramValue, 64)
+		if floatValErr != nil {
+			return nil, fmt.Errorf("invalid %s parameter %s: %s. Value must be a number", exprName, paramDef.Name, paramValue)
+		}
+		if (paramDef.Min != nil && floatVal < *paramDef.Min) ||
+			(paramDef.Max != nil && floatVal > *paramDef.Max) {
+			return nil, fmt.Errorf("invalid %s parameter %s: %s. Value must satisfy: %s",
+				exprName,
+				paramDef.Name,
+				paramValue,
+				paramBounds(paramDef))
+		}
+		args.values[paramDef.Name] = floatVal
+	}
+	// Named parameters may be passed in any order, so verify that the
+	// required ones were all provided
+	if len(schema.Arities) == 0 {
+		for _, eachParam := range schema.Params {
+			if !eachParam.Optional && !args.Has(eachParam.Name) {
+				return nil, fmt.Errorf("missing %s parameter: %s. Expected: %s",
+					exprName,
+					eachParam.Name,
+					schema.Usage(exprName))
+			}
+		}
+	}
+	return args, nil
+}
+
+func paramBounds(paramDef *Param) string {
+	bounds := paramDef.Name
+	if paramDef.Min != nil {
+		bounds = fmt.Sprintf("%s <= %s", strconv.FormatFloat(*paramDef.Min, 'f', -1, 64), bounds)
+	}
+	if paramDef.Max != nil {
+		bounds = fmt.Sprintf("%s <= %s", bounds, strconv.FormatFloat(*paramDef.Max, 'f', -1, 64))
+	}
+	return bounds
+}
+
+// newGenerator validates the expression arguments against the schema and
+// returns the verified generator created by the factory
+func (r *registration) newGenerator(name string, expression string, log *slog.Logger) (DurationGenerator, error) {
+	args, argsErr := parseArguments(expression, r.schema, log)
+	if argsErr != nil {
+		return nil, argsErr
+	}
+	durGenerator, durGeneratorErr := r.factory(args, log)
+	if durGeneratorErr != nil {
+		return nil, durGeneratorErr
+	}
+	if durGenerator == nil {
+		return nil, fmt.Errorf("invalid %s generator: factory returned nil", name)
+	}
+	validator, validatorOk := durGenerator.(Validator)
+	if validatorOk {
+		validateErr := validator.Validate()
+		if validateErr != nil {
+			return nil, validateErr
+		}
+	}
+	return durGenerator, nil
+}
+
+// unmarshalRegistered returns the generator for the expression of the
+// registered generator with the name
+func unmarshalRegistered(name string, expression string, log *slog.Logger) (DurationGenerator, error) {
+	entry, entryExists := lookupGenerator(name)
+	if !entryExists {
+		return nil, fmt.Errorf("unsupported generator function name: %s. Supported types: %v", name, RegisteredGenerators())
+	}
+	return entry.newGenerator(name, expression, log)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// SamplerGenerator
+// /////////////////////////////////////////////////////////////////////////////
+
+// SamplerFunc returns the distribution sampled with the random source
+type SamplerFunc func(src rand.Source) distuv.Rander
+
+// SamplerGenerator is a DurationGenerator that samples a distribution. It's
+// the simplest way to implement a custom generator Factory.
+type SamplerGenerator struct {
+	BaseGenerator
+	name    string
+	sampler SamplerFunc
+}
+
+// NewSamplerGenerator returns a generator with the display name that samples
+// the distribution returned by sampler
+func NewSamplerGenerator(name string, sampler SamplerFunc) *SamplerGenerator {
+	return &SamplerGenerator{
+		name:    name,
+		sampler: sampler,
+	}
+}
+
+func (sg *SamplerGenerator) Name() string {
+	return sg.name
+}
+
+func (sg *SamplerGenerator) Generate(priorSamples map[int64]*GenerationResults,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) (*GenerationResults, error) {
+	// Delegate to the Base generator
+	return sg.BaseGenerator.Generate(sg.sampler(src), priorSamples, percentiles, log)
+}
@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/rand"
+)
+
+// testExpression returns an expression of the registered generator with the
+// first count parameters. Bounded parameters take their midpoint and the
+// others increase, so min <= mode <= max.
+func testExpression(name string, schema *ParamSchema, count int) string {
+	params := make([]string, count)
+	for i, eachParam := range schema.Params[:count] {
+		switch {
+		case eachParam.Kind == PARAM_KIND_GENERATOR:
+			params[i] = fmt.Sprintf("%s=Fixed(1)", eachParam.Name)
+		case eachParam.Min != nil && eachParam.Max != nil:
+			params[i] = fmt.Sprint((*eachParam.Min + *eachParam.Max) / 2)
+		default:
+			params[i] = fmt.Sprint(i + 1)
+		}
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(params, ", "))
+}
+
+// generateSamples draws the samples of the generator
+func generateSamples(t *testing.T, durGenerator DurationGenerator, count int) []float64 {
+	t.Helper()
+	zeros := make([]float64, count)
+	priorSamples := map[int64]*GenerationResults{
+		0: {RawValues: &zeros, CumulativeValues: &zeros},
+	}
+	results, resultsErr := durGenerator.Generate(priorSamples, []float64{50}, rand.NewSource(7), slog.Default())
+	if resultsErr != nil {
+		t.Fatal(resultsErr)
+	}
+	return *results.RawValues
+}
+
+// TestRegisteredArities builds and samples every registered generator once
+// for each advertised arity
+func TestRegisteredArities(t *testing.T) {
+	for _, eachName := range RegisteredGenerators() {
+		schema, _ := GeneratorSchema(eachName)
+		arityCount := 0
+		for count := 0; count <= len(schema.Params); count++ {
+			if !schema.acceptsArity(count) {
+				continue
+			}
+			arityCount++
+			expression := testExpression(eachName, schema, count)
+			t.Run(expression, func(t *testing.T) {
+				durGenerator, durGeneratorErr := NewDurationGeneratorFromExpression(expression, slog.Default())
+				if durGeneratorErr != nil {
+					t.Fatal(durGeneratorErr)
+				}
+				for i, eachSample := range generateSamples(t, durGenerator, 1000) {
+					if math.IsNaN(eachSample) || math.IsInf(eachSample, 0) {
+						t.Fatalf("invalid sample %d: %v", i, eachSample)
+					}
+				}
+			})
+		}
+		if arityCount == 0 {
+			t.Errorf("generator %s doesn't accept any arity", eachName)
+		}
+	}
+}
+
+func TestSingleValueForms(t *testing.T) {
+	for _, eachExpression := range []string{"PERT(5)", "Triangle(5)", "PERT(max=5)"} {
+		durGenerator, durGeneratorErr := NewDurationGeneratorFromExpression(eachExpression, slog.Default())
+		if durGeneratorErr != nil {
+			t.Fatalf("invalid %s: %v", eachExpression, durGeneratorErr)
+		}
+		for _, eachSample := range generateSamples(t, durGenerator, 100) {
+			if eachSample != 5 {
+				t.Fatalf("invalid %s sample. Expected: 5, Found: %v", eachExpression, eachSample)
+			}
+		}
+	}
+}
+
+func TestDuplicateRegistration(t *testing.T) {
+	schema, _ := GeneratorSchema("PERT")
+	for _, eachName := range RegisteredGenerators() {
+		registerErr := RegisterGenerator(eachName, pertFactory, schema)
+		if registerErr == nil || !strings.Contains(registerErr.Error(), "duplicate generator name") {
+			t.Errorf("invalid duplicate registration of %s. Expected a duplicate name error, Found: %v", eachName, registerErr)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package generator
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// ___                   _
+// | _ \___ _ __  ___ __ _| |_
+// |   / -_) '_ \/ -_) _` |  _|
+// |_|_\___| .__/\___\__,_|\__|
+//         |_|
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// RepeatCount is a discrete distribution for the number of times an activity
+// is executed. Supported forms:
+//
+//	Geometric(p)   - number of attempts until the first success, p in (0, 1]
+//	Poisson(λ)     - Poisson distributed count
+//	Binomial(n, p) - number of successes in n trials
+//	Fixed(n)       - always n
+//
+// Each form accepts an optional integer offset (ex: `Poisson(1.5)+1`).
+type RepeatCount struct {
+	distribution string
+	params       []float64
+	offset       int
+}
+
+var reRepeatCount = regexp.MustCompile(`^\s*(\w+)\s*\(([^()]*)\)\s*(?:\+\s*(\d+))?\s*$`)
+
+// ParseRepeatCount parses a repeat count expression
+func ParseRepeatCount(expression string) (*RepeatCount, error) {
+	// Plain integers are shorthand for Fixed(n)
+	fixedCount, fixedCountErr := strconv.Atoi(strings.TrimSpace(expression))
+	if fixedCountErr == nil {
+		expression = fmt.Sprintf("Fixed(%d)", fixedCount)
+	}
+	matches := reRepeatCount.FindStringSubmatch(expression)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid repeat expression: %s", expression)
+	}
+	rc := &RepeatCount{
+		distribution: matches[1],
+		params:       make([]float64, 0),
+	}
+	for _, eachParam := range strings.Split(matches[2], ",") {
+		paramValue, paramValueErr := strconv.ParseFloat(strings.TrimSpace(eachParam), 64)
+		if paramValueErr != nil {
+			return nil, fmt.Errorf("invalid repeat expression: %s. Error: %w", expression, paramValueErr)
+		}
+		rc.params = append(rc.params, paramValue)
+	}
+	if len(matches[3]) != 0 {
+		offset, offsetErr := strconv.Atoi(matches[3])
+		if offsetErr != nil {
+			return nil, offsetErr
+		}
+		rc.offset = offset
+	}
+	return rc, rc.Validate()
+}
+
+func (rc *RepeatCount) Validate() error {
+	paramCount := map[string]int{
+		"Geometric": 1,
+		"Poisson":   1,
+		"Binomial":  2,
+		"Fixed":     1,
+	}
+	expectedCount, expectedCountOk := paramCount[rc.distribution]
+	if !expectedCountOk {
+		return fmt.Errorf("unsupported repeat distribution: %s. Supported types: [Geometric Poisson Binomial Fixed]", rc.distribution)
+	}
+	if len(rc.params) != expectedCount {
+		return fmt.Errorf("invalid repeat expression: %s", rc.Name())
+	}
+	var validationErr error
+	switch rc.distribution {
+	case "Geometric":
+		if rc.params[0] <= 0 || rc.params[0] > 1 {
+			validationErr = fmt.Errorf("invalid Geometric repeat probability: %.2f. Probability must satisfy: 0 < p <= 1", rc.params[0])
+		}
+	case "Poisson":
+		if rc.params[0] < 0 {
+			validationErr = fmt.Errorf("invalid Poisson repeat rate: %.2f. Rate must be non-negative", rc.params[0])
+		}
+	case "Binomial":
+		if rc.params[0] < 0 || rc.params[1] < 0 || rc.params[1] > 1 {
+			validationErr = fmt.Errorf("invalid Binomial repeat parameters: (n=%.0f, p=%.2f)", rc.params[0], rc.params[1])
+		}
+	case "Fixed":
+		if rc.params[0] < 0 || math.Floor(rc.params[0]) != rc.params[0] {
+			validationErr = fmt.Errorf("invalid Fixed repeat count: %.2f. Count must be a non-negative integer", rc.params[0])
+		}
+	}
+	return validationErr
+}
+
+func (rc *RepeatCount) Name() string {
+	paramValues := make([]string, len(rc.params))
+	for i, eachParam := range rc.params {
+		paramValues[i] = strconv.FormatFloat(eachParam, 'f', -1, 64)
+	}
+	offsetSuffix := ""
+	if rc.offset != 0 {
+		offsetSuffix = fmt.Sprintf("+%d", rc.offset)
+	}
+	return fmt.Sprintf("%s(%s)%s", rc.distribution, strings.Join(paramValues, ", "), offsetSuffix)
+}
+
+// Mean returns the expected number of iterations
+func (rc *RepeatCount) Mean() float64 {
+	mean := float64(0)
+	switch rc.distribution {
+	case "Geometric":
+		mean = 1 / rc.params[0]
+	case "Poisson":
+		mean = rc.params[0]
+	case "Binomial":
+		mean = rc.params[0] * rc.params[1]
+	case "Fixed":
+		mean = rc.params[0]
+	}
+	return mean + float64(rc.offset)
+}
+
+// Sample returns runCount iteration counts
+func (rc *RepeatCount) Sample(runCount int, src rand.Source) []int {
+	counts := make([]int, runCount)
+	var rander func() float64
+	switch rc.distribution {
+	case "Geometric":
+		uniform := distuv.Uniform{Min: 0, Max: 1, Src: src}
+		rander = func() float64 {
+			if rc.params[0] >= 1 {
+				return 1
+			}
+			// Inverse CDF of the number of trials until the first success
+			return math.Max(1, math.Ceil(math.Log(1-uniform.Rand())/math.Log(1-rc.params[0])))
+		}
+	case "Poisson":
+		rander = distuv.Poisson{Lambda: rc.params[0], Src: src}.Rand
+	case "Binomial":
+		rander = distuv.Binomial{N: rc.params[0], P: rc.params[1], Src: src}.Rand
+	default:
+		rander = func() float64 {
+			return rc.params[0]
+		}
+	}
+	for i := range counts {
+		counts[i] = int(rander()) + rc.offset
+	}
+	return counts
+}
+
+// meanCount returns the mean of the sampled iteration counts
+func meanCount(counts []int) float64 {
+	if len(counts) <= 0 {
+		return 0
+	}
+	total := 0
+	for _, eachCount := range counts {
+		total += eachCount
+	}
+	return float64(total) / float64(len(counts))
+}
+
+// iterationTally totals the iteration counts of summarized chunks, whose
+// counts aren't retained
+type iterationTally struct {
+	total int
+	runs  int
+}
+
+func (it *iterationTally) add(counts []int) {
+	for _, eachCount := range counts {
+		it.total += eachCount
+	}
+	it.runs += len(counts)
+}
+
+func (it *iterationTally) merge(other *iterationTally) {
+	it.total += other.total
+	it.runs += other.runs
+}
+
+func (it *iterationTally) mean() float64 {
+	return float64(it.total) / float64(it.runs)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// RepeatGenerator
+//
+// Samples the body generator once per iteration and sums the iterations for
+// each run.
+//
+// /////////////////////////////////////////////////////////////////////////////
+type RepeatGenerator struct {
+	BaseGenerator
+	Body   DurationGenerator
+	Count  *RepeatCount
+	counts []int
+	// iterations tallies the counts of summarized chunks
+	iterations iterationTally
+}
+
+func (rg *RepeatGenerator) Name() string {
+	return fmt.Sprintf("%s ⟳ %s", rg.Body.Name(), rg.Count.Name())
+}
+
+// Iterations returns the sampled iteration count of each run
+func (rg *RepeatGenerator) Iterations() []int {
+	return rg.counts
+}
+
+// MeanIterations returns the mean number of sampled iterations
+func (rg *RepeatGenerator) MeanIterations() float64 {
+	if rg.iterations.runs != 0 {
+		return rg.iterations.mean()
+	}
+	return meanCount(rg.counts)
+}
+
+func (rg *RepeatGenerator) Generate(priorSamples map[int64]*GenerationResults,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) (*GenerationResults, error) {
+
+	if len(priorSamples) != 1 {
+		return nil, fmt.Errorf("invalid length for prior samples: %d", len(priorSamples))
+	}
+	var genResults *GenerationResults
+	for _, val := range priorSamples {
+		genResults = val
+	}
+	runCount := len(*genResults.CumulativeValues)
+	rg.counts = rg.Count.Sample(runCount, src)
+	totalIterations := 0
+	for _, eachCount := range rg.counts {
+		totalIterations += eachCount
+	}
+	generatedSamples := make([]float64, runCount)
+	if totalIterations <= 0 {
+		return rg.computeAggregates(generatedSamples, *genResults.CumulativeValues, percentiles, log)
+	}
+	// Generate all the iterations at once, then fold them back into runs
+	iterationPrior := make([]float64, totalIterations)
+	iterationResults, iterationResultsErr := rg.Body.Generate(map[int64]*GenerationResults{
+		0: {
+			RawValues:        &iterationPrior,
+			CumulativeValues: &iterationPrior,
+		},
+	}, percentiles, src, log)
+	if iterationResultsErr != nil {
+		return nil, iterationResultsErr
+	}
+	iterationValues := *iterationResults.RawValues
+	iterationIndex := 0
+	for i, eachCount := range rg.counts {
+		for j := 0; j != eachCount; j++ {
+			generatedSamples[i] += iterationValues[iterationIndex]
+			iterationIndex++
+		}
+	}
+	return rg.computeAggregates(generatedSamples, *genResults.CumulativeValues, percentiles, log)
+}
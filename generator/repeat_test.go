@@ -0,0 +1,69 @@
+package generator
+
+import (
+	"math"
+	"testing"
+
+	"golang.org/x/exp/rand"
+)
+
+// The sampled iteration counts of each form have its expected mean
+func TestRepeatCountMeans(t *testing.T) {
+	expected := map[string]float64{
+		"Geometric(0.4)":     2.5,
+		"Geometric(1)":       1,
+		"Poisson(1.5)+1":     2.5,
+		"Binomial(10, 0.3)":  3,
+		"Fixed(3)":           3,
+		"4":                  4,
+		"Fixed(0)":           0,
+		"Binomial(4, 0.5)+2": 4,
+	}
+	for expression, eachMean := range expected {
+		repeatCount, repeatCountErr := ParseRepeatCount(expression)
+		if repeatCountErr != nil {
+			t.Fatalf("invalid %s: %v", expression, repeatCountErr)
+		}
+		if math.Abs(repeatCount.Mean()-eachMean) > 1e-9 {
+			t.Errorf("invalid mean of %s. Expected: %v, Found: %v", expression, eachMean, repeatCount.Mean())
+		}
+		counts := repeatCount.Sample(50000, rand.NewSource(7))
+		if math.Abs(meanCount(counts)-eachMean) > 0.05 {
+			t.Errorf("invalid sampled mean of %s. Expected: %v, Found: %v", expression, eachMean, meanCount(counts))
+		}
+	}
+}
+
+func TestRepeatCountErrors(t *testing.T) {
+	for _, eachExpression := range []string{
+		"Geometric(0)",
+		"Geometric(1.5)",
+		"Poisson(-1)",
+		"Binomial(10)",
+		"Binomial(10, 2)",
+		"Fixed(1.5)",
+		"Uniform(1, 2)",
+		"Poisson(1)-1",
+		"",
+	} {
+		_, repeatCountErr := ParseRepeatCount(eachExpression)
+		if repeatCountErr == nil {
+			t.Errorf("expected an error for repeat expression: %q", eachExpression)
+		}
+	}
+}
+
+// A repeated generator sums an independent body sample for each iteration
+func TestRepeatGeneratorSums(t *testing.T) {
+	repeatCount, _ := ParseRepeatCount("Poisson(1.5)+1")
+	repeatGenerator := &RepeatGenerator{
+		Body:  &FixedGenerator{value: 2},
+		Count: repeatCount,
+	}
+	samples := generateSamples(t, repeatGenerator, 1000)
+	for i, eachCount := range repeatGenerator.Iterations() {
+		if samples[i] != 2*float64(eachCount) {
+			t.Fatalf("invalid sample %d. Expected: %v, Found: %v", i, 2*float64(eachCount), samples[i])
+		}
+	}
+}
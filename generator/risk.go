@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"fmt"
+	"log/slog"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// ___ _    _
+// | _ (_)__| |__
+// |   / (_-< / /
+// |_|_\_/__/_\_\
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// RiskGenerator is a risk event. Each run the event occurs with probability
+// `prob`. When it occurs the run's value is sampled from the impact generator,
+// otherwise the value is zero.
+type RiskGenerator struct {
+	BaseGenerator
+	prob      float64
+	impact    DurationGenerator
+	triggered []bool
+}
+
+func (rg *RiskGenerator) Validate() error {
+	if rg.prob < 0 || rg.prob > 1 {
+		return fmt.Errorf("invalid Risk probability: %.2f. Probability must satisfy: 0 <= p <= 1", rg.prob)
+	}
+	if rg.impact == nil {
+		return fmt.Errorf("invalid Risk expression: missing impact generator")
+	}
+	return nil
+}
+
+func (rg *RiskGenerator) Name() string {
+	return fmt.Sprintf("Risk(p=%.2f, impact=%s)",
+		rg.prob,
+		rg.impact.Name())
+}
+
+// Probability returns the probability that the risk event occurs in any
+// given run
+func (rg *RiskGenerator) Probability() float64 {
+	return rg.prob
+}
+
+// Triggered returns, for each run, whether the risk event occurred
+func (rg *RiskGenerator) Triggered() []bool {
+	return rg.triggered
+}
+
+func (rg *RiskGenerator) Generate(priorSamples map[int64]*GenerationResults,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) (*GenerationResults, error) {
+
+	impactResults, impactResultsErr := rg.impact.Generate(priorSamples, percentiles, src, log)
+	if impactResultsErr != nil {
+		return nil, impactResultsErr
+	}
+	if len(priorSamples) != 1 {
+		return nil, fmt.Errorf("invalid length for prior samples: %d", len(priorSamples))
+	}
+	var genResults *GenerationResults
+	for _, val := range priorSamples {
+		genResults = val
+	}
+	gate := distuv.Bernoulli{
+		P:   rg.prob,
+		Src: src,
+	}
+	impactValues := *impactResults.RawValues
+	rg.triggered = make([]bool, len(impactValues))
+	generatedSamples := make([]float64, len(impactValues))
+	for i, eachGate := range rg.draw(gate, len(impactValues)) {
+		if eachGate != 0 {
+			rg.triggered[i] = true
+			generatedSamples[i] = impactValues[i]
+		}
+	}
+	return rg.computeAggregates(generatedSamples, *genResults.CumulativeValues, percentiles, log)
+}
+
+// riskFactory returns the Risk generator for the arguments
+func riskFactory(args *Arguments, _ *slog.Logger) (DurationGenerator, error) {
+	return &RiskGenerator{
+		prob:   args.Float("p"),
+		impact: args.Generator("impact"),
+	}, nil
+}
+
+// UnmarshalRisk returns the Risk generator for the Risk(...) expression
+func UnmarshalRisk(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
+	return unmarshalRegistered("Risk", typeParameter, log)
+}
@@ -0,0 +1,341 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Sampling
+//
+// Generators draw their samples from the distribution's Rand() by default,
+// which is plain Monte Carlo sampling. A Sampler replaces the draws of
+// distributions with an inverse CDF (distuv.Quantiler) by the quantiles of
+// variance reduced uniforms:
+//
+//   - lhs: Latin hypercube sampling. Each generator's runs are stratified,
+//     one uniform per equal probability stratum, in a random order.
+//   - antithetic: antithetic variates. Runs are pairs whose uniforms are u
+//     and 1-u.
+//   - sobol: scrambled Sobol sequences. Each generator is a dimension of a
+//     Sobol sequence with random linear scrambling and a random digital
+//     shift.
+//
+// The runs are split into SAMPLING_REPLICATES independently randomized
+// replicates, so the variance reduction can be estimated from the variance
+// between the replicates. Rank correlations reorder the samples of the
+// correlated generators: their marginals stay stratified, but the runs no
+// longer share the joint structure. Risk gates draw their uniforms from the
+// sampler. Choices, repeat counts and resampled subgraph iterations always
+// draw pseudo-random numbers.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// Sampling strategies
+const (
+	SAMPLING_MC         = "mc"
+	SAMPLING_LHS        = "lhs"
+	SAMPLING_ANTITHETIC = "antithetic"
+	SAMPLING_SOBOL      = "sobol"
+)
+
+// SAMPLING_STRATEGIES are the supported sampling strategies
+var SAMPLING_STRATEGIES = []string{
+	SAMPLING_MC,
+	SAMPLING_LHS,
+	SAMPLING_ANTITHETIC,
+	SAMPLING_SOBOL,
+}
+
+// SAMPLING_REPLICATES is the number of independently randomized replicates
+// the runs of a Sampler are split into
+var SAMPLING_REPLICATES = 16
+
+// ValidateSamplingStrategy returns an error if the strategy isn't supported
+func ValidateSamplingStrategy(strategy string) error {
+	for _, eachStrategy := range SAMPLING_STRATEGIES {
+		if strategy == eachStrategy {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sampling strategy: %s. Expected one of: %v", strategy, SAMPLING_STRATEGIES)
+}
+
+// ReplicateBounds returns the first run of each replicate of runCount runs,
+// followed by runCount
+func ReplicateBounds(runCount int) []int {
+	bounds := make([]int, SAMPLING_REPLICATES+1)
+	for i := range bounds {
+		bounds[i] = i * runCount / SAMPLING_REPLICATES
+	}
+	return bounds
+}
+
+// Sampler draws the variance reduced uniforms of a sampling strategy. Each
+// call to the sampler is a new dimension. Samplers aren't safe for
+// concurrent use.
+type Sampler struct {
+	strategy  string
+	rng       *rand.Rand
+	dimension int
+}
+
+// NewSampler returns the sampler for the strategy that draws its
+// randomization from src
+func NewSampler(strategy string, src rand.Source) (*Sampler, error) {
+	validateErr := ValidateSamplingStrategy(strategy)
+	if validateErr != nil {
+		return nil, validateErr
+	}
+	return &Sampler{
+		strategy: strategy,
+		rng:      rand.New(src),
+	}, nil
+}
+
+// Strategy returns the sampling strategy
+func (s *Sampler) Strategy() string {
+	return s.strategy
+}
+
+// openUniform returns a uniform in (0, 1)
+func (s *Sampler) openUniform() float64 {
+	for {
+		u := s.rng.Float64()
+		if u > 0 {
+			return u
+		}
+	}
+}
+
+// uniforms returns the next dimension's uniforms in (0, 1) for each of the
+// runs
+func (s *Sampler) uniforms(runCount int) []float64 {
+	values := make([]float64, runCount)
+	bounds := ReplicateBounds(runCount)
+	for i := 0; i != len(bounds)-1; i++ {
+		replicate := values[bounds[i]:bounds[i+1]]
+		switch s.strategy {
+		case SAMPLING_LHS:
+			strata := s.rng.Perm(len(replicate))
+			for j := range replicate {
+				replicate[j] = (float64(strata[j]) + s.openUniform()) / float64(len(replicate))
+			}
+		case SAMPLING_ANTITHETIC:
+			for j := range replicate {
+				if j%2 == 1 {
+					replicate[j] = 1 - replicate[j-1]
+				} else {
+					replicate[j] = s.openUniform()
+				}
+			}
+		case SAMPLING_SOBOL:
+			s.sobolUniforms(replicate)
+		default:
+			for j := range replicate {
+				replicate[j] = s.openUniform()
+			}
+		}
+	}
+	s.dimension++
+	return values
+}
+
+// draw returns count samples of the distribution. Distributions with an
+// inverse CDF are sampled through the sampler, if there is one.
+func (bg *BaseGenerator) draw(rander distuv.Rander, count int) []float64 {
+	samples := make([]float64, count)
+	quantiler, quantilerOk := rander.(distuv.Quantiler)
+	if bg.sampler != nil && quantilerOk {
+		for i, eachUniform := range bg.sampler.uniforms(count) {
+			samples[i] = quantiler.Quantile(eachUniform)
+		}
+		return samples
+	}
+	for i := range samples {
+		samples[i] = rander.Rand()
+	}
+	return samples
+}
+
+// SetSampler samples the generator and its nested generators with the
+// sampler
+func SetSampler(gen DurationGenerator, sampler *Sampler) {
+	genChunked, genChunkedOk := gen.(chunkedGenerator)
+	if genChunkedOk {
+		genChunked.base().sampler = sampler
+	}
+	for _, eachNested := range nestedGenerators(gen) {
+		SetSampler(eachNested, sampler)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// Sobol sequences
+//
+// Dimension d > 0 of the sequence uses the d'th primitive polynomial over
+// GF(2), in order of degree, with odd initial direction numbers m_k < 2^k
+// derived from the dimension. Any such direction numbers generate a
+// (0,1)-sequence in each dimension, so each generator's samples are
+// stratified. Dimension 0 is the van der Corput sequence.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+const sobolBits = 32
+
+var sobolDirections = struct {
+	sync.Mutex
+	polynomials []uint32
+	directions  [][]uint32
+}{}
+
+// isPrimitive returns true if the degree s polynomial, with its x^s term in
+// bit s, is primitive: x has multiplicative order 2^s-1 modulo the
+// polynomial
+func isPrimitive(polynomial uint32, degree int) bool {
+	order := uint64(1)<<degree - 1
+	if polyPowX(polynomial, degree, order) != 1 {
+		return false
+	}
+	remaining := order
+	for factor := uint64(2); factor*factor <= remaining; factor++ {
+		if remaining%factor != 0 {
+			continue
+		}
+		if polyPowX(polynomial, degree, order/factor) == 1 {
+			return false
+		}
+		for remaining%factor == 0 {
+			remaining /= factor
+		}
+	}
+	if remaining > 1 && polyPowX(polynomial, degree, order/remaining) == 1 {
+		return false
+	}
+	return true
+}
+
+// polyPowX returns x^exponent modulo the polynomial
+func polyPowX(polynomial uint32, degree int, exponent uint64) uint32 {
+	result := uint32(1)
+	base := uint32(2)
+	if degree == 1 {
+		base = 1
+	}
+	for ; exponent != 0; exponent >>= 1 {
+		if exponent&1 != 0 {
+			result = polyMulMod(result, base, polynomial, degree)
+		}
+		base = polyMulMod(base, base, polynomial, degree)
+	}
+	return result
+}
+
+func polyMulMod(a uint32, b uint32, polynomial uint32, degree int) uint32 {
+	product := uint32(0)
+	for ; b != 0; b >>= 1 {
+		if b&1 != 0 {
+			product ^= a
+		}
+		a <<= 1
+		if a&(1<<degree) != 0 {
+			a ^= polynomial
+		}
+	}
+	return product
+}
+
+// nextPrimitivePolynomial returns the primitive polynomial that follows the
+// polynomial, in order of degree then value
+func nextPrimitivePolynomial(polynomial uint32) uint32 {
+	for candidate := polynomial + 1; ; candidate++ {
+		degree := bits.Len32(candidate) - 1
+		// Primitive polynomials have a constant term
+		if candidate&1 != 0 && isPrimitive(candidate, degree) {
+			return candidate
+		}
+	}
+}
+
+// sobolDirectionNumbers returns the direction numbers of the dimension,
+// scaled to sobolBits
+func sobolDirectionNumbers(dimension int) []uint32 {
+	sobolDirections.Lock()
+	defer sobolDirections.Unlock()
+	for len(sobolDirections.directions) <= dimension {
+		nextDimension := len(sobolDirections.directions)
+		directions := make([]uint32, sobolBits)
+		if nextDimension == 0 {
+			for k := range directions {
+				directions[k] = 1 << (sobolBits - 1 - k)
+			}
+			sobolDirections.directions = append(sobolDirections.directions, directions)
+			continue
+		}
+		polynomial := uint32(2)
+		if len(sobolDirections.polynomials) != 0 {
+			polynomial = sobolDirections.polynomials[len(sobolDirections.polynomials)-1]
+		}
+		polynomial = nextPrimitivePolynomial(polynomial)
+		sobolDirections.polynomials = append(sobolDirections.polynomials, polynomial)
+		degree := bits.Len32(polynomial) - 1
+
+		// Odd initial direction numbers m_k < 2^k, derived from the
+		// dimension with SplitMix64
+		state := uint64(nextDimension)
+		for k := 0; k != degree && k != sobolBits; k++ {
+			state += 0x9E3779B97F4A7C15
+			z := (state ^ (state >> 30)) * 0xBF58476D1CE4E5B9
+			z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+			z ^= z >> 31
+			initial := uint32(z&(1<<(k+1)-1)) | 1
+			directions[k] = initial << (sobolBits - 1 - k)
+		}
+		for k := degree; k < sobolBits; k++ {
+			direction := directions[k-degree] ^ (directions[k-degree] >> degree)
+			for j := 1; j != degree; j++ {
+				if (polynomial>>(degree-j))&1 != 0 {
+					direction ^= directions[k-j]
+				}
+			}
+			directions[k] = direction
+		}
+		sobolDirections.directions = append(sobolDirections.directions, directions)
+	}
+	return sobolDirections.directions[dimension]
+}
+
+// sobolUniforms fills the values with the points of the sampler's current
+// dimension, scrambled by a random lower triangular matrix and a random
+// digital shift
+func (s *Sampler) sobolUniforms(values []float64) {
+	directions := sobolDirectionNumbers(s.dimension)
+	scrambled := make([]uint32, sobolBits)
+	// Row j of the scrambling matrix has a unit diagonal and random digits
+	// to the left, digit 0 being the most significant bit
+	rows := make([]uint32, sobolBits)
+	for j := range rows {
+		highDigits := ^uint32(0) << (sobolBits - j)
+		rows[j] = (s.rng.Uint32() & highDigits) | 1<<(sobolBits-1-j)
+	}
+	for k, eachDirection := range directions {
+		for j, eachRow := range rows {
+			if bits.OnesCount32(eachRow&eachDirection)%2 != 0 {
+				scrambled[k] |= 1 << (sobolBits - 1 - j)
+			}
+		}
+	}
+	point := s.rng.Uint32()
+	for i := range values {
+		if i != 0 {
+			// Gray code order: flip the direction of the lowest zero bit
+			point ^= scrambled[bits.TrailingZeros32(uint32(i))]
+		}
+		values[i] = (float64(point) + 0.5) / math.Exp2(sobolBits)
+	}
+}
@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"math/bits"
+	"testing"
+
+	"golang.org/x/exp/rand"
+)
+
+// The first primitive polynomials over GF(2), in order of degree, with the
+// x^s term in bit s
+var primitivePolynomials = []uint32{
+	0b11,
+	0b111,
+	0b1011, 0b1101,
+	0b10011, 0b11001,
+	0b100101, 0b101001, 0b101111, 0b110111, 0b111011, 0b111101,
+}
+
+func TestSobolPolynomials(t *testing.T) {
+	sobolDirectionNumbers(len(primitivePolynomials))
+	for i, eachPolynomial := range primitivePolynomials {
+		if sobolDirections.polynomials[i] != eachPolynomial {
+			t.Errorf("invalid polynomial of dimension %d. Expected: %b, Found: %b",
+				i+1,
+				eachPolynomial,
+				sobolDirections.polynomials[i])
+		}
+	}
+}
+
+func TestSobolDirectionNumbers(t *testing.T) {
+	for k, eachDirection := range sobolDirectionNumbers(0) {
+		if eachDirection != 1<<(sobolBits-1-k) {
+			t.Fatalf("invalid van der Corput direction %d: %x", k, eachDirection)
+		}
+	}
+	for dimension := 1; dimension != 64; dimension++ {
+		directions := sobolDirectionNumbers(dimension)
+		degree := bits.Len32(sobolDirections.polynomials[dimension-1]) - 1
+		for k := 0; k != degree && k != sobolBits; k++ {
+			// m_k is the direction number as a k+1 bit integer
+			initial := directions[k] >> (sobolBits - 1 - k)
+			if initial&1 == 0 || initial >= 1<<(k+1) {
+				t.Errorf("invalid initial direction number m_%d of dimension %d: %d. Expected an odd number < %d",
+					k+1,
+					dimension,
+					initial,
+					1<<(k+1))
+			}
+		}
+	}
+}
+
+// Each dimension is a (0,1)-sequence: the first 2^m points have exactly
+// one point in each interval [i/2^m, (i+1)/2^m)
+func TestSobolStratification(t *testing.T) {
+	for dimension := 0; dimension != 64; dimension++ {
+		directions := sobolDirectionNumbers(dimension)
+		for m := 1; m <= 12; m++ {
+			occupied := make([]bool, 1<<m)
+			point := uint32(0)
+			for i := 0; i != 1<<m; i++ {
+				if i != 0 {
+					point ^= directions[bits.TrailingZeros32(uint32(i))]
+				}
+				interval := point >> (sobolBits - m)
+				if occupied[interval] {
+					t.Fatalf("dimension %d isn't stratified in %d intervals. Point %d duplicates interval %d",
+						dimension,
+						1<<m,
+						i,
+						interval)
+				}
+				occupied[interval] = true
+			}
+		}
+	}
+}
+
+// Scrambling preserves the stratification of the points
+func TestSobolScrambledStratification(t *testing.T) {
+	sampler, samplerErr := NewSampler(SAMPLING_SOBOL, rand.NewSource(42))
+	if samplerErr != nil {
+		t.Fatal(samplerErr)
+	}
+	const pointCount = 1024
+	for dimension := 0; dimension != 16; dimension++ {
+		sampler.dimension = dimension
+		values := make([]float64, pointCount)
+		sampler.sobolUniforms(values)
+		occupied := make([]bool, pointCount)
+		for i, eachValue := range values {
+			if eachValue <= 0 || eachValue >= 1 {
+				t.Fatalf("invalid uniform %d of dimension %d: %v. Expected a value in (0, 1)", i, dimension, eachValue)
+			}
+			interval := int(eachValue * pointCount)
+			if occupied[interval] {
+				t.Fatalf("scrambled dimension %d isn't stratified. Point %d duplicates interval %d", dimension, i, interval)
+			}
+			occupied[interval] = true
+		}
+	}
+}
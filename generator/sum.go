@@ -0,0 +1,58 @@
+package generator
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/exp/rand"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// SumGenerator
+//
+// Composite generator whose samples are the per-run sum of each of its
+// member generators. Used to attach risk events to existing activities.
+//
+// /////////////////////////////////////////////////////////////////////////////
+type SumGenerator struct {
+	BaseGenerator
+	Generators []DurationGenerator
+}
+
+func (sg *SumGenerator) Name() string {
+	names := make([]string, len(sg.Generators))
+	for i, eachGenerator := range sg.Generators {
+		names[i] = eachGenerator.Name()
+	}
+	return strings.Join(names, " + ")
+}
+
+func (sg *SumGenerator) Generate(priorSamples map[int64]*GenerationResults,
+	percentiles []float64,
+	src rand.Source,
+	log *slog.Logger) (*GenerationResults, error) {
+
+	if len(sg.Generators) <= 0 {
+		return nil, fmt.Errorf("no generators provided to SumGenerator")
+	}
+	if len(priorSamples) != 1 {
+		return nil, fmt.Errorf("invalid length for prior samples: %d", len(priorSamples))
+	}
+	var genResults *GenerationResults
+	for _, val := range priorSamples {
+		genResults = val
+	}
+	summedValues := make([]float64, len(*genResults.CumulativeValues))
+	for _, eachGenerator := range sg.Generators {
+		memberResults, memberResultsErr := eachGenerator.Generate(priorSamples, percentiles, src, log)
+		if memberResultsErr != nil {
+			return nil, memberResultsErr
+		}
+		memberValues := *memberResults.RawValues
+		for i := range summedValues {
+			summedValues[i] += memberValues[i]
+		}
+	}
+	return sg.computeAggregates(summedValues, *genResults.CumulativeValues, percentiles, log)
+}
@@ -3,8 +3,6 @@ package generator
 import (
 	"fmt"
 	"log/slog"
-	"regexp"
-	"strings"
 
 	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/stat/distuv"
@@ -56,41 +54,22 @@ func (tg *TriangularGenerator) Generate(priorSamples map[int64]*GenerationResult
 	return tg.BaseGenerator.Generate(generator, priorSamples, percentiles, log)
 }
 
-func UnmarshalTriangle(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
-	// Supported forms:
-	// Triangle(n)
-	// Triangle(min, mode, max)
-	tg := &TriangularGenerator{}
-	reParams := regexp.MustCompile(`[()]`)
-	pertParts := reParams.Split(typeParameter, -1)
-	if len(pertParts) < 2 {
-		return nil, fmt.Errorf("invalid PERT generator expression: %s", typeParameter)
-	}
-	pertFloatParts := strings.Split(pertParts[1], ",")
-	var floatErr error
-	if len(pertFloatParts) == 1 {
-		floatErr = tg.BaseGenerator.parseFloat(pertFloatParts[0], &tg.mode)
-		if floatErr != nil {
-			return nil, floatErr
-		}
-		tg.max = tg.mode
-		tg.min = tg.mode
-	} else if len(pertFloatParts) == 3 {
-		floatErr = tg.BaseGenerator.parseFloat(pertFloatParts[0], &tg.min)
-		if floatErr != nil {
-			return nil, floatErr
-		}
-		floatErr = tg.BaseGenerator.parseFloat(pertFloatParts[1], &tg.mode)
-		if floatErr != nil {
-			return nil, floatErr
-		}
-		floatErr = tg.BaseGenerator.parseFloat(pertFloatParts[2], &tg.max)
-		if floatErr != nil {
-			return nil, floatErr
-		}
-	} else {
-		return nil, fmt.Errorf("invalid PERT generator expression: %s", typeParameter)
+// triangleFactory returns the Triangle generator for the arguments.
+// Triangle(n) is the fixed value n.
+func triangleFactory(args *Arguments, _ *slog.Logger) (DurationGenerator, error) {
+	if args.Count == 1 {
+		return &FixedGenerator{
+			value: args.first("min", "mode", "max"),
+		}, nil
 	}
-	// Check the values
-	return tg, tg.Validate()
+	return &TriangularGenerator{
+		min:  args.Float("min"),
+		mode: args.Float("mode"),
+		max:  args.Float("max"),
+	}, nil
+}
+
+// UnmarshalTriangle returns the Triangle generator for the Triangle(...) expression
+func UnmarshalTriangle(typeParameter string, log *slog.Logger) (DurationGenerator, error) {
+	return unmarshalRegistered("Triangle", typeParameter, log)
 }
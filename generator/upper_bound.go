@@ -10,17 +10,43 @@ import (
 
 // /////////////////////////////////////////////////////////////////////////////
 type UpperBoundGenerator struct {
-	BaseGenerator
+	JoinBase
 }
 
 func (ubg *UpperBoundGenerator) Name() string {
 	return "UpperBoundGenerator"
 }
 
+func (ubg *UpperBoundGenerator) CriticalPredecessors(predecessorMeans map[int64]float64) []int64 {
+	return extremePredecessor(predecessorMeans, true)
+}
+
 func (ubg *UpperBoundGenerator) Unmarshal(typeParameter string, log *slog.Logger) error {
 	return fmt.Errorf("unmarshal not supported for UpperBoundGenerator")
 }
 
+func (ubg *UpperBoundGenerator) combine(priorValues [][]float64, _ []float64) []float64 {
+	// Extract the upper bound, then run that through the aggregation function
+	if len(priorValues) == 1 {
+		return priorValues[0]
+	}
+	// Use the first sample to determine the sample size
+	sampleSize := len(priorValues[0])
+	maxValues := make([]float64, sampleSize)
+	for sampleIdx := 0; sampleIdx != sampleSize; sampleIdx++ {
+		curMax := math.SmallestNonzeroFloat64
+		for priorSeriesIdx := 0; priorSeriesIdx != len(priorValues); priorSeriesIdx++ {
+			curMax = math.Max(curMax, priorValues[priorSeriesIdx][sampleIdx])
+		}
+		maxValues[sampleIdx] = curMax
+	}
+	return maxValues
+}
+
+func (ubg *UpperBoundGenerator) Rejoin(priorValues map[int64][]float64, entry []float64) ([]float64, error) {
+	return ubg.rejoin(ubg.combine, priorValues, entry)
+}
+
 func (ubg *UpperBoundGenerator) Generate(priorSamples map[int64]*GenerationResults,
 	percentiles []float64,
 	src rand.Source,
@@ -29,31 +55,10 @@ func (ubg *UpperBoundGenerator) Generate(priorSamples map[int64]*GenerationResul
 	if len(priorSamples) <= 0 {
 		return nil, fmt.Errorf("no slices provided to UpperBoundGenerator")
 	}
-	priorSampleKeys := make([]int64, len(priorSamples))
-	i := 0
-	for eachKey := range priorSamples {
-		priorSampleKeys[i] = eachKey
-		i++
-	}
-
-	// Extract the upper bound, then run that through the aggregation function
-	var maxValues []float64
-	if len(priorSampleKeys) == 1 {
-		maxValues = *priorSamples[priorSampleKeys[0]].RawValues
-	} else {
-		// Use the first sample to determine the sample size
-		sampleSize := len(*priorSamples[priorSampleKeys[0]].RawValues)
-		maxValues = make([]float64, sampleSize)
-		for sampleIdx := 0; sampleIdx != sampleSize; sampleIdx++ {
-			curMax := math.SmallestNonzeroFloat64
-			for priorSeriesIdx := 0; priorSeriesIdx != len(priorSampleKeys); priorSeriesIdx++ {
-				priorData := priorSamples[priorSampleKeys[priorSeriesIdx]]
-				priorRawValues := *priorData.RawValues
-				curMax = math.Max(curMax, priorRawValues[sampleIdx])
-			}
-			maxValues[sampleIdx] = curMax
-		}
+	priorValues, priorValuesErr := predecessorValues(priorSamples)
+	if priorValuesErr != nil {
+		return nil, priorValuesErr
 	}
-	generatorValues := make([]float64, len(maxValues))
-	return ubg.computeAggregates(generatorValues, maxValues, percentiles, log)
+	entry := entryValues(priorSamples)
+	return ubg.computeJoinAggregates(ubg.combine(priorValues, entry), entry, percentiles, src, log)
 }
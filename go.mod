@@ -7,8 +7,8 @@ require (
 	golang.org/x/exp v0.0.0-20240318143956-a85f2c67cd81
 	gonum.org/v1/gonum v0.15.0
 	gonum.org/v1/plot v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 	oss.terrastruct.com/d2 v0.6.3
-
 )
 
 require (
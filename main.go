@@ -4,6 +4,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path"
@@ -13,28 +14,298 @@ import (
 
 	"github.com/mweagle/goestimate/app"
 	"github.com/mweagle/goestimate/buildinfo"
+	"github.com/mweagle/goestimate/generator"
+	"github.com/mweagle/goestimate/plan"
+	"github.com/mweagle/goestimate/stats"
 	"oss.terrastruct.com/d2/d2themes/d2themescatalog"
 )
 
+// Process exit codes
+const (
+	exitCodeSuccess         = 0
+	exitCodeAssertionFailed = 1
+	exitCodePlanInvalid     = 2
+	exitCodeError           = 3
+)
+
+// stdioPath is the input or output path for stdin and stdout
+const stdioPath = "-"
+
+// //////////////////////////////////////////////////////////////////////////////
+// stringSliceFlag is a repeatable string flag
+type stringSliceFlag []string
+
+func (ssf *stringSliceFlag) String() string {
+	return strings.Join(*ssf, ", ")
+}
+
+func (ssf *stringSliceFlag) Set(value string) error {
+	*ssf = append(*ssf, value)
+	return nil
+}
+
 // //////////////////////////////////////////////////////////////////////////////
 // commandLineArgs
 type commandLineArgs struct {
+	command         *command
 	logLevelValue   int
 	inputFile       string
+	outputPath      string
 	outputDirectory string
+	outputs         []string
 	lightTheme      int64
 	darkTheme       int64
+	seed            uint64
+	runCount        uint64
+	workers         int
+	streaming       bool
+	sampling        string
+	intervals       string
+	sensitivity     string
+	assertions      stringSliceFlag
+	againstFile     string
+	planName        string
+	force           bool
+}
+
+// writesStdout returns true if the command writes its output to stdout, in
+// which case logging goes to stderr
+func (cla *commandLineArgs) writesStdout() bool {
+	return cla.outputPath == stdioPath
+}
+
+// runParams are the evaluation parameters shared by the commands that
+// evaluate a plan
+func (cla *commandLineArgs) runParams() *app.ApplicationFlowGraphParams {
+	return &app.ApplicationFlowGraphParams{
+		InputFile:       cla.inputFile,
+		OutputDirectory: cla.outputDirectory,
+		Outputs:         cla.outputs,
+		LightThemeID:    cla.lightTheme,
+		DarkThemeID:     cla.darkTheme,
+		EvaluateOptions: app.EvaluateOptions{
+			Seed:        cla.seed,
+			RunCount:    cla.runCount,
+			Workers:     cla.workers,
+			Streaming:   cla.streaming,
+			Sampling:    cla.sampling,
+			Intervals:   cla.intervals,
+			Sensitivity: cla.sensitivity,
+		},
+	}
+}
+
+// //////////////////////////////////////////////////////////////////////////////
+// command
+type command struct {
+	name        string
+	usage       string
+	description string
+	// inputRequired is true if the command requires the --input flag
+	inputRequired bool
+	defineFlags   func(flagSet *flag.FlagSet, cla *commandLineArgs)
+	execute       func(cla *commandLineArgs, logger *slog.Logger) int
+	// finalize validates the parsed flags
+	finalize func(cla *commandLineArgs) error
+}
+
+func defineInputFlag(flagSet *flag.FlagSet, cla *commandLineArgs) {
+	flagSet.StringVar(&cla.inputFile, "input", "", "Full filepath to definition to be evaluated, or - to read from stdin.")
+}
+
+func defineThemeFlags(flagSet *flag.FlagSet, cla *commandLineArgs) {
+	flagSet.Int64Var(&cla.lightTheme, "lightTheme", d2themescatalog.NeutralGrey.ID, "Light theme ID to use for generated SVG. Defaults to NeutralGrey.")
+	flagSet.Int64Var(&cla.darkTheme, "darkTheme", d2themescatalog.DarkMauve.ID, "Dark theme ID to use for generated SVG. Defaults to DarkMauve.")
+}
+
+func defineRunFlags(flagSet *flag.FlagSet, cla *commandLineArgs) {
+	flagSet.Uint64Var(&cla.seed, "seed", 0, "Seed for the random number source.")
+	flagSet.Uint64Var(&cla.runCount, "runs", 0, "Number of runs. Overrides the plan's runCount.")
+	flagSet.IntVar(&cla.workers, "workers", 0, "Number of concurrent evaluation workers. Defaults to the number of CPUs. Results don't depend on the number of workers.")
+	flagSet.StringVar(&cla.sampling, "sampling", "", fmt.Sprintf("Sampling strategy. Overrides the plan's sampling. One of: %v.", generator.SAMPLING_STRATEGIES))
+	flagSet.StringVar(&cla.intervals, "intervals", "", fmt.Sprintf("Confidence interval method of the mean and percentiles. Overrides the plan's intervals. One of: %v.", stats.INTERVAL_METHODS))
+	flagSet.StringVar(&cla.sensitivity, "sensitivity", "", fmt.Sprintf("Task sensitivity method. Overrides the plan's sensitivity. One of: %v.", app.SENSITIVITY_METHODS))
+	flagSet.BoolVar(&cla.streaming, "streaming", false, "Summarize the runs with quantile sketches rather than retaining every sample. Bounds memory for large run counts.")
+}
+
+var commands = []*command{
+	{
+		name:          "run",
+		usage:         "goestimate [run] --input=PLAN [--output=DIR|-] [--outputs=d2,svg,png,dot,json]",
+		description:   "Evaluate the plan and create the selected outputs. This is the default command.",
+		inputRequired: true,
+		defineFlags: func(flagSet *flag.FlagSet, cla *commandLineArgs) {
+			defineInputFlag(flagSet, cla)
+			flagSet.StringVar(&cla.outputPath, "output", "", "Path to output directory for created files, or - to write the single selected output to stdout. Defaults to inputFile parent directory.")
+			flagSet.Func("outputs", fmt.Sprintf("Comma separated outputs to create. Supported: %s. Defaults to: %s.",
+				strings.Join(app.SupportedOutputs, ","),
+				strings.Join(app.DefaultOutputs, ",")),
+				func(value string) error {
+					outputs, outputsErr := app.ParseOutputs(value)
+					cla.outputs = outputs
+					return outputsErr
+				})
+			defineRunFlags(flagSet, cla)
+			defineThemeFlags(flagSet, cla)
+		},
+		finalize: func(cla *commandLineArgs) error {
+			if cla.writesStdout() && len(cla.outputs) != 1 {
+				return errors.New("writing to stdout requires exactly one output. Ex: --outputs=json")
+			}
+			return nil
+		},
+		execute: runCommand,
+	},
+	{
+		name:          "validate",
+		usage:         "goestimate validate --input=PLAN",
+		description:   "Report every problem with the plan without evaluating it.",
+		inputRequired: true,
+		defineFlags:   defineInputFlag,
+		execute:       validateCommand,
+	},
+	{
+		name:          "check",
+		usage:         "goestimate check --input=PLAN [--assert=EXPR ...]",
+		description:   "Evaluate the plan without rendering it and verify its assertions.",
+		inputRequired: true,
+		defineFlags: func(flagSet *flag.FlagSet, cla *commandLineArgs) {
+			defineInputFlag(flagSet, cla)
+			flagSet.Var(&cla.assertions, "assert", "Assertion to check, in addition to the plan's assertions. Ex: \"p85 <= 2027-03-01\". May be repeated.")
+			defineRunFlags(flagSet, cla)
+		},
+		execute: checkCommand,
+	},
+	{
+		name:          "render",
+		usage:         "goestimate render --input=D2 [--output=SVG|-]",
+		description:   "Render D2 source, such as the d2 output of run, as an SVG.",
+		inputRequired: true,
+		defineFlags: func(flagSet *flag.FlagSet, cla *commandLineArgs) {
+			flagSet.StringVar(&cla.inputFile, "input", "", "Full filepath to the D2 source, or - to read from stdin.")
+			flagSet.StringVar(&cla.outputPath, "output", "", "Path to the SVG, or - to write to stdout. Defaults to the input path with an .svg extension, or stdout if reading from stdin.")
+			defineThemeFlags(flagSet, cla)
+		},
+		finalize: func(cla *commandLineArgs) error {
+			if len(cla.outputPath) != 0 {
+				return nil
+			}
+			if cla.inputFile == stdioPath {
+				cla.outputPath = stdioPath
+			} else {
+				cla.outputPath = strings.TrimSuffix(cla.inputFile, filepath.Ext(cla.inputFile)) + ".svg"
+			}
+			return nil
+		},
+		execute: renderCommand,
+	},
+	{
+		name:          "fit",
+		usage:         "goestimate fit --input=SAMPLES",
+		description:   "Propose task generators for historical durations. Samples are numbers separated by commas or whitespace.",
+		inputRequired: true,
+		defineFlags: func(flagSet *flag.FlagSet, cla *commandLineArgs) {
+			flagSet.StringVar(&cla.inputFile, "input", "", "Full filepath to the samples, or - to read from stdin.")
+		},
+		execute: fitCommand,
+	},
+	{
+		name:          "diff",
+		usage:         "goestimate diff --input=BASELINE --against=PROPOSED",
+		description:   "Evaluate two plans with the same seed and compare their total durations.",
+		inputRequired: true,
+		defineFlags: func(flagSet *flag.FlagSet, cla *commandLineArgs) {
+			flagSet.StringVar(&cla.inputFile, "input", "", "Full filepath to the baseline plan, or - to read from stdin.")
+			flagSet.StringVar(&cla.againstFile, "against", "", "Full filepath to the proposed plan.")
+			defineRunFlags(flagSet, cla)
+		},
+		finalize: func(cla *commandLineArgs) error {
+			if len(cla.againstFile) <= 0 {
+				return errors.New("empty against path provided")
+			}
+			if cla.againstFile == stdioPath && cla.inputFile == stdioPath {
+				return errors.New("only one plan can be read from stdin")
+			}
+			if cla.againstFile == stdioPath {
+				return nil
+			}
+			absPath, absPathErr := filepath.Abs(cla.againstFile)
+			cla.againstFile = absPath
+			return absPathErr
+		},
+		execute: diffCommand,
+	},
+	{
+		name:        "init",
+		usage:       "goestimate init [--output=PLAN|-] [--name=NAME] [--force]",
+		description: "Create a starter plan.",
+		defineFlags: func(flagSet *flag.FlagSet, cla *commandLineArgs) {
+			flagSet.StringVar(&cla.outputPath, "output", stdioPath, "Path to the plan to create, or - to write to stdout.")
+			flagSet.StringVar(&cla.planName, "name", "My Project", "Name of the plan.")
+			flagSet.BoolVar(&cla.force, "force", false, "Overwrite an existing plan.")
+		},
+		execute: initCommand,
+	},
+}
+
+// commandNamed returns the command with the given name
+func commandNamed(name string) *command {
+	for _, eachCommand := range commands {
+		if eachCommand.name == name {
+			return eachCommand
+		}
+	}
+	return nil
 }
 
-func (cla *commandLineArgs) parseCommandLine(_ *slog.Logger) error {
+// writeUsage writes the list of commands
+func writeUsage(output io.Writer) {
+	fmt.Fprintf(output, "Usage: goestimate [command] [flags]\n\nCommands:\n")
+	for _, eachCommand := range commands {
+		fmt.Fprintf(output, "  %-10s%s\n", eachCommand.name, eachCommand.description)
+	}
+	fmt.Fprintf(output, "\nRun `goestimate help COMMAND` or `goestimate COMMAND --help` for the command's flags.\n")
+}
+
+// newFlagSet returns the command's flags
+func (cmd *command) newFlagSet(cla *commandLineArgs, logLevelString *string) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flagSet.StringVar(logLevelString, "level", "INFO", "Logging verbosity level. Must be one of: {DEBUG, INFO, WARN, ERROR}.")
+	cmd.defineFlags(flagSet, cla)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "Usage: %s\n\n%s\n\nFlags:\n", cmd.usage, cmd.description)
+		flagSet.PrintDefaults()
+	}
+	return flagSet
+}
+
+func (cla *commandLineArgs) parseCommandLine(args []string, _ *slog.Logger) error {
 	logLevelString := ""
 
-	flag.StringVar(&logLevelString, "level", "INFO", "Logging verbosity level. Must be one of: {DEBUG, INFO, WARN, ERROR}.")
-	flag.StringVar(&cla.inputFile, "input", "", "Full filepath to definition to be evaluated.")
-	flag.StringVar(&cla.outputDirectory, "output", "", "Path to output directory for created files. Defaults to inputFile parent directory.")
-	flag.Int64Var(&cla.lightTheme, "lightTheme", d2themescatalog.NeutralGrey.ID, "Light theme ID to use for generated SVG. Defaults to NeutralGrey.")
-	flag.Int64Var(&cla.darkTheme, "darkTheme", d2themescatalog.DarkMauve.ID, "Light theme ID to use for generated SVG. Defaults to DarkMauve.")
-	flag.Parse()
+	// The optional first argument is the command
+	commandName := "run"
+	if len(args) != 0 && !strings.HasPrefix(args[0], "-") {
+		commandName = args[0]
+		args = args[1:]
+	}
+	if commandName == "help" {
+		if len(args) != 0 && commandNamed(args[0]) != nil {
+			commandNamed(args[0]).newFlagSet(cla, &logLevelString).Usage()
+		} else {
+			writeUsage(os.Stderr)
+		}
+		return flag.ErrHelp
+	}
+	cla.command = commandNamed(commandName)
+	if cla.command == nil {
+		writeUsage(os.Stderr)
+		return fmt.Errorf("unsupported command: %s", commandName)
+	}
+	flagSet := cla.command.newFlagSet(cla, &logLevelString)
+	parseErr := flagSet.Parse(args)
+	if parseErr != nil {
+		return parseErr
+	}
 
 	// Parse the verbosity level
 	switch strings.ToLower(logLevelString) {
@@ -49,20 +320,218 @@ func (cla *commandLineArgs) parseCommandLine(_ *slog.Logger) error {
 	default:
 		return fmt.Errorf("invalid log level specified: %s", logLevelString)
 	}
-	if len(cla.inputFile) <= 0 {
-		return errors.New("empty inputFile path provided")
-	}
-	absPath, absPathErr := filepath.Abs(cla.inputFile)
-	if absPathErr != nil {
-		return absPathErr
+	if cla.command.inputRequired {
+		if len(cla.inputFile) <= 0 {
+			return errors.New("empty inputFile path provided")
+		}
+		if cla.inputFile != stdioPath {
+			absPath, absPathErr := filepath.Abs(cla.inputFile)
+			if absPathErr != nil {
+				return absPathErr
+			}
+			cla.inputFile = absPath
+		}
 	}
-	cla.inputFile = absPath
+	// Outputs are created next to the input unless an output directory
+	// is provided
+	cla.outputDirectory = cla.outputPath
 	if len(cla.outputDirectory) <= 0 {
-		cla.outputDirectory = path.Dir(cla.inputFile)
+		cla.outputDirectory = "."
+		if cla.inputFile != stdioPath {
+			cla.outputDirectory = path.Dir(cla.inputFile)
+		}
+	}
+	if cla.command.finalize != nil {
+		return cla.command.finalize(cla)
 	}
 	return nil
 }
 
+// exitCode returns the process exit code for an error
+func exitCode(err error) int {
+	var invalidPlanErr *app.InvalidPlanError
+	if errors.As(err, &invalidPlanErr) {
+		return exitCodePlanInvalid
+	}
+	return exitCodeError
+}
+
+// logError logs the error. Plan errors are logged one per line with their
+// location.
+func logError(logger *slog.Logger, msg string, err error) {
+	planErrs := plan.AsErrors(err)
+	if planErrs == nil {
+		logger.Error(msg, "error", err)
+		return
+	}
+	for _, eachErr := range planErrs {
+		logger.Error(msg, "location", eachErr.Location(), "error", eachErr.Message)
+	}
+}
+
+// runCommand evaluates the plan and writes the selected artifacts. A single
+// artifact written to stdout is created in a temporary directory first.
+func runCommand(cla *commandLineArgs, logger *slog.Logger) int {
+	params := cla.runParams()
+	if cla.writesStdout() {
+		tempDir, tempDirErr := os.MkdirTemp("", "goestimate")
+		if tempDirErr != nil {
+			logger.Error("Failed to create temporary directory", "error", tempDirErr)
+			return exitCodeError
+		}
+		defer os.RemoveAll(tempDir)
+		params.OutputDirectory = tempDir
+	}
+	_, err := app.NewApplicationFlowGraph(params, logger)
+	if err != nil {
+		logError(logger, "Failed to create graph", err)
+		return exitCode(err)
+	}
+	if cla.writesStdout() {
+		outputBytes, outputBytesErr := os.ReadFile(params.OutputPath(params.Outputs[0]))
+		if outputBytesErr == nil {
+			_, outputBytesErr = os.Stdout.Write(outputBytes)
+		}
+		if outputBytesErr != nil {
+			logger.Error("Failed to write output", "error", outputBytesErr)
+			return exitCodeError
+		}
+	}
+	logger.Info("goestimate generated")
+	return exitCodeSuccess
+}
+
+// checkCommand evaluates the plan and verifies its assertions
+func checkCommand(cla *commandLineArgs, logger *slog.Logger) int {
+	report, err := app.CheckApplicationFlowGraph(cla.runParams(), cla.assertions, logger)
+	if err != nil {
+		logError(logger, "Failed to check graph", err)
+		return exitCode(err)
+	}
+	writeErr := report.WriteTable(os.Stdout)
+	if writeErr != nil {
+		logger.Error("Failed to write check results", "error", writeErr)
+		return exitCodeError
+	}
+	if !report.Passed() {
+		return exitCodeAssertionFailed
+	}
+	return exitCodeSuccess
+}
+
+// validateCommand reports every problem with the plan without evaluating it
+func validateCommand(cla *commandLineArgs, logger *slog.Logger) int {
+	report, err := app.ValidatePlan(cla.inputFile, logger)
+	if err != nil {
+		logger.Error("Failed to validate graph", "error", err)
+		return exitCode(err)
+	}
+	writeErr := report.WriteTable(os.Stdout)
+	if writeErr != nil {
+		logger.Error("Failed to write validation results", "error", writeErr)
+		return exitCodeError
+	}
+	if report.HasErrors() {
+		return exitCodePlanInvalid
+	}
+	return exitCodeSuccess
+}
+
+// renderCommand renders D2 source as an SVG
+func renderCommand(cla *commandLineArgs, logger *slog.Logger) int {
+	var d2Source []byte
+	var readErr error
+	if cla.inputFile == stdioPath {
+		d2Source, readErr = io.ReadAll(os.Stdin)
+	} else {
+		d2Source, readErr = os.ReadFile(cla.inputFile)
+	}
+	if readErr != nil {
+		logger.Error("Failed to read D2 source", "error", readErr)
+		return exitCodeError
+	}
+	svgBytes, svgBytesErr := app.RenderSVG(string(d2Source), cla.lightTheme, cla.darkTheme, logger)
+	if svgBytesErr != nil {
+		logger.Error("Failed to render D2 source", "error", svgBytesErr)
+		return exitCodeError
+	}
+	var writeErr error
+	if cla.writesStdout() {
+		_, writeErr = os.Stdout.Write(svgBytes)
+	} else {
+		writeErr = os.WriteFile(cla.outputPath, svgBytes, 0644)
+		logger.Info("Created SVG", "path", cla.outputPath)
+	}
+	if writeErr != nil {
+		logger.Error("Failed to write SVG", "error", writeErr)
+		return exitCodeError
+	}
+	return exitCodeSuccess
+}
+
+// fitCommand proposes generators for historical durations
+func fitCommand(cla *commandLineArgs, logger *slog.Logger) int {
+	report, err := app.FitSamples(cla.inputFile, logger)
+	if err != nil {
+		logger.Error("Failed to fit samples", "error", err)
+		return exitCodeError
+	}
+	logger.Info("Samples",
+		"count", report.SampleCount,
+		"mean", fmt.Sprintf("%.2f", report.Mean),
+		"stddev", fmt.Sprintf("%.2f", report.StdDev))
+	writeErr := report.WriteTable(os.Stdout)
+	if writeErr != nil {
+		logger.Error("Failed to write fit results", "error", writeErr)
+		return exitCodeError
+	}
+	return exitCodeSuccess
+}
+
+// diffCommand compares the total durations of two plans
+func diffCommand(cla *commandLineArgs, logger *slog.Logger) int {
+	report, err := app.DiffApplicationFlowGraphs(cla.runParams(), cla.againstFile, logger)
+	if err != nil {
+		logError(logger, "Failed to diff graphs", err)
+		return exitCode(err)
+	}
+	logger.Info("Diff", "baseline", report.BaselineName, "proposed", report.ProposedName)
+	writeErr := report.WriteTable(os.Stdout)
+	if writeErr != nil {
+		logger.Error("Failed to write diff results", "error", writeErr)
+		return exitCodeError
+	}
+	return exitCodeSuccess
+}
+
+// initCommand creates a starter plan
+func initCommand(cla *commandLineArgs, logger *slog.Logger) int {
+	planBytes, planBytesErr := app.StarterPlan(cla.planName)
+	if planBytesErr != nil {
+		logger.Error("Failed to create plan", "error", planBytesErr)
+		return exitCodeError
+	}
+	if cla.writesStdout() {
+		_, writeErr := os.Stdout.Write(planBytes)
+		if writeErr != nil {
+			logger.Error("Failed to write plan", "error", writeErr)
+			return exitCodeError
+		}
+		return exitCodeSuccess
+	}
+	if _, statErr := os.Stat(cla.outputPath); statErr == nil && !cla.force {
+		logger.Error("Plan already exists. Use --force to overwrite it", "path", cla.outputPath)
+		return exitCodeError
+	}
+	writeErr := os.WriteFile(cla.outputPath, planBytes, 0644)
+	if writeErr != nil {
+		logger.Error("Failed to write plan", "error", writeErr)
+		return exitCodeError
+	}
+	logger.Info("Created plan", "path", cla.outputPath)
+	return exitCodeSuccess
+}
+
 // //////////////////////////////////////////////////////////////////////////////
 //
 // _ __  __ _(_)_ _
@@ -77,26 +546,23 @@ func main() {
 		Level: lvl,
 	}))
 	cla := commandLineArgs{}
-	parseError := cla.parseCommandLine(logger)
+	parseError := cla.parseCommandLine(os.Args[1:], logger)
+	if errors.Is(parseError, flag.ErrHelp) {
+		os.Exit(exitCodeSuccess)
+	}
 	if parseError != nil {
 		logger.Error("Failed to parse command line arguments", "error", parseError)
-		os.Exit(-1)
+		os.Exit(exitCodeError)
 	}
 	lvl.Set(slog.Level(cla.logLevelValue))
+	// Keep stdout clean for piped output
+	if cla.writesStdout() {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: lvl,
+		}))
+	}
 	logger.Info("Welcome to goestimate!",
 		"version", buildinfo.BuildInfo(),
 		"go", runtime.Version())
-
-	params := &app.ApplicationFlowGraphParams{
-		InputFile:       cla.inputFile,
-		OutputDirectory: cla.outputDirectory,
-		CreateDot:       true,
-		LightThemeID:    cla.lightTheme,
-		DarkThemeID:     cla.darkTheme,
-	}
-	_, err := app.NewApplicationFlowGraph(params, logger)
-	if err != nil {
-		logger.Error("Failed to create graph", "error", err)
-	}
-	logger.Info("goestimate generated")
+	os.Exit(cla.command.execute(&cla, logger))
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const exitCodesPlan = `{
+	"name": "Exit Codes",
+	"runCount": 1000,
+	"activities": {
+		"tasks": [{"name": "Build", "type": "Fixed(10)"}]
+	}
+}`
+
+// runCommandLine executes the command line and returns its exit code
+func runCommandLine(t *testing.T, args ...string) int {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cla := commandLineArgs{}
+	parseErr := cla.parseCommandLine(args, logger)
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+	return cla.command.execute(&cla, logger)
+}
+
+func writePlan(t *testing.T, planJSON string) string {
+	t.Helper()
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	writeErr := os.WriteFile(planPath, []byte(planJSON), 0644)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	return planPath
+}
+
+func TestExitCodes(t *testing.T) {
+	planPath := writePlan(t, exitCodesPlan)
+	emptyPath := writePlan(t, `{"name": "Empty", "runCount": 1000, "activities": {}}`)
+	// Outputs can't be written below a regular file
+	outputFile := writePlan(t, exitCodesPlan)
+	testCases := map[string]struct {
+		args     []string
+		exitCode int
+	}{
+		"assertions pass": {
+			args:     []string{"check", "--input=" + planPath, "--assert=p50 <= 10"},
+			exitCode: exitCodeSuccess,
+		},
+		"assertion fails": {
+			args:     []string{"check", "--input=" + planPath, "--assert=p50 < 10"},
+			exitCode: exitCodeAssertionFailed,
+		},
+		"percentile out of range": {
+			args:     []string{"check", "--input=" + planPath, "--assert=p101 < 3"},
+			exitCode: exitCodePlanInvalid,
+		},
+		"invalid assertion": {
+			args:     []string{"check", "--input=" + planPath, "--assert=p50 ~ 3"},
+			exitCode: exitCodePlanInvalid,
+		},
+		"empty activities": {
+			args:     []string{"check", "--input=" + emptyPath, "--assert=p50 < 10"},
+			exitCode: exitCodePlanInvalid,
+		},
+		"invalid plan validation": {
+			args:     []string{"validate", "--input=" + emptyPath},
+			exitCode: exitCodePlanInvalid,
+		},
+		"unwritable output": {
+			args:     []string{"run", "--input=" + planPath, "--outputs=json", "--output=" + filepath.Join(outputFile, "out")},
+			exitCode: exitCodeError,
+		},
+	}
+	for name, eachCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			exitCode := runCommandLine(t, eachCase.args...)
+			if exitCode != eachCase.exitCode {
+				t.Errorf("invalid exit code. Expected: %d, Found: %d", eachCase.exitCode, exitCode)
+			}
+		})
+	}
+}
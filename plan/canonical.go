@@ -0,0 +1,88 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Canonical
+//
+// The canonical document for a plan. Keys are in model field order,
+// activities are in entry order and values use their most compact
+// representation, so equal plans always serialize identically.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// EncodeJSON writes the canonical, indented, JSON document
+func EncodeJSON(p *Plan, output io.Writer) error {
+	compactBytes, compactBytesErr := json.Marshal(p)
+	if compactBytesErr != nil {
+		return compactBytesErr
+	}
+	var buffer bytes.Buffer
+	indentErr := json.Indent(&buffer, compactBytes, "", "    ")
+	if indentErr != nil {
+		return indentErr
+	}
+	buffer.WriteByte('\n')
+	_, writeErr := buffer.WriteTo(output)
+	return writeErr
+}
+
+// EncodeYAML writes the canonical YAML document. The document has the same
+// structure and order as the JSON document.
+func EncodeYAML(p *Plan, output io.Writer) error {
+	jsonBytes, jsonBytesErr := json.Marshal(p)
+	if jsonBytesErr != nil {
+		return jsonBytesErr
+	}
+	doc, docErr := ParseDocument("", jsonBytes)
+	if docErr != nil {
+		return docErr
+	}
+	encoder := yaml.NewEncoder(output)
+	encoder.SetIndent(2)
+	encodeErr := encoder.Encode(yamlNode(doc.Root))
+	if encodeErr != nil {
+		return encodeErr
+	}
+	return encoder.Close()
+}
+
+// yamlNode converts the document node, preserving the key order
+func yamlNode(node *Node) *yaml.Node {
+	switch node.Kind {
+	case KindObject:
+		mapping := &yaml.Node{Kind: yaml.MappingNode}
+		for _, eachKey := range node.Keys {
+			mapping.Content = append(mapping.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: eachKey},
+				yamlNode(node.Fields[eachKey]))
+		}
+		return mapping
+	case KindArray:
+		sequence := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, eachItem := range node.Items {
+			sequence.Content = append(sequence.Content, yamlNode(eachItem))
+		}
+		return sequence
+	case KindString:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: node.Value.(string)}
+	case KindNumber:
+		numberValue := node.Value.(float64)
+		tag := "!!float"
+		if numberValue == math.Trunc(numberValue) {
+			tag = "!!int"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: strconv.FormatFloat(numberValue, 'f', -1, 64)}
+	case KindBool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(node.Value.(bool))}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
@@ -0,0 +1,512 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Decode
+//
+// Strict, reflective decoding of a Document into the typed model. Decoding
+// doesn't stop at the first error: every error is accumulated so that a plan
+// can be fixed in one pass.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+var sourceType = reflect.TypeOf(Source{})
+
+// nodeDecoder is implemented by model types whose document representation
+// isn't a plain object
+type nodeDecoder interface {
+	decodeNode(d *decoder, node *Node)
+}
+
+// nodeValidator is implemented by model types that constrain their
+// decoded values
+type nodeValidator interface {
+	validateNode(d *decoder, node *Node)
+}
+
+type decoder struct {
+	errs Errors
+	// suppressed are the rule IDs suppressed by the enclosing objects. Only
+	// unknown keys can be suppressed while decoding.
+	suppressed []string
+}
+
+// Decode parses and strictly decodes a plan document. The filename is only
+// used to describe error locations. When the document is valid JSON the
+// partially decoded plan is returned together with any Errors.
+func Decode(filename string, data []byte) (*Plan, error) {
+	doc, docErr := ParseDocument(filename, data)
+	if docErr != nil {
+		return nil, AsErrors(docErr)
+	}
+	return DecodeDocument(doc)
+}
+
+// DecodeDocument strictly decodes a parsed plan document
+func DecodeDocument(doc *Document) (*Plan, error) {
+	d := &decoder{}
+	parsedPlan := &Plan{}
+	d.decode(doc.Root, reflect.ValueOf(parsedPlan).Elem())
+	if len(d.errs) != 0 {
+		return parsedPlan, d.errs
+	}
+	return parsedPlan, nil
+}
+
+func (d *decoder) addError(err *Error) {
+	if err.Code == CodeUnknownKey && slices.Contains(d.suppressed, err.Code) {
+		return
+	}
+	d.errs = append(d.errs, err)
+}
+
+func (d *decoder) expectKind(node *Node, kind Kind) bool {
+	if node.Kind != kind {
+		d.addError(node.Errorf(CodeInvalidType, "expected %s, found %s", kind, node.Kind))
+		return false
+	}
+	return true
+}
+
+func (d *decoder) decode(node *Node, target reflect.Value) {
+	customDecoder, customDecoderOk := target.Addr().Interface().(nodeDecoder)
+	if customDecoderOk {
+		customDecoder.decodeNode(d, node)
+		return
+	}
+	switch target.Kind() {
+	case reflect.Pointer:
+		if node.Kind == KindNull {
+			return
+		}
+		elem := reflect.New(target.Type().Elem())
+		d.decode(node, elem.Elem())
+		target.Set(elem)
+	case reflect.String:
+		if d.expectKind(node, KindString) {
+			target.SetString(node.Value.(string))
+		}
+	case reflect.Bool:
+		if d.expectKind(node, KindBool) {
+			target.SetBool(node.Value.(bool))
+		}
+	case reflect.Float64:
+		if d.expectKind(node, KindNumber) {
+			target.SetFloat(node.Value.(float64))
+		}
+	case reflect.Uint64:
+		if d.expectKind(node, KindNumber) {
+			floatVal := node.Value.(float64)
+			if floatVal < 0 || floatVal != math.Trunc(floatVal) {
+				d.addError(node.Errorf(CodeInvalidValue, "expected a non-negative integer, found %v", floatVal))
+				return
+			}
+			target.SetUint(uint64(floatVal))
+		}
+	case reflect.Slice:
+		if !d.expectKind(node, KindArray) {
+			return
+		}
+		slice := reflect.MakeSlice(target.Type(), len(node.Items), len(node.Items))
+		for i, eachItem := range node.Items {
+			d.decode(eachItem, slice.Index(i))
+		}
+		target.Set(slice)
+	case reflect.Map:
+		if !d.expectKind(node, KindObject) {
+			return
+		}
+		mapVal := reflect.MakeMapWithSize(target.Type(), len(node.Keys))
+		for _, eachKey := range node.Keys {
+			elem := reflect.New(target.Type().Elem()).Elem()
+			d.decode(node.Fields[eachKey], elem)
+			mapVal.SetMapIndex(reflect.ValueOf(eachKey).Convert(target.Type().Key()), elem)
+		}
+		target.Set(mapVal)
+	case reflect.Struct:
+		d.decodeStruct(node, target)
+	default:
+		d.addError(node.Errorf(CodeInvalidType, "unsupported model type: %s", target.Type()))
+	}
+}
+
+// decodeStruct decodes an object into a model struct. Objects may include a
+// `suppress` array of rule IDs that are suppressed for the object and every
+// value nested within it.
+func (d *decoder) decodeStruct(node *Node, target reflect.Value) {
+	if !d.expectKind(node, KindObject) {
+		return
+	}
+	fields := modelFields(target.Type())
+
+	enclosingSuppressed := d.suppressed
+	defer func() {
+		d.suppressed = enclosingSuppressed
+	}()
+	suppressNode := node.Field("suppress")
+	if suppressNode != nil && suppressNode.Kind == KindArray {
+		for _, eachItem := range suppressNode.Items {
+			if eachItem.Kind == KindString {
+				d.suppressed = append(slices.Clone(d.suppressed), eachItem.Value.(string))
+			}
+		}
+	}
+
+	for _, eachKey := range node.Keys {
+		field, fieldOk := fields.byName[eachKey]
+		if !fieldOk {
+			d.addError(node.Fields[eachKey].Errorf(CodeUnknownKey, "%s", fields.unknownKeyMessage(eachKey)))
+			continue
+		}
+		d.decode(node.Fields[eachKey], target.FieldByIndex(field.index))
+	}
+	for _, eachField := range fields.list {
+		_, keyExists := node.Fields[eachField.name]
+		if eachField.required && !keyExists {
+			d.addError(node.Errorf(CodeMissingValue, "missing required key %q", eachField.name))
+		}
+	}
+	if fields.source != nil {
+		target.FieldByIndex(fields.source).Set(reflect.ValueOf(Source{node: node}))
+	}
+	validator, validatorOk := target.Addr().Interface().(nodeValidator)
+	if validatorOk {
+		validator.validateNode(d, node)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// Model fields
+// /////////////////////////////////////////////////////////////////////////////
+
+type modelField struct {
+	name        string
+	index       []int
+	required    bool
+	description string
+	fieldType   reflect.Type
+}
+
+type modelFieldSet struct {
+	list   []*modelField
+	byName map[string]*modelField
+	names  []string
+	// source is the index of the embedded Source, if any
+	source []int
+}
+
+// modelFields returns the document fields of a model struct, in
+// declaration order
+func modelFields(structType reflect.Type) *modelFieldSet {
+	fieldSet := &modelFieldSet{
+		byName: make(map[string]*modelField),
+	}
+	for i := 0; i != structType.NumField(); i++ {
+		structField := structType.Field(i)
+		if structField.Type == sourceType {
+			fieldSet.source = structField.Index
+			continue
+		}
+		jsonName, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+		if !structField.IsExported() || jsonName == "-" || len(jsonName) <= 0 {
+			continue
+		}
+		field := &modelField{
+			name:        jsonName,
+			index:       structField.Index,
+			required:    structField.Tag.Get("plan") == "required",
+			description: structField.Tag.Get("description"),
+			fieldType:   structField.Type,
+		}
+		fieldSet.list = append(fieldSet.list, field)
+		fieldSet.byName[jsonName] = field
+		fieldSet.names = append(fieldSet.names, jsonName)
+	}
+	return fieldSet
+}
+
+// unknownKeyMessage describes an unknown key, suggesting the supported key
+// that only differs by case
+func (mfs *modelFieldSet) unknownKeyMessage(key string) string {
+	for _, eachName := range mfs.names {
+		if strings.EqualFold(eachName, key) {
+			return fmt.Sprintf("unknown key %q. Did you mean %q?", key, eachName)
+		}
+	}
+	return fmt.Sprintf("unknown key %q. Supported keys: %s", key, strings.Join(mfs.names, ", "))
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// Custom decoders
+// /////////////////////////////////////////////////////////////////////////////
+
+func (a *Activities) decodeNode(d *decoder, node *Node) {
+	if !d.expectKind(node, KindObject) {
+		return
+	}
+	a.Source = Source{node: node}
+	for _, eachKey := range node.Keys {
+		entryNode := node.Fields[eachKey]
+		entry := &ActivityEntry{
+			Key: eachKey,
+		}
+		switch entryNode.Kind {
+		case KindArray:
+			entry.Serial = make([]*Task, 0, len(entryNode.Items))
+			for _, eachItem := range entryNode.Items {
+				task := &Task{}
+				d.decode(eachItem, reflect.ValueOf(task).Elem())
+				entry.Serial = append(entry.Serial, task)
+			}
+		case KindObject:
+			_, nameExists := entryNode.Fields["name"]
+			_, activitiesExist := entryNode.Fields["activities"]
+			_, branchesExist := entryNode.Fields["branches"]
+			if nameExists && branchesExist {
+				entry.Choice = &Choice{}
+				d.decode(entryNode, reflect.ValueOf(entry.Choice).Elem())
+			} else if nameExists && activitiesExist {
+				entry.Subgraph = &Subgraph{}
+				d.decode(entryNode, reflect.ValueOf(entry.Subgraph).Elem())
+			} else {
+				entry.Parallel = &Parallel{}
+				d.decode(entryNode, reflect.ValueOf(entry.Parallel).Elem())
+			}
+		default:
+			d.addError(entryNode.Errorf(CodeInvalidType,
+				"expected an array of serial tasks or an object, found %s", entryNode.Kind))
+			continue
+		}
+		a.Entries = append(a.Entries, entry)
+	}
+}
+
+func (p *Parallel) decodeNode(d *decoder, node *Node) {
+	if !d.expectKind(node, KindObject) {
+		return
+	}
+	p.Source = Source{node: node}
+	for _, eachKey := range node.Keys {
+		if eachKey == "join" {
+			d.decode(node.Fields[eachKey], reflect.ValueOf(&p.Join).Elem())
+			continue
+		}
+		task := &Task{
+			Key: eachKey,
+		}
+		d.decode(node.Fields[eachKey], reflect.ValueOf(task).Elem())
+		p.Tasks = append(p.Tasks, task)
+	}
+}
+
+func (tr *TaskResources) decodeNode(d *decoder, node *Node) {
+	resources := make(TaskResources)
+	switch node.Kind {
+	case KindArray:
+		for _, eachItem := range node.Items {
+			if d.expectKind(eachItem, KindString) {
+				resources[eachItem.Value.(string)]++
+			}
+		}
+	case KindObject:
+		for _, eachKey := range node.Keys {
+			unitsNode := node.Fields[eachKey]
+			if !d.expectKind(unitsNode, KindNumber) {
+				continue
+			}
+			units := unitsNode.Value.(float64)
+			if units <= 0 {
+				d.addError(unitsNode.Errorf(CodeInvalidValue, "resource units must be positive, found %v", units))
+				continue
+			}
+			resources[eachKey] = units
+		}
+	default:
+		d.addError(node.Errorf(CodeInvalidType,
+			"expected an array of resource names or an object of units, found %s", node.Kind))
+		return
+	}
+	*tr = resources
+}
+
+func (c *Cost) decodeNode(d *decoder, node *Node) {
+	c.Source = Source{node: node}
+	switch node.Kind {
+	case KindNumber:
+		c.Fixed = node.Value.(float64)
+	case KindString:
+		c.Type = node.Value.(string)
+	case KindObject:
+		d.decodeStruct(node, reflect.ValueOf(c).Elem())
+	default:
+		d.addError(node.Errorf(CodeInvalidType,
+			"expected a number, a generator expression or an object, found %s", node.Kind))
+	}
+}
+
+func (rc *RunCount) decodeNode(d *decoder, node *Node) {
+	rc.Source = Source{node: node}
+	switch node.Kind {
+	case KindNumber:
+		floatVal := node.Value.(float64)
+		if floatVal <= 0 || floatVal != math.Trunc(floatVal) {
+			d.addError(node.Errorf(CodeInvalidValue, "runCount must be a positive integer, found %v", floatVal))
+			return
+		}
+		rc.Count = uint64(floatVal)
+	case KindString:
+		if node.Value.(string) != RunCountAuto {
+			d.addError(node.Errorf(CodeInvalidValue, "expected a run count or %q, found %q", RunCountAuto, node.Value))
+			return
+		}
+		rc.Auto = true
+	case KindObject:
+		rc.Auto = true
+		d.decodeStruct(node, reflect.ValueOf(rc).Elem())
+	default:
+		d.addError(node.Errorf(CodeInvalidType,
+			"expected a run count, %q or an object, found %s", RunCountAuto, node.Kind))
+	}
+}
+
+func (dl *Deadline) decodeNode(d *decoder, node *Node) {
+	dl.Source = Source{node: node}
+	switch node.Kind {
+	case KindNumber:
+		dl.Duration = node.Value.(float64)
+		if dl.Duration <= 0 {
+			d.addError(node.Errorf(CodeInvalidValue, "deadline must be positive, found %v", dl.Duration))
+		}
+	case KindString:
+		dl.Date = node.Value.(string)
+		_, dateErr := time.Parse(DateFormat, dl.Date)
+		if dateErr != nil {
+			d.addError(node.Errorf(CodeInvalidValue, "expected a %s date, found %q", DateFormat, dl.Date))
+		}
+	default:
+		d.addError(node.Errorf(CodeInvalidType,
+			"expected a duration or a %s date, found %s", DateFormat, node.Kind))
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// Validators
+// /////////////////////////////////////////////////////////////////////////////
+
+func (p *Plan) validateNode(d *decoder, node *Node) {
+	percentilesNode := node.Field("percentiles")
+	for i, eachPercentile := range p.Percentiles {
+		// Mistyped percentiles were reported while decoding
+		if percentilesNode.Items[i].Kind != KindNumber {
+			continue
+		}
+		if eachPercentile <= 0 || eachPercentile >= 100 {
+			d.addError(percentilesNode.Items[i].Errorf(CodeInvalidValue,
+				"percentiles must be in (0, 100), found %v", eachPercentile))
+		}
+	}
+	if p.DeadlineThreshold != nil && (*p.DeadlineThreshold < 0 || *p.DeadlineThreshold > 1) {
+		d.addError(node.Field("deadlineThreshold").Errorf(CodeInvalidValue,
+			"deadlineThreshold must be in [0, 1], found %v", *p.DeadlineThreshold))
+	}
+	if p.Budget < 0 {
+		d.addError(node.Field("budget").Errorf(CodeInvalidValue, "budget must be non-negative, found %v", p.Budget))
+	}
+	resourcesNode := node.Field("resources")
+	for _, eachName := range sortedNames(p.Resources) {
+		if p.Resources[eachName] <= 0 {
+			d.addError(resourcesNode.Field(eachName).Errorf(CodeInvalidValue,
+				"resource capacity must be positive, found %v", p.Resources[eachName]))
+		}
+	}
+	validateDates(d, node.Field("holidays"), p.Holidays)
+	scenariosNode := node.Field("scenarios")
+	scenarioNames := make(map[string]bool)
+	for i, eachScenario := range p.Scenarios {
+		// Missing names were reported while decoding
+		if eachScenario == nil || len(eachScenario.Name) == 0 {
+			continue
+		}
+		if scenarioNames[eachScenario.Name] {
+			d.addError(scenariosNode.Items[i].Field("name").Errorf(CodeInvalidValue,
+				"duplicate scenario name %q", eachScenario.Name))
+		}
+		scenarioNames[eachScenario.Name] = true
+	}
+}
+
+func (s *Scenario) validateNode(d *decoder, node *Node) {
+	validateDates(d, node.Field("holidays"), s.Holidays)
+}
+
+// validateDates reports the items of the array node that aren't dates
+func validateDates(d *decoder, datesNode *Node, dates []string) {
+	for i, eachDate := range dates {
+		// Mistyped dates were reported while decoding
+		if datesNode.Items[i].Kind != KindString {
+			continue
+		}
+		_, dateErr := time.Parse(DateFormat, eachDate)
+		if dateErr != nil {
+			d.addError(datesNode.Items[i].Errorf(CodeInvalidValue, "expected a %s date, found %q", DateFormat, eachDate))
+		}
+	}
+}
+
+func (rc *RunCount) validateNode(d *decoder, node *Node) {
+	toleranceNode := node.Field("tolerance")
+	if toleranceNode != nil && (rc.Tolerance <= 0 || rc.Tolerance >= 1) {
+		d.addError(toleranceNode.Errorf(CodeInvalidValue, "tolerance must be in (0, 1), found %v", rc.Tolerance))
+	}
+	maxNode := node.Field("max")
+	if maxNode != nil && rc.Max <= 0 {
+		d.addError(maxNode.Errorf(CodeInvalidValue, "max must be positive"))
+	}
+}
+
+func (t *Task) validateNode(d *decoder, node *Node) {
+	if len(t.Type) != 0 && len(t.Effort) != 0 {
+		d.addError(node.Field("effort").Errorf(CodeInvalidValue, "tasks define either a type or an effort, not both"))
+	}
+	if t.Staff != nil && *t.Staff <= 0 {
+		d.addError(node.Field("staff").Errorf(CodeInvalidValue, "staff must be positive, found %v", *t.Staff))
+	}
+	if t.Overhead < 0 {
+		d.addError(node.Field("overhead").Errorf(CodeInvalidValue, "overhead must be non-negative, found %v", t.Overhead))
+	}
+}
+
+func (b *Branch) validateNode(d *decoder, node *Node) {
+	if b.Weight != nil && *b.Weight < 0 {
+		d.addError(node.Field("weight").Errorf(CodeInvalidValue, "weight must be non-negative, found %v", *b.Weight))
+	}
+}
+
+func (cp *CorrelationPair) validateNode(d *decoder, node *Node) {
+	tasksNode := node.Field("tasks")
+	if tasksNode != nil && len(cp.Tasks) != 2 {
+		d.addError(tasksNode.Errorf(CodeInvalidValue, "expected two task names, found %d", len(cp.Tasks)))
+	} else if tasksNode != nil && cp.Tasks[0] == cp.Tasks[1] {
+		d.addError(tasksNode.Errorf(CodeInvalidValue, "a task can't be correlated with itself: %s", cp.Tasks[0]))
+	}
+	if cp.Rho < -1 || cp.Rho > 1 {
+		d.addError(node.Field("rho").Errorf(CodeInvalidValue, "rho must be in [-1, 1], found %v", cp.Rho))
+	}
+}
+
+func sortedNames[V any](values map[string]V) []string {
+	names := make([]string, 0, len(values))
+	for eachName := range values {
+		names = append(names, eachName)
+	}
+	slices.Sort(names)
+	return names
+}
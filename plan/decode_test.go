@@ -0,0 +1,74 @@
+package plan
+
+import "testing"
+
+// Decoding errors are located at the offending value, and every error is
+// reported
+func TestDecodeErrorLocations(t *testing.T) {
+	planJSON := `{
+  "name": "Located",
+  "runcount": 1000,
+  "activities": {
+    "tasks": [
+      {"name": "Design", "type": 5}
+    ]
+  }
+}`
+	_, decodeErr := Decode("plan.json", []byte(planJSON))
+	decodeErrs := AsErrors(decodeErr)
+	expected := []struct {
+		code    string
+		line    int
+		column  int
+		pointer string
+	}{
+		{CodeUnknownKey, 3, 15, "/runcount"},
+		{CodeInvalidType, 6, 34, "/activities/tasks/0/type"},
+		{CodeMissingValue, 1, 1, ""},
+	}
+	if len(decodeErrs) != len(expected) {
+		t.Fatalf("invalid error count. Expected: %d, Found: %d (%v)", len(expected), len(decodeErrs), decodeErr)
+	}
+	for i, eachExpected := range expected {
+		eachErr := decodeErrs[i]
+		if eachErr.Code != eachExpected.code ||
+			eachErr.Filename != "plan.json" ||
+			eachErr.Line != eachExpected.line ||
+			eachErr.Column != eachExpected.column ||
+			eachErr.Pointer != eachExpected.pointer {
+			t.Errorf("invalid error %d. Expected: %s at %d:%d %s, Found: %s at %d:%d %s",
+				i,
+				eachExpected.code,
+				eachExpected.line,
+				eachExpected.column,
+				eachExpected.pointer,
+				eachErr.Code,
+				eachErr.Line,
+				eachErr.Column,
+				eachErr.Pointer)
+		}
+	}
+}
+
+func TestDecodeSyntaxErrorLocation(t *testing.T) {
+	_, decodeErr := Decode("plan.json", []byte("{\n  \"name\": \"Broken\",\n  \"runCount\": 10,,\n}"))
+	decodeErrs := AsErrors(decodeErr)
+	if len(decodeErrs) != 1 || decodeErrs[0].Code != CodeSyntax || decodeErrs[0].Line != 3 {
+		t.Fatalf("invalid syntax error. Expected a %s error on line 3, Found: %v", CodeSyntax, decodeErr)
+	}
+}
+
+// The deprecated format version key is decoded rather than rejected
+func TestDecodeDeprecatedSchemaKey(t *testing.T) {
+	planDef, decodeErr := Decode("plan.json", []byte(`{
+		"schema": 1,
+		"runCount": 10,
+		"activities": {"tasks": [{"name": "Design", "type": "PERT(1,2,3)"}]}
+	}`))
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if planDef.Version == nil || *planDef.Version != 1 {
+		t.Errorf("invalid plan format version: %v", planDef.Version)
+	}
+}
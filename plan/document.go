@@ -0,0 +1,232 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Document
+//
+// A parsed JSON document that retains the source location and the key order
+// of every value. The encoding/json package discards both, so the document
+// is parsed token by token.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// Kind is the JSON type of a Node
+type Kind int
+
+const (
+	KindNull Kind = iota
+	KindBool
+	KindNumber
+	KindString
+	KindArray
+	KindObject
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindBool:
+		return "boolean"
+	case KindNumber:
+		return "number"
+	case KindString:
+		return "string"
+	case KindArray:
+		return "array"
+	case KindObject:
+		return "object"
+	}
+	return "null"
+}
+
+// Node is a JSON value together with its location in the document
+type Node struct {
+	Kind Kind
+	// Value is the bool, float64 or string value of scalar nodes
+	Value interface{}
+	// Keys are the object keys in document order
+	Keys   []string
+	Fields map[string]*Node
+	Items  []*Node
+	// Pointer is the RFC 6901 JSON pointer to the node
+	Pointer string
+	// Offset is the byte offset of the start of the node
+	Offset int64
+	doc    *Document
+}
+
+// Document is a parsed plan document
+type Document struct {
+	Filename string
+	Root     *Node
+	data     []byte
+}
+
+// ParseDocument parses the JSON data. The filename is only used to
+// describe error locations.
+func ParseDocument(filename string, data []byte) (*Document, error) {
+	doc := &Document{
+		Filename: filename,
+		data:     data,
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, rootErr := doc.parseValue(dec, "")
+	if rootErr != nil {
+		return nil, rootErr
+	}
+	_, trailingErr := dec.Token()
+	if trailingErr != io.EOF {
+		return nil, doc.errorAt(dec.InputOffset(), "", CodeSyntax, "unexpected data after the plan object")
+	}
+	doc.Root = root
+	return doc, nil
+}
+
+// Position returns the 1-based line and column of the byte offset
+func (d *Document) Position(offset int64) (int, int) {
+	if offset > int64(len(d.data)) {
+		offset = int64(len(d.data))
+	}
+	precedingData := d.data[:offset]
+	line := bytes.Count(precedingData, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(precedingData, '\n')
+	return line, column
+}
+
+func (d *Document) errorAt(offset int64, pointer string, code string, format string, args ...interface{}) *Error {
+	line, column := d.Position(offset)
+	return &Error{
+		Code:     code,
+		Filename: d.Filename,
+		Line:     line,
+		Column:   column,
+		Pointer:  pointer,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// valueStart returns the offset of the next value, skipping the whitespace
+// and separators the decoder hasn't consumed yet
+func (d *Document) valueStart(offset int64) int64 {
+	for offset < int64(len(d.data)) && strings.IndexByte(" \t\r\n,:", d.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+func (d *Document) syntaxError(tokenErr error, pointer string) *Error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(tokenErr, &syntaxErr) {
+		return d.errorAt(syntaxErr.Offset, pointer, CodeSyntax, "%s", syntaxErr.Error())
+	}
+	return d.errorAt(int64(len(d.data)), pointer, CodeSyntax, "%s", tokenErr.Error())
+}
+
+func (d *Document) parseValue(dec *json.Decoder, pointer string) (*Node, error) {
+	node := &Node{
+		Pointer: pointer,
+		Offset:  d.valueStart(dec.InputOffset()),
+		doc:     d,
+	}
+	token, tokenErr := dec.Token()
+	if tokenErr != nil {
+		return nil, d.syntaxError(tokenErr, pointer)
+	}
+	switch typedToken := token.(type) {
+	case json.Delim:
+		switch typedToken {
+		case '{':
+			node.Kind = KindObject
+			node.Fields = make(map[string]*Node)
+			for dec.More() {
+				keyOffset := d.valueStart(dec.InputOffset())
+				keyToken, keyTokenErr := dec.Token()
+				if keyTokenErr != nil {
+					return nil, d.syntaxError(keyTokenErr, pointer)
+				}
+				key, _ := keyToken.(string)
+				childPointer := pointer + "/" + escapePointerToken(key)
+				_, keyExists := node.Fields[key]
+				if keyExists {
+					return nil, d.errorAt(keyOffset, childPointer, CodeDuplicateKey, "duplicate key %q", key)
+				}
+				child, childErr := d.parseValue(dec, childPointer)
+				if childErr != nil {
+					return nil, childErr
+				}
+				node.Keys = append(node.Keys, key)
+				node.Fields[key] = child
+			}
+		case '[':
+			node.Kind = KindArray
+			node.Items = make([]*Node, 0)
+			for dec.More() {
+				child, childErr := d.parseValue(dec, fmt.Sprintf("%s/%d", pointer, len(node.Items)))
+				if childErr != nil {
+					return nil, childErr
+				}
+				node.Items = append(node.Items, child)
+			}
+		}
+		// Consume the closing delimiter
+		_, closeErr := dec.Token()
+		if closeErr != nil {
+			return nil, d.syntaxError(closeErr, pointer)
+		}
+	case bool:
+		node.Kind = KindBool
+		node.Value = typedToken
+	case json.Number:
+		floatVal, floatValErr := typedToken.Float64()
+		if floatValErr != nil {
+			return nil, d.errorAt(node.Offset, pointer, CodeInvalidValue, "invalid number: %s", typedToken)
+		}
+		node.Kind = KindNumber
+		node.Value = floatVal
+	case string:
+		node.Kind = KindString
+		node.Value = typedToken
+	case nil:
+		node.Kind = KindNull
+	}
+	return node, nil
+}
+
+// Field returns the named field of an object node, or nil
+func (n *Node) Field(key string) *Node {
+	if n == nil || n.Fields == nil {
+		return nil
+	}
+	return n.Fields[key]
+}
+
+// Position returns the 1-based line and column of the node
+func (n *Node) Position() (int, int) {
+	if n == nil || n.doc == nil {
+		return 0, 0
+	}
+	return n.doc.Position(n.Offset)
+}
+
+// Errorf returns an Error located at the node
+func (n *Node) Errorf(code string, format string, args ...interface{}) *Error {
+	if n == nil || n.doc == nil {
+		return &Error{
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+		}
+	}
+	return n.doc.errorAt(n.Offset, n.Pointer, code, format, args...)
+}
+
+func escapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,71 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error codes
+const (
+	CodeSyntax       = "syntax"
+	CodeDuplicateKey = "duplicate-key"
+	CodeUnknownKey   = "unknown-key"
+	CodeInvalidType  = "invalid-type"
+	CodeInvalidValue = "invalid-value"
+	CodeMissingValue = "missing-value"
+)
+
+// Error is a plan error with its location in the plan document
+type Error struct {
+	Code     string
+	Filename string
+	Line     int
+	Column   int
+	// Pointer is the RFC 6901 JSON pointer to the value
+	Pointer string
+	Message string
+}
+
+func (e *Error) Location() string {
+	pointer := e.Pointer
+	if len(pointer) <= 0 {
+		pointer = "/"
+	}
+	if e.Line <= 0 {
+		return pointer
+	}
+	filename := e.Filename
+	if len(filename) <= 0 {
+		filename = "<input>"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", filename, e.Line, e.Column, pointer)
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Location(), e.Message)
+}
+
+// Errors is every error found decoding a plan
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, eachErr := range e {
+		messages[i] = eachErr.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// AsErrors returns the plan errors in err, if any
+func AsErrors(err error) Errors {
+	var planErrs Errors
+	if errors.As(err, &planErrs) {
+		return planErrs
+	}
+	var planErr *Error
+	if errors.As(err, &planErr) {
+		return Errors{planErr}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Marshal
+//
+// Model types whose document representation isn't a plain object marshal
+// themselves back to that representation. Activities preserve their entry
+// order.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// MarshalJSON returns the activities object, in entry order
+func (a *Activities) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, eachEntry := range a.Entries {
+		if i != 0 {
+			buffer.WriteByte(',')
+		}
+		var entryValue interface{}
+		switch {
+		case eachEntry.Serial != nil:
+			entryValue = eachEntry.Serial
+		case eachEntry.Parallel != nil:
+			entryValue = eachEntry.Parallel
+		case eachEntry.Subgraph != nil:
+			entryValue = eachEntry.Subgraph
+		case eachEntry.Choice != nil:
+			entryValue = eachEntry.Choice
+		default:
+			entryValue = []*Task{}
+		}
+		writeMemberErr := writeMember(&buffer, eachEntry.Key, entryValue)
+		if writeMemberErr != nil {
+			return nil, writeMemberErr
+		}
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// MarshalJSON returns the parallel tasks keyed by name, followed by the
+// optional join
+func (p *Parallel) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, eachTask := range p.Tasks {
+		if i != 0 {
+			buffer.WriteByte(',')
+		}
+		key := eachTask.Key
+		if len(key) <= 0 {
+			key = eachTask.Name
+		}
+		writeMemberErr := writeMember(&buffer, key, eachTask)
+		if writeMemberErr != nil {
+			return nil, writeMemberErr
+		}
+	}
+	if len(p.Join) != 0 {
+		if len(p.Tasks) != 0 {
+			buffer.WriteByte(',')
+		}
+		writeMemberErr := writeMember(&buffer, "join", p.Join)
+		if writeMemberErr != nil {
+			return nil, writeMemberErr
+		}
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// MarshalJSON returns a fixed cost as a number, a generator expression as a
+// string and other costs as an object
+func (c *Cost) MarshalJSON() ([]byte, error) {
+	switch {
+	case c.Rate == 0 && len(c.Type) == 0:
+		return json.Marshal(c.Fixed)
+	case c.Rate == 0 && c.Fixed == 0:
+		return json.Marshal(c.Type)
+	}
+	type costObject Cost
+	return json.Marshal((*costObject)(c))
+}
+
+// MarshalJSON returns a fixed run count as a number, an auto run count with
+// the default settings as "auto" and other auto run counts as an object
+func (rc RunCount) MarshalJSON() ([]byte, error) {
+	switch {
+	case !rc.Auto:
+		return json.Marshal(rc.Count)
+	case rc.Tolerance == 0 && rc.Max == 0:
+		return json.Marshal(RunCountAuto)
+	}
+	type runCountObject RunCount
+	return json.Marshal(runCountObject(rc))
+}
+
+// MarshalJSON returns the date if the deadline has one, otherwise the
+// duration
+func (dl *Deadline) MarshalJSON() ([]byte, error) {
+	if len(dl.Date) != 0 {
+		return json.Marshal(dl.Date)
+	}
+	return json.Marshal(dl.Duration)
+}
+
+func writeMember(buffer *bytes.Buffer, key string, value interface{}) error {
+	keyBytes, keyErr := json.Marshal(key)
+	if keyErr != nil {
+		return keyErr
+	}
+	valueBytes, valueErr := json.Marshal(value)
+	if valueErr != nil {
+		return valueErr
+	}
+	buffer.Write(keyBytes)
+	buffer.WriteByte(':')
+	buffer.Write(valueBytes)
+	return nil
+}
@@ -0,0 +1,255 @@
+package plan
+
+// /////////////////////////////////////////////////////////////////////////////
+// Model
+//
+// The typed plan model. Plans are decoded strictly: unknown keys, mistyped
+// values and missing required values are errors that carry the location of
+// the offending value. The JSON Schema is generated from the same types, so
+// the `description` tags are the schema documentation.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// DateFormat is the layout for calendar dates in plans
+const DateFormat = "2006-01-02"
+
+// RunCountAuto is the run count that runs until the percentiles converge
+const RunCountAuto = "auto"
+
+// Source is the location of a model value in its plan document. Values that
+// are created in code have no source.
+type Source struct {
+	node *Node
+}
+
+// Node returns the document node the value was decoded from, or nil
+func (s Source) Node() *Node {
+	return s.node
+}
+
+// Pointer returns the JSON pointer to the value
+func (s Source) Pointer() string {
+	if s.node == nil {
+		return ""
+	}
+	return s.node.Pointer
+}
+
+// Field returns the source of the named field of an object value. Values
+// without the field return their own source.
+func (s Source) Field(key string) Source {
+	fieldNode := s.node.Field(key)
+	if fieldNode == nil {
+		return s
+	}
+	return Source{node: fieldNode}
+}
+
+// Index returns the source of an array value's item
+func (s Source) Index(index int) Source {
+	if s.node == nil || index < 0 || index >= len(s.node.Items) {
+		return s
+	}
+	return Source{node: s.node.Items[index]}
+}
+
+// Location returns the file:line:column and JSON pointer of the value
+func (s Source) Location() string {
+	return s.node.Errorf("", "").Location()
+}
+
+// Wrap locates err at the value. Errors that already have a location and
+// values without a source are returned unchanged.
+func (s Source) Wrap(err error) error {
+	if err == nil || s.node == nil || AsErrors(err) != nil {
+		return err
+	}
+	return s.node.Errorf(CodeInvalidValue, "%v", err)
+}
+
+// Plan is the root of a plan document
+type Plan struct {
+	Source            `json:"-"`
+	Schema            string        `json:"$schema,omitempty" description:"JSON Schema reference for editors"`
+	Version           *float64      `json:"schema,omitempty" description:"Deprecated plan format version. Ignored"`
+	Name              string        `json:"name,omitempty" description:"Plan name"`
+	RunCount          RunCount      `json:"runCount" plan:"required" description:"Number of Monte Carlo runs, or auto to run until the percentiles converge"`
+	Percentiles       []float64     `json:"percentiles,omitempty" description:"Percentiles to report. Defaults to [50, 95]"`
+	Sampling          string        `json:"sampling,omitempty" description:"Sampling strategy: mc, lhs, antithetic or sobol. Defaults to mc"`
+	Intervals         string        `json:"intervals,omitempty" description:"Confidence intervals of the mean and percentiles: order, bootstrap or bootstrap-all. bootstrap only resamples the plan totals. Defaults to none"`
+	Sensitivity       string        `json:"sensitivity,omitempty" description:"Task sensitivity analysis: spearman, or sobol for rank correlations and first-order Sobol indices. Defaults to none"`
+	Statistics        []string      `json:"statistics,omitempty" description:"Shape and tail statistics shown in the diagram: skewness, kurtosis, mode, cv and cvar. The JSON summary always has them"`
+	Workdays          bool          `json:"workdays,omitempty" description:"Durations are workdays and completion dates are estimated"`
+	Holidays          []string      `json:"holidays,omitempty" description:"Non-working YYYY-MM-DD dates added to the workday calendar"`
+	Activities        *Activities   `json:"activities" plan:"required" description:"The plan's activities"`
+	Risks             []*Risk       `json:"risks,omitempty" description:"Risk register"`
+	Correlations      *Correlations `json:"correlations,omitempty" description:"Correlations between task durations"`
+	Resources         Capacities    `json:"resources,omitempty" description:"Shared resource capacities"`
+	Budget            float64       `json:"budget,omitempty" description:"Budget the total cost is compared against"`
+	Deadline          *Deadline     `json:"deadline,omitempty" description:"Plan deadline"`
+	DeadlineThreshold *float64      `json:"deadlineThreshold,omitempty" description:"Deadline probability below which joins are flagged. Defaults to 0.8"`
+	Assertions        []string      `json:"assertions,omitempty" description:"Forecast assertions verified by the check command"`
+	Scenarios         []*Scenario   `json:"scenarios,omitempty" description:"What-if scenarios compared against the plan"`
+	Suppress          []string      `json:"suppress,omitempty" description:"Validation rule IDs to suppress"`
+}
+
+// Activities are the named entries of an activities object, in document
+// order. Arrays are serial tasks and sibling arrays are serial lanes that
+// run in parallel. Objects are subgraphs when they have a name and
+// activities, choices when they have a name and branches, and parallel
+// tasks otherwise.
+type Activities struct {
+	Source
+	Entries []*ActivityEntry
+}
+
+// ActivityEntry is a single entry of an activities object. Exactly one of
+// Serial, Parallel, Subgraph or Choice is set.
+type ActivityEntry struct {
+	Key      string
+	Serial   []*Task
+	Parallel *Parallel
+	Subgraph *Subgraph
+	Choice   *Choice
+}
+
+// Parallel are tasks that run in parallel. An optional join closes the
+// tasks with that join rather than the enclosing subgraph's join.
+type Parallel struct {
+	Source
+	Join  string
+	Tasks []*Task
+}
+
+// Task is a single activity
+type Task struct {
+	Source    `json:"-"`
+	Key       string        `json:"-"`
+	Name      string        `json:"name,omitempty" description:"Task name. Defaults to the parallel key or the serial index"`
+	Type      string        `json:"type,omitempty" description:"Duration generator expression. Ex: PERT(4,5,8)"`
+	Effort    string        `json:"effort,omitempty" description:"Effort generator expression in person units. Used instead of type"`
+	Staff     *float64      `json:"staff,omitempty" description:"Headcount assigned to the effort. Defaults to 1"`
+	Overhead  float64       `json:"overhead,omitempty" description:"Communication overhead per additional person"`
+	Repeat    string        `json:"repeat,omitempty" description:"Repeat count expression. Ex: Geometric(0.3)"`
+	Resources TaskResources `json:"resources,omitempty" description:"Resource units held for the task's duration"`
+	Cost      *Cost         `json:"cost,omitempty" description:"Task cost model"`
+	Suppress  []string      `json:"suppress,omitempty" description:"Validation rule IDs to suppress"`
+}
+
+// Subgraph is a named group of activities
+type Subgraph struct {
+	Source     `json:"-"`
+	Name       string      `json:"name" plan:"required" description:"Subgraph name"`
+	Join       string      `json:"join,omitempty" description:"Join expression: max, min, sum or kofn(k). Defaults to max"`
+	Repeat     string      `json:"repeat,omitempty" description:"Repeat count expression for rework loops"`
+	Deadline   *Deadline   `json:"deadline,omitempty" description:"Subgraph deadline"`
+	Activities *Activities `json:"activities" plan:"required" description:"The subgraph's activities"`
+	Suppress   []string    `json:"suppress,omitempty" description:"Validation rule IDs to suppress"`
+}
+
+// Choice is a weighted choice between alternative branches
+type Choice struct {
+	Source   `json:"-"`
+	Name     string    `json:"name" plan:"required" description:"Choice name"`
+	Repeat   string    `json:"repeat,omitempty" description:"Repeat count expression for rework loops"`
+	Branches []*Branch `json:"branches" plan:"required" description:"Alternative branches"`
+	Suppress []string  `json:"suppress,omitempty" description:"Validation rule IDs to suppress"`
+}
+
+// Branch is one alternative of a Choice
+type Branch struct {
+	Source     `json:"-"`
+	Name       string      `json:"name,omitempty" description:"Branch name"`
+	Weight     *float64    `json:"weight" plan:"required" description:"Relative weight of the branch"`
+	Activities *Activities `json:"activities,omitempty" description:"The branch's activities. Empty branches take no time"`
+	Suppress   []string    `json:"suppress,omitempty" description:"Validation rule IDs to suppress"`
+}
+
+// Risk is a risk register entry
+type Risk struct {
+	Source   `json:"-"`
+	Name     string   `json:"name,omitempty" description:"Risk name"`
+	Type     string   `json:"type" plan:"required" description:"Risk(p=..., impact=...) expression"`
+	Attach   string   `json:"attach,omitempty" description:"Task or subgraph the risk is attached to. Defaults to the plan"`
+	Suppress []string `json:"suppress,omitempty" description:"Validation rule IDs to suppress"`
+}
+
+// Correlations are the correlations between task durations
+type Correlations struct {
+	Source   `json:"-"`
+	Pairs    []*CorrelationPair `json:"pairs,omitempty" description:"Pairwise rank correlations"`
+	Drivers  []*RiskDriver      `json:"drivers,omitempty" description:"Shared factors that multiply task durations"`
+	Suppress []string           `json:"suppress,omitempty" description:"Validation rule IDs to suppress"`
+}
+
+// CorrelationPair is a target rank correlation between two tasks
+type CorrelationPair struct {
+	Source   `json:"-"`
+	Tasks    []string `json:"tasks" plan:"required" description:"The two correlated task names"`
+	Rho      float64  `json:"rho" plan:"required" description:"Target rank correlation"`
+	Suppress []string `json:"suppress,omitempty" description:"Validation rule IDs to suppress"`
+}
+
+// RiskDriver is a shared factor that multiplies a group of tasks
+type RiskDriver struct {
+	Source   `json:"-"`
+	Name     string   `json:"name,omitempty" description:"Driver name"`
+	Type     string   `json:"type" plan:"required" description:"Factor generator expression"`
+	Tasks    []string `json:"tasks" plan:"required" description:"Names of the tasks the factor multiplies"`
+	Suppress []string `json:"suppress,omitempty" description:"Validation rule IDs to suppress"`
+}
+
+// Capacities are named resource capacities
+type Capacities map[string]float64
+
+// TaskResources are the resource units a task holds. In documents they're
+// either a map of names to units or an array of names that each hold one
+// unit.
+type TaskResources map[string]float64
+
+// Cost is a task's cost model. In documents costs are either a fixed
+// number, a generator expression, or an object.
+type Cost struct {
+	Source `json:"-"`
+	Fixed  float64 `json:"fixed,omitempty" description:"Fixed cost"`
+	Rate   float64 `json:"rate,omitempty" description:"Cost per unit of the task's duration"`
+	Type   string  `json:"type,omitempty" description:"Cost generator expression"`
+}
+
+// RunCount is the number of Monte Carlo runs. In documents run counts are
+// either a positive number, "auto", or an object with the auto settings.
+// Auto run counts add runs until the plan's percentiles converge.
+type RunCount struct {
+	Source    `json:"-"`
+	Count     uint64  `json:"-"`
+	Auto      bool    `json:"-"`
+	Tolerance float64 `json:"tolerance,omitempty" description:"Relative half-width of the percentiles' 95% confidence intervals. Defaults to 0.01"`
+	Max       uint64  `json:"max,omitempty" description:"Maximum number of runs, at least 100000. Defaults to 1000000"`
+}
+
+// Deadline is a target completion, either in duration units or as a date
+type Deadline struct {
+	Source
+	Duration float64
+	Date     string
+}
+
+// Scenario is a what-if variation of the plan. The scenario's changes are
+// applied to a copy of the plan in field order.
+type Scenario struct {
+	Source    `json:"-"`
+	Name      string            `json:"name" plan:"required" description:"Scenario name"`
+	Overrides map[string]string `json:"overrides,omitempty" description:"Duration generator expressions that replace the type, or effort, of the named tasks"`
+	Add       []*ScenarioTask   `json:"add,omitempty" description:"Tasks the scenario adds"`
+	Remove    []string          `json:"remove,omitempty" description:"Names of the tasks the scenario removes"`
+	Workdays  *bool             `json:"workdays,omitempty" description:"Overrides the plan's workdays"`
+	Holidays  []string          `json:"holidays,omitempty" description:"Non-working YYYY-MM-DD dates added to the plan's holidays"`
+	Suppress  []string          `json:"suppress,omitempty" description:"Validation rule IDs to suppress"`
+}
+
+// ScenarioTask is a task added by a scenario
+type ScenarioTask struct {
+	Source `json:"-"`
+	After  string `json:"after,omitempty" description:"Name of the serial task the task follows. Defaults to running in parallel with the plan's activities"`
+	Task   *Task  `json:"task" plan:"required" description:"The added task. Added tasks must be named"`
+}
@@ -0,0 +1,277 @@
+package plan
+
+import (
+	"fmt"
+	"slices"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Scenarios
+//
+// A scenario's plan is a copy of the plan with the scenario's changes
+// applied, in order: the type overrides, the added tasks, the removed
+// tasks and then the calendar. Tasks are referenced by name, and unnamed
+// tasks by their default name. Removing a task also removes the risks
+// attached to it and its correlations. The copied tasks keep their source,
+// so they're located at the plan's tasks.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// SerialTaskName is the default name of the unnamed serial task at the
+// index of its serial array
+func SerialTaskName(index int) string {
+	return fmt.Sprintf("serial-%d", index)
+}
+
+// taskLocation is a task and the activities entry that holds it
+type taskLocation struct {
+	task       *Task
+	activities *Activities
+	entry      *ActivityEntry
+	index      int
+}
+
+// cloneTasks copies the tasks. Unnamed serial tasks are named by their
+// index, so they keep their names when tasks are added or removed.
+func cloneTasks(tasks []*Task, serial bool) []*Task {
+	cloned := make([]*Task, len(tasks))
+	for i, eachTask := range tasks {
+		clonedTask := *eachTask
+		if serial && len(clonedTask.Name) == 0 {
+			clonedTask.Name = SerialTaskName(i)
+		}
+		cloned[i] = &clonedTask
+	}
+	return cloned
+}
+
+// clone copies the activities and every task within them
+func (a *Activities) clone() *Activities {
+	if a == nil {
+		return nil
+	}
+	cloned := &Activities{
+		Source:  a.Source,
+		Entries: make([]*ActivityEntry, len(a.Entries)),
+	}
+	for i, eachEntry := range a.Entries {
+		clonedEntry := &ActivityEntry{
+			Key: eachEntry.Key,
+		}
+		switch {
+		case eachEntry.Serial != nil:
+			clonedEntry.Serial = cloneTasks(eachEntry.Serial, true)
+		case eachEntry.Parallel != nil:
+			clonedParallel := *eachEntry.Parallel
+			clonedParallel.Tasks = cloneTasks(eachEntry.Parallel.Tasks, false)
+			clonedEntry.Parallel = &clonedParallel
+		case eachEntry.Subgraph != nil:
+			clonedSubgraph := *eachEntry.Subgraph
+			clonedSubgraph.Activities = eachEntry.Subgraph.Activities.clone()
+			clonedEntry.Subgraph = &clonedSubgraph
+		case eachEntry.Choice != nil:
+			clonedChoice := *eachEntry.Choice
+			clonedChoice.Branches = make([]*Branch, len(eachEntry.Choice.Branches))
+			for j, eachBranch := range eachEntry.Choice.Branches {
+				clonedBranch := *eachBranch
+				clonedBranch.Activities = eachBranch.Activities.clone()
+				clonedChoice.Branches[j] = &clonedBranch
+			}
+			clonedEntry.Choice = &clonedChoice
+		}
+		cloned.Entries[i] = clonedEntry
+	}
+	return cloned
+}
+
+// taskLocations returns the location of every task, in document order
+func (a *Activities) taskLocations() []*taskLocation {
+	locations := make([]*taskLocation, 0)
+	if a == nil {
+		return locations
+	}
+	for _, eachEntry := range a.Entries {
+		switch {
+		case eachEntry.Serial != nil:
+			for i, eachTask := range eachEntry.Serial {
+				locations = append(locations, &taskLocation{task: eachTask, activities: a, entry: eachEntry, index: i})
+			}
+		case eachEntry.Parallel != nil:
+			for i, eachTask := range eachEntry.Parallel.Tasks {
+				locations = append(locations, &taskLocation{task: eachTask, activities: a, entry: eachEntry, index: i})
+			}
+		case eachEntry.Subgraph != nil:
+			locations = append(locations, eachEntry.Subgraph.Activities.taskLocations()...)
+		case eachEntry.Choice != nil:
+			for _, eachBranch := range eachEntry.Choice.Branches {
+				locations = append(locations, eachBranch.Activities.taskLocations()...)
+			}
+		}
+	}
+	return locations
+}
+
+// name returns the task's name, or its default name
+func (tl *taskLocation) name() string {
+	switch {
+	case len(tl.task.Name) != 0:
+		return tl.task.Name
+	case tl.entry.Serial != nil:
+		return SerialTaskName(tl.index)
+	}
+	return tl.task.Key
+}
+
+// taskNamed returns the location of the single task with the given name
+func (a *Activities) taskNamed(name string) (*taskLocation, error) {
+	var location *taskLocation
+	matchCount := 0
+	for _, eachLocation := range a.taskLocations() {
+		if eachLocation.name() == name {
+			location = eachLocation
+			matchCount++
+		}
+	}
+	if matchCount != 1 {
+		return nil, fmt.Errorf("expected exactly one task named %q. Found: %d", name, matchCount)
+	}
+	return location, nil
+}
+
+// entryKey returns a key that's unique among the activities
+func (a *Activities) entryKey(baseKey string) string {
+	key := baseKey
+	for i := 2; slices.ContainsFunc(a.Entries, func(entry *ActivityEntry) bool {
+		return entry.Key == key
+	}); i++ {
+		key = fmt.Sprintf("%s%d", baseKey, i)
+	}
+	return key
+}
+
+// addTask adds the scenario's task after its serial task, or in parallel
+// with the activities
+func (a *Activities) addTask(scenarioTask *ScenarioTask) error {
+	if scenarioTask.Task == nil {
+		return fmt.Errorf("missing added task")
+	}
+	addedTask := *scenarioTask.Task
+	if len(addedTask.Name) == 0 {
+		return fmt.Errorf("invalid added task: the task must be named")
+	}
+	_, existingErr := a.taskNamed(addedTask.Name)
+	if existingErr == nil {
+		return fmt.Errorf("invalid added task: a task named %q already exists", addedTask.Name)
+	}
+	if len(scenarioTask.After) == 0 {
+		addedTask.Key = a.entryKey(addedTask.Name)
+		a.Entries = append(a.Entries, &ActivityEntry{
+			Key: addedTask.Key,
+			Parallel: &Parallel{
+				Source: scenarioTask.Source,
+				Tasks:  []*Task{&addedTask},
+			},
+		})
+		return nil
+	}
+	location, locationErr := a.taskNamed(scenarioTask.After)
+	if locationErr != nil {
+		return locationErr
+	}
+	if location.entry.Serial == nil {
+		return fmt.Errorf("invalid added task: %q is a parallel task. Tasks can only be added after serial tasks", scenarioTask.After)
+	}
+	location.entry.Serial = slices.Insert(location.entry.Serial, location.index+1, &addedTask)
+	return nil
+}
+
+// removeTask removes the named task. Entries without tasks are removed.
+func (a *Activities) removeTask(name string) error {
+	location, locationErr := a.taskNamed(name)
+	if locationErr != nil {
+		return locationErr
+	}
+	remainingTasks := 0
+	if location.entry.Serial != nil {
+		location.entry.Serial = slices.Delete(location.entry.Serial, location.index, location.index+1)
+		remainingTasks = len(location.entry.Serial)
+	} else {
+		location.entry.Parallel.Tasks = slices.Delete(location.entry.Parallel.Tasks, location.index, location.index+1)
+		remainingTasks = len(location.entry.Parallel.Tasks)
+	}
+	if remainingTasks == 0 {
+		location.activities.Entries = slices.DeleteFunc(location.activities.Entries, func(entry *ActivityEntry) bool {
+			return entry == location.entry
+		})
+	}
+	return nil
+}
+
+// removeTaskReferences removes the risks attached to the task and the
+// task's correlations
+func (p *Plan) removeTaskReferences(name string) {
+	p.Risks = slices.DeleteFunc(slices.Clone(p.Risks), func(risk *Risk) bool {
+		return risk.Attach == name
+	})
+	if p.Correlations == nil {
+		return
+	}
+	correlations := *p.Correlations
+	correlations.Pairs = slices.DeleteFunc(slices.Clone(correlations.Pairs), func(pair *CorrelationPair) bool {
+		return slices.Contains(pair.Tasks, name)
+	})
+	correlations.Drivers = make([]*RiskDriver, 0, len(p.Correlations.Drivers))
+	for _, eachDriver := range p.Correlations.Drivers {
+		driver := *eachDriver
+		driver.Tasks = slices.DeleteFunc(slices.Clone(eachDriver.Tasks), func(taskName string) bool {
+			return taskName == name
+		})
+		if len(driver.Tasks) != 0 {
+			correlations.Drivers = append(correlations.Drivers, &driver)
+		}
+	}
+	p.Correlations = &correlations
+}
+
+// ScenarioPlan returns the plan named by the scenario with the scenario's
+// changes applied. The plan isn't modified. Errors are located at the
+// scenario's changes.
+func (p *Plan) ScenarioPlan(scenario *Scenario) (*Plan, error) {
+	scenarioPlan := *p
+	scenarioPlan.Name = scenario.Name
+	scenarioPlan.Scenarios = nil
+	scenarioPlan.Activities = p.Activities.clone()
+	activities := scenarioPlan.Activities
+
+	overridesSource := scenario.Field("overrides")
+	for _, eachName := range sortedNames(scenario.Overrides) {
+		location, locationErr := activities.taskNamed(eachName)
+		if locationErr != nil {
+			return nil, overridesSource.Field(eachName).Wrap(locationErr)
+		}
+		location.task.Type = scenario.Overrides[eachName]
+		location.task.Effort = ""
+		location.task.Staff = nil
+		location.task.Overhead = 0
+	}
+	for i, eachAdd := range scenario.Add {
+		addErr := activities.addTask(eachAdd)
+		if addErr != nil {
+			return nil, scenario.Field("add").Index(i).Wrap(addErr)
+		}
+	}
+	for i, eachName := range scenario.Remove {
+		removeErr := activities.removeTask(eachName)
+		if removeErr != nil {
+			return nil, scenario.Field("remove").Index(i).Wrap(removeErr)
+		}
+		scenarioPlan.removeTaskReferences(eachName)
+	}
+	if scenario.Workdays != nil {
+		scenarioPlan.Workdays = *scenario.Workdays
+	}
+	if len(scenario.Holidays) != 0 {
+		scenarioPlan.Holidays = append(slices.Clone(p.Holidays), scenario.Holidays...)
+	}
+	return &scenarioPlan, nil
+}
@@ -0,0 +1,184 @@
+package plan
+
+//go:generate go run ./script/schema-generator.go ../schema/plan.schema.json
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// JSON Schema
+//
+// The published JSON Schema is generated from the model types so that editor
+// validation and the decoder can't disagree.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// SchemaID is the published location of the plan JSON Schema
+const SchemaID = "https://raw.githubusercontent.com/mweagle/goestimate/main/schema/plan.schema.json"
+
+type jsonSchema map[string]interface{}
+
+// schemaProvider is implemented by model types whose document
+// representation isn't a plain object
+type schemaProvider interface {
+	jsonSchema(g *schemaGenerator) jsonSchema
+}
+
+type schemaGenerator struct {
+	definitions map[string]jsonSchema
+}
+
+// JSONSchema returns the draft-07 JSON Schema of a plan document
+func JSONSchema() ([]byte, error) {
+	g := &schemaGenerator{
+		definitions: make(map[string]jsonSchema),
+	}
+	root := jsonSchema{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         SchemaID,
+		"title":       "goestimate plan",
+		"$ref":        g.schemaFor(reflect.TypeOf(Plan{}))["$ref"],
+		"definitions": g.definitions,
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func (g *schemaGenerator) ref(modelType reflect.Type) jsonSchema {
+	return jsonSchema{
+		"$ref": "#/definitions/" + modelType.Name(),
+	}
+}
+
+func (g *schemaGenerator) schemaFor(modelType reflect.Type) jsonSchema {
+	if modelType.Kind() == reflect.Pointer {
+		modelType = modelType.Elem()
+	}
+	provider, providerOk := reflect.New(modelType).Interface().(schemaProvider)
+	if providerOk || modelType.Kind() == reflect.Struct {
+		// Named model types are shared definitions. Register the definition
+		// before building it so that recursive types terminate.
+		_, definitionExists := g.definitions[modelType.Name()]
+		if !definitionExists {
+			g.definitions[modelType.Name()] = jsonSchema{}
+			if providerOk {
+				g.definitions[modelType.Name()] = provider.jsonSchema(g)
+			} else {
+				g.definitions[modelType.Name()] = g.structSchema(modelType)
+			}
+		}
+		return g.ref(modelType)
+	}
+	switch modelType.Kind() {
+	case reflect.String:
+		return jsonSchema{"type": "string"}
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}
+	case reflect.Float64:
+		return jsonSchema{"type": "number"}
+	case reflect.Uint64:
+		return jsonSchema{"type": "integer", "minimum": 0}
+	case reflect.Slice:
+		return jsonSchema{"type": "array", "items": g.schemaFor(modelType.Elem())}
+	case reflect.Map:
+		return jsonSchema{"type": "object", "additionalProperties": g.schemaFor(modelType.Elem())}
+	}
+	return jsonSchema{}
+}
+
+func (g *schemaGenerator) structSchema(modelType reflect.Type) jsonSchema {
+	properties := make(map[string]jsonSchema)
+	required := make([]string, 0)
+	for _, eachField := range modelFields(modelType).list {
+		fieldSchema := g.schemaFor(eachField.fieldType)
+		if len(eachField.description) != 0 {
+			// Sibling keywords of a $ref are ignored by draft-07 validators
+			_, isRef := fieldSchema["$ref"]
+			if isRef {
+				fieldSchema = jsonSchema{"allOf": []jsonSchema{fieldSchema}}
+			}
+			fieldSchema["description"] = eachField.description
+		}
+		properties[eachField.name] = fieldSchema
+		if eachField.required {
+			required = append(required, eachField.name)
+		}
+	}
+	schema := jsonSchema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) != 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (a *Activities) jsonSchema(g *schemaGenerator) jsonSchema {
+	return jsonSchema{
+		"type": "object",
+		"additionalProperties": jsonSchema{
+			"anyOf": []jsonSchema{
+				{"type": "array", "items": g.schemaFor(reflect.TypeOf(Task{}))},
+				g.schemaFor(reflect.TypeOf(Subgraph{})),
+				g.schemaFor(reflect.TypeOf(Choice{})),
+				g.schemaFor(reflect.TypeOf(Parallel{})),
+			},
+		},
+	}
+}
+
+func (p *Parallel) jsonSchema(g *schemaGenerator) jsonSchema {
+	return jsonSchema{
+		"type": "object",
+		"properties": jsonSchema{
+			"join": jsonSchema{
+				"type":        "string",
+				"description": "Join expression: max, min, sum or kofn(k). Defaults to max",
+			},
+		},
+		"additionalProperties": g.schemaFor(reflect.TypeOf(Task{})),
+	}
+}
+
+func (tr *TaskResources) jsonSchema(g *schemaGenerator) jsonSchema {
+	return jsonSchema{
+		"oneOf": []jsonSchema{
+			{"type": "array", "items": jsonSchema{"type": "string"}},
+			{"type": "object", "additionalProperties": jsonSchema{"type": "number", "exclusiveMinimum": 0}},
+		},
+	}
+}
+
+func (c *Cost) jsonSchema(g *schemaGenerator) jsonSchema {
+	type costObject Cost
+	return jsonSchema{
+		"oneOf": []jsonSchema{
+			{"type": "number"},
+			{"type": "string"},
+			g.structSchema(reflect.TypeOf(costObject{})),
+		},
+	}
+}
+
+func (rc *RunCount) jsonSchema(g *schemaGenerator) jsonSchema {
+	type runCountObject RunCount
+	return jsonSchema{
+		"oneOf": []jsonSchema{
+			{"type": "integer", "minimum": 1},
+			{"const": RunCountAuto},
+			g.structSchema(reflect.TypeOf(runCountObject{})),
+		},
+	}
+}
+
+func (dl *Deadline) jsonSchema(g *schemaGenerator) jsonSchema {
+	return jsonSchema{
+		"oneOf": []jsonSchema{
+			{"type": "number", "exclusiveMinimum": 0},
+			{"type": "string", "format": "date"},
+		},
+	}
+}
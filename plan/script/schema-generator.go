@@ -0,0 +1,41 @@
+//go:build ignore
+
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/mweagle/goestimate/plan"
+)
+
+// //////////////////////////////////////////////////////////////////////////////
+//
+// _ __  __ _(_)_ _
+// | '  \/ _` | | ' \
+// |_|_|_\__,_|_|_||_|
+//
+// //////////////////////////////////////////////////////////////////////////////
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalf("Provide output file path as only command line argument")
+	}
+	outputFile, outputFileErr := filepath.Abs(os.Args[1])
+	if outputFileErr != nil {
+		log.Fatalf("Failed to get absolute output path. Error: %s", outputFileErr)
+	}
+	schemaBytes, schemaBytesErr := plan.JSONSchema()
+	if schemaBytesErr != nil {
+		log.Fatalf("Failed to generate plan schema. Error: %s", schemaBytesErr)
+	}
+	mkdirErr := os.MkdirAll(filepath.Dir(outputFile), os.ModePerm)
+	if mkdirErr != nil {
+		log.Fatalf("Failed to create output directory for: %s. Error: %s", outputFile, mkdirErr)
+	}
+	writeErr := os.WriteFile(outputFile, append(schemaBytes, '\n'), 0644)
+	if writeErr != nil {
+		log.Fatalf("Failed to create output file: %s. Error: %s", outputFile, writeErr)
+	}
+	log.Printf("Created output file: %s", outputFile)
+}
@@ -0,0 +1,116 @@
+package stats
+
+import (
+	"math"
+)
+
+// Accumulator summarizes a sample sequence without retaining it. The mean,
+// standard deviation and higher central moments are exact, computed online
+// with Welford's method, extended by Terriberry to the third and fourth
+// moments, and merged with the parallel updates of Chan and Pébay. The
+// median and percentiles are estimated by a TDigest, so they're within the
+// TDigest rank error bound.
+type Accumulator struct {
+	count  float64
+	mean   float64
+	m2     float64
+	m3     float64
+	m4     float64
+	digest *TDigest
+}
+
+// NewAccumulator returns an empty accumulator whose digest uses the
+// DEFAULT_COMPRESSION
+func NewAccumulator() *Accumulator {
+	return &Accumulator{
+		digest: NewTDigest(DEFAULT_COMPRESSION),
+	}
+}
+
+// Add adds the samples
+func (a *Accumulator) Add(samples ...float64) {
+	for _, eachSample := range samples {
+		previousCount := a.count
+		a.count++
+		delta := eachSample - a.mean
+		deltaCount := delta / a.count
+		term := delta * deltaCount * previousCount
+		a.m4 += term*deltaCount*deltaCount*(a.count*a.count-3*a.count+3) +
+			6*deltaCount*deltaCount*a.m2 -
+			4*deltaCount*a.m3
+		a.m3 += term*deltaCount*(a.count-2) - 3*deltaCount*a.m2
+		a.mean += delta / a.count
+		a.m2 += delta * (eachSample - a.mean)
+	}
+	a.digest.Add(samples...)
+}
+
+// Merge adds the samples summarized by the other accumulator
+func (a *Accumulator) Merge(other *Accumulator) {
+	if other.count <= 0 {
+		return
+	}
+	totalCount := a.count + other.count
+	delta := other.mean - a.mean
+	deltaSquared := delta * delta
+	a.m4 += other.m4 +
+		deltaSquared*deltaSquared*a.count*other.count*(a.count*a.count-a.count*other.count+other.count*other.count)/(totalCount*totalCount*totalCount) +
+		6*deltaSquared*(a.count*a.count*other.m2+other.count*other.count*a.m2)/(totalCount*totalCount) +
+		4*delta*(a.count*other.m3-other.count*a.m3)/totalCount
+	a.m3 += other.m3 +
+		deltaSquared*delta*a.count*other.count*(a.count-other.count)/(totalCount*totalCount) +
+		3*delta*(a.count*other.m2-other.count*a.m2)/totalCount
+	a.mean += delta * other.count / totalCount
+	a.m2 += other.m2 + delta*delta*a.count*other.count/totalCount
+	a.count = totalCount
+	a.digest.Merge(other.digest)
+}
+
+// Count returns the number of samples
+func (a *Accumulator) Count() float64 {
+	return a.count
+}
+
+// Mean returns the exact mean of the samples
+func (a *Accumulator) Mean() float64 {
+	if a.count <= 0 {
+		return math.NaN()
+	}
+	return a.mean
+}
+
+// StdDev returns the exact sample standard deviation of the samples
+func (a *Accumulator) StdDev() float64 {
+	if a.count <= 1 {
+		return math.NaN()
+	}
+	return math.Sqrt(a.m2 / (a.count - 1))
+}
+
+// Digest returns the quantile sketch of the samples
+func (a *Accumulator) Digest() *TDigest {
+	return a.digest
+}
+
+// Statistics returns the aggregated statistics of the samples, with the
+// median and percentiles estimated by the digest
+func (a *Accumulator) Statistics(percentiles []float64) *AggregatedStatistics {
+	aggStats := &AggregatedStatistics{
+		Mean:        a.Mean(),
+		Median:      a.digest.Quantile(0.5),
+		StdDev:      a.StdDev(),
+		Percentiles: make([]*PercentilePair, len(percentiles)),
+	}
+	for eachPercentileIndex := range percentiles {
+		percentileValue := percentiles[eachPercentileIndex]
+		if percentileValue > 1.00 {
+			percentileValue = percentileValue / 100
+		}
+		aggStats.Percentiles[eachPercentileIndex] = &PercentilePair{
+			P:   percentileValue,
+			Val: a.digest.Quantile(percentileValue),
+		}
+	}
+	a.setAccumulatorShape(aggStats)
+	return aggStats
+}
@@ -0,0 +1,201 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"golang.org/x/exp/rand"
+	gonumstat "gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Confidence intervals
+//
+// The mean and percentiles of a sample sequence are Monte Carlo estimates.
+// Their confidence intervals bound the error of the estimates:
+//
+//   - order: distribution free order statistic intervals. The rank of a
+//     percentile p of n samples is binomial, so the interval is the samples
+//     whose ranks are the normal approximation bounds np ± z·√(np(1-p)).
+//     The mean's interval is mean ± z·σ/√n.
+//   - bootstrap: percentile bootstrap intervals of BOOTSTRAP_RESAMPLES
+//     resamples of the sequence, drawn from a fixed seed so the intervals
+//     are repeatable. Resampling every task and join is slow, so only the
+//     plan's totals are bootstrapped and the nodes use order intervals.
+//   - bootstrap-all: bootstrap intervals for the nodes as well.
+//
+// Accumulators don't retain their samples, so their intervals are order
+// statistic intervals read from the digest and widened by its rank error
+// bound, for either method.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// Confidence interval methods
+const (
+	INTERVALS_ORDER         = "order"
+	INTERVALS_BOOTSTRAP     = "bootstrap"
+	INTERVALS_BOOTSTRAP_ALL = "bootstrap-all"
+)
+
+// INTERVAL_METHODS are the supported confidence interval methods
+var INTERVAL_METHODS = []string{
+	INTERVALS_ORDER,
+	INTERVALS_BOOTSTRAP,
+	INTERVALS_BOOTSTRAP_ALL,
+}
+
+// INTERVAL_CONFIDENCE is the confidence level of the intervals
+var INTERVAL_CONFIDENCE = 0.95
+
+// BOOTSTRAP_RESAMPLES is the number of resamples of bootstrap intervals
+var BOOTSTRAP_RESAMPLES = 200
+
+// BOOTSTRAP_SEED seeds the resamples of bootstrap intervals
+var BOOTSTRAP_SEED uint64 = 0x5EED
+
+// Interval is the confidence interval of an estimate
+type Interval struct {
+	Lower float64
+	Upper float64
+}
+
+// ValidateIntervalMethod returns an error if the method isn't supported
+func ValidateIntervalMethod(method string) error {
+	for _, eachMethod := range INTERVAL_METHODS {
+		if method == eachMethod {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid confidence interval method: %s. Expected one of: %v", method, INTERVAL_METHODS)
+}
+
+// NodeIntervalMethod returns the interval method of the statistics of each
+// task and join for the plan's method. Only bootstrap-all bootstraps them.
+func NodeIntervalMethod(method string) string {
+	switch method {
+	case INTERVALS_BOOTSTRAP:
+		return INTERVALS_ORDER
+	case INTERVALS_BOOTSTRAP_ALL:
+		return INTERVALS_BOOTSTRAP
+	}
+	return method
+}
+
+// intervalZ is the standard normal quantile of the two sided
+// INTERVAL_CONFIDENCE
+func intervalZ() float64 {
+	return distuv.UnitNormal.Quantile(0.5 + INTERVAL_CONFIDENCE/2)
+}
+
+// setOrderIntervals sets the order statistic intervals of the statistics of
+// the sorted samples
+func setOrderIntervals(aggStats *AggregatedStatistics, sortedSamples []float64) {
+	count := float64(len(sortedSamples))
+	z := intervalZ()
+	halfWidth := z * aggStats.StdDev / math.Sqrt(count)
+	aggStats.MeanInterval = &Interval{
+		Lower: aggStats.Mean - halfWidth,
+		Upper: aggStats.Mean + halfWidth,
+	}
+	lastIndex := len(sortedSamples) - 1
+	for _, eachPercentile := range aggStats.Percentiles {
+		rank := count * eachPercentile.P
+		rankHalfWidth := z * math.Sqrt(rank*(1-eachPercentile.P))
+		lowerIndex := int(math.Max(math.Floor(rank-rankHalfWidth)-1, 0))
+		upperIndex := int(math.Min(math.Ceil(rank+rankHalfWidth)-1, float64(lastIndex)))
+		eachPercentile.Interval = &Interval{
+			Lower: math.Min(sortedSamples[lowerIndex], eachPercentile.Val),
+			Upper: math.Max(sortedSamples[upperIndex], eachPercentile.Val),
+		}
+	}
+}
+
+// setBootstrapIntervals sets the percentile bootstrap intervals of the
+// statistics of the sorted samples. Each resample is represented by the
+// number of times each sorted sample was drawn, so the resample doesn't
+// need to be sorted.
+func setBootstrapIntervals(aggStats *AggregatedStatistics, sortedSamples []float64) {
+	rng := rand.New(rand.NewSource(BOOTSTRAP_SEED))
+	weights := make([]float64, len(sortedSamples))
+	meanEstimates := make([]float64, BOOTSTRAP_RESAMPLES)
+	percentileEstimates := make([][]float64, len(aggStats.Percentiles))
+	for i := range percentileEstimates {
+		percentileEstimates[i] = make([]float64, BOOTSTRAP_RESAMPLES)
+	}
+	for i := 0; i != BOOTSTRAP_RESAMPLES; i++ {
+		for j := range weights {
+			weights[j] = 0
+		}
+		for range sortedSamples {
+			weights[rng.Intn(len(sortedSamples))]++
+		}
+		meanEstimates[i] = gonumstat.Mean(sortedSamples, weights)
+		for j, eachPercentile := range aggStats.Percentiles {
+			percentileEstimates[j][i] = gonumstat.Quantile(eachPercentile.P,
+				gonumstat.Empirical,
+				sortedSamples,
+				weights)
+		}
+	}
+	aggStats.MeanInterval = bootstrapInterval(meanEstimates)
+	for i, eachPercentile := range aggStats.Percentiles {
+		eachPercentile.Interval = bootstrapInterval(percentileEstimates[i])
+	}
+}
+
+// bootstrapInterval returns the interval of the estimates' central
+// INTERVAL_CONFIDENCE quantiles
+func bootstrapInterval(estimates []float64) *Interval {
+	sort.Float64s(estimates)
+	return &Interval{
+		Lower: gonumstat.Quantile((1-INTERVAL_CONFIDENCE)/2, gonumstat.Empirical, estimates, nil),
+		Upper: gonumstat.Quantile(0.5+INTERVAL_CONFIDENCE/2, gonumstat.Empirical, estimates, nil),
+	}
+}
+
+// StatsWithIntervals returns the aggregated statistics of the samples and,
+// if the method isn't empty, the confidence intervals of the mean and
+// percentiles
+func StatsWithIntervals(unsortedSamples []float64, percentiles []float64, method string) *AggregatedStatistics {
+	sortedSamples := make([]float64, len(unsortedSamples))
+	copy(sortedSamples, unsortedSamples)
+	sort.Float64s(sortedSamples)
+	aggStats := statsForSortedSequence(sortedSamples, percentiles)
+	if len(sortedSamples) == 0 {
+		return aggStats
+	}
+	switch method {
+	case INTERVALS_ORDER:
+		setOrderIntervals(aggStats, sortedSamples)
+	case INTERVALS_BOOTSTRAP, INTERVALS_BOOTSTRAP_ALL:
+		setBootstrapIntervals(aggStats, sortedSamples)
+	}
+	return aggStats
+}
+
+// StatisticsWithIntervals returns the aggregated statistics of the samples
+// and, if the method isn't empty, the order statistic confidence intervals
+// of the mean and percentiles estimated by the digest
+func (a *Accumulator) StatisticsWithIntervals(percentiles []float64, method string) *AggregatedStatistics {
+	aggStats := a.Statistics(percentiles)
+	if len(method) == 0 || a.count <= 1 {
+		return aggStats
+	}
+	z := intervalZ()
+	halfWidth := z * aggStats.StdDev / math.Sqrt(a.count)
+	aggStats.MeanInterval = &Interval{
+		Lower: aggStats.Mean - halfWidth,
+		Upper: aggStats.Mean + halfWidth,
+	}
+	for _, eachPercentile := range aggStats.Percentiles {
+		p := eachPercentile.P
+		quantileHalfWidth := z*math.Sqrt(p*(1-p)/a.count) + a.digest.RankErrorBound(p)
+		eachPercentile.Interval = &Interval{
+			Lower: math.Min(a.digest.Quantile(math.Max(p-quantileHalfWidth, 0)), eachPercentile.Val),
+			Upper: math.Max(a.digest.Quantile(math.Min(p+quantileHalfWidth, 1)), eachPercentile.Val),
+		}
+	}
+	return aggStats
+}
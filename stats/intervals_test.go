@@ -0,0 +1,166 @@
+package stats
+
+import (
+	"math"
+	"testing"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// checkIntervals verifies that every estimate has an interval that contains
+// it
+func checkIntervals(t *testing.T, aggStats *AggregatedStatistics) {
+	t.Helper()
+	if aggStats.MeanInterval == nil {
+		t.Fatal("missing mean interval")
+	}
+	if aggStats.MeanInterval.Lower > aggStats.Mean || aggStats.MeanInterval.Upper < aggStats.Mean {
+		t.Errorf("mean interval %v doesn't contain the mean: %v", *aggStats.MeanInterval, aggStats.Mean)
+	}
+	for _, eachPercentile := range aggStats.Percentiles {
+		if eachPercentile.Interval == nil {
+			t.Fatalf("missing interval of p=%v", eachPercentile.P)
+		}
+		if eachPercentile.Interval.Lower > eachPercentile.Val || eachPercentile.Interval.Upper < eachPercentile.Val {
+			t.Errorf("interval %v of p=%v doesn't contain the estimate: %v",
+				*eachPercentile.Interval,
+				eachPercentile.P,
+				eachPercentile.Val)
+		}
+	}
+}
+
+// TestIntervalCoverage verifies that the intervals of the percentiles of
+// repeated samples of a known distribution contain the true percentile at
+// close to the INTERVAL_CONFIDENCE rate
+func TestIntervalCoverage(t *testing.T) {
+	percentiles := []float64{50, 90}
+	normal := distuv.Normal{Mu: 0, Sigma: 1, Src: rand.NewSource(3)}
+	trialCounts := map[string]int{
+		INTERVALS_ORDER:     1000,
+		INTERVALS_BOOTSTRAP: 200,
+	}
+	for _, eachMethod := range []string{INTERVALS_ORDER, INTERVALS_BOOTSTRAP} {
+		t.Run(eachMethod, func(t *testing.T) {
+			trials := trialCounts[eachMethod]
+			meanCovered := 0
+			percentileCovered := make([]int, len(percentiles))
+			samples := make([]float64, 1000)
+			for trial := 0; trial != trials; trial++ {
+				for i := range samples {
+					samples[i] = normal.Rand()
+				}
+				aggStats := StatsWithIntervals(samples, percentiles, eachMethod)
+				checkIntervals(t, aggStats)
+				if aggStats.MeanInterval.Lower <= 0 && aggStats.MeanInterval.Upper >= 0 {
+					meanCovered++
+				}
+				for i, eachPercentile := range aggStats.Percentiles {
+					trueValue := distuv.UnitNormal.Quantile(eachPercentile.P)
+					if eachPercentile.Interval.Lower <= trueValue && eachPercentile.Interval.Upper >= trueValue {
+						percentileCovered[i]++
+					}
+				}
+			}
+			// Allow for the binomial error of the coverage estimate and
+			// the approximations of each method
+			minCoverage := INTERVAL_CONFIDENCE - 4*math.Sqrt(INTERVAL_CONFIDENCE*(1-INTERVAL_CONFIDENCE)/float64(trials)) - 0.02
+			coverages := map[string]float64{
+				"mean": float64(meanCovered) / float64(trials),
+				"p50":  float64(percentileCovered[0]) / float64(trials),
+				"p90":  float64(percentileCovered[1]) / float64(trials),
+			}
+			for name, eachCoverage := range coverages {
+				if eachCoverage < minCoverage {
+					t.Errorf("invalid %s coverage: %v. Expected at least: %v", name, eachCoverage, minCoverage)
+				}
+			}
+		})
+	}
+}
+
+func TestBootstrapIntervalsRepeatable(t *testing.T) {
+	normal := distuv.Normal{Mu: 5, Sigma: 2, Src: rand.NewSource(5)}
+	samples := make([]float64, 5000)
+	for i := range samples {
+		samples[i] = normal.Rand()
+	}
+	first := StatsWithIntervals(samples, []float64{50, 95}, INTERVALS_BOOTSTRAP)
+	second := StatsWithIntervals(samples, []float64{50, 95}, INTERVALS_BOOTSTRAP)
+	if *first.MeanInterval != *second.MeanInterval {
+		t.Errorf("mean intervals differ: %v, %v", *first.MeanInterval, *second.MeanInterval)
+	}
+	for i := range first.Percentiles {
+		if *first.Percentiles[i].Interval != *second.Percentiles[i].Interval {
+			t.Errorf("p=%v intervals differ: %v, %v",
+				first.Percentiles[i].P,
+				*first.Percentiles[i].Interval,
+				*second.Percentiles[i].Interval)
+		}
+	}
+}
+
+// The order interval of the mean is the normal interval mean ± z·σ/√n
+func TestOrderMeanInterval(t *testing.T) {
+	samples := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	aggStats := StatsWithIntervals(samples, []float64{50}, INTERVALS_ORDER)
+	halfWidth := 1.959963984540054 * aggStats.StdDev / math.Sqrt(float64(len(samples)))
+	if math.Abs(aggStats.MeanInterval.Lower-(aggStats.Mean-halfWidth)) > 1e-9 ||
+		math.Abs(aggStats.MeanInterval.Upper-(aggStats.Mean+halfWidth)) > 1e-9 {
+		t.Errorf("invalid mean interval: %v. Expected: [%v, %v]",
+			*aggStats.MeanInterval,
+			aggStats.Mean-halfWidth,
+			aggStats.Mean+halfWidth)
+	}
+}
+
+func TestIntervalsWithoutMethod(t *testing.T) {
+	aggStats := StatsWithIntervals([]float64{1, 2, 3}, []float64{50}, "")
+	if aggStats.MeanInterval != nil || aggStats.Percentiles[0].Interval != nil {
+		t.Error("unexpected intervals without a method")
+	}
+}
+
+// Accumulator intervals are widened by the digest's rank error, so they
+// contain the order intervals of the retained samples' percentiles
+func TestAccumulatorIntervals(t *testing.T) {
+	normal := distuv.Normal{Mu: 0, Sigma: 1, Src: rand.NewSource(9)}
+	samples := make([]float64, 100000)
+	for i := range samples {
+		samples[i] = normal.Rand()
+	}
+	accumulator := NewAccumulator()
+	accumulator.Add(samples...)
+	for _, eachMethod := range INTERVAL_METHODS {
+		aggStats := accumulator.StatisticsWithIntervals([]float64{50, 95}, eachMethod)
+		checkIntervals(t, aggStats)
+		for _, eachPercentile := range aggStats.Percentiles {
+			trueValue := distuv.UnitNormal.Quantile(eachPercentile.P)
+			if eachPercentile.Interval.Lower > trueValue || eachPercentile.Interval.Upper < trueValue {
+				t.Errorf("%s interval %v of p=%v doesn't contain the true value: %v",
+					eachMethod,
+					*eachPercentile.Interval,
+					eachPercentile.P,
+					trueValue)
+			}
+		}
+	}
+}
+
+func TestNodeIntervalMethod(t *testing.T) {
+	expected := map[string]string{
+		"":                      "",
+		INTERVALS_ORDER:         INTERVALS_ORDER,
+		INTERVALS_BOOTSTRAP:     INTERVALS_ORDER,
+		INTERVALS_BOOTSTRAP_ALL: INTERVALS_BOOTSTRAP,
+	}
+	for method, eachNodeMethod := range expected {
+		if NodeIntervalMethod(method) != eachNodeMethod {
+			t.Errorf("invalid node interval method of %q. Expected: %q, Found: %q",
+				method,
+				eachNodeMethod,
+				NodeIntervalMethod(method))
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Shape and tail statistics
+//
+// The skewness and excess kurtosis are the moment coefficients
+// g1 = m3/m2^(3/2) and g2 = m4/m2² - 3 of the central moments. The mode is
+// the maximum of a Gaussian kernel density estimate with Silverman's
+// bandwidth, evaluated on a grid of KDE_GRID_POINTS linearly binned
+// points. The coefficient of variation is σ/μ and the conditional value at
+// risk is the mean of the samples at or above the CVAR_LEVEL quantile: the
+// average outcome of the worst 1-CVAR_LEVEL of the runs.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// Shape and tail statistic names
+const (
+	STATISTIC_SKEWNESS = "skewness"
+	STATISTIC_KURTOSIS = "kurtosis"
+	STATISTIC_MODE     = "mode"
+	STATISTIC_CV       = "cv"
+	STATISTIC_CVAR     = "cvar"
+)
+
+// STATISTICS are the names of the shape and tail statistics
+var STATISTICS = []string{
+	STATISTIC_SKEWNESS,
+	STATISTIC_KURTOSIS,
+	STATISTIC_MODE,
+	STATISTIC_CV,
+	STATISTIC_CVAR,
+}
+
+// CVAR_LEVEL is the quantile above which the conditional value at risk
+// averages the samples
+var CVAR_LEVEL = 0.9
+
+// KDE_GRID_POINTS is the number of grid points of the kernel density
+// estimate of the mode
+var KDE_GRID_POINTS = 512
+
+// ValidateStatistic returns an error if the name isn't a shape or tail
+// statistic
+func ValidateStatistic(name string) error {
+	for _, eachName := range STATISTICS {
+		if name == eachName {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid statistic: %s. Expected one of: %v", name, STATISTICS)
+}
+
+// setShape sets the shape statistics of the central moments m2, m3 and m4,
+// each divided by the count. Samples without variance have no skewness or
+// kurtosis.
+func setShape(aggStats *AggregatedStatistics, m2 float64, m3 float64, m4 float64) {
+	if m2 > 0 {
+		aggStats.Skewness = m3 / math.Pow(m2, 1.5)
+		aggStats.Kurtosis = m4/(m2*m2) - 3
+	}
+	if aggStats.Mean != 0 {
+		aggStats.CV = aggStats.StdDev / aggStats.Mean
+	}
+}
+
+// setSequenceShape sets the shape and tail statistics of the sorted samples
+func setSequenceShape(aggStats *AggregatedStatistics, sortedSamples []float64) {
+	count := float64(len(sortedSamples))
+	if count <= 0 {
+		return
+	}
+	m2, m3, m4 := float64(0), float64(0), float64(0)
+	for _, eachSample := range sortedSamples {
+		delta := eachSample - aggStats.Mean
+		deltaSquared := delta * delta
+		m2 += deltaSquared
+		m3 += deltaSquared * delta
+		m4 += deltaSquared * deltaSquared
+	}
+	setShape(aggStats, m2/count, m3/count, m4/count)
+
+	tailStart := min(int(math.Floor(CVAR_LEVEL*count)), len(sortedSamples)-1)
+	tailSum := float64(0)
+	for _, eachSample := range sortedSamples[tailStart:] {
+		tailSum += eachSample
+	}
+	aggStats.CVaR = tailSum / float64(len(sortedSamples)-tailStart)
+
+	lowerQuartile := sortedSamples[int(math.Floor(0.25*(count-1)))]
+	upperQuartile := sortedSamples[int(math.Floor(0.75*(count-1)))]
+	aggStats.Mode = kdeMode(sortedSamples,
+		nil,
+		sortedSamples[0],
+		sortedSamples[len(sortedSamples)-1],
+		aggStats.StdDev,
+		upperQuartile-lowerQuartile,
+		count)
+}
+
+// kdeMode returns the maximum of the Gaussian kernel density estimate of the
+// weighted values, which are between minValue and maxValue. Nil weights
+// weigh each value equally.
+func kdeMode(values []float64,
+	weights []float64,
+	minValue float64,
+	maxValue float64,
+	stdDev float64,
+	iqr float64,
+	count float64) float64 {
+
+	spread := stdDev
+	if iqr > 0 {
+		spread = math.Min(stdDev, iqr/1.34)
+	}
+	bandwidth := 0.9 * spread * math.Pow(count, -0.2)
+	if !(bandwidth > 0) || maxValue <= minValue {
+		return minValue
+	}
+	// Linearly bin the values onto the grid
+	gridStep := (maxValue - minValue) / float64(KDE_GRID_POINTS-1)
+	binned := make([]float64, KDE_GRID_POINTS)
+	for i, eachValue := range values {
+		weight := float64(1)
+		if weights != nil {
+			weight = weights[i]
+		}
+		position := (eachValue - minValue) / gridStep
+		lowerIndex := min(int(math.Floor(position)), KDE_GRID_POINTS-2)
+		fraction := position - float64(lowerIndex)
+		binned[lowerIndex] += weight * (1 - fraction)
+		binned[lowerIndex+1] += weight * fraction
+	}
+	// Convolve the bins with the kernel, truncated at four bandwidths
+	kernelSteps := min(int(math.Ceil(4*bandwidth/gridStep)), KDE_GRID_POINTS-1)
+	kernel := make([]float64, kernelSteps+1)
+	for i := range kernel {
+		scaled := float64(i) * gridStep / bandwidth
+		kernel[i] = math.Exp(-scaled * scaled / 2)
+	}
+	modeIndex := 0
+	modeDensity := math.Inf(-1)
+	for i := range binned {
+		density := float64(0)
+		for j := max(i-kernelSteps, 0); j <= min(i+kernelSteps, KDE_GRID_POINTS-1); j++ {
+			offset := i - j
+			if offset < 0 {
+				offset = -offset
+			}
+			density += binned[j] * kernel[offset]
+		}
+		if density > modeDensity {
+			modeIndex = i
+			modeDensity = density
+		}
+	}
+	return minValue + float64(modeIndex)*gridStep
+}
+
+// setAccumulatorShape sets the shape and tail statistics of the accumulated
+// samples. The moments are exact; the mode and conditional value at risk
+// are estimated from the digest.
+func (a *Accumulator) setAccumulatorShape(aggStats *AggregatedStatistics) {
+	if a.count <= 0 {
+		return
+	}
+	setShape(aggStats, a.m2/a.count, a.m3/a.count, a.m4/a.count)
+
+	// Average the tail quantiles at the midpoints of equal probability
+	// steps
+	const tailSteps = 100
+	tailSum := float64(0)
+	for i := 0; i != tailSteps; i++ {
+		tailSum += a.digest.Quantile(CVAR_LEVEL + (float64(i)+0.5)*(1-CVAR_LEVEL)/tailSteps)
+	}
+	aggStats.CVaR = tailSum / tailSteps
+
+	means, weights := a.digest.centroidValues()
+	aggStats.Mode = kdeMode(means,
+		weights,
+		a.digest.Quantile(0),
+		a.digest.Quantile(1),
+		aggStats.StdDev,
+		a.digest.Quantile(0.75)-a.digest.Quantile(0.25),
+		a.count)
+}
@@ -9,19 +9,32 @@ import (
 type PercentilePair struct {
 	P   float64
 	Val float64
+	// Interval is the confidence interval of Val, if computed
+	Interval *Interval
 }
 type AggregatedStatistics struct {
 	Mean        float64
 	Median      float64
 	StdDev      float64
 	Percentiles []*PercentilePair
+	// MeanInterval is the confidence interval of Mean, if computed
+	MeanInterval *Interval
+	// Skewness and Kurtosis (excess) describe the distribution's shape, Mode
+	// is its kernel density estimate maximum, CV is the coefficient of
+	// variation and CVaR is the mean of the worst 1-CVAR_LEVEL of the
+	// samples
+	Skewness float64
+	Kurtosis float64
+	Mode     float64
+	CV       float64
+	CVaR     float64
 }
 
 func StatsForSequence(unsortedSamples []float64, percentiles []float64) *AggregatedStatistics {
-	sortedSamples := make([]float64, len(unsortedSamples))
-	copy(sortedSamples, unsortedSamples)
-	sort.Float64s(sortedSamples)
+	return StatsWithIntervals(unsortedSamples, percentiles, "")
+}
 
+func statsForSortedSequence(sortedSamples []float64, percentiles []float64) *AggregatedStatistics {
 	// Compute aggregates...
 	mean, stddev := gonumstat.MeanStdDev(sortedSamples, nil)
 	median := gonumstat.Quantile(0.5, gonumstat.Empirical, sortedSamples, nil)
@@ -46,5 +59,72 @@ func StatsForSequence(unsortedSamples []float64, percentiles []float64) *Aggrega
 			Val: quantValue,
 		}
 	}
+	setSequenceShape(aggStats, sortedSamples)
 	return aggStats
 }
+
+// Ranks returns the 1-based fractional ranks of the samples. Tied samples
+// share the average of their ranks.
+func Ranks(samples []float64) []float64 {
+	sortedIndices := make([]int, len(samples))
+	for i := range sortedIndices {
+		sortedIndices[i] = i
+	}
+	sort.SliceStable(sortedIndices, func(i, j int) bool {
+		return samples[sortedIndices[i]] < samples[sortedIndices[j]]
+	})
+	ranks := make([]float64, len(samples))
+	for i := 0; i < len(sortedIndices); {
+		j := i
+		for j+1 < len(sortedIndices) && samples[sortedIndices[j+1]] == samples[sortedIndices[i]] {
+			j++
+		}
+		averageRank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			ranks[sortedIndices[k]] = averageRank
+		}
+		i = j + 1
+	}
+	return ranks
+}
+
+// SpearmanCorrelation returns the rank correlation coefficient of
+// two equal length sample sequences
+func SpearmanCorrelation(x []float64, y []float64) float64 {
+	return gonumstat.Correlation(Ranks(x), Ranks(y), nil)
+}
+
+// FirstOrderSobolIndex estimates the first-order Sobol index of x, the
+// fraction of the variance of y explained by x, from the samples alone.
+// The runs are sorted by x into equal count bins and the index is the
+// variance of the bins' mean y, Var(E[y|x]), over Var(y).
+func FirstOrderSobolIndex(x []float64, y []float64, binCount int) float64 {
+	count := len(y)
+	if count <= 1 || binCount <= 1 {
+		return 0
+	}
+	sortedIndices := make([]int, count)
+	for i := range sortedIndices {
+		sortedIndices[i] = i
+	}
+	sort.SliceStable(sortedIndices, func(i, j int) bool {
+		return x[sortedIndices[i]] < x[sortedIndices[j]]
+	})
+	mean, variance := gonumstat.MeanVariance(y, nil)
+	if !(variance > 0) {
+		return 0
+	}
+	binCount = min(binCount, count)
+	betweenSum := float64(0)
+	for bin := 0; bin != binCount; bin++ {
+		binStart := bin * count / binCount
+		binEnd := (bin + 1) * count / binCount
+		binSum := float64(0)
+		for _, eachIndex := range sortedIndices[binStart:binEnd] {
+			binSum += y[eachIndex]
+		}
+		binDelta := binSum/float64(binEnd-binStart) - mean
+		betweenSum += float64(binEnd-binStart) * binDelta * binDelta
+	}
+	return betweenSum / float64(count-1) / variance
+}
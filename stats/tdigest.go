@@ -0,0 +1,233 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// TDigest
+//
+// A merging t-digest (Dunning & Ertl, "Computing Extremely Accurate
+// Quantiles Using t-Digests") is a mergeable quantile sketch. Samples are
+// clustered into weighted centroids, sorted by mean, whose size is limited by
+// the k1 scale function:
+//
+//	k(q) = δ/(2π)·asin(2q-1)
+//
+// Each centroid spans at most one unit of k, so a centroid at quantile q
+// holds at most a 2π·√(q(1-q))/δ fraction of the samples. Quantiles are
+// interpolated within a centroid, so the rank of an estimated quantile is
+// within that fraction of q:
+//
+//	|rank error| <= 2π·√(q(1-q))/δ
+//
+// With the DEFAULT_COMPRESSION δ = 1000 the rank error is at most 0.31% at
+// the median, 0.14% at p95 and 0.063% at p99. Centroids are smaller in the
+// tails, so tail percentiles are the most accurate. The minimum and maximum
+// are exact.
+//
+// /////////////////////////////////////////////////////////////////////////////
+
+// DEFAULT_COMPRESSION is the t-digest compression δ
+var DEFAULT_COMPRESSION float64 = 1000
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// TDigest is a mergeable quantile sketch
+type TDigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       float64
+	min         float64
+	max         float64
+}
+
+// NewTDigest returns an empty t-digest with the compression δ
+func NewTDigest(compression float64) *TDigest {
+	return &TDigest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+// RankErrorBound returns the maximum rank error of the estimate of the
+// quantile q
+func (td *TDigest) RankErrorBound(q float64) float64 {
+	return 2 * math.Pi * math.Sqrt(q*(1-q)) / td.compression
+}
+
+// Count returns the number of samples
+func (td *TDigest) Count() float64 {
+	return td.count + td.bufferWeight()
+}
+
+func (td *TDigest) bufferWeight() float64 {
+	weight := float64(0)
+	for _, eachCentroid := range td.buffer {
+		weight += eachCentroid.weight
+	}
+	return weight
+}
+
+// Add adds the samples
+func (td *TDigest) Add(samples ...float64) {
+	for _, eachSample := range samples {
+		td.addCentroid(centroid{mean: eachSample, weight: 1})
+	}
+}
+
+// Merge adds the samples summarized by the other digest
+func (td *TDigest) Merge(other *TDigest) {
+	other.compress()
+	for _, eachCentroid := range other.centroids {
+		td.addCentroid(eachCentroid)
+	}
+	td.min = math.Min(td.min, other.min)
+	td.max = math.Max(td.max, other.max)
+}
+
+func (td *TDigest) addCentroid(c centroid) {
+	td.buffer = append(td.buffer, c)
+	td.min = math.Min(td.min, c.mean)
+	td.max = math.Max(td.max, c.mean)
+	if len(td.buffer) >= int(10*td.compression) {
+		td.compress()
+	}
+}
+
+// scaleK is the k1 scale function
+func (td *TDigest) scaleK(q float64) float64 {
+	return td.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+// scaleQ is the inverse of the k1 scale function
+func (td *TDigest) scaleQ(k float64) float64 {
+	if k >= td.compression/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/td.compression) + 1) / 2
+}
+
+// compress merges the buffered samples into the centroids
+func (td *TDigest) compress() {
+	if len(td.buffer) <= 0 {
+		return
+	}
+	merged := append(td.centroids, td.buffer...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].mean < merged[j].mean
+	})
+	td.count += td.bufferWeight()
+	td.buffer = nil
+
+	compressed := make([]centroid, 0, len(td.centroids)+1)
+	current := merged[0]
+	weightSoFar := float64(0)
+	qLimit := td.scaleQ(td.scaleK(0) + 1)
+	for _, eachCentroid := range merged[1:] {
+		proposedWeight := current.weight + eachCentroid.weight
+		if (weightSoFar+proposedWeight)/td.count <= qLimit {
+			current.mean += (eachCentroid.mean - current.mean) * eachCentroid.weight / proposedWeight
+			current.weight = proposedWeight
+			continue
+		}
+		weightSoFar += current.weight
+		compressed = append(compressed, current)
+		qLimit = td.scaleQ(td.scaleK(weightSoFar/td.count) + 1)
+		current = eachCentroid
+	}
+	td.centroids = append(compressed, current)
+}
+
+// centroidValues returns the mean and weight of each centroid
+func (td *TDigest) centroidValues() ([]float64, []float64) {
+	td.compress()
+	means := make([]float64, len(td.centroids))
+	weights := make([]float64, len(td.centroids))
+	for i, eachCentroid := range td.centroids {
+		means[i] = eachCentroid.mean
+		weights[i] = eachCentroid.weight
+	}
+	return means, weights
+}
+
+// Quantile returns the estimated value at the quantile q, 0 <= q <= 1
+func (td *TDigest) Quantile(q float64) float64 {
+	td.compress()
+	centroidCount := len(td.centroids)
+	if centroidCount <= 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return td.min
+	}
+	if q >= 1 {
+		return td.max
+	}
+	if centroidCount == 1 {
+		return td.centroids[0].mean
+	}
+	// Centroid means are at the midpoint of their weight. The tails are
+	// interpolated to the exact minimum and maximum.
+	index := q * td.count
+	first := td.centroids[0]
+	if index < first.weight/2 {
+		return td.min + (first.mean-td.min)*index/(first.weight/2)
+	}
+	weightSoFar := first.weight / 2
+	for i := 0; i != centroidCount-1; i++ {
+		left := td.centroids[i]
+		right := td.centroids[i+1]
+		deltaWeight := (left.weight + right.weight) / 2
+		if weightSoFar+deltaWeight > index {
+			fraction := (index - weightSoFar) / deltaWeight
+			return left.mean + (right.mean-left.mean)*fraction
+		}
+		weightSoFar += deltaWeight
+	}
+	last := td.centroids[centroidCount-1]
+	fraction := math.Min(1, (index-weightSoFar)/(last.weight/2))
+	return last.mean + (td.max-last.mean)*fraction
+}
+
+// CDF returns the estimated fraction of samples less than or equal to x
+func (td *TDigest) CDF(x float64) float64 {
+	td.compress()
+	centroidCount := len(td.centroids)
+	if centroidCount <= 0 {
+		return math.NaN()
+	}
+	if x < td.min {
+		return 0
+	}
+	if x >= td.max {
+		return 1
+	}
+	first := td.centroids[0]
+	if x < first.mean {
+		if first.mean == td.min {
+			return 0
+		}
+		return (x - td.min) / (first.mean - td.min) * first.weight / 2 / td.count
+	}
+	weightSoFar := first.weight / 2
+	for i := 0; i != centroidCount-1; i++ {
+		left := td.centroids[i]
+		right := td.centroids[i+1]
+		deltaWeight := (left.weight + right.weight) / 2
+		if x < right.mean {
+			fraction := (x - left.mean) / (right.mean - left.mean)
+			return (weightSoFar + deltaWeight*fraction) / td.count
+		}
+		weightSoFar += deltaWeight
+	}
+	last := td.centroids[centroidCount-1]
+	fraction := (x - last.mean) / (td.max - last.mean)
+	return (weightSoFar + last.weight/2*fraction) / td.count
+}
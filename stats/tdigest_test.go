@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+var digestQuantiles = []float64{0.001, 0.01, 0.05, 0.25, 0.5, 0.75, 0.95, 0.99, 0.999}
+
+// checkRankError verifies that the rank of each estimated quantile is
+// within the digest's rank error bound of the quantile
+func checkRankError(t *testing.T, digest *TDigest, sortedSamples []float64) {
+	t.Helper()
+	count := float64(len(sortedSamples))
+	for _, eachQ := range digestQuantiles {
+		estimate := digest.Quantile(eachQ)
+		// The estimate's rank is anywhere between the samples less than it
+		// and the samples less than or equal to it
+		lowerRank := float64(sort.SearchFloat64s(sortedSamples, estimate)) / count
+		upperRank := float64(sort.Search(len(sortedSamples), func(i int) bool {
+			return sortedSamples[i] > estimate
+		})) / count
+		bound := digest.RankErrorBound(eachQ) + 1/count
+		if lowerRank > eachQ+bound || upperRank < eachQ-bound {
+			t.Errorf("rank error of q=%v exceeds the bound: %v. Estimate: %v, rank: [%v, %v]",
+				eachQ,
+				bound,
+				estimate,
+				lowerRank,
+				upperRank)
+		}
+	}
+	if digest.Quantile(0) != sortedSamples[0] || digest.Quantile(1) != sortedSamples[len(sortedSamples)-1] {
+		t.Errorf("invalid extremes. Expected: [%v, %v], Found: [%v, %v]",
+			sortedSamples[0],
+			sortedSamples[len(sortedSamples)-1],
+			digest.Quantile(0),
+			digest.Quantile(1))
+	}
+}
+
+func TestTDigestRankErrorBound(t *testing.T) {
+	src := rand.NewSource(7)
+	distributions := map[string]distuv.Rander{
+		"uniform":   distuv.Uniform{Min: 0, Max: 1, Src: src},
+		"normal":    distuv.Normal{Mu: 10, Sigma: 2, Src: src},
+		"lognormal": distuv.LogNormal{Mu: 0, Sigma: 1.5, Src: src},
+		"pareto":    distuv.Pareto{Xm: 1, Alpha: 1.5, Src: src},
+	}
+	for name, eachDistribution := range distributions {
+		t.Run(name, func(t *testing.T) {
+			samples := make([]float64, 200000)
+			for i := range samples {
+				samples[i] = eachDistribution.Rand()
+			}
+			digest := NewTDigest(DEFAULT_COMPRESSION)
+			digest.Add(samples...)
+			sortedSamples := append([]float64(nil), samples...)
+			sort.Float64s(sortedSamples)
+			checkRankError(t, digest, sortedSamples)
+		})
+	}
+}
+
+// Merged digests, as built by the chunks of streaming evaluations, have the
+// same error bound
+func TestTDigestMergedRankErrorBound(t *testing.T) {
+	normal := distuv.Normal{Mu: 0, Sigma: 1, Src: rand.NewSource(11)}
+	merged := NewTDigest(DEFAULT_COMPRESSION)
+	samples := make([]float64, 0, 200000)
+	for chunk := 0; chunk != 20; chunk++ {
+		chunkDigest := NewTDigest(DEFAULT_COMPRESSION)
+		for i := 0; i != 10000; i++ {
+			eachSample := normal.Rand()
+			chunkDigest.Add(eachSample)
+			samples = append(samples, eachSample)
+		}
+		merged.Merge(chunkDigest)
+	}
+	if merged.Count() != float64(len(samples)) {
+		t.Fatalf("invalid merged count. Expected: %d, Found: %v", len(samples), merged.Count())
+	}
+	sort.Float64s(samples)
+	checkRankError(t, merged, samples)
+}
+
+func TestTDigestRankErrorBoundValues(t *testing.T) {
+	digest := NewTDigest(DEFAULT_COMPRESSION)
+	expected := map[float64]float64{
+		0.5:  0.0031,
+		0.9:  0.0019,
+		0.95: 0.0014,
+		0.99: 0.00063,
+	}
+	for q, eachBound := range expected {
+		if math.Abs(digest.RankErrorBound(q)-eachBound) > 0.00005 {
+			t.Errorf("invalid rank error bound of q=%v. Expected: %v, Found: %v", q, eachBound, digest.RankErrorBound(q))
+		}
+	}
+}